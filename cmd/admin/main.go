@@ -0,0 +1,281 @@
+// Command admin runs maintenance tasks against the configured storage backend.
+// It reads the same environment (and .env file) as the server. With the file
+// backend, stop the server first: it keeps its data in memory and would
+// overwrite changes made here.
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/yourname/sleeptracker/internal"
+	"github.com/yourname/sleeptracker/internal/backup"
+	"github.com/yourname/sleeptracker/internal/config"
+	"github.com/yourname/sleeptracker/internal/importer"
+	"github.com/yourname/sleeptracker/internal/service"
+	"github.com/yourname/sleeptracker/internal/storage"
+	"go.uber.org/zap"
+)
+
+type command struct {
+	summary string
+	run     func(args []string, cfg *config.Config, logger internal.Logger) error
+}
+
+var commands = map[string]command{
+	"rebuild-aggregates": {
+		summary: "regenerate daily sleep aggregates from raw sleep logs",
+		run:     rebuildAggregates,
+	},
+	"snapshot": {
+		summary: "take a snapshot of the file storage data files",
+		run:     snapshot,
+	},
+	"import": {
+		summary: "import sleep exported by another app for a user",
+		run:     importSource,
+	},
+	"restore": {
+		summary: "validate a snapshot and swap it in place of the file storage data",
+		run:     restore,
+	},
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", os.Args[1])
+		usage()
+		os.Exit(2)
+	}
+
+	cfg := config.Load()
+	zapLogger, err := zap.NewDevelopment()
+	if err != nil {
+		panic("failed to initialize logger: " + err.Error())
+	}
+	defer zapLogger.Sync()
+	logger := internal.NewZapLogger(zapLogger.Sugar())
+
+	if err := cmd.run(os.Args[2:], cfg, logger); err != nil {
+		logger.Errorf("%s: %v", os.Args[1], err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: admin <command> [flags]")
+	fmt.Fprintln(os.Stderr, "\ncommands:")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-20s %s\n", name, commands[name].summary)
+	}
+}
+
+func rebuildAggregates(args []string, cfg *config.Config, logger internal.Logger) error {
+	repos, err := storage.Open(cfg, logger)
+	if err != nil {
+		return err
+	}
+	if err := repos.Aggregates.RebuildDailyAggregates(context.Background()); err != nil {
+		repos.Close()
+		return err
+	}
+	if err := repos.Close(); err != nil {
+		return err
+	}
+	logger.Infof("rebuilt daily aggregates for %s storage", cfg.DBType)
+	return nil
+}
+
+func snapshot(args []string, cfg *config.Config, logger internal.Logger) error {
+	repos, err := storage.Open(cfg, logger)
+	if err != nil {
+		return err
+	}
+	defer repos.Close()
+	if repos.Files == nil {
+		return errors.New("snapshots require STORAGE_BACKEND=file")
+	}
+	snap, err := backup.NewSnapshotter(repos.Files, backup.Config{
+		Dir:        cfg.BackupDir,
+		KeepHourly: cfg.BackupKeepHourly,
+		KeepDaily:  cfg.BackupKeepDaily,
+	}, logger).Snapshot()
+	if err != nil {
+		return err
+	}
+	fmt.Println(snap.Path)
+	return nil
+}
+
+func restore(args []string, cfg *config.Config, logger internal.Logger) error {
+	flags := flag.NewFlagSet("restore", flag.ExitOnError)
+	path := flags.String("snapshot", "", "snapshot file to restore; defaults to the newest one in BACKUP_DIR")
+	flags.Parse(args)
+
+	if cfg.DBType != "file" {
+		return errors.New("restore requires STORAGE_BACKEND=file")
+	}
+	if *path == "" {
+		snapshots, err := backup.List(cfg.BackupDir)
+		if err != nil {
+			return err
+		}
+		if len(snapshots) == 0 {
+			return fmt.Errorf("no snapshots in %s", cfg.BackupDir)
+		}
+		*path = snapshots[0].Path
+	}
+
+	targets := storage.FileDataFiles(cfg.FileSleep, cfg.FileGoals)
+
+	// A snapshot is valid if file storage can load it from the staging
+	// directory; closing it also derives any collection the snapshot lacks.
+	validate := func(dir string) error {
+		staged, err := storage.NewFileStorage(
+			filepath.Join(dir, filepath.Base(cfg.FileSleep)),
+			filepath.Join(dir, filepath.Base(cfg.FileGoals)),
+			logger)
+		if err != nil {
+			return err
+		}
+		return staged.Close()
+	}
+	if err := backup.Restore(*path, targets, validate); err != nil {
+		return err
+	}
+	logger.Infof("restored %s; previous files were kept with a .pre-restore suffix", *path)
+	return nil
+}
+
+func importSource(args []string, cfg *config.Config, logger internal.Logger) error {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	source := flags.String("source", "", "app the export is from: "+strings.Join(importer.Names(), ", "))
+	email := flags.String("email", "", "email of the user to import for")
+	path := flags.String("file", "", "exported file, or a zip or directory holding the export")
+	tz := flags.String("tz", "", "time zone of times the export gives without an offset; defaults to UTC")
+	dryRun := flags.Bool("dry-run", false, "report what would be imported without saving")
+	flags.Parse(args)
+	if *source == "" || *email == "" || *path == "" {
+		return errors.New("-source, -email and -file are required")
+	}
+	src, err := service.ImportSource(*source)
+	if err != nil {
+		return fmt.Errorf("%s: %w", *source, err)
+	}
+	opts := &service.ImportOptions{DryRun: *dryRun, TZ: *tz}
+	loc, err := service.ValidateImportOptions(opts)
+	if err != nil {
+		return err
+	}
+
+	logs, err := readExport(src, *path, loc)
+	if err != nil {
+		return err
+	}
+	rows, err := service.SourceRows(logs)
+	if err != nil {
+		return err
+	}
+
+	repos, err := storage.Open(cfg, logger)
+	if err != nil {
+		return err
+	}
+	defer repos.Close()
+	ctx := context.Background()
+	user, err := service.UserByEmail(ctx, repos.Users, *email)
+	if err != nil {
+		return fmt.Errorf("user %s: %w", *email, err)
+	}
+	result, err := service.ImportSleepLogs(ctx, repos.Sleep, user, rows, opts)
+	if err != nil {
+		return err
+	}
+	out := json.NewEncoder(os.Stdout)
+	out.SetIndent("", "  ")
+	return out.Encode(result)
+}
+
+// readExport parses path, a file src reads or a zip or directory holding
+// them, such as the zip of a Google Takeout export.
+func readExport(src importer.Source, path string, loc *time.Location) ([]internal.SleepLog, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	parse := func(name string, open func() (io.ReadCloser, error)) ([]internal.SleepLog, error) {
+		f, err := open()
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		logs, err := src.Parse(f, loc)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		return logs, nil
+	}
+
+	var logs []internal.SleepLog
+	read := 0
+	switch {
+	case info.IsDir():
+		err = filepath.WalkDir(path, func(name string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() || !src.Match(filepath.ToSlash(name)) {
+				return err
+			}
+			read++
+			more, err := parse(name, func() (io.ReadCloser, error) { return os.Open(name) })
+			logs = append(logs, more...)
+			return err
+		})
+	case strings.EqualFold(filepath.Ext(path), ".zip"):
+		var zr *zip.ReadCloser
+		if zr, err = zip.OpenReader(path); err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		for _, f := range zr.File {
+			if !src.Match(f.Name) {
+				continue
+			}
+			read++
+			more, err := parse(f.Name, f.Open)
+			if err != nil {
+				return nil, err
+			}
+			logs = append(logs, more...)
+		}
+	default:
+		read++
+		logs, err = parse(path, func() (io.ReadCloser, error) { return os.Open(path) })
+	}
+	if err != nil {
+		return nil, err
+	}
+	if read == 0 {
+		return nil, fmt.Errorf("%s holds no %s export files", path, src.Name())
+	}
+	return logs, nil
+}
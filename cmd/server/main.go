@@ -1,6 +1,8 @@
 package main
 
 import (
+	"context"
+	"expvar"
 	"os/exec"
 	"runtime"
 	"time"
@@ -11,7 +13,12 @@ import (
 	"github.com/yourname/sleeptracker/internal"
 	api "github.com/yourname/sleeptracker/internal/api"
 	"github.com/yourname/sleeptracker/internal/auth"
+	"github.com/yourname/sleeptracker/internal/backup"
 	"github.com/yourname/sleeptracker/internal/config"
+	"github.com/yourname/sleeptracker/internal/idempotency"
+	"github.com/yourname/sleeptracker/internal/openapi"
+	"github.com/yourname/sleeptracker/internal/ratelimit"
+	"github.com/yourname/sleeptracker/internal/service"
 	"github.com/yourname/sleeptracker/internal/storage"
 	"go.uber.org/zap"
 )
@@ -22,11 +29,74 @@ type App struct {
 	logger    internal.Logger
 	sleepRepo storage.SleepLogRepository
 	goalRepo  storage.GoalRepository
+	aggRepo   storage.AggregateRepository
+	userRepo  storage.UserRepository
+	sessions  storage.SessionRepository
+	apiKeys   storage.APIKeyRepository
+	sharing   storage.SharingRepository
+	audit     storage.AuditRepository
+	snapshots *backup.Snapshotter
+	health    storage.HealthChecker
+	routes    api.RouteConfig
 }
 
-func (a *App) Logger() internal.Logger               { return a.logger }
-func (a *App) SleepRepo() storage.SleepLogRepository { return a.sleepRepo }
-func (a *App) GoalRepo() storage.GoalRepository      { return a.goalRepo }
+func (a *App) Logger() internal.Logger                    { return a.logger }
+func (a *App) SleepRepo() storage.SleepLogRepository      { return a.sleepRepo }
+func (a *App) GoalRepo() storage.GoalRepository           { return a.goalRepo }
+func (a *App) AggregateRepo() storage.AggregateRepository { return a.aggRepo }
+func (a *App) UserRepo() storage.UserRepository           { return a.userRepo }
+func (a *App) SessionRepo() storage.SessionRepository     { return a.sessions }
+func (a *App) APIKeyRepo() storage.APIKeyRepository       { return a.apiKeys }
+func (a *App) SharingRepo() storage.SharingRepository     { return a.sharing }
+func (a *App) AuditRepo() storage.AuditRepository         { return a.audit }
+func (a *App) Snapshotter() *backup.Snapshotter           { return a.snapshots }
+func (a *App) StorageHealth() storage.HealthChecker       { return a.health }
+func (a *App) RouteConfig() api.RouteConfig               { return a.routes }
+
+// newAuthProvider chains the providers named in AUTH_MODE, in order.
+func newAuthProvider(cfg *config.Config, repos *storage.Repositories, logger internal.Logger) (auth.Provider, error) {
+	var providers []auth.Provider
+	for _, mode := range cfg.AuthModes {
+		switch mode {
+		case config.AuthLocal:
+			providers = append(providers, auth.NewTokenAuthProvider(repos.Users, repos.Sessions, logger))
+			if cfg.AuthDevToken != "" {
+				providers = append(providers, auth.NewLocalAuthProvider(cfg.AuthDevToken, logger))
+			}
+		case config.AuthAPIKey:
+			providers = append(providers, auth.NewAPIKeyAuthProvider(repos.APIKeys, repos.Users, logger))
+		case config.AuthJWT:
+			jwtProvider, err := auth.NewJWTAuthProvider(auth.JWTConfig{
+				Secret:      cfg.JWTSecret,
+				JWKSFile:    cfg.JWTJWKSFile,
+				JWKSURL:     cfg.JWTJWKSURL,
+				JWKSRefresh: cfg.JWTJWKSRefresh,
+				Issuer:      cfg.JWTIssuer,
+				Audience:    cfg.JWTAudience,
+				Leeway:      cfg.JWTLeeway,
+				RoleClaim:   cfg.JWTRoleClaim,
+			}, logger)
+			if err != nil {
+				return nil, err
+			}
+			providers = append(providers, jwtProvider)
+		case config.AuthRemote:
+			remote := auth.NewRemoteAuthProvider(auth.RemoteConfig{
+				URL:              cfg.AuthServiceURL,
+				Timeout:          cfg.AuthTimeout,
+				CacheTTL:         cfg.AuthCacheTTL,
+				NegativeCacheTTL: cfg.AuthNegativeCacheTTL,
+				Retries:          cfg.AuthRetries,
+				BreakerThreshold: cfg.AuthBreakerThreshold,
+				BreakerCooldown:  cfg.AuthBreakerCooldown,
+			}, logger)
+			expvar.Publish("auth_remote", expvar.Func(func() any { return remote.Stats() }))
+			providers = append(providers, remote)
+		}
+	}
+	logger.Infof("auth providers: %v", cfg.AuthModes)
+	return auth.NewChainProvider(providers...), nil
+}
 
 func main() {
 	cfg := config.Load()
@@ -42,62 +112,81 @@ func main() {
 	defer zapLogger.Sync()
 	logger := internal.NewZapLogger(sugar)
 
-	var (
-		sleepRepo storage.SleepLogRepository
-		goalRepo  storage.GoalRepository
-	)
+	repos, err := storage.Open(cfg, logger)
+	if err != nil {
+		logger.Fatalf("failed to initialize %s repositories: %v", cfg.DBType, err)
+	}
+	sleepRepo := repos.Sleep
 
-	switch cfg.DBType {
-	case "file":
-		sleepRepo, goalRepo, err = storage.NewFileRepositories(cfg.FileSleep, cfg.FileGoals, logger)
-		if err != nil {
-			logger.Fatalf("failed to initialize repositories: %v", err)
-		}
-	case "postgres":
-		if cfg.DBDSN == "" {
-			logger.Fatalf("POSTGRES_DSN env var required for postgres backend")
-		}
-		// Make sure to run migrations/001_init.sql before starting the app
-		sleepRepo, goalRepo, err = storage.NewPostgresRepositories(cfg.DBDSN, logger)
-		if err != nil {
-			logger.Fatalf("failed to initialize postgres repositories: %v", err)
-		}
-	default:
-		logger.Fatalf("unsupported STORAGE_BACKEND: %s", cfg.DBType)
+	if cfg.CacheSize > 0 {
+		cached := storage.NewCachedSleepLogRepository(sleepRepo, cfg.CacheSize, cfg.CacheTTL)
+		expvar.Publish("storage_cache", expvar.Func(func() any { return cached.CacheStats() }))
+		sleepRepo = cached
 	}
 
 	app := &App{
 		Config:    cfg,
 		logger:    logger,
 		sleepRepo: sleepRepo,
-		goalRepo:  goalRepo,
+		goalRepo:  repos.Goals,
+		aggRepo:   repos.Aggregates,
+		userRepo:  repos.Users,
+		sessions:  repos.Sessions,
+		apiKeys:   repos.APIKeys,
+		sharing:   repos.Sharing,
+		audit:     repos.Audit,
+		health:    repos.Health,
+	}
+
+	if err := service.BootstrapAdmins(context.Background(), repos.Users, cfg.AdminEmails, logger); err != nil {
+		logger.Fatalf("failed to grant admin roles: %v", err)
+	}
+
+	if repos.Files != nil {
+		app.snapshots = backup.NewSnapshotter(repos.Files, backup.Config{
+			Dir:        cfg.BackupDir,
+			KeepHourly: cfg.BackupKeepHourly,
+			KeepDaily:  cfg.BackupKeepDaily,
+		}, logger)
+		if cfg.BackupInterval > 0 {
+			app.snapshots.Start(cfg.BackupInterval)
+		}
 	}
 
 	r := gin.Default()
+	if err := r.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		logger.Fatalf("invalid TRUSTED_PROXIES: %v", err)
+	}
 
 	r.Use(api.RequestIDMiddleware())
 	// Serve the OpenAPI spec locally
 	r.GET("/swagger.yaml", func(c *gin.Context) {
-		c.File("swagger.yaml")
+		c.Data(http.StatusOK, "application/yaml", openapi.Spec)
 	})
 
 	// Serve local Swagger UI static files
 	r.Static("/swagger", "./swagger-ui")
 
-	// Protected routes
-	var authProvider auth.Provider
-	if cfg.Env == "development" {
-		authProvider = auth.NewLocalAuthProvider("MOCK-TOKEN", logger)
-	} else {
-		authProvider = auth.NewRemoteAuthProvider(cfg.DBDSN, logger)
+	authProvider, err := newAuthProvider(cfg, repos, logger)
+	if err != nil {
+		logger.Fatalf("failed to initialize auth: %v", err)
+	}
+	spec, err := openapi.NewValidator(logger)
+	if err != nil {
+		logger.Fatalf("failed to load the OpenAPI spec: %v", err)
+	}
+	app.routes = api.RouteConfig{
+		TokenPolicy:      service.TokenPolicy{AccessTTL: cfg.AccessTokenTTL, RefreshTTL: cfg.RefreshTokenTTL},
+		Auth:             authProvider,
+		OpenAPI:          spec,
+		Limiter:          ratelimit.NewMemoryLimiter(),
+		AuthLimit:        cfg.RateLimitAuth,
+		AuthFailureLimit: cfg.RateLimitAuthFailures,
+		APILimit:         cfg.RateLimitAPI,
+		WriteLimit:       cfg.RateLimitWrite,
+		Idempotency:      idempotency.NewMemoryStore(cfg.IdempotencyTTL),
 	}
-	r.Use(auth.AuthMiddleware(authProvider, cfg))
-	r.POST("/sleep", api.PostSleep(app))
-	r.GET("/sleep", api.GetSleep(app))
-	r.GET("/sleep/stats", api.GetSleepStats(app))
-	r.GET("/sleep/recommendations", api.GetSleepRecommendations(app))
-	r.POST("/api/goals", api.PostGoal(app))
-	r.GET("/api/goals/progress", api.GetGoalProgress(app))
+	api.RegisterRoutes(r, app)
 
 	go func() {
 		app.Logger().Infof("Server running on :8088")
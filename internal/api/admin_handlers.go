@@ -0,0 +1,133 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yourname/sleeptracker/internal"
+	"github.com/yourname/sleeptracker/internal/response"
+	"github.com/yourname/sleeptracker/internal/service"
+)
+
+var (
+	errSnapshotsUnsupported = internal.NewAppError(http.StatusNotImplemented, "snapshots require the file storage backend")
+	errHealthUnsupported    = internal.NewAppError(http.StatusNotImplemented, "the storage backend does not report its health")
+)
+
+func PostSnapshot(app App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		snapshotter := app.Snapshotter()
+		if snapshotter == nil {
+			HandleError(c, app.Logger(), errSnapshotsUnsupported, "Snapshot unavailable")
+			return
+		}
+
+		snap, err := snapshotter.Snapshot()
+		if err != nil {
+			HandleError(c, app.Logger(), err, "Failed to take snapshot")
+			return
+		}
+
+		HandleSuccess(c, app.Logger(), snap, nil)
+	}
+}
+
+func GetSnapshots(app App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		snapshotter := app.Snapshotter()
+		if snapshotter == nil {
+			HandleError(c, app.Logger(), errSnapshotsUnsupported, "Snapshot unavailable")
+			return
+		}
+
+		snapshots, err := snapshotter.List()
+		if err != nil {
+			HandleError(c, app.Logger(), err, "Failed to list snapshots")
+			return
+		}
+
+		HandleSuccess(c, app.Logger(), snapshots, nil)
+	}
+}
+
+func GetUsers(app App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		users, err := service.ListUsers(c.Request.Context(), app.UserRepo())
+		if err != nil {
+			HandleError(c, app.Logger(), err, "Failed to list users")
+			return
+		}
+
+		HandleSuccess(c, app.Logger(), users, map[string]any{"count": len(users)})
+	}
+}
+
+// PostUserDisabled disables or re-enables the account in the :id path
+// parameter. Disabling an account also revokes its sessions.
+func PostUserDisabled(app App, disabled bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		actor := c.MustGet("user").(*internal.User)
+
+		user, err := service.SetUserDisabled(c.Request.Context(), app.UserRepo(), app.SessionRepo(), actor, c.Param("id"), disabled)
+		if err != nil {
+			HandleError(c, app.Logger(), err, "Failed to update user")
+			return
+		}
+		action := internal.AuditUserEnable
+		if disabled {
+			action = internal.AuditUserDisable
+		}
+		recordAudit(c, app, newAuditEvent(c, action, user.ID, ""))
+
+		HandleSuccess(c, app.Logger(), user, nil)
+	}
+}
+
+func PutUserRole(app App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		actor := c.MustGet("user").(*internal.User)
+
+		var req service.RoleRequest
+		if err := bindJSON(c, &req); err != nil {
+			HandleError(c, app.Logger(), err, "Invalid JSON")
+			return
+		}
+
+		if err := service.ValidateRoleRequest(&req); err != nil {
+			HandleError(c, app.Logger(), err, "Validation failed")
+			return
+		}
+
+		user, err := service.SetUserRole(c.Request.Context(), app.UserRepo(), actor, c.Param("id"), &req)
+		if err != nil {
+			HandleError(c, app.Logger(), err, "Failed to update user")
+			return
+		}
+		event := newAuditEvent(c, internal.AuditUserRole, user.ID, "")
+		event.Details = map[string]string{"role": user.Role}
+		recordAudit(c, app, event)
+
+		HandleSuccess(c, app.Logger(), user, nil)
+	}
+}
+
+// GetStorageHealth reports the storage backend's health, with a 503 status
+// when it is unhealthy.
+func GetStorageHealth(app App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		checker := app.StorageHealth()
+		if checker == nil {
+			HandleError(c, app.Logger(), errHealthUnsupported, "Health unavailable")
+			return
+		}
+
+		health := checker.Health(c.Request.Context())
+		if !health.OK {
+			app.Logger().Warnf("[request_id=%s] storage unhealthy: %s", c.GetString("request_id"), health.Error)
+			c.JSON(http.StatusServiceUnavailable, response.Success(health, nil))
+			return
+		}
+
+		HandleSuccess(c, app.Logger(), health, nil)
+	}
+}
@@ -0,0 +1,75 @@
+package api
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/yourname/sleeptracker/internal"
+	"github.com/yourname/sleeptracker/internal/service"
+)
+
+func PostAPIKey(app App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := c.MustGet("user").(*internal.User)
+
+		var req service.APIKeyRequest
+		if err := bindJSON(c, &req); err != nil {
+			HandleError(c, app.Logger(), err, "Invalid JSON")
+			return
+		}
+
+		if err := service.ValidateAPIKeyRequest(&req); err != nil {
+			HandleError(c, app.Logger(), err, "Validation failed")
+			return
+		}
+
+		key, err := service.CreateAPIKey(c.Request.Context(), app.APIKeyRepo(), user, &req)
+		if err != nil {
+			HandleError(c, app.Logger(), err, "Failed to create API key")
+			return
+		}
+		recordAudit(c, app, newAuditEvent(c, internal.AuditAPIKeyCreate, user.ID, key.ID))
+
+		HandleCreated(c, app.Logger(), V1Prefix+"/api-keys/"+key.ID, key)
+	}
+}
+
+func GetAPIKeys(app App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := c.MustGet("user").(*internal.User)
+
+		keys, err := service.ListAPIKeys(c.Request.Context(), app.APIKeyRepo(), user)
+		if err != nil {
+			HandleError(c, app.Logger(), err, "Failed to list API keys")
+			return
+		}
+
+		HandleSuccess(c, app.Logger(), keys, nil)
+	}
+}
+
+func GetAPIKey(app App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := c.MustGet("user").(*internal.User)
+
+		key, err := service.GetAPIKey(c.Request.Context(), app.APIKeyRepo(), user, c.Param("id"))
+		if err != nil {
+			HandleError(c, app.Logger(), err, "Failed to fetch API key")
+			return
+		}
+
+		HandleSuccess(c, app.Logger(), key, nil)
+	}
+}
+
+func DeleteAPIKey(app App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := c.MustGet("user").(*internal.User)
+
+		if err := service.RevokeAPIKey(c.Request.Context(), app.APIKeyRepo(), user, c.Param("id")); err != nil {
+			HandleError(c, app.Logger(), err, "Failed to revoke API key")
+			return
+		}
+		recordAudit(c, app, newAuditEvent(c, internal.AuditAPIKeyRevoke, user.ID, c.Param("id")))
+
+		HandleNoContent(c, app.Logger())
+	}
+}
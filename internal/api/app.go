@@ -2,6 +2,7 @@ package api
 
 import (
 	"github.com/yourname/sleeptracker/internal"
+	"github.com/yourname/sleeptracker/internal/backup"
 	"github.com/yourname/sleeptracker/internal/storage"
 )
 
@@ -9,4 +10,15 @@ type App interface {
 	Logger() internal.Logger
 	SleepRepo() storage.SleepLogRepository
 	GoalRepo() storage.GoalRepository
+	AggregateRepo() storage.AggregateRepository
+	UserRepo() storage.UserRepository
+	SessionRepo() storage.SessionRepository
+	APIKeyRepo() storage.APIKeyRepository
+	SharingRepo() storage.SharingRepository
+	AuditRepo() storage.AuditRepository
+	// Snapshotter is nil when the storage backend does not support snapshots.
+	Snapshotter() *backup.Snapshotter
+	// StorageHealth is nil when the storage backend cannot report its health.
+	StorageHealth() storage.HealthChecker
+	RouteConfig() RouteConfig
 }
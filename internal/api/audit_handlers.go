@@ -0,0 +1,107 @@
+package api
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yourname/sleeptracker/internal"
+	"github.com/yourname/sleeptracker/internal/auth"
+	"github.com/yourname/sleeptracker/internal/service"
+)
+
+// newAuditEvent describes an action taken in this request by the
+// authenticated caller, if there is one.
+func newAuditEvent(c *gin.Context, action, targetUserID, resourceID string) *internal.AuditEvent {
+	event := &internal.AuditEvent{
+		Action:       action,
+		TargetUserID: targetUserID,
+		ResourceID:   resourceID,
+		RequestID:    c.GetString("request_id"),
+		IP:           c.ClientIP(),
+	}
+	if user, ok := c.Get("user"); ok {
+		event.ActorID = user.(*internal.User).ID
+	}
+	return event
+}
+
+// recordAudit appends event to the audit log. The action it describes has
+// already taken effect, so a failure is logged instead of failing the
+// request.
+func recordAudit(c *gin.Context, app App, event *internal.AuditEvent) {
+	if err := service.RecordAuditEvent(c.Request.Context(), app.AuditRepo(), event); err != nil {
+		app.Logger().Errorf("[request_id=%s] failed to record audit event %s: %v", c.GetString("request_id"), event.Action, err)
+	}
+}
+
+// AuditAuthFailure records requests whose credentials AuthMiddleware
+// rejected. The credentials themselves are not recorded.
+func AuditAuthFailure(app App) auth.FailureHook {
+	return func(c *gin.Context, err error) {
+		credential := "bearer"
+		if strings.HasPrefix(c.GetHeader("X-API-Key"), auth.APIKeyPrefix) || strings.HasPrefix(c.GetHeader("Authorization"), "Bearer "+auth.APIKeyPrefix) {
+			credential = "api_key"
+		}
+		event := newAuditEvent(c, internal.AuditAuthFailed, "", "")
+		event.Details = map[string]string{
+			"credential": credential,
+			"method":     c.Request.Method,
+			"path":       c.Request.URL.Path,
+			"error":      err.Error(),
+		}
+		recordAudit(c, app, event)
+	}
+}
+
+// GetAuditLog returns the audit events the caller performed or was the
+// target of.
+func GetAuditLog(app App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := c.MustGet("user").(*internal.User)
+
+		q, ok := bindAuditQuery(c, app)
+		if !ok {
+			return
+		}
+
+		events, err := service.ListOwnAuditEvents(c.Request.Context(), app.AuditRepo(), user, q)
+		if err != nil {
+			HandleError(c, app.Logger(), err, "Failed to list audit events")
+			return
+		}
+
+		HandleSuccess(c, app.Logger(), events, map[string]any{"count": len(events)})
+	}
+}
+
+// GetAdminAuditLog returns events from the whole audit log, optionally
+// narrowed to one user with ?user_id=.
+func GetAdminAuditLog(app App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		q, ok := bindAuditQuery(c, app)
+		if !ok {
+			return
+		}
+
+		events, err := service.ListAuditEvents(c.Request.Context(), app.AuditRepo(), q)
+		if err != nil {
+			HandleError(c, app.Logger(), err, "Failed to list audit events")
+			return
+		}
+
+		HandleSuccess(c, app.Logger(), events, map[string]any{"count": len(events)})
+	}
+}
+
+func bindAuditQuery(c *gin.Context, app App) (*service.AuditQuery, bool) {
+	var q service.AuditQuery
+	if err := bindQuery(c, &q); err != nil {
+		HandleError(c, app.Logger(), err, "Invalid query")
+		return nil, false
+	}
+	if err := service.ValidateAuditQuery(&q); err != nil {
+		HandleError(c, app.Logger(), err, "Validation failed")
+		return nil, false
+	}
+	return &q, true
+}
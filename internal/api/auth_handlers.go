@@ -0,0 +1,133 @@
+package api
+
+import (
+	"errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yourname/sleeptracker/internal"
+	"github.com/yourname/sleeptracker/internal/service"
+)
+
+// PostRegister creates an account with the user role.
+func PostRegister(app App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req service.RegisterRequest
+		if err := bindJSON(c, &req); err != nil {
+			HandleError(c, app.Logger(), err, "Invalid JSON")
+			return
+		}
+
+		if err := service.ValidateRegisterRequest(&req); err != nil {
+			HandleError(c, app.Logger(), err, "Validation failed")
+			return
+		}
+
+		user, err := service.Register(c.Request.Context(), app.UserRepo(), &req)
+		if err != nil {
+			HandleError(c, app.Logger(), err, "Registration failed")
+			return
+		}
+		recordAudit(c, app, newAuditEvent(c, internal.AuditRegister, user.ID, ""))
+
+		HandleCreated(c, app.Logger(), "", user)
+	}
+}
+
+// PostLogin exchanges an email and password for an access and refresh token.
+func PostLogin(app App, policy service.TokenPolicy) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req service.LoginRequest
+		if err := bindJSON(c, &req); err != nil {
+			HandleError(c, app.Logger(), err, "Invalid JSON")
+			return
+		}
+
+		if err := service.ValidateLoginRequest(&req); err != nil {
+			HandleError(c, app.Logger(), err, "Validation failed")
+			return
+		}
+
+		client := service.ClientInfo{UserAgent: c.Request.UserAgent(), IP: c.ClientIP()}
+		result, err := service.Login(c.Request.Context(), app.UserRepo(), app.SessionRepo(), &req, policy, client)
+		if errors.Is(err, service.ErrInvalidCredentials) || errors.Is(err, service.ErrAccountDisabled) {
+			event := newAuditEvent(c, internal.AuditLoginFailed, service.UserIDByEmail(c.Request.Context(), app.UserRepo(), req.Email), "")
+			event.Details = map[string]string{"error": err.Error()}
+			recordAudit(c, app, event)
+		}
+		if err != nil {
+			HandleError(c, app.Logger(), err, "Login failed")
+			return
+		}
+		event := newAuditEvent(c, internal.AuditLogin, result.UserID, result.SessionID)
+		event.ActorID = result.UserID
+		recordAudit(c, app, event)
+
+		HandleSuccess(c, app.Logger(), result, nil)
+	}
+}
+
+func PostLogout(app App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := c.MustGet("user").(*internal.User)
+
+		if err := service.Logout(c.Request.Context(), app.UserRepo(), app.SessionRepo(), user); err != nil {
+			HandleError(c, app.Logger(), err, "Failed to log out")
+			return
+		}
+		recordAudit(c, app, newAuditEvent(c, internal.AuditLogout, user.ID, ""))
+
+		HandleNoContent(c, app.Logger())
+	}
+}
+
+// PostRefresh rotates a refresh token into a new access and refresh token.
+func PostRefresh(app App, policy service.TokenPolicy) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req service.RefreshRequest
+		if err := bindJSON(c, &req); err != nil {
+			HandleError(c, app.Logger(), err, "Invalid JSON")
+			return
+		}
+
+		if err := service.ValidateRefreshRequest(&req); err != nil {
+			HandleError(c, app.Logger(), err, "Validation failed")
+			return
+		}
+
+		result, err := service.Refresh(c.Request.Context(), app.UserRepo(), app.SessionRepo(), &req, policy, app.Logger())
+		if err != nil {
+			HandleError(c, app.Logger(), err, "Refresh failed")
+			return
+		}
+
+		HandleSuccess(c, app.Logger(), result, nil)
+	}
+}
+
+func GetSessions(app App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := c.MustGet("user").(*internal.User)
+
+		sessions, err := service.ListSessions(c.Request.Context(), app.UserRepo(), app.SessionRepo(), user)
+		if err != nil {
+			HandleError(c, app.Logger(), err, "Failed to list sessions")
+			return
+		}
+
+		HandleSuccess(c, app.Logger(), sessions, nil)
+	}
+}
+
+func DeleteSession(app App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := c.MustGet("user").(*internal.User)
+
+		if err := service.RevokeSession(c.Request.Context(), app.SessionRepo(), user, c.Param("id")); err != nil {
+			HandleError(c, app.Logger(), err, "Failed to revoke session")
+			return
+		}
+		recordAudit(c, app, newAuditEvent(c, internal.AuditSessionRevoke, user.ID, c.Param("id")))
+
+		HandleNoContent(c, app.Logger())
+	}
+}
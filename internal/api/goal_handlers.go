@@ -11,42 +11,55 @@ func PostGoal(app App) gin.HandlerFunc {
 		user := c.MustGet("user").(*internal.User)
 
 		var req service.GoalRequest
-		if err := c.ShouldBindJSON(&req); err != nil {
-			HandleError(c, app.Logger(), err, 400, "Invalid request: type and value required")
+		if err := bindJSON(c, &req); err != nil {
+			HandleError(c, app.Logger(), err, "Invalid request: type and value required")
 			return
 		}
 
 		if err := service.ValidateGoalRequest(&req); err != nil {
-			HandleError(c, app.Logger(), err, 400, "Goal validation failed")
+			HandleError(c, app.Logger(), err, "Goal validation failed")
 			return
 		}
 
 		goal, err := service.CreateGoal(c.Request.Context(), app.GoalRepo(), user, &req)
 		if err != nil {
-			HandleError(c, app.Logger(), err, 500, "Failed to save goal")
+			HandleError(c, app.Logger(), err, "Failed to save goal")
 			return
 		}
+		event := newAuditEvent(c, internal.AuditGoalSet, user.ID, goal.ID)
+		event.Details = map[string]string{"type": goal.Type, "value": goal.Value}
+		recordAudit(c, app, event)
 
-		HandleSuccess(c, app.Logger(), goal, nil)
+		HandleCreated(c, app.Logger(), V1Prefix+"/goals", goal)
+	}
+}
+
+func GetGoal(app App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		goal, err := service.GetGoal(c.Request.Context(), app.GoalRepo(), dataOwnerID(c))
+		if err != nil {
+			HandleError(c, app.Logger(), err, "Failed to fetch goal")
+			return
+		}
+
+		HandleCacheable(c, app.Logger(), goal, nil)
 	}
 }
 
 func GetGoalProgress(app App) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		user := c.MustGet("user").(*internal.User)
-		goal, err := app.GoalRepo().GetGoal(c.Request.Context(), user.ID)
+		goal, err := service.GetGoal(c.Request.Context(), app.GoalRepo(), dataOwnerID(c))
 		if err != nil {
-			HandleError(c, app.Logger(), err, 404, "No goal set for user")
+			HandleError(c, app.Logger(), err, "No goal set for user")
 			return
 		}
 
-		logs, err := app.SleepRepo().ListSleepLogs(c.Request.Context(), user.ID)
+		progress, err := service.GetGoalProgress(c.Request.Context(), goal, app.AggregateRepo())
 		if err != nil {
-			HandleError(c, app.Logger(), err, 500, "Failed to fetch logs for goal progress")
+			HandleError(c, app.Logger(), err, "Failed to fetch logs for goal progress")
 			return
 		}
 
-		progress := service.CalculateGoalProgress(goal, logs)
-		HandleSuccess(c, app.Logger(), progress, nil)
+		HandleCacheable(c, app.Logger(), progress, nil)
 	}
 }
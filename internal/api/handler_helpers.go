@@ -1,30 +1,171 @@
 package api
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+
 	"github.com/gin-gonic/gin"
 	"github.com/yourname/sleeptracker/internal"
+	"github.com/yourname/sleeptracker/internal/export"
 	"github.com/yourname/sleeptracker/internal/response"
 )
 
-func HandleError(c *gin.Context, logger internal.Logger, err error, status int, msg string) {
+// HandleError logs err and writes the error response. The status and the
+// detail shown to the client come from response.Classify, so the text of
+// unexpected errors is only logged.
+func HandleError(c *gin.Context, logger internal.Logger, err error, msg string) {
 	requestID := c.GetString("request_id")
 	logger.Errorf("[request_id=%s] %s: %v", requestID, msg, err)
-	var resp response.APIResponse
-	switch status {
-	case 400:
-		resp = response.BadRequest(msg + ": " + err.Error())
-	case 404:
-		resp = response.NotFound(msg + ": " + err.Error())
-	case 500:
-		resp = response.InternalError(msg + ": " + err.Error())
-	default:
-		resp = response.NewAppError(status, msg+": "+err.Error())
+	response.Error(c, err, msg)
+}
+
+// bindJSON decodes the request body into v. Malformed bodies are validation
+// errors, naming the field when a value has the wrong type.
+func bindJSON(c *gin.Context, v interface{}) error {
+	err := c.ShouldBindJSON(v)
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		expected := jsonType(typeErr.Type)
+		return &internal.ValidationError{Fields: []internal.FieldError{{
+			Field:   typeErr.Field,
+			Code:    "type",
+			Message: "must be a JSON " + expected,
+			Params:  map[string]string{"expected": expected},
+		}}}
+	}
+	if err != nil {
+		return internal.WrapError(internal.ErrValidation, err)
+	}
+	return nil
+}
+
+// jsonType names the JSON type that decodes into t.
+func jsonType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.String:
+		return "string"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	}
+	return "object"
+}
+
+// bindQuery decodes the query string into v. Malformed values are
+// validation errors.
+func bindQuery(c *gin.Context, v interface{}) error {
+	if err := c.ShouldBindQuery(v); err != nil {
+		return internal.WrapError(internal.ErrValidation, err)
 	}
-	c.JSON(status, resp)
+	return nil
 }
 
+// HandleSuccess writes a 200 response.
 func HandleSuccess(c *gin.Context, logger internal.Logger, data interface{}, meta map[string]any) {
 	requestID := c.GetString("request_id")
 	logger.Infof("[request_id=%s] Success", requestID)
-	c.JSON(200, response.Success(data, meta))
+	c.JSON(http.StatusOK, response.Success(data, meta))
+}
+
+// HandleCreated writes a 201 response for a new resource, with a Location
+// header pointing at it when location is set.
+func HandleCreated(c *gin.Context, logger internal.Logger, location string, data interface{}) {
+	requestID := c.GetString("request_id")
+	logger.Infof("[request_id=%s] Created %s", requestID, location)
+	if location != "" {
+		c.Header("Location", location)
+	}
+	c.JSON(http.StatusCreated, response.Success(data, nil))
+}
+
+// HandleAccepted writes a 202 response for work that will finish after the
+// response is sent. location, if set, is where its progress can be checked.
+func HandleAccepted(c *gin.Context, logger internal.Logger, location string, data interface{}) {
+	requestID := c.GetString("request_id")
+	logger.Infof("[request_id=%s] Accepted %s", requestID, location)
+	if location != "" {
+		c.Header("Location", location)
+	}
+	c.JSON(http.StatusAccepted, response.Success(data, nil))
+}
+
+// HandleNoContent writes a 204 response with no body.
+func HandleNoContent(c *gin.Context, logger internal.Logger) {
+	requestID := c.GetString("request_id")
+	logger.Infof("[request_id=%s] No content", requestID)
+	c.Status(http.StatusNoContent)
+}
+
+// HandleCacheable writes a 200 response with an ETag derived from the body.
+// If the request's If-None-Match already names that ETag, it writes 304 Not
+// Modified with no body instead.
+func HandleCacheable(c *gin.Context, logger internal.Logger, data interface{}, meta map[string]any) {
+	requestID := c.GetString("request_id")
+	body, err := json.Marshal(response.Success(data, meta))
+	if err != nil {
+		HandleError(c, logger, err, "Failed to encode response")
+		return
+	}
+	sum := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	c.Header("ETag", etag)
+	if etagMatches(c.GetHeader("If-None-Match"), etag) {
+		logger.Infof("[request_id=%s] Not modified", requestID)
+		c.Status(http.StatusNotModified)
+		return
+	}
+	logger.Infof("[request_id=%s] Success", requestID)
+	c.Data(http.StatusOK, "application/json; charset=utf-8", body)
+}
+
+// negotiateCSV reports whether the client asked for CSV, with ?format=csv
+// or, when format is unset, an Accept header naming text/csv. Since the
+// answer depends on Accept, it marks the response as varying by it.
+func negotiateCSV(c *gin.Context, format string) bool {
+	c.Header("Vary", "Accept")
+	if format != "" {
+		return format == "csv"
+	}
+	return strings.Contains(c.GetHeader("Accept"), "text/csv")
+}
+
+// HandleCSV writes a 200 CSV response as an attachment named filename,
+// streaming the rows write produces. Once the first rows are sent the status
+// cannot change, so a later failure is only logged and the response is cut
+// short.
+func HandleCSV(c *gin.Context, logger internal.Logger, filename string, write func(io.Writer) error) {
+	requestID := c.GetString("request_id")
+	c.Header("Content-Type", export.ContentType)
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Status(http.StatusOK)
+	if err := write(c.Writer); err != nil {
+		logger.Errorf("[request_id=%s] Failed to write CSV: %v", requestID, err)
+		_ = c.Error(err)
+		c.Abort()
+		return
+	}
+	logger.Infof("[request_id=%s] Success", requestID)
+}
+
+// etagMatches reports whether an If-None-Match header matches etag, using
+// the weak comparison RFC 9110 prescribes for it.
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
 }
@@ -0,0 +1,115 @@
+package api
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yourname/sleeptracker/internal"
+	"github.com/yourname/sleeptracker/internal/service"
+)
+
+const (
+	// maxImportBytes bounds the body of a JSON or CSV import.
+	maxImportBytes = 10 << 20
+	// maxSourceImportBytes bounds exports from other apps, which hold far
+	// more than sleep; they are streamed rather than read into memory.
+	maxSourceImportBytes = 1 << 30
+)
+
+// PostSleepImport imports a JSON array of sleep logs, or CSV with its
+// columns mapped by ?columns[field]=header, and reports each rejected row.
+// With ?dry_run=true nothing is saved.
+func PostSleepImport(app App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var opts service.ImportOptions
+		if err := bindQuery(c, &opts); err != nil {
+			HandleError(c, app.Logger(), err, "Invalid query")
+			return
+		}
+		opts.Columns = c.QueryMap("columns")
+		loc, err := service.ValidateImportOptions(&opts)
+		if err != nil {
+			HandleError(c, app.Logger(), err, "Validation failed")
+			return
+		}
+
+		body := http.MaxBytesReader(c.Writer, c.Request.Body, maxImportBytes)
+		var rows []service.ImportRow
+		switch c.ContentType() {
+		case "text/csv":
+			rows, err = service.ParseImportCSV(body, opts.Columns, loc)
+		case "application/json", "":
+			rows, err = service.ParseImportJSON(body, loc)
+		default:
+			err = internal.NewAppError(http.StatusUnsupportedMediaType, "imports must be application/json or text/csv")
+		}
+		if err != nil {
+			HandleError(c, app.Logger(), err, "Invalid import")
+			return
+		}
+		importRows(c, app, rows, &opts, "")
+	}
+}
+
+// PostSourceImport imports a file exported by another app, the source named
+// in the path, such as an Apple Health export.xml or a Fitbit
+// sleep-YYYY-MM-DD.json. Logs imported before are updated, or skipped if
+// unchanged, so the same export can be uploaded again.
+func PostSourceImport(app App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		src, err := service.ImportSource(c.Param("source"))
+		if err != nil {
+			HandleError(c, app.Logger(), err, "Invalid import")
+			return
+		}
+		var opts service.ImportOptions
+		if err := bindQuery(c, &opts); err != nil {
+			HandleError(c, app.Logger(), err, "Invalid query")
+			return
+		}
+		loc, err := service.ValidateImportOptions(&opts)
+		if err != nil {
+			HandleError(c, app.Logger(), err, "Validation failed")
+			return
+		}
+		if !slices.Contains(src.MediaTypes(), c.ContentType()) {
+			err := internal.NewAppError(http.StatusUnsupportedMediaType, src.Name()+" exports must be "+strings.Join(src.MediaTypes(), " or "))
+			HandleError(c, app.Logger(), err, "Invalid import")
+			return
+		}
+
+		rows, err := service.ParseSource(src, http.MaxBytesReader(c.Writer, c.Request.Body, maxSourceImportBytes), loc)
+		if err != nil {
+			HandleError(c, app.Logger(), err, "Invalid import")
+			return
+		}
+		importRows(c, app, rows, &opts, src.Name())
+	}
+}
+
+// importRows imports rows for the user and writes the report. source names
+// the app an export came from, if any, for the audit log.
+func importRows(c *gin.Context, app App, rows []service.ImportRow, opts *service.ImportOptions, source string) {
+	user := c.MustGet("user").(*internal.User)
+	result, err := service.ImportSleepLogs(c.Request.Context(), app.SleepRepo(), user, rows, opts)
+	if err != nil {
+		HandleError(c, app.Logger(), err, "Failed to import logs")
+		return
+	}
+	if result.Imported > 0 || result.Updated > 0 {
+		event := newAuditEvent(c, internal.AuditSleepImport, user.ID, "")
+		event.Details = map[string]string{"imported": strconv.Itoa(result.Imported)}
+		if result.Updated > 0 {
+			event.Details["updated"] = strconv.Itoa(result.Updated)
+		}
+		if source != "" {
+			event.Details["source"] = source
+		}
+		recordAudit(c, app, event)
+	}
+
+	HandleSuccess(c, app.Logger(), result, nil)
+}
@@ -0,0 +1,189 @@
+package api
+
+import (
+	"expvar"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yourname/sleeptracker/internal"
+	"github.com/yourname/sleeptracker/internal/auth"
+	"github.com/yourname/sleeptracker/internal/idempotency"
+	"github.com/yourname/sleeptracker/internal/openapi"
+	"github.com/yourname/sleeptracker/internal/ratelimit"
+	"github.com/yourname/sleeptracker/internal/service"
+)
+
+// V1Prefix is where version 1 of the API is mounted.
+const V1Prefix = "/api/v1"
+
+// The unversioned routes the API started with are served until LegacySunset
+// with Deprecation, Sunset and successor Link headers.
+var (
+	LegacyDeprecatedAt = time.Date(2026, time.November, 1, 0, 0, 0, 0, time.UTC)
+	LegacySunset       = time.Date(2027, time.May, 1, 0, 0, 0, 0, time.UTC)
+)
+
+// RouteConfig is what RegisterRoutes needs beyond the repositories.
+type RouteConfig struct {
+	TokenPolicy service.TokenPolicy
+	Auth        auth.Provider
+	// OpenAPI rejects requests that do not match the spec; with a nil
+	// OpenAPI they go straight to the handlers.
+	OpenAPI *openapi.Validator
+	// Limiter enforces the limits below; with a nil Limiter none are.
+	Limiter          ratelimit.Limiter
+	AuthLimit        ratelimit.Limit // registration, login and refresh, per IP
+	AuthFailureLimit ratelimit.Limit // failed authentication, per IP
+	APILimit         ratelimit.Limit // authenticated requests, per user
+	WriteLimit       ratelimit.Limit // data-changing requests, per user
+	// Idempotency replays responses to creates retried with the same
+	// Idempotency-Key; with a nil Idempotency the header is ignored.
+	Idempotency idempotency.Store
+}
+
+// routes registers each endpoint under /api/v1 and, if it had one, at its
+// legacy path.
+type routes struct {
+	v1, legacy *gin.RouterGroup
+	// successors maps legacy route patterns to their /api/v1 patterns.
+	successors map[string]string
+	spec       *openapi.Validator
+}
+
+func (rt routes) group(relativePath string, handlers ...gin.HandlerFunc) routes {
+	return routes{
+		v1:         rt.v1.Group(relativePath, handlers...),
+		legacy:     rt.legacy.Group(relativePath, handlers...),
+		successors: rt.successors,
+		spec:       rt.spec,
+	}
+}
+
+// handle registers the route. Requests are checked against the spec after
+// the other middleware, so that credentials, scopes and roles are checked
+// first, and just before the handler.
+func (rt routes) handle(method, relativePath, legacyPath string, handlers ...gin.HandlerFunc) {
+	if rt.spec != nil {
+		specPath := strings.TrimPrefix(path.Join(rt.v1.BasePath(), relativePath), V1Prefix)
+		if validate := rt.spec.Middleware(method, specPath); validate != nil {
+			last := len(handlers) - 1
+			handlers = append(append(handlers[:last:last], validate), handlers[last])
+		}
+	}
+	rt.v1.Handle(method, relativePath, handlers...)
+	if legacyPath != "" {
+		rt.successors[path.Join(rt.legacy.BasePath(), legacyPath)] = path.Join(rt.v1.BasePath(), relativePath)
+		rt.legacy.Handle(method, legacyPath, handlers...)
+	}
+}
+
+// deprecated marks responses from legacy routes, linking to the /api/v1
+// successor. It runs before anything else on those routes so that errors,
+// such as a 401, are marked too.
+func deprecated(successors map[string]string) gin.HandlerFunc {
+	deprecation := "@" + strconv.FormatInt(LegacyDeprecatedAt.Unix(), 10)
+	sunset := LegacySunset.Format(http.TimeFormat)
+	return func(c *gin.Context) {
+		successor, ok := successors[c.FullPath()]
+		if !ok {
+			c.Next()
+			return
+		}
+		for _, p := range c.Params {
+			successor = strings.Replace(successor, ":"+p.Key, p.Value, 1)
+		}
+		h := c.Writer.Header()
+		h.Set("Deprecation", deprecation)
+		h.Set("Sunset", sunset)
+		h.Set("Link", "<"+successor+`>; rel="successor-version"`)
+		c.Next()
+	}
+}
+
+// RegisterRoutes mounts the API on r.
+func RegisterRoutes(r *gin.Engine, app App) {
+	cfg := app.RouteConfig()
+	if cfg.Limiter == nil {
+		cfg.AuthLimit, cfg.AuthFailureLimit, cfg.APILimit, cfg.WriteLimit = ratelimit.Limit{}, ratelimit.Limit{}, ratelimit.Limit{}, ratelimit.Limit{}
+	}
+	limit := func(name string, l ratelimit.Limit) gin.HandlerFunc {
+		return ratelimit.Middleware(cfg.Limiter, name, l, app.Logger())
+	}
+	idempotent := func(c *gin.Context) { c.Next() }
+	if cfg.Idempotency != nil {
+		// The largest body among the idempotent routes is an import.
+		idempotent = idempotency.Middleware(cfg.Idempotency, maxImportBytes, app.Logger())
+	}
+
+	successors := map[string]string{}
+	root := routes{v1: r.Group(V1Prefix), legacy: r.Group("/", deprecated(successors)), successors: successors, spec: cfg.OpenAPI}
+	authLimit := limit("auth", cfg.AuthLimit)
+	root.handle(http.MethodPost, "/auth/register", "/auth/register", authLimit, PostRegister(app))
+	root.handle(http.MethodPost, "/auth/login", "/auth/login", authLimit, PostLogin(app, cfg.TokenPolicy))
+	root.handle(http.MethodPost, "/auth/refresh", "/auth/refresh", authLimit, PostRefresh(app, cfg.TokenPolicy))
+
+	// Everything else needs credentials
+	protected := root.group("",
+		ratelimit.FailureMiddleware(cfg.Limiter, "auth-failures", cfg.AuthFailureLimit, app.Logger()),
+		auth.AuthMiddleware(cfg.Auth, AuditAuthFailure(app)),
+		limit("api", cfg.APILimit),
+	)
+	account := auth.RequireScope(auth.ScopeAccount)
+	protected.handle(http.MethodPost, "/auth/logout", "/auth/logout", account, PostLogout(app))
+	protected.handle(http.MethodGet, "/auth/sessions", "/auth/sessions", account, GetSessions(app))
+	protected.handle(http.MethodDelete, "/auth/sessions/:id", "/auth/sessions/:id", account, DeleteSession(app))
+	protected.handle(http.MethodPost, "/api-keys", "", account, idempotent, PostAPIKey(app))
+	protected.handle(http.MethodGet, "/api-keys", "", account, GetAPIKeys(app))
+	protected.handle(http.MethodGet, "/api-keys/:id", "", account, GetAPIKey(app))
+	protected.handle(http.MethodDelete, "/api-keys/:id", "", account, DeleteAPIKey(app))
+	protected.handle(http.MethodGet, "/audit-events", "", account, GetAuditLog(app))
+
+	// Viewers are read-only
+	writer := auth.RequireRole(internal.RoleUser, internal.RoleAdmin)
+	writeLimit := limit("write", cfg.WriteLimit)
+	protected.handle(http.MethodPost, "/sleep-logs", "/sleep", auth.RequireScope(auth.ScopeSleepWrite), writer, writeLimit, idempotent, PostSleep(app))
+	protected.handle(http.MethodPost, "/sleep-logs/import", "/sleep/import", auth.RequireScope(auth.ScopeSleepWrite), writer, writeLimit, idempotent, PostSleepImport(app))
+	// Exports from other apps are too large to buffer for Idempotency-Key
+	// checks; importing one twice updates the logs it imported instead.
+	protected.handle(http.MethodPost, "/sleep-logs/import/:source", "/sleep/import/:source", auth.RequireScope(auth.ScopeSleepWrite), writer, writeLimit, PostSourceImport(app))
+	protected.handle(http.MethodGet, "/sleep-logs", "/sleep", auth.RequireScope(auth.ScopeSleepRead), GetSleep(app))
+	protected.handle(http.MethodGet, "/sleep-logs/:id", "/sleep/:id", auth.RequireScope(auth.ScopeSleepRead), GetSleepLog(app))
+	protected.handle(http.MethodGet, "/sleep-stats", "/sleep/stats", auth.RequireScope(auth.ScopeStatsRead), GetSleepStats(app))
+	protected.handle(http.MethodGet, "/sleep-recommendations", "/sleep/recommendations", auth.RequireScope(auth.ScopeStatsRead), GetSleepRecommendations(app))
+	protected.handle(http.MethodPost, "/goals", "/api/goals", auth.RequireScope(auth.ScopeGoalsWrite), writer, writeLimit, idempotent, PostGoal(app))
+	protected.handle(http.MethodGet, "/goals", "/api/goals", auth.RequireScope(auth.ScopeGoalsRead), GetGoal(app))
+	protected.handle(http.MethodGet, "/goals/progress", "/api/goals/progress", auth.RequireScope(auth.ScopeGoalsRead), GetGoalProgress(app))
+
+	// Sharing: owners invite other users to read their data, which grantees
+	// then read under /users/:id
+	protected.handle(http.MethodPost, "/shares", "", account, writer, writeLimit, idempotent, PostShare(app))
+	protected.handle(http.MethodGet, "/shares", "", account, GetShares(app))
+	protected.handle(http.MethodGet, "/shares/incoming", "", account, GetIncomingShares(app))
+	protected.handle(http.MethodGet, "/shares/access-log", "", account, GetShareAccessLog(app))
+	protected.handle(http.MethodGet, "/shares/:id", "", account, GetShare(app))
+	protected.handle(http.MethodPost, "/shares/:id/accept", "", account, PostAcceptShare(app))
+	protected.handle(http.MethodDelete, "/shares/:id", "", account, DeleteShare(app))
+	shared := protected.group("/users/:id")
+	shared.handle(http.MethodGet, "/sleep-logs", "", auth.RequireScope(auth.ScopeSleepRead), RequireGrant(app), GetSleep(app))
+	shared.handle(http.MethodGet, "/sleep-stats", "", auth.RequireScope(auth.ScopeStatsRead), RequireGrant(app), GetSleepStats(app))
+	shared.handle(http.MethodGet, "/goals/progress", "", auth.RequireScope(auth.ScopeGoalsRead), RequireGrant(app), GetGoalProgress(app))
+
+	// Operator endpoints: viewers may read, only admins may change anything
+	admin := protected.group("/admin", auth.RequireScope(auth.ScopeAdmin), auth.RequireRole(internal.RoleAdmin, internal.RoleViewer))
+	adminOnly := auth.RequireRole(internal.RoleAdmin)
+	admin.handle(http.MethodGet, "/users", "", GetUsers(app))
+	admin.handle(http.MethodPost, "/users/:id/disable", "", adminOnly, PostUserDisabled(app, true))
+	admin.handle(http.MethodPost, "/users/:id/enable", "", adminOnly, PostUserDisabled(app, false))
+	admin.handle(http.MethodPut, "/users/:id/role", "", adminOnly, PutUserRole(app))
+	admin.handle(http.MethodGet, "/health", "", GetStorageHealth(app))
+	admin.handle(http.MethodGet, "/audit-events", "", GetAdminAuditLog(app))
+	admin.handle(http.MethodPost, "/snapshots", "", adminOnly, PostSnapshot(app))
+	admin.handle(http.MethodGet, "/snapshots", "", GetSnapshots(app))
+	// Runtime and cache counters for monitoring
+	protected.handle(http.MethodGet, "/debug/vars", "",
+		auth.RequireScope(auth.ScopeAdmin), auth.RequireRole(internal.RoleAdmin, internal.RoleViewer), gin.WrapH(expvar.Handler()))
+}
@@ -0,0 +1,169 @@
+package api
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/yourname/sleeptracker/internal"
+	"github.com/yourname/sleeptracker/internal/service"
+)
+
+// ownerIDKey is the context key under which RequireGrant stores whose data a
+// /users/:id request reads.
+const ownerIDKey = "owner_id"
+
+// dataOwnerID returns the ID of the user whose data the request reads: the
+// user named in the path on shared routes, otherwise the caller.
+func dataOwnerID(c *gin.Context) string {
+	if id := c.GetString(ownerIDKey); id != "" {
+		return id
+	}
+	return c.MustGet("user").(*internal.User).ID
+}
+
+// RequireGrant guards /users/:id routes. It lets the request through when the
+// caller is that user or holds an active grant to their data, recording every
+// access made under a grant. It must run after AuthMiddleware.
+func RequireGrant(app App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := c.MustGet("user").(*internal.User)
+		ownerID := c.Param("id")
+		if ownerID == user.ID {
+			c.Set(ownerIDKey, ownerID)
+			c.Next()
+			return
+		}
+
+		grant, err := service.AuthorizeGrant(c.Request.Context(), app.SharingRepo(), user, ownerID)
+		if err != nil {
+			HandleError(c, app.Logger(), err, "Failed to check access")
+			return
+		}
+		// Data is only served once the access is on record.
+		if err := service.RecordGrantAccess(c.Request.Context(), app.SharingRepo(), grant, c.Request.Method, c.Request.URL.Path); err != nil {
+			HandleError(c, app.Logger(), err, "Failed to record access")
+			return
+		}
+		c.Set(ownerIDKey, ownerID)
+		c.Next()
+	}
+}
+
+func PostShare(app App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := c.MustGet("user").(*internal.User)
+
+		var req service.ShareRequest
+		if err := bindJSON(c, &req); err != nil {
+			HandleError(c, app.Logger(), err, "Invalid JSON")
+			return
+		}
+
+		if err := service.ValidateShareRequest(&req); err != nil {
+			HandleError(c, app.Logger(), err, "Validation failed")
+			return
+		}
+
+		grant, err := service.CreateGrant(c.Request.Context(), app.SharingRepo(), user, &req)
+		if err != nil {
+			HandleError(c, app.Logger(), err, "Failed to share data")
+			return
+		}
+		// No account is bound to the grant until it is accepted, so the
+		// event names the invited email rather than a target user.
+		event := newAuditEvent(c, internal.AuditGrantCreate, "", grant.ID)
+		event.Details = map[string]string{"grantee_email": grant.GranteeEmail}
+		recordAudit(c, app, event)
+
+		HandleCreated(c, app.Logger(), V1Prefix+"/shares/"+grant.ID, grant)
+	}
+}
+
+func GetShares(app App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := c.MustGet("user").(*internal.User)
+
+		grants, err := service.ListGrants(c.Request.Context(), app.SharingRepo(), user)
+		if err != nil {
+			HandleError(c, app.Logger(), err, "Failed to list grants")
+			return
+		}
+
+		HandleSuccess(c, app.Logger(), grants, nil)
+	}
+}
+
+func GetIncomingShares(app App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := c.MustGet("user").(*internal.User)
+
+		grants, err := service.ListIncomingGrants(c.Request.Context(), app.SharingRepo(), user)
+		if err != nil {
+			HandleError(c, app.Logger(), err, "Failed to list grants")
+			return
+		}
+
+		HandleSuccess(c, app.Logger(), grants, nil)
+	}
+}
+
+func GetShare(app App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := c.MustGet("user").(*internal.User)
+
+		grant, err := service.GetGrant(c.Request.Context(), app.SharingRepo(), user, c.Param("id"))
+		if err != nil {
+			HandleError(c, app.Logger(), err, "Failed to fetch grant")
+			return
+		}
+
+		HandleSuccess(c, app.Logger(), grant, nil)
+	}
+}
+
+func PostAcceptShare(app App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := c.MustGet("user").(*internal.User)
+
+		grant, err := service.AcceptGrant(c.Request.Context(), app.SharingRepo(), user, c.Param("id"))
+		if err != nil {
+			HandleError(c, app.Logger(), err, "Failed to accept grant")
+			return
+		}
+		recordAudit(c, app, newAuditEvent(c, internal.AuditGrantAccept, grant.OwnerID, grant.ID))
+
+		HandleSuccess(c, app.Logger(), grant, nil)
+	}
+}
+
+func DeleteShare(app App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := c.MustGet("user").(*internal.User)
+
+		grant, err := service.RevokeGrant(c.Request.Context(), app.SharingRepo(), user, c.Param("id"))
+		if err != nil {
+			HandleError(c, app.Logger(), err, "Failed to revoke grant")
+			return
+		}
+		// The target is the other party: the grantee when the owner revokes.
+		target := grant.OwnerID
+		if user.ID == grant.OwnerID {
+			target = grant.GranteeID
+		}
+		recordAudit(c, app, newAuditEvent(c, internal.AuditGrantRevoke, target, grant.ID))
+
+		HandleNoContent(c, app.Logger())
+	}
+}
+
+func GetShareAccessLog(app App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := c.MustGet("user").(*internal.User)
+
+		accesses, err := service.ListGrantAccesses(c.Request.Context(), app.SharingRepo(), user)
+		if err != nil {
+			HandleError(c, app.Logger(), err, "Failed to list accesses")
+			return
+		}
+
+		HandleSuccess(c, app.Logger(), accesses, nil)
+	}
+}
@@ -1,10 +1,12 @@
 package api
 
 import (
-	"sort"
+	"io"
+	"net/url"
 
 	"github.com/gin-gonic/gin"
 	"github.com/yourname/sleeptracker/internal"
+	"github.com/yourname/sleeptracker/internal/export"
 	"github.com/yourname/sleeptracker/internal/service"
 )
 
@@ -13,57 +15,157 @@ func PostSleep(app App) gin.HandlerFunc {
 		user := c.MustGet("user").(*internal.User)
 
 		var body service.SleepLogRequest
-		if err := c.ShouldBindJSON(&body); err != nil {
-			HandleError(c, app.Logger(), err, 400, "Invalid JSON")
+		if err := bindJSON(c, &body); err != nil {
+			HandleError(c, app.Logger(), err, "Invalid JSON")
 			return
 		}
 		app.Logger().Infof("Parsed SleepLogRequest: %+v", body)
 
 		if err := service.ValidateSleepLogRequest(&body); err != nil {
-			HandleError(c, app.Logger(), err, 400, "Validation failed")
+			HandleError(c, app.Logger(), err, "Validation failed")
 			return
 		}
 
 		log, err := service.CreateSleepLog(c.Request.Context(), app.SleepRepo(), user, &body)
 		if err != nil {
-			HandleError(c, app.Logger(), err, 500, "Failed to save log")
+			HandleError(c, app.Logger(), err, "Failed to save log")
 			return
 		}
+		recordAudit(c, app, newAuditEvent(c, internal.AuditSleepCreate, user.ID, log.ID))
 
-		HandleSuccess(c, app.Logger(), log, nil)
+		HandleCreated(c, app.Logger(), V1Prefix+"/sleep-logs/"+log.ID, log)
 	}
 }
 
+func GetSleepLog(app App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		log, err := service.GetSleepLog(c.Request.Context(), app.SleepRepo(), dataOwnerID(c), c.Param("id"))
+		if err != nil {
+			HandleError(c, app.Logger(), err, "Failed to fetch log")
+			return
+		}
+
+		HandleCacheable(c, app.Logger(), log, nil)
+	}
+}
+
+// GetSleep lists sleep logs, newest first. With ?limit= it returns a page,
+// and meta.next_cursor, passed as ?cursor=, fetches the next one. Clients
+// that ask for CSV get the page as a spreadsheet, with a Link header to the
+// next one; without a limit the logs are streamed from storage.
 func GetSleep(app App) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		user := c.MustGet("user").(*internal.User)
+		var page service.SleepLogPage
+		var opts service.ExportOptions
+		if err := bindQuery(c, &page); err != nil {
+			HandleError(c, app.Logger(), err, "Invalid query")
+			return
+		}
+		if err := bindQuery(c, &opts); err != nil {
+			HandleError(c, app.Logger(), err, "Invalid query")
+			return
+		}
+		if err := service.ValidateSleepLogPage(&page); err != nil {
+			HandleError(c, app.Logger(), err, "Validation failed")
+			return
+		}
+		loc, err := service.ValidateExportOptions(&opts)
+		if err != nil {
+			HandleError(c, app.Logger(), err, "Validation failed")
+			return
+		}
+
+		csv := negotiateCSV(c, opts.Format)
+		if csv && page.Limit == 0 {
+			serveExport(c, app, "sleep-logs.csv", func(w io.Writer) error {
+				out, err := export.NewSleepLogWriter(w, loc)
+				if err != nil {
+					return err
+				}
+				if err := service.IterateSleepLogs(c.Request.Context(), app.SleepRepo(), dataOwnerID(c), page.Cursor, out.Write); err != nil {
+					return err
+				}
+				return out.Flush()
+			})
+			return
+		}
 
-		logs, err := app.SleepRepo().ListSleepLogs(c.Request.Context(), user.ID)
+		logs, next, err := service.ListSleepLogs(c.Request.Context(), app.SleepRepo(), dataOwnerID(c), &page)
 		if err != nil {
-			HandleError(c, app.Logger(), err, 500, "Failed to fetch logs")
+			HandleError(c, app.Logger(), err, "Failed to fetch logs")
 			return
 		}
 
-		sort.Slice(logs, func(i, j int) bool {
-			return logs[i].StartTime.After(logs[j].StartTime)
-		})
+		if csv {
+			if next != "" {
+				c.Header("Link", "<"+nextPageURL(c, next)+`>; rel="next"`)
+			}
+			serveExport(c, app, "sleep-logs.csv", func(w io.Writer) error {
+				return export.WriteSleepLogs(w, logs, loc)
+			})
+			return
+		}
 
-		HandleSuccess(c, app.Logger(), logs, nil)
+		var meta map[string]any
+		if next != "" {
+			meta = map[string]any{"next_cursor": next}
+		}
+		HandleCacheable(c, app.Logger(), logs, meta)
 	}
 }
 
+// serveExport writes a CSV export of the data owner's data and records it in
+// the audit log, whoever asked for it: the owner or a grantee.
+func serveExport(c *gin.Context, app App, filename string, write func(io.Writer) error) {
+	event := newAuditEvent(c, internal.AuditSleepExport, dataOwnerID(c), "")
+	event.Details = map[string]string{"file": filename}
+	recordAudit(c, app, event)
+	HandleCSV(c, app.Logger(), filename, write)
+}
+
+// nextPageURL is the request's URL with its cursor moved on to next.
+func nextPageURL(c *gin.Context, next string) string {
+	u := url.URL{Path: c.Request.URL.Path}
+	query := c.Request.URL.Query()
+	query.Set("cursor", next)
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// GetSleepStats returns the last week's average quality and daily trend.
+// Clients that ask for CSV get the daily series behind the trend instead.
 func GetSleepStats(app App) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		user := c.MustGet("user").(*internal.User)
-		logs, err := app.SleepRepo().ListSleepLogs(c.Request.Context(), user.ID)
+		var opts service.ExportOptions
+		if err := bindQuery(c, &opts); err != nil {
+			HandleError(c, app.Logger(), err, "Invalid query")
+			return
+		}
+		loc, err := service.ValidateExportOptions(&opts)
 		if err != nil {
-			HandleError(c, app.Logger(), err, 500, "Failed to fetch logs for stats")
+			HandleError(c, app.Logger(), err, "Validation failed")
+			return
+		}
+		if negotiateCSV(c, opts.Format) {
+			days, err := service.GetSleepDays(c.Request.Context(), app.AggregateRepo(), dataOwnerID(c))
+			if err != nil {
+				HandleError(c, app.Logger(), err, "Failed to fetch daily stats")
+				return
+			}
+			serveExport(c, app, "sleep-stats.csv", func(w io.Writer) error {
+				return export.WriteDailySleep(w, days, loc)
+			})
 			return
 		}
 
-		avg, trend := service.CalculateSleepStats(logs)
-		meta := map[string]any{"average_quality": avg, "trend": trend}
-		HandleSuccess(c, app.Logger(), nil, meta)
+		stats, err := service.GetSleepStats(c.Request.Context(), app.SleepRepo(), app.AggregateRepo(), dataOwnerID(c))
+		if err != nil {
+			HandleError(c, app.Logger(), err, "Failed to fetch logs for stats")
+			return
+		}
+
+		meta := map[string]any{"average_quality": stats.AverageQuality, "trend": stats.Trend}
+		HandleCacheable(c, app.Logger(), nil, meta)
 	}
 }
 
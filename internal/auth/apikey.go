@@ -0,0 +1,80 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/yourname/sleeptracker/internal"
+	"github.com/yourname/sleeptracker/internal/storage"
+)
+
+// APIKeyPrefix marks a bearer credential as an API key.
+const APIKeyPrefix = "slk_"
+
+// apiKeyDisplayLen is how much of a key is kept in clear to identify it.
+const apiKeyDisplayLen = len(APIKeyPrefix) + 8
+
+// touchInterval limits how often a key's last-used time is written.
+const touchInterval = time.Minute
+
+// NewAPIKey returns a random API key, the prefix shown to identify it and the
+// hash to store for it.
+func NewAPIKey() (key, prefix, hash string, err error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", "", err
+	}
+	key = APIKeyPrefix + base64.RawURLEncoding.EncodeToString(buf)
+	return key, key[:apiKeyDisplayLen], HashToken(key), nil
+}
+
+// APIKeyAuthProvider accepts users' API keys and restricts the request to the
+// key's scopes.
+type APIKeyAuthProvider struct {
+	keys   storage.APIKeyRepository
+	users  storage.UserRepository
+	logger internal.Logger
+}
+
+func NewAPIKeyAuthProvider(keys storage.APIKeyRepository, users storage.UserRepository, logger internal.Logger) *APIKeyAuthProvider {
+	return &APIKeyAuthProvider{keys: keys, users: users, logger: logger}
+}
+
+func (a *APIKeyAuthProvider) Authenticate(ctx context.Context, creds Credentials) (*internal.User, error) {
+	token := creds.Token
+	if !strings.HasPrefix(token, APIKeyPrefix) {
+		return nil, ErrUnrecognized
+	}
+	key, err := a.keys.GetAPIKeyByHash(ctx, HashToken(token))
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, ErrUnrecognized
+	}
+	if err != nil {
+		a.logger.Errorf("failed to look up api key: %v", err)
+		return nil, errors.New("invalid api key")
+	}
+	user, err := a.users.GetUserByID(ctx, key.UserID)
+	if err != nil {
+		a.logger.Errorf("api key %s for unknown user %s: %v", key.Prefix, key.UserID, err)
+		return nil, errors.New("invalid api key")
+	}
+	if user.DisabledAt != nil {
+		a.logger.Warnf("api key %s for disabled user %s", key.Prefix, user.ID)
+		return nil, errors.New("account disabled")
+	}
+
+	now := time.Now()
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) > touchInterval {
+		if err := a.keys.TouchAPIKey(ctx, key.ID, now); err != nil {
+			a.logger.Warnf("failed to record use of api key %s: %v", key.Prefix, err)
+		}
+	}
+
+	user.Token = token
+	user.Scopes = append([]string{}, key.Scopes...)
+	return user, nil
+}
@@ -0,0 +1,251 @@
+package auth
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/yourname/sleeptracker/internal"
+	"golang.org/x/sync/singleflight"
+)
+
+// keySet resolves the RSA public key a token was signed with by its key ID.
+type keySet interface {
+	Key(ctx context.Context, kid string) (*rsa.PublicKey, error)
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// parseJWKS decodes the RSA signing keys of a JWKS document. Keys of other
+// types or for other uses are skipped.
+func parseJWKS(data []byte) (map[string]*rsa.PublicKey, error) {
+	var doc struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("jwks: %w", err)
+	}
+	keys := make(map[string]*rsa.PublicKey)
+	for _, k := range doc.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") || (k.Alg != "" && k.Alg != "RS256") {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("jwks: key %q: invalid modulus: %w", k.Kid, err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("jwks: key %q: invalid exponent: %w", k.Kid, err)
+		}
+		exp := new(big.Int).SetBytes(e)
+		if !exp.IsInt64() || exp.Int64() < 3 || exp.Int64() > 1<<31-1 {
+			return nil, fmt.Errorf("jwks: key %q: unsupported exponent", k.Kid)
+		}
+		keys[k.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exp.Int64())}
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("jwks: no RSA signing keys")
+	}
+	return keys, nil
+}
+
+// lookupKey finds kid in keys. A token without a kid matches a set with a
+// single key.
+func lookupKey(keys map[string]*rsa.PublicKey, kid string) *rsa.PublicKey {
+	if kid == "" && len(keys) == 1 {
+		for _, k := range keys {
+			return k
+		}
+	}
+	return keys[kid]
+}
+
+var errUnknownKey = errors.New("jwks: unknown key id")
+
+// fileKeySet serves keys from a local JWKS file, re-reading it whenever its
+// modification time changes so keys can be rotated without a restart.
+type fileKeySet struct {
+	path    string
+	mu      sync.Mutex
+	modTime time.Time
+	keys    map[string]*rsa.PublicKey
+}
+
+func newFileKeySet(path string) (*fileKeySet, error) {
+	s := &fileKeySet{path: path}
+	if err := s.reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *fileKeySet) reload() error {
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return err
+	}
+	if info.ModTime().Equal(s.modTime) && s.keys != nil {
+		return nil
+	}
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return err
+	}
+	keys, err := parseJWKS(data)
+	if err != nil {
+		return err
+	}
+	s.keys, s.modTime = keys, info.ModTime()
+	return nil
+}
+
+func (s *fileKeySet) Key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.reload(); err != nil {
+		// Keep serving the last good key set if the file is mid-rewrite.
+		if s.keys == nil {
+			return nil, err
+		}
+	}
+	if key := lookupKey(s.keys, kid); key != nil {
+		return key, nil
+	}
+	return nil, errUnknownKey
+}
+
+// remoteKeySet serves keys from a JWKS URL. The document is cached for
+// refresh; a token signed with an unknown key triggers an early refetch (at
+// most once per minRefetch, capped at refresh) so rotated keys are picked up
+// promptly.
+type remoteKeySet struct {
+	url        string
+	client     *http.Client
+	refresh    time.Duration
+	minRefetch time.Duration
+	logger     internal.Logger
+	// group shares one fetch among concurrent callers; mu is held only to
+	// read or swap the cached keys, never across the request.
+	group singleflight.Group
+
+	mu        sync.Mutex
+	keys      map[string]*rsa.PublicKey
+	fetchedAt time.Time
+}
+
+func newRemoteKeySet(url string, refresh time.Duration, logger internal.Logger) *remoteKeySet {
+	return &remoteKeySet{
+		url:        url,
+		client:     &http.Client{Timeout: 5 * time.Second},
+		refresh:    refresh,
+		minRefetch: min(30*time.Second, refresh),
+		logger:     logger,
+	}
+}
+
+func (s *remoteKeySet) Key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	keys, fetchedAt := s.cached()
+	if keys == nil || time.Since(fetchedAt) > s.refresh {
+		fresh, err := s.fetch(ctx)
+		if err != nil && keys == nil {
+			return nil, err
+		}
+		if err == nil {
+			keys = fresh
+		}
+	}
+	if key := lookupKey(keys, kid); key != nil {
+		return key, nil
+	}
+	if _, fetchedAt := s.cached(); time.Since(fetchedAt) > s.minRefetch {
+		fresh, err := s.fetch(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if key := lookupKey(fresh, kid); key != nil {
+			return key, nil
+		}
+	}
+	return nil, errUnknownKey
+}
+
+func (s *remoteKeySet) cached() (map[string]*rsa.PublicKey, time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.keys, s.fetchedAt
+}
+
+// fetch replaces the cached keys and returns them. Concurrent callers share
+// one request, which runs detached from ctx so that the first caller going
+// away does not fail the others; the client's timeout still bounds it. On
+// failure the previous keys stay in use.
+func (s *remoteKeySet) fetch(ctx context.Context) (map[string]*rsa.PublicKey, error) {
+	ch := s.group.DoChan("jwks", func() (interface{}, error) {
+		// Failed attempts count too, so an unreachable endpoint is not hammered.
+		s.mu.Lock()
+		s.fetchedAt = time.Now()
+		s.mu.Unlock()
+		keys, err := s.download(context.WithoutCancel(ctx))
+		if err != nil {
+			return nil, err
+		}
+		s.mu.Lock()
+		s.keys = keys
+		s.mu.Unlock()
+		return keys, nil
+	})
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case res := <-ch:
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		return res.Val.(map[string]*rsa.PublicKey), nil
+	}
+}
+
+// download fetches and parses the JWKS document.
+func (s *remoteKeySet) download(ctx context.Context) (map[string]*rsa.PublicKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		s.logger.Errorf("jwks: failed to fetch %s: %v", s.url, err)
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		s.logger.Errorf("jwks: %s returned %d", s.url, resp.StatusCode)
+		return nil, fmt.Errorf("jwks: %s returned %d", s.url, resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	keys, err := parseJWKS(data)
+	if err != nil {
+		s.logger.Errorf("jwks: invalid document from %s: %v", s.url, err)
+		return nil, err
+	}
+	return keys, nil
+}
@@ -0,0 +1,129 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/yourname/sleeptracker/internal"
+)
+
+// JWTConfig selects where signing keys come from and which claims a token
+// must carry. Secret enables HS256; JWKSFile or JWKSURL enables RS256.
+type JWTConfig struct {
+	Secret      string
+	JWKSFile    string
+	JWKSURL     string
+	JWKSRefresh time.Duration // how long a fetched JWKS document is cached
+	Issuer      string        // required "iss" when set
+	Audience    string        // required "aud" when set
+	Leeway      time.Duration // clock skew allowed for exp and nbf
+	// RoleClaim takes the user's role from the "role" claim. Otherwise every
+	// token's user has RoleUser, since any issuer could claim admin.
+	RoleClaim bool
+}
+
+// JWTAuthProvider validates signed bearer tokens without calling out to an
+// auth service (beyond fetching a JWKS document). The "sub" claim becomes the
+// user ID and the optional "name" claim the user name. The "role" claim is
+// only read when JWTConfig.RoleClaim is set.
+type JWTAuthProvider struct {
+	secret    []byte
+	keys      keySet
+	parser    *jwt.Parser
+	roleClaim bool
+	logger    internal.Logger
+}
+
+type jwtClaims struct {
+	Name string `json:"name,omitempty"`
+	Role string `json:"role,omitempty"`
+	jwt.RegisteredClaims
+}
+
+func NewJWTAuthProvider(cfg JWTConfig, logger internal.Logger) (*JWTAuthProvider, error) {
+	p := &JWTAuthProvider{roleClaim: cfg.RoleClaim, logger: logger}
+	var methods []string
+	if cfg.Secret != "" {
+		p.secret = []byte(cfg.Secret)
+		methods = append(methods, jwt.SigningMethodHS256.Alg())
+	}
+	switch {
+	case cfg.JWKSFile != "" && cfg.JWKSURL != "":
+		return nil, errors.New("jwt: set either a JWKS file or a JWKS URL, not both")
+	case cfg.JWKSFile != "":
+		keys, err := newFileKeySet(cfg.JWKSFile)
+		if err != nil {
+			return nil, err
+		}
+		p.keys = keys
+	case cfg.JWKSURL != "":
+		refresh := cfg.JWKSRefresh
+		if refresh <= 0 {
+			refresh = time.Hour
+		}
+		p.keys = newRemoteKeySet(cfg.JWKSURL, refresh, logger)
+	}
+	if p.keys != nil {
+		methods = append(methods, jwt.SigningMethodRS256.Alg())
+	}
+	if len(methods) == 0 {
+		return nil, errors.New("jwt: a secret or a JWKS source is required")
+	}
+
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods(methods),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(cfg.Leeway),
+	}
+	if cfg.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(cfg.Issuer))
+	}
+	if cfg.Audience != "" {
+		opts = append(opts, jwt.WithAudience(cfg.Audience))
+	}
+	p.parser = jwt.NewParser(opts...)
+	return p, nil
+}
+
+// Authenticate validates the token locally; ctx only bounds a JWKS fetch, if
+// one is needed. Tokens that are not JWTs at all are ErrUnrecognized.
+func (a *JWTAuthProvider) Authenticate(ctx context.Context, creds Credentials) (*internal.User, error) {
+	token := creds.Token
+	if strings.Count(token, ".") != 2 {
+		return nil, ErrUnrecognized
+	}
+	var claims jwtClaims
+	_, err := a.parser.ParseWithClaims(token, &claims, func(t *jwt.Token) (interface{}, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); ok {
+			return a.secret, nil
+		}
+		kid, _ := t.Header["kid"].(string)
+		return a.keys.Key(ctx, kid)
+	})
+	if err != nil {
+		a.logger.Warnf("invalid jwt: %v", err)
+		return nil, errors.New("invalid token")
+	}
+	if claims.Subject == "" {
+		a.logger.Warnf("invalid jwt: missing sub claim")
+		return nil, errors.New("invalid token")
+	}
+	return &internal.User{ID: claims.Subject, Token: token, Name: claims.Name, Role: a.role(claims.Role)}, nil
+}
+
+// role is the role a token's user gets: the claimed one if role claims are
+// trusted and it is a known role, RoleUser otherwise.
+func (a *JWTAuthProvider) role(claimed string) string {
+	if !a.roleClaim || claimed == "" {
+		return internal.RoleUser
+	}
+	switch claimed {
+	case internal.RoleUser, internal.RoleViewer, internal.RoleAdmin:
+		return claimed
+	}
+	a.logger.Warnf("jwt claims unknown role %q; using %q", claimed, internal.RoleUser)
+	return internal.RoleUser
+}
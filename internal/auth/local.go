@@ -2,27 +2,22 @@ package auth
 
 import (
 	"context"
-	"errors"
 
 	"github.com/yourname/sleeptracker/internal"
 )
 
+// LocalAuthProvider accepts a single static token as the demo user. It is
+// meant for development.
 type LocalAuthProvider struct {
 	Token  string
 	logger internal.Logger
 }
 
-func (a *LocalAuthProvider) ValidateTokenLocal(token string) (*internal.User, error) {
-	if token == a.Token {
-		return &internal.User{ID: "u1", Token: a.Token, Name: "Demo User"}, nil
+func (a *LocalAuthProvider) Authenticate(ctx context.Context, creds Credentials) (*internal.User, error) {
+	if a.Token != "" && creds.Token == a.Token {
+		return &internal.User{ID: "u1", Token: a.Token, Name: "Demo User", Role: internal.RoleUser}, nil
 	}
-	a.logger.Warnf("invalid token: %s", token)
-	return nil, errors.New("invalid token")
-}
-
-func (a *LocalAuthProvider) ValidateTokenRemote(ctx context.Context, token string) (*internal.User, error) {
-	a.logger.Warnf("ValidateTokenRemote not implemented in LocalAuthProvider")
-	return nil, errors.New("not implemented in LocalAuthProvider")
+	return nil, ErrUnrecognized
 }
 
 func NewLocalAuthProvider(token string, logger internal.Logger) *LocalAuthProvider {
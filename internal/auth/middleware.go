@@ -1,32 +1,41 @@
 package auth
 
 import (
-	"net/http"
 	"strings"
 
 	"github.com/gin-gonic/gin"
-	"github.com/yourname/sleeptracker/internal/config"
+	"github.com/yourname/sleeptracker/internal"
+	"github.com/yourname/sleeptracker/internal/response"
 )
 
-func AuthMiddleware(provider Provider, cfg *config.Config) gin.HandlerFunc {
+var errNoCredentials = internal.NewError(internal.ErrUnauthorized, "missing or invalid credentials")
+
+// FailureHook is called with the provider's error when a request presents
+// credentials that are not accepted, before the 401 is sent.
+type FailureHook func(c *gin.Context, err error)
+
+// AuthMiddleware authenticates the request with provider. onFailure may be
+// nil.
+func AuthMiddleware(provider Provider, onFailure FailureHook) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		header := c.GetHeader("Authorization")
-		if strings.HasPrefix(header, "Bearer ") {
-			token := strings.TrimPrefix(header, "Bearer ")
-			token = strings.TrimSpace(token)
-			var user interface{}
-			var err error
-			if cfg.Env == "development" {
-				user, err = provider.ValidateTokenLocal(token)
-			} else {
-				user, err = provider.ValidateTokenRemote(c.Request.Context(), token)
-			}
+		token := ""
+		if header := c.GetHeader("Authorization"); strings.HasPrefix(header, "Bearer ") {
+			token = strings.TrimSpace(strings.TrimPrefix(header, "Bearer "))
+		} else if key := strings.TrimSpace(c.GetHeader("X-API-Key")); strings.HasPrefix(key, APIKeyPrefix) {
+			// API keys, and only API keys, may also be sent in their own header.
+			token = key
+		}
+		if token != "" {
+			user, err := provider.Authenticate(c.Request.Context(), Credentials{Token: token})
 			if err == nil {
 				c.Set("user", user)
 				c.Next()
 				return
 			}
+			if onFailure != nil {
+				onFailure(c, err)
+			}
 		}
-		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		response.Error(c, errNoCredentials, "Unauthorized")
 	}
 }
@@ -2,11 +2,52 @@ package auth
 
 import (
 	"context"
+	"errors"
 
 	"github.com/yourname/sleeptracker/internal"
 )
 
+// Credentials are what a request presented to authenticate.
+type Credentials struct {
+	// Token is the bearer token or API key.
+	Token string
+}
+
+// Provider authenticates a request's credentials.
 type Provider interface {
-	ValidateTokenLocal(token string) (*internal.User, error)
-	ValidateTokenRemote(ctx context.Context, token string) (*internal.User, error)
+	Authenticate(ctx context.Context, creds Credentials) (*internal.User, error)
+}
+
+// ErrUnrecognized is returned by a provider for credentials it does not
+// handle, such as a token it never issued.
+var ErrUnrecognized = errors.New("credentials not recognized")
+
+// ChainProvider tries its providers in order and accepts the first user one
+// of them returns.
+type ChainProvider struct {
+	providers []Provider
+}
+
+func NewChainProvider(providers ...Provider) *ChainProvider {
+	return &ChainProvider{providers: providers}
+}
+
+// Authenticate returns the first successful result. If every provider fails,
+// the first error other than ErrUnrecognized is returned, so that a revoked
+// session or an unreachable auth service is not reported as an unknown token.
+func (p *ChainProvider) Authenticate(ctx context.Context, creds Credentials) (*internal.User, error) {
+	var firstErr error
+	for _, provider := range p.providers {
+		user, err := provider.Authenticate(ctx, creds)
+		if err == nil {
+			return user, nil
+		}
+		if firstErr == nil && !errors.Is(err, ErrUnrecognized) {
+			firstErr = err
+		}
+	}
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return nil, ErrUnrecognized
 }
@@ -1,56 +1,293 @@
 package auth
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"math/rand/v2"
 	"net/http"
-	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/yourname/sleeptracker/internal"
+	"github.com/yourname/sleeptracker/internal/cache"
+	"golang.org/x/sync/singleflight"
 )
 
+// RemoteConfig tunes how RemoteAuthProvider calls the auth service. Zero
+// values take the defaults noted on each field.
+type RemoteConfig struct {
+	URL              string
+	Timeout          time.Duration // per attempt; 2s
+	CacheSize        int           // cached results; 10000
+	CacheTTL         time.Duration // how long a valid token is trusted; 1m
+	NegativeCacheTTL time.Duration // how long a rejected token stays rejected; 10s
+	Retries          int           // extra attempts after a failed call; 2
+	RetryBackoff     time.Duration // first retry delay, doubled each time; 100ms
+	BreakerThreshold int           // consecutive failures that open the breaker; 5
+	BreakerCooldown  time.Duration // how long the breaker stays open; 30s
+}
+
+func (c *RemoteConfig) setDefaults() {
+	if c.Timeout <= 0 {
+		c.Timeout = 2 * time.Second
+	}
+	if c.CacheSize <= 0 {
+		c.CacheSize = 10000
+	}
+	if c.CacheTTL <= 0 {
+		c.CacheTTL = time.Minute
+	}
+	if c.NegativeCacheTTL <= 0 {
+		c.NegativeCacheTTL = 10 * time.Second
+	}
+	if c.Retries < 0 {
+		c.Retries = 0
+	}
+	if c.RetryBackoff <= 0 {
+		c.RetryBackoff = 100 * time.Millisecond
+	}
+	if c.BreakerThreshold <= 0 {
+		c.BreakerThreshold = 5
+	}
+	if c.BreakerCooldown <= 0 {
+		c.BreakerCooldown = 30 * time.Second
+	}
+}
+
+var (
+	// ErrAuthUnavailable is returned while the auth service cannot be reached
+	// or the circuit breaker is open.
+	ErrAuthUnavailable = errors.New("auth service unavailable")
+	errTokenRejected   = fmt.Errorf("%w: rejected by auth service", ErrUnrecognized)
+)
+
+// RemoteAuthProvider validates tokens against an external auth service.
+// Results are cached by token hash, both for valid tokens and for tokens the
+// service rejected, and concurrent lookups of the same token share one call.
 type RemoteAuthProvider struct {
-	AuthServiceURL string
-	HTTPClient     *http.Client
-	logger         internal.Logger
+	cfg      RemoteConfig
+	client   *http.Client
+	valid    *cache.LRU[string, internal.User]
+	rejected *cache.LRU[string, struct{}]
+	group    singleflight.Group
+	breaker  *breaker
+	metrics  remoteMetrics
+	logger   internal.Logger
+}
+
+func NewRemoteAuthProvider(cfg RemoteConfig, logger internal.Logger) *RemoteAuthProvider {
+	cfg.setDefaults()
+	return &RemoteAuthProvider{
+		cfg:      cfg,
+		client:   &http.Client{},
+		valid:    cache.New[string, internal.User](cfg.CacheSize, cfg.CacheTTL),
+		rejected: cache.New[string, struct{}](cfg.CacheSize, cfg.NegativeCacheTTL),
+		breaker:  newBreaker(cfg.BreakerThreshold, cfg.BreakerCooldown),
+		logger:   logger,
+	}
+}
+
+// Authenticate returns ErrUnrecognized for tokens the service rejects and
+// ErrAuthUnavailable when it cannot be reached.
+func (a *RemoteAuthProvider) Authenticate(ctx context.Context, creds Credentials) (*internal.User, error) {
+	token := creds.Token
+	key := HashToken(token)
+	if user, ok := a.valid.Get(key); ok {
+		user.Token = token
+		return &user, nil
+	}
+	if _, ok := a.rejected.Get(key); ok {
+		return nil, errTokenRejected
+	}
+
+	// The shared lookup must not fail for every waiter because the first
+	// caller went away, so it runs detached from ctx; each attempt is still
+	// bounded by the configured timeout.
+	ch := a.group.DoChan(key, func() (interface{}, error) {
+		return a.lookup(context.WithoutCancel(ctx), key, token)
+	})
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case res := <-ch:
+		if res.Shared {
+			a.metrics.sharedLookups.Add(1)
+		}
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		user := res.Val.(internal.User)
+		user.Token = token
+		return &user, nil
+	}
 }
 
-func (a *RemoteAuthProvider) ValidateTokenLocal(token string) (*internal.User, error) {
-	return nil, errors.New("not implemented in RemoteAuthProvider")
+// lookup calls the auth service, retrying failed calls, and caches the
+// outcome.
+func (a *RemoteAuthProvider) lookup(ctx context.Context, key, token string) (internal.User, error) {
+	if !a.breaker.allow(time.Now()) {
+		a.metrics.breakerRejections.Add(1)
+		return internal.User{}, ErrAuthUnavailable
+	}
+
+	var err error
+	for attempt := 0; attempt <= a.cfg.Retries; attempt++ {
+		if attempt > 0 {
+			a.metrics.retries.Add(1)
+			// Exponential backoff with up to 50% jitter.
+			delay := a.cfg.RetryBackoff << (attempt - 1)
+			delay += rand.N(delay/2 + 1)
+			select {
+			case <-ctx.Done():
+				a.breaker.failure(time.Now())
+				return internal.User{}, ctx.Err()
+			case <-time.After(delay):
+			}
+		}
+
+		var user internal.User
+		user, err = a.call(ctx, token)
+		switch {
+		case err == nil:
+			a.breaker.success()
+			a.valid.Set(key, user)
+			return user, nil
+		case errors.Is(err, errTokenRejected):
+			// The service answered; only its verdict was negative.
+			a.breaker.success()
+			a.metrics.rejections.Add(1)
+			a.rejected.Set(key, struct{}{})
+			return internal.User{}, err
+		}
+		a.metrics.failures.Add(1)
+		a.logger.Warnf("auth service call failed (attempt %d of %d): %v", attempt+1, a.cfg.Retries+1, err)
+	}
+
+	a.breaker.failure(time.Now())
+	a.logger.Errorf("auth service unavailable: %v", err)
+	return internal.User{}, fmt.Errorf("%w: %v", ErrAuthUnavailable, err)
 }
 
-func (a *RemoteAuthProvider) ValidateTokenRemote(ctx context.Context, token string) (*internal.User, error) {
-	body := `{"token":"` + token + `"}`
-	req, err := http.NewRequestWithContext(ctx, "POST", a.AuthServiceURL, strings.NewReader(body))
+// call makes one request. It returns errTokenRejected when the service
+// answers 401 or 403, and another error when the call itself failed.
+func (a *RemoteAuthProvider) call(ctx context.Context, token string) (internal.User, error) {
+	ctx, cancel := context.WithTimeout(ctx, a.cfg.Timeout)
+	defer cancel()
+
+	body, err := json.Marshal(struct {
+		Token string `json:"token"`
+	}{token})
 	if err != nil {
-		a.logger.Errorf("failed to create request: %v", err)
-		return nil, err
+		return internal.User{}, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return internal.User{}, err
 	}
 	req.Header.Set("Content-Type", "application/json")
-	resp, err := a.HTTPClient.Do(req)
+
+	start := time.Now()
+	resp, err := a.client.Do(req)
+	a.metrics.observe(time.Since(start))
 	if err != nil {
-		a.logger.Errorf("failed to call auth service: %v", err)
-		return nil, err
+		return internal.User{}, err
 	}
 	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusOK {
-		a.logger.Errorf("auth service returned %d", resp.StatusCode)
-		return nil, errors.New("auth service returned non-200")
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return internal.User{}, errTokenRejected
+	case resp.StatusCode != http.StatusOK:
+		return internal.User{}, fmt.Errorf("auth service returned %d", resp.StatusCode)
 	}
 	var user internal.User
 	if err := json.NewDecoder(resp.Body).Decode(&user); err != nil {
-		a.logger.Errorf("failed to decode auth response: %v", err)
-		return nil, err
+		return internal.User{}, fmt.Errorf("invalid auth service response: %w", err)
+	}
+	if user.ID == "" {
+		return internal.User{}, errors.New("auth service response has no user id")
 	}
-	return &user, nil
+	return user, nil
 }
 
-func NewRemoteAuthProvider(url string, logger internal.Logger) *RemoteAuthProvider {
-	return &RemoteAuthProvider{
-		AuthServiceURL: url,
-		HTTPClient:     &http.Client{Timeout: 5 * time.Second},
-		logger:         logger,
+// latencyBuckets are the upper bounds of the call latency histogram.
+var latencyBuckets = []time.Duration{
+	10 * time.Millisecond, 50 * time.Millisecond, 100 * time.Millisecond,
+	250 * time.Millisecond, 500 * time.Millisecond, time.Second, 2500 * time.Millisecond,
+}
+
+type remoteMetrics struct {
+	calls             atomic.Uint64
+	failures          atomic.Uint64
+	retries           atomic.Uint64
+	rejections        atomic.Uint64
+	breakerRejections atomic.Uint64
+	sharedLookups     atomic.Uint64
+	latencyTotalNanos atomic.Uint64
+	latencyMaxNanos   atomic.Uint64
+	latencyBuckets    [8]atomic.Uint64 // one per bucket plus +Inf
+}
+
+func (m *remoteMetrics) observe(d time.Duration) {
+	m.calls.Add(1)
+	m.latencyTotalNanos.Add(uint64(d))
+	for {
+		max := m.latencyMaxNanos.Load()
+		if uint64(d) <= max || m.latencyMaxNanos.CompareAndSwap(max, uint64(d)) {
+			break
+		}
+	}
+	i := 0
+	for i < len(latencyBuckets) && d > latencyBuckets[i] {
+		i++
+	}
+	m.latencyBuckets[i].Add(1)
+}
+
+// RemoteAuthStats is a snapshot of RemoteAuthProvider's counters, suitable
+// for publishing with expvar.
+type RemoteAuthStats struct {
+	Calls             uint64            `json:"calls"`
+	Failures          uint64            `json:"failures"`
+	Retries           uint64            `json:"retries"`
+	Rejections        uint64            `json:"rejections"`
+	BreakerState      string            `json:"breaker_state"`
+	BreakerTrips      uint64            `json:"breaker_trips"`
+	BreakerRejections uint64            `json:"breaker_rejections"`
+	SharedLookups     uint64            `json:"shared_lookups"`
+	LatencyAvgMillis  float64           `json:"latency_avg_ms"`
+	LatencyMaxMillis  float64           `json:"latency_max_ms"`
+	LatencyBuckets    map[string]uint64 `json:"latency_buckets"` // keyed by upper bound
+	ValidCache        cache.Stats       `json:"valid_cache"`
+	RejectedCache     cache.Stats       `json:"rejected_cache"`
+}
+
+func (a *RemoteAuthProvider) Stats() RemoteAuthStats {
+	m := &a.metrics
+	state, trips := a.breaker.stats()
+	s := RemoteAuthStats{
+		Calls:             m.calls.Load(),
+		Failures:          m.failures.Load(),
+		Retries:           m.retries.Load(),
+		Rejections:        m.rejections.Load(),
+		BreakerState:      state,
+		BreakerTrips:      trips,
+		BreakerRejections: m.breakerRejections.Load(),
+		SharedLookups:     m.sharedLookups.Load(),
+		LatencyMaxMillis:  float64(m.latencyMaxNanos.Load()) / float64(time.Millisecond),
+		LatencyBuckets:    make(map[string]uint64, len(latencyBuckets)+1),
+		ValidCache:        a.valid.Stats(),
+		RejectedCache:     a.rejected.Stats(),
+	}
+	if s.Calls > 0 {
+		s.LatencyAvgMillis = float64(m.latencyTotalNanos.Load()) / float64(s.Calls) / float64(time.Millisecond)
+	}
+	for i, bound := range latencyBuckets {
+		s.LatencyBuckets[bound.String()] = m.latencyBuckets[i].Load()
 	}
+	s.LatencyBuckets["+Inf"] = m.latencyBuckets[len(latencyBuckets)].Load()
+	return s
 }
@@ -0,0 +1,54 @@
+package auth
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yourname/sleeptracker/internal"
+	"github.com/yourname/sleeptracker/internal/response"
+)
+
+const (
+	ScopeSleepRead  = "sleep:read"
+	ScopeSleepWrite = "sleep:write"
+	ScopeGoalsRead  = "goals:read"
+	ScopeGoalsWrite = "goals:write"
+	ScopeStatsRead  = "stats:read"
+	// ScopeAccount covers sessions and API key management. It is never
+	// granted to API keys, so a leaked key cannot mint more keys.
+	ScopeAccount = "account"
+	// ScopeAdmin covers operational endpoints and is never granted to API keys.
+	ScopeAdmin = "admin"
+)
+
+// APIKeyScopes are the scopes an API key may be granted.
+var APIKeyScopes = []string{ScopeSleepRead, ScopeSleepWrite, ScopeGoalsRead, ScopeGoalsWrite, ScopeStatsRead}
+
+// RequireScope rejects requests whose credential does not grant scope. It must
+// run after AuthMiddleware.
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if u, ok := c.Value("user").(*internal.User); ok && u.HasScope(scope) {
+			c.Next()
+			return
+		}
+		response.Error(c, internal.NewError(internal.ErrForbidden, "credential lacks scope "+scope), "Forbidden")
+	}
+}
+
+// RequireRole rejects requests from users whose role is not one of roles. It
+// must run after AuthMiddleware.
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if u, ok := c.Value("user").(*internal.User); ok {
+			role := u.EffectiveRole()
+			for _, r := range roles {
+				if role == r {
+					c.Next()
+					return
+				}
+			}
+		}
+		response.Error(c, internal.NewError(internal.ErrForbidden, "requires role "+strings.Join(roles, " or ")), "Forbidden")
+	}
+}
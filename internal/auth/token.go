@@ -0,0 +1,76 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/yourname/sleeptracker/internal"
+	"github.com/yourname/sleeptracker/internal/storage"
+)
+
+// NewOpaqueToken returns a random bearer token and the hash to store for it.
+func NewOpaqueToken() (token, hash string, err error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	token = base64.RawURLEncoding.EncodeToString(buf)
+	return token, HashToken(token), nil
+}
+
+// HashToken is the form in which opaque tokens are stored and looked up.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// TokenAuthProvider accepts access tokens issued at login, as long as their
+// session has not been revoked.
+type TokenAuthProvider struct {
+	users    storage.UserRepository
+	sessions storage.SessionRepository
+	logger   internal.Logger
+}
+
+func NewTokenAuthProvider(users storage.UserRepository, sessions storage.SessionRepository, logger internal.Logger) *TokenAuthProvider {
+	return &TokenAuthProvider{users: users, sessions: sessions, logger: logger}
+}
+
+func (a *TokenAuthProvider) Authenticate(ctx context.Context, creds Credentials) (*internal.User, error) {
+	token := creds.Token
+	issued, err := a.users.GetAccessToken(ctx, HashToken(token))
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, ErrUnrecognized
+	}
+	if err != nil {
+		a.logger.Errorf("failed to look up access token: %v", err)
+		return nil, err
+	}
+	if time.Now().After(issued.ExpiresAt) {
+		a.logger.Warnf("expired access token for user %s", issued.UserID)
+		return nil, errors.New("token expired")
+	}
+	if issued.SessionID != "" {
+		session, err := a.sessions.GetSession(ctx, issued.SessionID)
+		if err != nil || !session.Active(time.Now()) {
+			a.logger.Warnf("access token for revoked or expired session %s", issued.SessionID)
+			return nil, errors.New("session revoked")
+		}
+	}
+	user, err := a.users.GetUserByID(ctx, issued.UserID)
+	if err != nil {
+		a.logger.Errorf("access token for unknown user %s: %v", issued.UserID, err)
+		return nil, errors.New("invalid token")
+	}
+	if user.DisabledAt != nil {
+		a.logger.Warnf("access token for disabled user %s", user.ID)
+		return nil, errors.New("account disabled")
+	}
+	user.Token = token
+	return user, nil
+}
@@ -0,0 +1,437 @@
+// Package backup takes compressed point-in-time snapshots of file-based
+// storage, prunes them by retention policy and restores them.
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yourname/sleeptracker/internal"
+)
+
+const (
+	manifestName   = "manifest.json"
+	snapshotPrefix = "snapshot-"
+	snapshotSuffix = ".tar.gz"
+	timeLayout     = "20060102T150405Z"
+)
+
+// Source is a store whose state lives in a set of JSON files: JSON arrays,
+// or JSON Lines for files named *.jsonl.
+type Source interface {
+	// Flush writes any pending in-memory changes to the data files.
+	Flush() error
+	DataFiles() []string
+}
+
+type Config struct {
+	Dir        string
+	KeepHourly int // newest snapshot of each of the N most recent hours
+	KeepDaily  int // newest snapshot of each of the N most recent days
+}
+
+type Manifest struct {
+	CreatedAt time.Time      `json:"created_at"`
+	Files     []ManifestFile `json:"files"`
+}
+
+type ManifestFile struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+type Snapshot struct {
+	Name      string    `json:"name"`
+	Path      string    `json:"-"`
+	CreatedAt time.Time `json:"created_at"`
+	Size      int64     `json:"size"`
+}
+
+type Snapshotter struct {
+	source Source
+	cfg    Config
+	logger internal.Logger
+	mu     sync.Mutex
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+func NewSnapshotter(source Source, cfg Config, logger internal.Logger) *Snapshotter {
+	return &Snapshotter{source: source, cfg: cfg, logger: logger}
+}
+
+// Snapshot flushes the source, archives its data files and applies retention.
+func (s *Snapshotter) Snapshot() (*Snapshot, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.source.Flush(); err != nil {
+		return nil, fmt.Errorf("backup: flush storage: %w", err)
+	}
+	if err := os.MkdirAll(s.cfg.Dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	name := snapshotPrefix + now.Format(timeLayout) + snapshotSuffix
+	path := filepath.Join(s.cfg.Dir, name)
+	if _, err := os.Stat(path); err == nil {
+		// Two snapshots within the same second: the first one is current.
+		return statSnapshot(path, now)
+	}
+	if err := writeArchive(path, now, s.source.DataFiles()); err != nil {
+		return nil, err
+	}
+	s.logger.Infof("backup: wrote snapshot %s", name)
+
+	if err := s.prune(); err != nil {
+		s.logger.Errorf("backup: failed to apply retention: %v", err)
+	}
+	return statSnapshot(path, now)
+}
+
+func writeArchive(path string, createdAt time.Time, files []string) error {
+	tmp := path + ".tmp"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	err = func() error {
+		gz := gzip.NewWriter(out)
+		tw := tar.NewWriter(gz)
+		manifest := Manifest{CreatedAt: createdAt}
+		for _, file := range files {
+			data, err := os.ReadFile(file)
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			name := filepath.Base(file)
+			if err := addTarFile(tw, name, data, createdAt); err != nil {
+				return err
+			}
+			sum := sha256.Sum256(data)
+			manifest.Files = append(manifest.Files, ManifestFile{Name: name, Size: int64(len(data)), SHA256: hex.EncodeToString(sum[:])})
+		}
+		data, err := json.MarshalIndent(manifest, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := addTarFile(tw, manifestName, data, createdAt); err != nil {
+			return err
+		}
+		if err := tw.Close(); err != nil {
+			return err
+		}
+		if err := gz.Close(); err != nil {
+			return err
+		}
+		return out.Sync()
+	}()
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func addTarFile(tw *tar.Writer, name string, data []byte, modTime time.Time) error {
+	hdr := &tar.Header{Name: name, Mode: 0o644, Size: int64(len(data)), ModTime: modTime}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err := tw.Write(data)
+	return err
+}
+
+func statSnapshot(path string, createdAt time.Time) (*Snapshot, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Snapshot{Name: filepath.Base(path), Path: path, CreatedAt: createdAt, Size: info.Size()}, nil
+}
+
+// List returns the snapshots in the backup directory, newest first.
+func (s *Snapshotter) List() ([]Snapshot, error) {
+	return List(s.cfg.Dir)
+}
+
+func List(dir string) ([]Snapshot, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return []Snapshot{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	snapshots := []Snapshot{}
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, snapshotPrefix) || !strings.HasSuffix(name, snapshotSuffix) {
+			continue
+		}
+		createdAt, err := time.Parse(timeLayout, strings.TrimSuffix(strings.TrimPrefix(name, snapshotPrefix), snapshotSuffix))
+		if err != nil {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, Snapshot{Name: name, Path: filepath.Join(dir, name), CreatedAt: createdAt, Size: info.Size()})
+	}
+	sort.Slice(snapshots, func(i, j int) bool { return snapshots[i].CreatedAt.After(snapshots[j].CreatedAt) })
+	return snapshots, nil
+}
+
+// prune removes snapshots outside the retention policy.
+func (s *Snapshotter) prune() error {
+	snapshots, err := s.List()
+	if err != nil {
+		return err
+	}
+	for _, snap := range Expired(snapshots, s.cfg.KeepHourly, s.cfg.KeepDaily) {
+		if err := os.Remove(snap.Path); err != nil {
+			return err
+		}
+		s.logger.Infof("backup: removed expired snapshot %s", snap.Name)
+	}
+	return nil
+}
+
+// Expired returns the snapshots (given newest first) that retention drops.
+// The newest snapshot is always kept, as is the newest snapshot in each of the
+// keepHourly most recent hours and keepDaily most recent days that have one.
+func Expired(snapshots []Snapshot, keepHourly, keepDaily int) []Snapshot {
+	keep := make(map[string]bool)
+	if len(snapshots) > 0 {
+		keep[snapshots[0].Name] = true
+	}
+	mark := func(n int, bucket func(time.Time) time.Time) {
+		seen := make(map[time.Time]bool)
+		for _, snap := range snapshots {
+			b := bucket(snap.CreatedAt)
+			if seen[b] {
+				continue
+			}
+			if len(seen) == n {
+				return
+			}
+			seen[b] = true
+			keep[snap.Name] = true
+		}
+	}
+	mark(keepHourly, func(t time.Time) time.Time { return t.UTC().Truncate(time.Hour) })
+	mark(keepDaily, func(t time.Time) time.Time { return t.UTC().Truncate(24 * time.Hour) })
+
+	var expired []Snapshot
+	for _, snap := range snapshots {
+		if !keep[snap.Name] {
+			expired = append(expired, snap)
+		}
+	}
+	return expired
+}
+
+// Start takes a snapshot every interval until Stop is called.
+func (s *Snapshotter) Start(interval time.Duration) {
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+	go func() {
+		defer close(s.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if _, err := s.Snapshot(); err != nil {
+					s.logger.Errorf("backup: scheduled snapshot failed: %v", err)
+				}
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+}
+
+func (s *Snapshotter) Stop() {
+	if s.stop == nil {
+		return
+	}
+	close(s.stop)
+	<-s.done
+	s.stop = nil
+}
+
+// Restore validates a snapshot and swaps its files in place of targets, which
+// are matched to archive entries by base name. Extracted files are staged in
+// a directory next to the first target and passed to validate before any
+// target is touched. Targets missing from the snapshot did not exist when it
+// was taken and are removed. Replaced files are kept with a .pre-restore
+// suffix. If a swap fails, the ones already made are undone, so the targets
+// are either all restored or left as they were.
+func Restore(snapshotPath string, targets []string, validate func(stagingDir string) error) error {
+	if len(targets) == 0 {
+		return errors.New("backup: no restore targets")
+	}
+	staging, err := os.MkdirTemp(filepath.Dir(targets[0]), ".restore-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(staging)
+
+	manifest, err := extract(snapshotPath, staging)
+	if err != nil {
+		return fmt.Errorf("backup: invalid snapshot: %w", err)
+	}
+	if err := verify(manifest, staging); err != nil {
+		return fmt.Errorf("backup: invalid snapshot: %w", err)
+	}
+	if validate != nil {
+		if err := validate(staging); err != nil {
+			return fmt.Errorf("backup: snapshot failed validation: %w", err)
+		}
+	}
+
+	var undo []func() error
+	rollback := func(err error) error {
+		for i := len(undo) - 1; i >= 0; i-- {
+			if undoErr := undo[i](); undoErr != nil {
+				err = errors.Join(err, fmt.Errorf("backup: rolling back restore: %w", undoErr))
+			}
+		}
+		return err
+	}
+	for _, target := range targets {
+		if _, err := os.Stat(target); err == nil {
+			if err := os.Rename(target, target+".pre-restore"); err != nil {
+				return rollback(err)
+			}
+			undo = append(undo, func() error { return os.Rename(target+".pre-restore", target) })
+		}
+		staged := filepath.Join(staging, filepath.Base(target))
+		if _, err := os.Stat(staged); errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err := os.Rename(staged, target); err != nil {
+			return rollback(err)
+		}
+		undo = append(undo, func() error { return os.Remove(target) })
+	}
+	return nil
+}
+
+func extract(snapshotPath, dir string) (*Manifest, error) {
+	f, err := os.Open(snapshotPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+
+	var manifest *Manifest
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		name := filepath.Base(hdr.Name)
+		if hdr.Typeflag != tar.TypeReg || name != hdr.Name || strings.HasPrefix(name, ".") {
+			return nil, fmt.Errorf("unexpected entry %q", hdr.Name)
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, err
+		}
+		if name == manifestName {
+			manifest = &Manifest{}
+			if err := json.Unmarshal(data, manifest); err != nil {
+				return nil, fmt.Errorf("manifest: %w", err)
+			}
+			continue
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0o644); err != nil {
+			return nil, err
+		}
+	}
+	if manifest == nil {
+		return nil, errors.New("missing manifest")
+	}
+	return manifest, nil
+}
+
+func verify(manifest *Manifest, dir string) error {
+	listed := make(map[string]bool)
+	for _, file := range manifest.Files {
+		listed[file.Name] = true
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if !listed[e.Name()] {
+			return fmt.Errorf("%s: not listed in manifest", e.Name())
+		}
+	}
+	for _, file := range manifest.Files {
+		data, err := os.ReadFile(filepath.Join(dir, file.Name))
+		if err != nil {
+			return fmt.Errorf("%s: %w", file.Name, err)
+		}
+		sum := sha256.Sum256(data)
+		if int64(len(data)) != file.Size || hex.EncodeToString(sum[:]) != file.SHA256 {
+			return fmt.Errorf("%s: checksum mismatch", file.Name)
+		}
+		if err := checkJSON(file.Name, data); err != nil {
+			return fmt.Errorf("%s: %w", file.Name, err)
+		}
+	}
+	return nil
+}
+
+// checkJSON checks that a data file decodes: a .jsonl file as one JSON value
+// per line, anything else as a JSON array.
+func checkJSON(name string, data []byte) error {
+	if filepath.Ext(name) != ".jsonl" {
+		var rows []json.RawMessage
+		if err := json.Unmarshal(data, &rows); err != nil {
+			return fmt.Errorf("not a JSON array: %w", err)
+		}
+		return nil
+	}
+	for i, line := range bytes.Split(data, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) > 0 && !json.Valid(line) {
+			return fmt.Errorf("line %d is not valid JSON", i+1)
+		}
+	}
+	return nil
+}
@@ -3,18 +3,78 @@ package config
 import (
 	"errors"
 	"os"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
+
+	"github.com/yourname/sleeptracker/internal/ratelimit"
 )
 
 type Config struct {
-	Env       string
-	LogLevel  string
-	DBType    string
-	DBDSN     string
-	FileSleep string
-	FileGoals string
+	Env           string
+	LogLevel      string
+	DBType        string
+	DBDSN         string
+	FileSleep     string
+	FileGoals     string
+	MemoryFixture string
+	CacheSize     int
+	CacheTTL      time.Duration
+
+	BackupDir        string
+	BackupInterval   time.Duration
+	BackupKeepHourly int
+	BackupKeepDaily  int
+
+	JWTSecret      string
+	JWTJWKSFile    string
+	JWTJWKSURL     string
+	JWTJWKSRefresh time.Duration
+	JWTIssuer      string
+	JWTAudience    string
+	JWTLeeway      time.Duration
+	JWTRoleClaim   bool
+
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+
+	AdminEmails []string
+
+	// AuthModes lists the auth providers requests are checked against, in
+	// order: local, remote, jwt and apikey.
+	AuthModes      []string
+	AuthServiceURL string
+	AuthDevToken   string
+
+	AuthTimeout          time.Duration
+	AuthCacheTTL         time.Duration
+	AuthNegativeCacheTTL time.Duration
+	AuthRetries          int
+	AuthBreakerThreshold int
+	AuthBreakerCooldown  time.Duration
+
+	// Rate limits per route group; see ratelimit.ParseLimit.
+	RateLimitAuth         ratelimit.Limit // login, registration and refresh, per IP
+	RateLimitAuthFailures ratelimit.Limit // failed bearer authentication, per IP
+	RateLimitAPI          ratelimit.Limit // every authenticated request, per user
+	RateLimitWrite        ratelimit.Limit // data-changing requests, per user
+	// IdempotencyTTL is how long responses to requests sent with an
+	// Idempotency-Key are kept for replay.
+	IdempotencyTTL time.Duration
+	// TrustedProxies may set X-Forwarded-For; with none, the client IP is
+	// the connection's remote address.
+	TrustedProxies []string
 }
 
+// Auth modes accepted in AUTH_MODE.
+const (
+	AuthLocal  = "local"  // tokens issued at login, and AUTH_DEV_TOKEN
+	AuthRemote = "remote" // the auth service at AUTH_SERVICE_URL
+	AuthJWT    = "jwt"    // signed JWTs
+	AuthAPIKey = "apikey" // personal API keys
+)
+
 var (
 	cfg  *Config
 	once sync.Once
@@ -24,12 +84,57 @@ func Load() *Config {
 	once.Do(func() {
 		_ = loadDotEnv()
 		cfg = &Config{
-			Env:       getEnv("APP_ENV", "development"),
-			LogLevel:  getEnv("LOG_LEVEL", "info"),
-			DBType:    getEnv("STORAGE_BACKEND", "file"),
-			DBDSN:     getEnv("POSTGRES_DSN", ""),
-			FileSleep: getEnv("SLEEP_FILE", "data/sleep_logs.json"),
-			FileGoals: getEnv("GOALS_FILE", "data/goals.json"),
+			Env:           getEnv("APP_ENV", "development"),
+			LogLevel:      getEnv("LOG_LEVEL", "info"),
+			DBType:        getEnv("STORAGE_BACKEND", "file"),
+			DBDSN:         getEnv("POSTGRES_DSN", ""),
+			FileSleep:     getEnv("SLEEP_FILE", "data/sleep_logs.json"),
+			FileGoals:     getEnv("GOALS_FILE", "data/goals.json"),
+			MemoryFixture: getEnv("MEMORY_FIXTURE", ""),
+			CacheSize:     getEnvInt("CACHE_SIZE", 1000),
+			CacheTTL:      getEnvDuration("CACHE_TTL", time.Minute),
+
+			BackupDir:        getEnv("BACKUP_DIR", "data/backups"),
+			BackupInterval:   getEnvDuration("BACKUP_INTERVAL", time.Hour),
+			BackupKeepHourly: getEnvInt("BACKUP_KEEP_HOURLY", 24),
+			BackupKeepDaily:  getEnvInt("BACKUP_KEEP_DAILY", 7),
+
+			JWTSecret:      getEnv("JWT_SECRET", ""),
+			JWTJWKSFile:    getEnv("JWT_JWKS_FILE", ""),
+			JWTJWKSURL:     getEnv("JWT_JWKS_URL", ""),
+			JWTJWKSRefresh: getEnvDuration("JWT_JWKS_REFRESH", time.Hour),
+			JWTIssuer:      getEnv("JWT_ISSUER", ""),
+			JWTAudience:    getEnv("JWT_AUDIENCE", ""),
+			JWTLeeway:      getEnvDuration("JWT_LEEWAY", 30*time.Second),
+			JWTRoleClaim:   getEnvBool("JWT_ROLE_CLAIM", false),
+
+			AccessTokenTTL:  getEnvDuration("ACCESS_TOKEN_TTL", 15*time.Minute),
+			RefreshTokenTTL: getEnvDuration("REFRESH_TOKEN_TTL", 30*24*time.Hour),
+
+			AdminEmails: getEnvList("ADMIN_EMAILS"),
+
+			AuthModes:      getEnvList("AUTH_MODE"),
+			AuthServiceURL: getEnv("AUTH_SERVICE_URL", ""),
+
+			AuthTimeout:          getEnvDuration("AUTH_TIMEOUT", 2*time.Second),
+			AuthCacheTTL:         getEnvDuration("AUTH_CACHE_TTL", time.Minute),
+			AuthNegativeCacheTTL: getEnvDuration("AUTH_NEGATIVE_CACHE_TTL", 10*time.Second),
+			AuthRetries:          getEnvInt("AUTH_RETRIES", 2),
+			AuthBreakerThreshold: getEnvInt("AUTH_BREAKER_THRESHOLD", 5),
+			AuthBreakerCooldown:  getEnvDuration("AUTH_BREAKER_COOLDOWN", 30*time.Second),
+
+			RateLimitAuth:         getEnvLimit("RATE_LIMIT_AUTH", "10/1m"),
+			RateLimitAuthFailures: getEnvLimit("RATE_LIMIT_AUTH_FAILURES", "20/1m"),
+			RateLimitAPI:          getEnvLimit("RATE_LIMIT_API", "600/1m"),
+			RateLimitWrite:        getEnvLimit("RATE_LIMIT_WRITE", "60/1m"),
+			IdempotencyTTL:        getEnvDuration("IDEMPOTENCY_TTL", 24*time.Hour),
+			TrustedProxies:        getEnvList("TRUSTED_PROXIES"),
+		}
+		// The demo token is never on by default: anyone who knows it is let
+		// in, so it must be set explicitly.
+		cfg.AuthDevToken = getEnv("AUTH_DEV_TOKEN", "")
+		if len(cfg.AuthModes) == 0 {
+			cfg.AuthModes = cfg.defaultAuthModes()
 		}
 		if err := cfg.Validate(); err != nil {
 			panic("Invalid config: " + err.Error())
@@ -45,12 +150,81 @@ func (c *Config) Validate() error {
 	if c.DBType == "file" && (c.FileSleep == "" || c.FileGoals == "") {
 		return errors.New("File storage requires SLEEP_FILE and GOALS_FILE to be set")
 	}
+	if c.DBType != "file" && c.DBType != "postgres" && c.DBType != "memory" {
+		return errors.New("STORAGE_BACKEND must be one of: file, postgres, memory")
+	}
+	if c.CacheSize < 0 || c.CacheTTL < 0 {
+		return errors.New("CACHE_SIZE and CACHE_TTL must not be negative")
+	}
+	if c.BackupInterval < 0 || c.BackupKeepHourly < 0 || c.BackupKeepDaily < 0 {
+		return errors.New("BACKUP_INTERVAL, BACKUP_KEEP_HOURLY and BACKUP_KEEP_DAILY must not be negative")
+	}
+	if c.JWTJWKSFile != "" && c.JWTJWKSURL != "" {
+		return errors.New("set either JWT_JWKS_FILE or JWT_JWKS_URL, not both")
+	}
+	if c.JWTJWKSRefresh < 0 || c.JWTLeeway < 0 {
+		return errors.New("JWT_JWKS_REFRESH and JWT_LEEWAY must not be negative")
+	}
+	if c.AccessTokenTTL <= 0 || c.RefreshTokenTTL <= 0 {
+		return errors.New("ACCESS_TOKEN_TTL and REFRESH_TOKEN_TTL must be positive")
+	}
+	if c.AuthTimeout < 0 || c.AuthCacheTTL < 0 || c.AuthNegativeCacheTTL < 0 || c.AuthRetries < 0 || c.AuthBreakerThreshold < 0 || c.AuthBreakerCooldown < 0 {
+		return errors.New("AUTH_TIMEOUT, AUTH_CACHE_TTL, AUTH_NEGATIVE_CACHE_TTL, AUTH_RETRIES, AUTH_BREAKER_THRESHOLD and AUTH_BREAKER_COOLDOWN must not be negative")
+	}
+	if err := c.validateAuthModes(); err != nil {
+		return err
+	}
 	if c.Env != "development" && c.Env != "staging" && c.Env != "production" {
 		return errors.New("APP_ENV must be one of: development, staging, production")
 	}
 	return nil
 }
 
+// defaultAuthModes accepts API keys and tokens issued at login, plus JWTs
+// and the remote auth service when they are configured.
+func (c *Config) defaultAuthModes() []string {
+	modes := []string{AuthAPIKey, AuthLocal}
+	if c.JWTEnabled() {
+		modes = append(modes, AuthJWT)
+	}
+	if c.AuthServiceURL != "" {
+		modes = append(modes, AuthRemote)
+	}
+	return modes
+}
+
+func (c *Config) validateAuthModes() error {
+	if len(c.AuthModes) == 0 {
+		return errors.New("AUTH_MODE must name at least one of: local, remote, jwt, apikey")
+	}
+	seen := make(map[string]bool)
+	for _, mode := range c.AuthModes {
+		switch mode {
+		case AuthLocal, AuthAPIKey:
+		case AuthRemote:
+			if c.AuthServiceURL == "" {
+				return errors.New("AUTH_SERVICE_URL is required when AUTH_MODE includes remote")
+			}
+		case AuthJWT:
+			if !c.JWTEnabled() {
+				return errors.New("AUTH_MODE jwt requires JWT_SECRET, JWT_JWKS_FILE or JWT_JWKS_URL")
+			}
+		default:
+			return errors.New("AUTH_MODE entries must be one of: local, remote, jwt, apikey")
+		}
+		if seen[mode] {
+			return errors.New("AUTH_MODE lists " + mode + " more than once")
+		}
+		seen[mode] = true
+	}
+	return nil
+}
+
+// JWTEnabled reports whether a JWT signing key source is configured.
+func (c *Config) JWTEnabled() bool {
+	return c.JWTSecret != "" || c.JWTJWKSFile != "" || c.JWTJWKSURL != ""
+}
+
 func getEnv(key, fallback string) string {
 	if v := os.Getenv(key); v != "" {
 		return v
@@ -58,6 +232,61 @@ func getEnv(key, fallback string) string {
 	return fallback
 }
 
+// getEnvList splits a comma-separated variable, dropping empty entries.
+func getEnvList(key string) []string {
+	var list []string
+	for _, v := range strings.Split(os.Getenv(key), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+func getEnvInt(key string, fallback int) int {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		panic("Invalid config: " + key + " must be an integer")
+	}
+	return n
+}
+
+func getEnvBool(key string, fallback bool) bool {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		panic("Invalid config: " + key + " must be true or false")
+	}
+	return b
+}
+
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		panic("Invalid config: " + key + " must be a duration such as 30s or 5m")
+	}
+	return d
+}
+
+func getEnvLimit(key, fallback string) ratelimit.Limit {
+	limit, err := ratelimit.ParseLimit(getEnv(key, fallback))
+	if err != nil {
+		panic("Invalid config: " + key + ": " + err.Error())
+	}
+	return limit
+}
+
 func loadDotEnv() error {
 	if _, err := os.Stat(".env"); err == nil {
 		f, err := os.Open(".env")
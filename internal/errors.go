@@ -1,8 +1,14 @@
 package internal
 
+import (
+	"errors"
+	"strings"
+)
+
 type AppError struct {
-	Code    int    `json:"code"`
-	Message string `json:"message"`
+	Code    int          `json:"code"`
+	Message string       `json:"message"`
+	Fields  []FieldError `json:"fields,omitempty"`
 }
 
 func (e *AppError) Error() string {
@@ -12,3 +18,68 @@ func (e *AppError) Error() string {
 func NewAppError(code int, message string) *AppError {
 	return &AppError{Code: code, Message: message}
 }
+
+// Error kinds. Errors of a kind, made with NewError or WrapError, are
+// reported to clients with the matching HTTP status.
+var (
+	ErrValidation   = errors.New("validation failed")
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrForbidden    = errors.New("forbidden")
+	ErrNotFound     = errors.New("not found")
+	ErrConflict     = errors.New("conflict")
+)
+
+// KindError is an error of one of the kinds above. errors.Is matches both
+// the kind and the wrapped error.
+type KindError struct {
+	Kind error
+	Err  error
+}
+
+func (e *KindError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *KindError) Unwrap() []error {
+	return []error{e.Kind, e.Err}
+}
+
+func NewError(kind error, message string) error {
+	return &KindError{Kind: kind, Err: errors.New(message)}
+}
+
+func WrapError(kind, err error) error {
+	return &KindError{Kind: kind, Err: err}
+}
+
+// FieldError says why one request field was rejected. Field is the field's
+// name as clients send it, Code a stable machine-readable reason such as
+// "required" or "max", and Params the rule's arguments, if any.
+type FieldError struct {
+	Field   string            `json:"field"`
+	Code    string            `json:"code"`
+	Message string            `json:"message"`
+	Params  map[string]string `json:"params,omitempty"`
+}
+
+// ValidationError is an ErrValidation error that names the fields at fault.
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	parts := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		parts[i] = f.Field + ": " + f.Message
+	}
+	return strings.Join(parts, "; ")
+}
+
+func (e *ValidationError) Unwrap() error {
+	return ErrValidation
+}
+
+// NewFieldError returns a ValidationError for a single field.
+func NewFieldError(field, code, message string) error {
+	return &ValidationError{Fields: []FieldError{{Field: field, Code: code, Message: message}}}
+}
@@ -0,0 +1,157 @@
+// Package export writes sleep data as RFC 4180 CSV for spreadsheets.
+package export
+
+import (
+	"encoding/csv"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yourname/sleeptracker/internal"
+)
+
+const ContentType = "text/csv; charset=utf-8"
+
+// chunkRows is how many rows are buffered before they are sent, so that
+// long histories stream rather than build up in memory.
+const chunkRows = 500
+
+var (
+	sleepLogHeader = []string{"id", "start_time", "end_time", "duration_hours", "quality", "reason", "interruptions"}
+	dailyHeader    = []string{"date", "logs", "average_quality", "total_sleep_hours", "interruptions", "bedtime", "wake_time"}
+)
+
+// writer is a csv.Writer that flushes every chunkRows rows, through to the
+// client when w is an http.Flusher.
+type writer struct {
+	csv  *csv.Writer
+	dst  io.Writer
+	rows int
+}
+
+func newWriter(w io.Writer, header []string) (*writer, error) {
+	cw := csv.NewWriter(w)
+	cw.UseCRLF = true
+	out := &writer{csv: cw, dst: w}
+	return out, out.write(header)
+}
+
+func (w *writer) write(record []string) error {
+	if err := w.csv.Write(record); err != nil {
+		return err
+	}
+	w.rows++
+	if w.rows%chunkRows == 0 {
+		return w.flush()
+	}
+	return nil
+}
+
+func (w *writer) flush() error {
+	w.csv.Flush()
+	if err := w.csv.Error(); err != nil {
+		return err
+	}
+	if f, ok := w.dst.(http.Flusher); ok {
+		f.Flush()
+	}
+	return nil
+}
+
+// WriteSleepLogs writes one row per log, with times in loc and the
+// interruptions joined by "; ". If loc is nil, both times are written in the
+// offset the log's start time was recorded in.
+func WriteSleepLogs(w io.Writer, logs []internal.SleepLog, loc *time.Location) error {
+	out, err := NewSleepLogWriter(w, loc)
+	if err != nil {
+		return err
+	}
+	for _, l := range logs {
+		if err := out.Write(l); err != nil {
+			return err
+		}
+	}
+	return out.Flush()
+}
+
+// SleepLogWriter writes the rows of WriteSleepLogs one log at a time, for
+// logs that are read as they are written.
+type SleepLogWriter struct {
+	out *writer
+	loc *time.Location
+}
+
+// NewSleepLogWriter writes the header row to w.
+func NewSleepLogWriter(w io.Writer, loc *time.Location) (*SleepLogWriter, error) {
+	out, err := newWriter(w, sleepLogHeader)
+	if err != nil {
+		return nil, err
+	}
+	return &SleepLogWriter{out: out, loc: loc}, nil
+}
+
+func (w *SleepLogWriter) Write(l internal.SleepLog) error {
+	loc := w.loc
+	if loc == nil {
+		loc = l.StartTime.Location()
+	}
+	return w.out.write([]string{
+		l.ID,
+		l.StartTime.In(loc).Format(time.RFC3339),
+		l.EndTime.In(loc).Format(time.RFC3339),
+		hours(l.EndTime.Sub(l.StartTime)),
+		strconv.Itoa(l.Quality),
+		text(l.Reason),
+		text(strings.Join(l.Interruptions, "; ")),
+	})
+}
+
+// text guards a user-written cell against formula injection: spreadsheets
+// evaluate cells starting with =, +, -, @, tab or CR, so those are prefixed
+// with an apostrophe to be shown as written.
+func text(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+// Flush sends the rows not yet sent.
+func (w *SleepLogWriter) Flush() error {
+	return w.out.flush()
+}
+
+// WriteDailySleep writes one row per day. Days are UTC calendar days, as
+// aggregated; bedtime and wake time are shown in loc or, if loc is nil, in
+// the offset of the log the bedtime came from.
+func WriteDailySleep(w io.Writer, days []internal.DailyAggregate, loc *time.Location) error {
+	out, err := newWriter(w, dailyHeader)
+	if err != nil {
+		return err
+	}
+	for _, d := range days {
+		loc := loc
+		if loc == nil {
+			loc = d.LocalBedtime().Location()
+		}
+		err := out.write([]string{
+			d.Date,
+			strconv.Itoa(d.LogCount),
+			strconv.FormatFloat(d.AverageQuality(), 'f', 2, 64),
+			hours(d.TotalSleep()),
+			strconv.Itoa(d.InterruptionCount),
+			d.Bedtime.In(loc).Format(time.RFC3339),
+			d.WakeTime.In(loc).Format(time.RFC3339),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return out.flush()
+}
+
+func hours(d time.Duration) string {
+	return strconv.FormatFloat(d.Hours(), 'f', 2, 64)
+}
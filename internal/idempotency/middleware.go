@@ -0,0 +1,137 @@
+package idempotency
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yourname/sleeptracker/internal"
+	"github.com/yourname/sleeptracker/internal/response"
+)
+
+// Header is the request header carrying the client's key.
+const Header = "Idempotency-Key"
+
+// maxKeyLength bounds the keys clients may choose; a UUID is 36 characters.
+const maxKeyLength = 255
+
+var errKeyTooLong = &internal.ValidationError{Fields: []internal.FieldError{{
+	Field:   Header,
+	Code:    "max",
+	Message: "must have at most 255 characters",
+	Params:  map[string]string{"max": "255"},
+}}}
+
+// inProgressRetryAfter is the Retry-After, in seconds, sent with the 409 to a
+// request whose key is still in progress. It marks that conflict as one
+// that goes away, unlike reusing a key for a different request.
+const inProgressRetryAfter = "1"
+
+// replayedHeaders are the response headers saved along with the body.
+var replayedHeaders = []string{"Content-Type", "Location"}
+
+// Middleware replays the saved response to a request whose Idempotency-Key
+// the same user already sent with the same method, path and body. Requests
+// without the header run as usual. Responses with a 5xx status are not
+// saved, so the request can be retried. A request whose key is still in
+// progress gets a 409 with a Retry-After header. If the store fails,
+// requests are let through. The body is read to fingerprint the request, so
+// bodies over maxBody bytes are rejected with a 413.
+func Middleware(store Store, maxBody int64, logger internal.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(Header)
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > maxKeyLength {
+			response.Error(c, errKeyTooLong, "Invalid request")
+			return
+		}
+		if u, ok := c.Value("user").(*internal.User); ok {
+			key = u.ID + ":" + key
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxBody))
+		if tooLarge := (*http.MaxBytesError)(nil); errors.As(err, &tooLarge) {
+			err := internal.NewAppError(http.StatusRequestEntityTooLarge, "request body must be at most "+strconv.FormatInt(maxBody, 10)+" bytes")
+			response.Error(c, err, "Invalid request")
+			return
+		}
+		if err != nil {
+			response.Error(c, internal.WrapError(internal.ErrValidation, err), "Invalid request")
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		sum := sha256.Sum256(body)
+		fingerprint := c.Request.Method + " " + c.Request.URL.Path + " " + hex.EncodeToString(sum[:])
+
+		saved, err := store.Begin(c.Request.Context(), key, fingerprint)
+		if errors.Is(err, ErrInProgress) {
+			c.Header("Retry-After", inProgressRetryAfter)
+		}
+		if errors.Is(err, ErrInProgress) || errors.Is(err, ErrMismatch) {
+			response.Error(c, err, "Idempotency-Key conflict")
+			return
+		}
+		if err != nil {
+			logger.Errorf("idempotency store: %v", err)
+			c.Next()
+			return
+		}
+		if saved != nil {
+			for name, values := range saved.Header {
+				c.Writer.Header()[name] = values
+			}
+			c.Header("Idempotent-Replayed", "true")
+			c.Status(saved.Status)
+			_, _ = c.Writer.Write(saved.Body)
+			c.Abort()
+			return
+		}
+
+		// Finish runs even if the handler panics, releasing the key rather
+		// than leaving it in progress until it expires.
+		var resp *Response
+		defer func() {
+			if err := store.Finish(c.Request.Context(), key, resp); err != nil {
+				logger.Errorf("idempotency store: %v", err)
+			}
+		}()
+
+		tee := &teeWriter{ResponseWriter: c.Writer}
+		c.Writer = tee
+		c.Next()
+		c.Writer = tee.ResponseWriter
+
+		if status := c.Writer.Status(); status < http.StatusInternalServerError {
+			resp = &Response{Status: status, Header: http.Header{}, Body: tee.body.Bytes()}
+			for _, name := range replayedHeaders {
+				if v := c.Writer.Header().Values(name); len(v) > 0 {
+					resp.Header[name] = v
+				}
+			}
+		}
+	}
+}
+
+// teeWriter copies the response body as it is written.
+type teeWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *teeWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *teeWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
@@ -0,0 +1,106 @@
+// Package idempotency lets clients retry data-changing requests safely: a
+// request sent again with the same Idempotency-Key gets the first response
+// instead of being carried out twice.
+package idempotency
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/yourname/sleeptracker/internal"
+)
+
+var (
+	// ErrInProgress means a request with the key has not finished yet.
+	ErrInProgress = internal.NewError(internal.ErrConflict, "a request with this Idempotency-Key is still in progress")
+	// ErrMismatch means the key was used for a different request.
+	ErrMismatch = internal.NewError(internal.ErrConflict, "this Idempotency-Key was used for a different request")
+)
+
+// Response is a saved response, replayed to later requests with its key.
+type Response struct {
+	Status int
+	Header http.Header
+	Body   []byte
+}
+
+// Store remembers the responses to keyed requests. Implementations must be
+// safe for concurrent use; MemoryStore keeps them in process, and a shared
+// store can implement the same interface when the API runs on several
+// instances.
+type Store interface {
+	// Begin claims key for a request identified by fingerprint. It returns
+	// the saved response if the request already completed, ErrInProgress if
+	// it is still running and ErrMismatch if the key was used for a request
+	// with a different fingerprint.
+	Begin(ctx context.Context, key, fingerprint string) (*Response, error)
+	// Finish saves the response to the request that claimed key. A nil
+	// response releases the key, so the request can be tried again.
+	Finish(ctx context.Context, key string, resp *Response) error
+}
+
+type entry struct {
+	fingerprint string
+	resp        *Response // nil while in progress
+	expires     time.Time
+}
+
+// MemoryStore is an in-process Store. Responses are kept for TTL after the
+// key is first claimed.
+type MemoryStore struct {
+	TTL       time.Duration
+	mu        sync.Mutex
+	entries   map[string]*entry
+	lastSweep time.Time
+}
+
+// sweepInterval is how often expired keys are dropped.
+const sweepInterval = time.Minute
+
+func NewMemoryStore(ttl time.Duration) *MemoryStore {
+	return &MemoryStore{TTL: ttl, entries: make(map[string]*entry), lastSweep: time.Now()}
+}
+
+func (m *MemoryStore) Begin(ctx context.Context, key, fingerprint string) (*Response, error) {
+	now := time.Now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if now.Sub(m.lastSweep) > sweepInterval {
+		for k, e := range m.entries {
+			if now.After(e.expires) {
+				delete(m.entries, k)
+			}
+		}
+		m.lastSweep = now
+	}
+
+	e, ok := m.entries[key]
+	if !ok || now.After(e.expires) {
+		m.entries[key] = &entry{fingerprint: fingerprint, expires: now.Add(m.TTL)}
+		return nil, nil
+	}
+	switch {
+	case e.fingerprint != fingerprint:
+		return nil, ErrMismatch
+	case e.resp == nil:
+		return nil, ErrInProgress
+	}
+	return e.resp, nil
+}
+
+func (m *MemoryStore) Finish(ctx context.Context, key string, resp *Response) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if resp == nil {
+		delete(m.entries, key)
+		return nil
+	}
+	if e, ok := m.entries[key]; ok {
+		e.resp = resp
+	}
+	return nil
+}
+
+var _ Store = (*MemoryStore)(nil)
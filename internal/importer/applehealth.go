@@ -0,0 +1,111 @@
+package importer
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"time"
+
+	"github.com/yourname/sleeptracker/internal"
+)
+
+func init() { Register(appleHealth{}) }
+
+// appleHealth reads export.xml from the Health app's Export All Health Data.
+// Its sleep analysis records are merged into nights; the ID of a night is
+// the time its first record starts.
+type appleHealth struct{}
+
+func (appleHealth) Name() string           { return "apple-health" }
+func (appleHealth) MediaTypes() []string   { return []string{"application/xml", "text/xml"} }
+func (appleHealth) Match(file string) bool { return path.Base(file) == "export.xml" }
+
+func (a appleHealth) Parse(r io.Reader, _ *time.Location) ([]internal.SleepLog, error) {
+	samples, err := parseAppleHealth(r)
+	if err != nil {
+		return nil, err
+	}
+	var logs []internal.SleepLog
+	for _, n := range Nights(samples) {
+		if !n.inBed() {
+			continue
+		}
+		log := n.SleepLog("Apple Health")
+		log.Source = &internal.SleepLogSource{Name: a.Name(), ID: n[0].Start.UTC().Format(time.RFC3339)}
+		logs = append(logs, log)
+	}
+	return logs, nil
+}
+
+const (
+	appleSleepType  = "HKCategoryTypeIdentifierSleepAnalysis"
+	appleDateLayout = "2006-01-02 15:04:05 -0700"
+)
+
+// appleStages maps the values of sleep analysis records to stages. Watches
+// since watchOS 9 split asleep into core, deep and REM.
+var appleStages = map[string]Stage{
+	"HKCategoryValueSleepAnalysisInBed":             InBed,
+	"HKCategoryValueSleepAnalysisAsleep":            Asleep,
+	"HKCategoryValueSleepAnalysisAsleepUnspecified": Asleep,
+	"HKCategoryValueSleepAnalysisAsleepCore":        Asleep,
+	"HKCategoryValueSleepAnalysisAsleepDeep":        Asleep,
+	"HKCategoryValueSleepAnalysisAsleepREM":         Asleep,
+	"HKCategoryValueSleepAnalysisAwake":             Awake,
+}
+
+// parseAppleHealth reads the sleep analysis records of an Apple Health
+// export.xml. The document is streamed, so only the sleep samples are held
+// in memory, however large the export.
+func parseAppleHealth(r io.Reader) ([]Sample, error) {
+	d := xml.NewDecoder(r)
+	var samples []Sample
+	sawRoot := false
+	for {
+		tok, err := d.RawToken()
+		if errors.Is(err, io.EOF) {
+			if !sawRoot {
+				return nil, errors.New("not an Apple Health export: no HealthData element")
+			}
+			return samples, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read export: %w", err)
+		}
+		el, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		if el.Name.Local == "HealthData" {
+			sawRoot = true
+			continue
+		}
+		if el.Name.Local != "Record" || attr(el, "type") != appleSleepType {
+			continue
+		}
+		stage, ok := appleStages[attr(el, "value")]
+		if !ok {
+			continue
+		}
+		start, err := time.Parse(appleDateLayout, attr(el, "startDate"))
+		if err != nil {
+			return nil, fmt.Errorf("record at byte %d: startDate: %w", d.InputOffset(), err)
+		}
+		end, err := time.Parse(appleDateLayout, attr(el, "endDate"))
+		if err != nil {
+			return nil, fmt.Errorf("record at byte %d: endDate: %w", d.InputOffset(), err)
+		}
+		samples = append(samples, Sample{Start: start, End: end, Stage: stage})
+	}
+}
+
+func attr(el xml.StartElement, name string) string {
+	for _, a := range el.Attr {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
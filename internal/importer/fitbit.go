@@ -0,0 +1,139 @@
+package importer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/yourname/sleeptracker/internal"
+)
+
+func init() { Register(fitbit{}) }
+
+// fitbit reads the sleep-YYYY-MM-DD.json files of a Fitbit data export, each
+// an array of sleep logs as the Fitbit Web API returns them. Their stages
+// become interruptions and Fitbit's efficiency the quality.
+type fitbit struct{}
+
+func (fitbit) Name() string         { return "fitbit" }
+func (fitbit) MediaTypes() []string { return []string{"application/json"} }
+
+func (fitbit) Match(file string) bool {
+	base := path.Base(file)
+	return strings.HasPrefix(base, "sleep-") && strings.HasSuffix(base, ".json")
+}
+
+// fitbitTimeLayout is how Fitbit writes times: local, without an offset,
+// usually with milliseconds.
+const fitbitTimeLayout = "2006-01-02T15:04:05"
+
+// fitbitStages maps the levels of both stage logs (wake, light, deep, rem)
+// and classic logs (awake, restless, asleep) to stages.
+var fitbitStages = map[string]Stage{
+	"wake":     Awake,
+	"light":    Asleep,
+	"deep":     Asleep,
+	"rem":      Asleep,
+	"awake":    Awake,
+	"restless": Awake,
+	"asleep":   Asleep,
+}
+
+type fitbitSleep struct {
+	LogID      json.Number `json:"logId"`
+	StartTime  string      `json:"startTime"`
+	EndTime    string      `json:"endTime"`
+	Efficiency *float64    `json:"efficiency"`
+	Levels     struct {
+		Data []struct {
+			DateTime string `json:"dateTime"`
+			Level    string `json:"level"`
+			Seconds  int    `json:"seconds"`
+		} `json:"data"`
+	} `json:"levels"`
+}
+
+func (f fitbit) Parse(r io.Reader, loc *time.Location) ([]internal.SleepLog, error) {
+	d := json.NewDecoder(r)
+	if tok, err := d.Token(); err != nil {
+		return nil, fmt.Errorf("read export: %w", err)
+	} else if tok != json.Delim('[') {
+		return nil, errors.New("not a Fitbit sleep export: expected an array of sleep logs")
+	}
+	var logs []internal.SleepLog
+	for i := 1; d.More(); i++ {
+		var raw json.RawMessage
+		if err := d.Decode(&raw); err != nil {
+			return nil, fmt.Errorf("sleep log %d: %w", i, err)
+		}
+		log, err := f.sleepLog(raw, loc)
+		if err != nil {
+			return nil, fmt.Errorf("sleep log %d: %w", i, err)
+		}
+		logs = append(logs, log)
+	}
+	if _, err := d.Token(); err != nil {
+		return nil, fmt.Errorf("read export: %w", err)
+	}
+	return logs, nil
+}
+
+func (f fitbit) sleepLog(raw json.RawMessage, loc *time.Location) (internal.SleepLog, error) {
+	var entry fitbitSleep
+	if err := json.Unmarshal(raw, &entry); err != nil {
+		return internal.SleepLog{}, err
+	}
+	if entry.LogID == "" {
+		return internal.SleepLog{}, errors.New("logId is missing")
+	}
+	start, err := time.ParseInLocation(fitbitTimeLayout, entry.StartTime, loc)
+	if err != nil {
+		return internal.SleepLog{}, fmt.Errorf("startTime: %w", err)
+	}
+	end, err := time.ParseInLocation(fitbitTimeLayout, entry.EndTime, loc)
+	if err != nil {
+		return internal.SleepLog{}, fmt.Errorf("endTime: %w", err)
+	}
+	if !end.After(start) {
+		return internal.SleepLog{}, errors.New("endTime must be after startTime")
+	}
+
+	night := Night{{Start: start, End: end, Stage: InBed}}
+	for _, level := range entry.Levels.Data {
+		stage, ok := fitbitStages[level.Level]
+		if !ok {
+			continue
+		}
+		at, err := time.ParseInLocation(fitbitTimeLayout, level.DateTime, loc)
+		if err != nil {
+			return internal.SleepLog{}, fmt.Errorf("levels: %w", err)
+		}
+		night = append(night, Sample{Start: at, End: at.Add(time.Duration(level.Seconds) * time.Second), Stage: stage})
+	}
+	log := night.SleepLog("Fitbit")
+	if entry.Efficiency != nil {
+		log.Quality = quality(*entry.Efficiency / 100)
+	}
+
+	meta, err := metadata(raw, "logId", "startTime", "endTime", "efficiency")
+	if err != nil {
+		return internal.SleepLog{}, err
+	}
+	// The stage timeline is mapped; the rest of levels, such as the
+	// minutes in each stage, is kept.
+	if levels, ok := meta["levels"].(map[string]any); ok {
+		delete(levels, "data")
+		if len(levels) == 0 {
+			delete(meta, "levels")
+		}
+	}
+	if len(meta) == 0 {
+		meta = nil
+	}
+	log.Source = &internal.SleepLogSource{Name: f.Name(), ID: entry.LogID.String(), Metadata: meta}
+	return log, nil
+}
@@ -0,0 +1,161 @@
+package importer
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yourname/sleeptracker/internal"
+)
+
+func init() { Register(googleFit{}) }
+
+// googleFit reads Google Fit sessions: the files under Fit/All Sessions in a
+// Google Takeout export, one session each, or the session list the Fit REST
+// API returns. Sessions other than sleep are skipped. Takeout sessions have
+// sleep stages as segments; a session without them spans the night.
+type googleFit struct{}
+
+func (googleFit) Name() string         { return "google-fit" }
+func (googleFit) MediaTypes() []string { return []string{"application/json"} }
+
+func (googleFit) Match(file string) bool {
+	return strings.Contains(file, "All Sessions/") && strings.HasSuffix(file, ".json")
+}
+
+// googleFitSleep is the activity type of sleep sessions in the REST API.
+const googleFitSleep = 72
+
+var googleFitStages = map[string]Stage{
+	"sleep":       Asleep,
+	"sleep.light": Asleep,
+	"sleep.deep":  Asleep,
+	"sleep.rem":   Asleep,
+	"sleep.awake": Awake,
+	"awake":       Awake,
+}
+
+type googleFitSession struct {
+	ID              string      `json:"id"`
+	FitnessActivity string      `json:"fitnessActivity"`
+	ActivityType    int         `json:"activityType"`
+	StartTime       string      `json:"startTime"`
+	EndTime         string      `json:"endTime"`
+	StartTimeMillis json.Number `json:"startTimeMillis"`
+	EndTimeMillis   json.Number `json:"endTimeMillis"`
+	Segment         []struct {
+		FitnessActivity string `json:"fitnessActivity"`
+		StartTime       string `json:"startTime"`
+		EndTime         string `json:"endTime"`
+	} `json:"segment"`
+}
+
+func (s googleFitSession) sleep() bool {
+	return s.ActivityType == googleFitSleep || s.FitnessActivity == "sleep" || strings.HasPrefix(s.FitnessActivity, "sleep.")
+}
+
+func (g googleFit) Parse(r io.Reader, loc *time.Location) ([]internal.SleepLog, error) {
+	var doc json.RawMessage
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("read export: %w", err)
+	}
+	var sessions []json.RawMessage
+	if bytes.HasPrefix(bytes.TrimSpace(doc), []byte("[")) {
+		if err := json.Unmarshal(doc, &sessions); err != nil {
+			return nil, fmt.Errorf("read export: %w", err)
+		}
+	} else {
+		var list struct {
+			Session []json.RawMessage `json:"session"`
+		}
+		if err := json.Unmarshal(doc, &list); err != nil {
+			return nil, errors.New("not a Google Fit export: expected a session or a list of sessions")
+		}
+		sessions = list.Session
+		if sessions == nil {
+			sessions = []json.RawMessage{doc}
+		}
+	}
+
+	var logs []internal.SleepLog
+	for i, raw := range sessions {
+		log, ok, err := g.sleepLog(raw, loc)
+		if err != nil {
+			return nil, fmt.Errorf("session %d: %w", i+1, err)
+		}
+		if ok {
+			logs = append(logs, log)
+		}
+	}
+	return logs, nil
+}
+
+// sleepLog converts a session, reporting false if it is not sleep.
+func (g googleFit) sleepLog(raw json.RawMessage, loc *time.Location) (internal.SleepLog, bool, error) {
+	var session googleFitSession
+	if err := json.Unmarshal(raw, &session); err != nil {
+		return internal.SleepLog{}, false, err
+	}
+	if !session.sleep() {
+		return internal.SleepLog{}, false, nil
+	}
+	start, err := googleFitTime(session.StartTime, session.StartTimeMillis)
+	if err != nil {
+		return internal.SleepLog{}, false, fmt.Errorf("start time: %w", err)
+	}
+	end, err := googleFitTime(session.EndTime, session.EndTimeMillis)
+	if err != nil {
+		return internal.SleepLog{}, false, fmt.Errorf("end time: %w", err)
+	}
+	if !end.After(start) {
+		return internal.SleepLog{}, false, errors.New("the session must end after it starts")
+	}
+
+	night := Night{{Start: start.In(loc), End: end.In(loc), Stage: InBed}}
+	for _, seg := range session.Segment {
+		stage, ok := googleFitStages[seg.FitnessActivity]
+		if !ok {
+			continue
+		}
+		from, err := googleFitTime(seg.StartTime, "")
+		if err != nil {
+			return internal.SleepLog{}, false, fmt.Errorf("segment: %w", err)
+		}
+		to, err := googleFitTime(seg.EndTime, "")
+		if err != nil {
+			return internal.SleepLog{}, false, fmt.Errorf("segment: %w", err)
+		}
+		night = append(night, Sample{Start: from.In(loc), End: to.In(loc), Stage: stage})
+	}
+	log := night.SleepLog("Google Fit")
+
+	meta, err := metadata(raw, "id", "fitnessActivity", "activityType", "startTime", "endTime", "startTimeMillis", "endTimeMillis", "segment")
+	if err != nil {
+		return internal.SleepLog{}, false, err
+	}
+	// Takeout sessions have no ID; the start time identifies them.
+	id := session.ID
+	if id == "" {
+		id = start.UTC().Format(time.RFC3339)
+	}
+	log.Source = &internal.SleepLogSource{Name: g.Name(), ID: id, Metadata: meta}
+	return log, true, nil
+}
+
+// googleFitTime reads a takeout timestamp or, failing that, the REST API's
+// milliseconds since the epoch.
+func googleFitTime(rfc3339 string, millis json.Number) (time.Time, error) {
+	if rfc3339 != "" || millis == "" {
+		return time.Parse(time.RFC3339, rfc3339)
+	}
+	ms, err := strconv.ParseInt(millis.String(), 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.UnixMilli(ms), nil
+}
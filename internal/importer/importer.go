@@ -0,0 +1,212 @@
+// Package importer turns sleep data exported by other apps into sleep logs.
+// Each app is a Source, registered by name. Sources parse their exports into
+// Samples, which a Night merges into one sleep log.
+package importer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/yourname/sleeptracker/internal"
+)
+
+// Source reads the exports of one app.
+type Source interface {
+	// Name identifies the source in import URLs and in the provenance of the
+	// logs it imports, such as "fitbit".
+	Name() string
+	// MediaTypes are the content types an uploaded export may have.
+	MediaTypes() []string
+	// Match reports whether a file in an export archive, by its path, is one
+	// Parse reads.
+	Match(path string) bool
+	// Parse reads one exported file into sleep logs without an ID or user,
+	// each with its Source set. Times the file gives without an offset are
+	// in loc.
+	Parse(r io.Reader, loc *time.Location) ([]internal.SleepLog, error)
+}
+
+var sources = make(map[string]Source)
+
+// Register makes a source available by its name. It panics if the name is
+// taken.
+func Register(s Source) {
+	if _, ok := sources[s.Name()]; ok {
+		panic(fmt.Sprintf("importer: source %q registered twice", s.Name()))
+	}
+	sources[s.Name()] = s
+}
+
+// Lookup returns the source registered under name.
+func Lookup(name string) (Source, bool) {
+	s, ok := sources[name]
+	return s, ok
+}
+
+// Names lists the registered sources, sorted.
+func Names() []string {
+	names := make([]string, 0, len(sources))
+	for name := range sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Stage is what a sample says the user was doing.
+type Stage int
+
+const (
+	InBed Stage = iota
+	Asleep
+	Awake
+)
+
+// Sample is one stretch of time a source recorded in a single stage.
+type Sample struct {
+	Start, End time.Time
+	Stage      Stage
+}
+
+const (
+	// nightGap is the longest break between samples of the same night.
+	nightGap = 3 * time.Hour
+	// minAwake is the shortest time awake that counts as an interruption.
+	minAwake = 2 * time.Minute
+	// maxInterruptions matches the most interruptions a sleep log may have.
+	maxInterruptions = 20
+	// defaultQuality is used when a source says nothing about how well the
+	// user slept.
+	defaultQuality = 5
+)
+
+// Night is the samples of one sleep, such as a night or a nap.
+type Night []Sample
+
+// Nights groups samples into nights, starting a new one wherever no sample
+// covers nightGap. Samples may overlap, as when a phone and a watch both
+// record the same night, and need not be sorted. Empty samples are dropped.
+func Nights(samples []Sample) []Night {
+	sorted := make([]Sample, 0, len(samples))
+	for _, s := range samples {
+		if s.End.After(s.Start) {
+			sorted = append(sorted, s)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Start.Before(sorted[j].Start) })
+
+	var nights []Night
+	var end time.Time
+	for _, s := range sorted {
+		if len(nights) == 0 || s.Start.Sub(end) > nightGap {
+			nights = append(nights, nil)
+		}
+		nights[len(nights)-1] = append(nights[len(nights)-1], s)
+		if s.End.After(end) {
+			end = s.End
+		}
+	}
+	return nights
+}
+
+// inBed reports whether any sample says the user was in bed or asleep.
+func (n Night) inBed() bool {
+	for _, s := range n {
+		if s.Stage != Awake {
+			return true
+		}
+	}
+	return false
+}
+
+// SleepLog converts the night into a sleep log, without an ID, user or
+// source. title names the app in the log's reason.
+//
+// The sleep runs from the first asleep sample to the last, or spans the
+// night if no sample says the user was asleep. Time awake within it, whether
+// recorded or a gap between asleep samples, becomes interruptions, written
+// in the night's own time zone. Quality is the share of time in bed spent
+// asleep, out of 10; without asleep samples it is 5.
+func (n Night) SleepLog(title string) internal.SleepLog {
+	var asleep, all []interval
+	for _, s := range n {
+		all = append(all, interval{s.Start, s.End})
+		if s.Stage == Asleep {
+			asleep = append(asleep, interval{s.Start, s.End})
+		}
+	}
+	all, asleep = union(all), union(asleep)
+	inBed := interval{all[0].start, all[len(all)-1].end}
+
+	log := internal.SleepLog{StartTime: inBed.start, EndTime: inBed.end, Quality: defaultQuality, Reason: "Imported from " + title}
+	if len(asleep) == 0 {
+		return log
+	}
+	log.StartTime, log.EndTime = asleep[0].start, asleep[len(asleep)-1].end
+
+	var slept time.Duration
+	for i, a := range asleep {
+		slept += a.end.Sub(a.start)
+		if i > 0 && a.start.Sub(asleep[i-1].end) >= minAwake {
+			log.Interruptions = append(log.Interruptions, awakeLabel(asleep[i-1].end, a.start))
+		}
+	}
+	if len(log.Interruptions) > maxInterruptions {
+		more := len(log.Interruptions) - maxInterruptions + 1
+		log.Interruptions = append(log.Interruptions[:maxInterruptions-1], strconv.Itoa(more)+" more awake periods")
+	}
+	log.Quality = quality(float64(slept) / float64(inBed.end.Sub(inBed.start)))
+	return log
+}
+
+// quality scores a sleep efficiency, the share of time in bed spent asleep,
+// out of 10.
+func quality(efficiency float64) int {
+	return max(1, min(10, int(math.Round(efficiency*10))))
+}
+
+func awakeLabel(from, to time.Time) string {
+	return "awake " + from.Format("15:04") + "-" + to.In(from.Location()).Format("15:04") +
+		" (" + strconv.Itoa(int(to.Sub(from).Round(time.Minute).Minutes())) + "m)"
+}
+
+type interval struct {
+	start, end time.Time
+}
+
+// union merges overlapping and touching intervals, returning them sorted.
+func union(in []interval) []interval {
+	sort.Slice(in, func(i, j int) bool { return in[i].start.Before(in[j].start) })
+	var out []interval
+	for _, iv := range in {
+		if n := len(out); n > 0 && !iv.start.After(out[n-1].end) {
+			if iv.end.After(out[n-1].end) {
+				out[n-1].end = iv.end
+			}
+			continue
+		}
+		out = append(out, iv)
+	}
+	return out
+}
+
+// metadata returns the fields of a JSON object other than mapped, the ones
+// a source maps into the sleep log, or nil if there are none.
+func metadata(raw json.RawMessage, mapped ...string) (map[string]any, error) {
+	var fields map[string]any
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, err
+	}
+	for _, name := range mapped {
+		delete(fields, name)
+	}
+	if len(fields) == 0 {
+		return nil, nil
+	}
+	return fields, nil
+}
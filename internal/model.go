@@ -3,20 +3,201 @@ package internal
 import "time"
 
 type User struct {
-	ID    string `json:"id"`
-	Token string `json:"token"`
-	Name  string `json:"name"`
+	ID           string    `json:"id"`
+	Token        string    `json:"-"`
+	Name         string    `json:"name"`
+	Email        string    `json:"email,omitempty"`
+	PasswordHash string    `json:"-"`
+	CreatedAt    time.Time `json:"created_at,omitzero"`
+	Role         string    `json:"role,omitempty"`
+	// DisabledAt is set when an admin disabled the account; disabled users
+	// cannot log in or use existing credentials.
+	DisabledAt *time.Time `json:"disabled_at,omitempty"`
+	// Scopes limits what the request's credential may do; nil means the
+	// credential is not restricted.
+	Scopes []string `json:"-"`
+}
+
+const (
+	RoleUser   = "user"   // manages their own data
+	RoleViewer = "viewer" // read-only: may view their own and system-wide data but change nothing
+	RoleAdmin  = "admin"  // operator with access to system-wide data and account management
+)
+
+// EffectiveRole returns the user's role, treating an unset role as RoleUser.
+func (u *User) EffectiveRole() string {
+	if u.Role == "" {
+		return RoleUser
+	}
+	return u.Role
+}
+
+// HasScope reports whether the user's credential grants scope.
+func (u *User) HasScope(scope string) bool {
+	if u.Scopes == nil {
+		return true
+	}
+	for _, s := range u.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// AccessToken is an opaque bearer token issued at login. Only a hash of the
+// token is stored.
+type AccessToken struct {
+	Hash      string    `json:"hash"`
+	UserID    string    `json:"user_id"`
+	SessionID string    `json:"session_id,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// APIKey is a long-lived credential a user creates for scripts and
+// integrations. Only its prefix, for display, and a hash are stored.
+type APIKey struct {
+	ID         string     `json:"id"`
+	UserID     string     `json:"user_id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Hash       string     `json:"hash"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+// Grant gives the account with GranteeEmail read-only access to OwnerID's
+// sleep logs, stats and goals. It takes effect once that account accepts it,
+// which sets GranteeID, and ends when revoked or at ExpiresAt, if set.
+type Grant struct {
+	ID           string     `json:"id"`
+	OwnerID      string     `json:"owner_id"`
+	GranteeID    string     `json:"grantee_id,omitempty"`
+	GranteeEmail string     `json:"grantee_email"`
+	CreatedAt    time.Time  `json:"created_at"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`
+	AcceptedAt   *time.Time `json:"accepted_at,omitempty"`
+	RevokedAt    *time.Time `json:"revoked_at,omitempty"`
+}
+
+const (
+	GrantPending = "pending"
+	GrantActive  = "active"
+	GrantExpired = "expired"
+	GrantRevoked = "revoked"
+)
+
+// Status reports where the grant is in its lifecycle at now.
+func (g *Grant) Status(now time.Time) string {
+	switch {
+	case g.RevokedAt != nil:
+		return GrantRevoked
+	case g.ExpiresAt != nil && !now.Before(*g.ExpiresAt):
+		return GrantExpired
+	case g.AcceptedAt == nil:
+		return GrantPending
+	}
+	return GrantActive
+}
+
+// GrantAccess records one request a grantee made for the owner's data.
+type GrantAccess struct {
+	ID        string    `json:"id"`
+	GrantID   string    `json:"grant_id"`
+	OwnerID   string    `json:"owner_id"`
+	GranteeID string    `json:"grantee_id"`
+	Method    string    `json:"method"`
+	Path      string    `json:"path"`
+	At        time.Time `json:"at"`
+}
+
+// AuditEvent records one security-relevant or data-changing action. ActorID
+// is empty when the caller was not authenticated; TargetUserID is the user
+// whose account or data the action concerns.
+type AuditEvent struct {
+	ID           string            `json:"id"`
+	At           time.Time         `json:"at"`
+	Action       string            `json:"action"`
+	ActorID      string            `json:"actor_id,omitempty"`
+	TargetUserID string            `json:"target_user_id,omitempty"`
+	ResourceID   string            `json:"resource_id,omitempty"`
+	RequestID    string            `json:"request_id,omitempty"`
+	IP           string            `json:"ip,omitempty"`
+	Details      map[string]string `json:"details,omitempty"`
+}
+
+// Audited actions.
+const (
+	AuditRegister      = "user.register"
+	AuditLogin         = "auth.login"
+	AuditLoginFailed   = "auth.login_failed"
+	AuditAuthFailed    = "auth.failed"
+	AuditLogout        = "auth.logout"
+	AuditSessionRevoke = "session.revoke"
+	AuditAPIKeyCreate  = "api_key.create"
+	AuditAPIKeyRevoke  = "api_key.revoke"
+	AuditSleepCreate   = "sleep.create"
+	AuditSleepImport   = "sleep.import"
+	AuditSleepExport   = "sleep.export"
+	AuditGoalSet       = "goal.set"
+	AuditGrantCreate   = "grant.create"
+	AuditGrantAccept   = "grant.accept"
+	AuditGrantRevoke   = "grant.revoke"
+	AuditUserDisable   = "user.disable"
+	AuditUserEnable    = "user.enable"
+	AuditUserRole      = "user.role"
+)
+
+// Session is one login. Its refresh tokens form a family: each use rotates
+// the token, and presenting a rotated token again revokes the whole session.
+type Session struct {
+	ID         string     `json:"id"`
+	UserID     string     `json:"user_id"`
+	UserAgent  string     `json:"user_agent,omitempty"`
+	IP         string     `json:"ip,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt time.Time  `json:"last_used_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+func (s *Session) Active(now time.Time) bool {
+	return s.RevokedAt == nil && now.Before(s.ExpiresAt)
+}
+
+// RefreshToken is an opaque, single-use token for obtaining new access
+// tokens. Only a hash of the token is stored.
+type RefreshToken struct {
+	Hash      string     `json:"hash"`
+	SessionID string     `json:"session_id"`
+	UserID    string     `json:"user_id"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
 }
 
 type SleepLog struct {
-	ID            string    `json:"id"`
-	UserID        string    `json:"user_id"`
-	StartTime     time.Time `json:"start_time"`
-	EndTime       time.Time `json:"end_time"`
-	Quality       int       `json:"quality"` // 1–10 scale
-	Reason        string    `json:"reason,omitempty"`
-	Interruptions []string  `json:"interruptions,omitempty"`
-	CreatedAt     time.Time `json:"created_at"`
+	ID            string          `json:"id"`
+	UserID        string          `json:"user_id"`
+	StartTime     time.Time       `json:"start_time"`
+	EndTime       time.Time       `json:"end_time"`
+	Quality       int             `json:"quality"` // 1–10 scale
+	Reason        string          `json:"reason,omitempty"`
+	Interruptions []string        `json:"interruptions,omitempty"`
+	Source        *SleepLogSource `json:"source,omitempty"` // set on logs imported from another app
+	CreatedAt     time.Time       `json:"created_at"`
+}
+
+// SleepLogSource records where an imported sleep log came from. A user has
+// at most one log per source and ID, so importing it again updates that log.
+type SleepLogSource struct {
+	Name string `json:"name"` // importer name, e.g. "fitbit"
+	ID   string `json:"id"`   // the log's ID in the source
+	// Metadata holds the source's fields that have no place in a sleep
+	// log, as the source wrote them.
+	Metadata map[string]any `json:"metadata,omitempty"`
 }
 
 type Goal struct {
@@ -26,3 +207,38 @@ type Goal struct {
 	Value     string    `json:"value"`
 	CreatedAt time.Time `json:"created_at"`
 }
+
+type SleepStats struct {
+	AverageQuality float64 `json:"average_quality"`
+	Trend          []int   `json:"trend"`
+}
+
+// DailyAggregate summarises one user's sleep for one UTC calendar day. Logs
+// are assigned to the day their StartTime falls on.
+type DailyAggregate struct {
+	UserID            string    `json:"user_id"`
+	Date              string    `json:"date"` // YYYY-MM-DD
+	LogCount          int       `json:"log_count"`
+	TotalSleepSeconds int64     `json:"total_sleep_seconds"`
+	QualitySum        int       `json:"quality_sum"`
+	InterruptionCount int       `json:"interruption_count"`
+	Bedtime           time.Time `json:"bedtime"`        // earliest start of the day
+	BedtimeOffset     int       `json:"bedtime_offset"` // seconds east of UTC where that log started
+	WakeTime          time.Time `json:"wake_time"`      // latest end of the day
+}
+
+// LocalBedtime is the bedtime on the clock of the log it came from.
+func (a DailyAggregate) LocalBedtime() time.Time {
+	return a.Bedtime.In(time.FixedZone("", a.BedtimeOffset))
+}
+
+func (a DailyAggregate) AverageQuality() float64 {
+	if a.LogCount == 0 {
+		return 0
+	}
+	return float64(a.QualitySum) / float64(a.LogCount)
+}
+
+func (a DailyAggregate) TotalSleep() time.Duration {
+	return time.Duration(a.TotalSleepSeconds) * time.Second
+}
@@ -0,0 +1,154 @@
+package openapi
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/yourname/sleeptracker/internal"
+)
+
+// requestError turns a failed request check into a *internal.ValidationError
+// naming each rejected field, with the codes the handlers' own validation
+// uses. Failures that are not about one field, such as a body that is not
+// JSON, are reported as they are.
+func requestError(err error) error {
+	verr := &internal.ValidationError{}
+	var other error
+	var walk func(field string, err error)
+	walk = func(field string, err error) {
+		switch e := err.(type) {
+		case openapi3.MultiError:
+			for _, err := range e {
+				walk(field, err)
+			}
+		case *openapi3filter.RequestError:
+			if e.Parameter != nil {
+				field = e.Parameter.Name
+			}
+			_, malformed := e.Err.(*openapi3filter.ParseError)
+			switch {
+			case e.Parameter != nil && errors.Is(e.Err, openapi3filter.ErrInvalidRequired):
+				verr.Fields = append(verr.Fields, internal.FieldError{Field: field, Code: "required", Message: "is required"})
+			case e.Parameter != nil && malformed && e.Parameter.Schema != nil:
+				expected := jsonType(e.Parameter.Schema.Value)
+				verr.Fields = append(verr.Fields, internal.FieldError{
+					Field:   field,
+					Code:    "type",
+					Message: "must be a " + expected,
+					Params:  map[string]string{"expected": expected},
+				})
+			case e.Err == nil || malformed:
+				other = e
+			default:
+				walk(field, e.Err)
+			}
+		case *openapi3.SchemaError:
+			name := fieldPath(field, e.JSONPointer())
+			if name == "" {
+				other = e
+				return
+			}
+			verr.Fields = append(verr.Fields, translate(name, e))
+		default:
+			other = err
+		}
+	}
+	walk("", err)
+
+	if len(verr.Fields) > 0 {
+		return verr
+	}
+	if other == nil {
+		other = err
+	}
+	return internal.WrapError(internal.ErrValidation, other)
+}
+
+// fieldPath names a value inside a parameter or body the way the handlers'
+// validation does: interruptions[2].
+func fieldPath(field string, pointer []string) string {
+	for _, p := range pointer {
+		if _, err := strconv.Atoi(p); err == nil {
+			field += "[" + p + "]"
+		} else if field == "" {
+			field = p
+		} else {
+			field += "." + p
+		}
+	}
+	return field
+}
+
+// translate turns a failed schema keyword into a FieldError. Keywords that
+// mean the same thing share a code, as they do for validate tags: maximum,
+// maxLength and maxItems all report as max.
+func translate(field string, err *openapi3.SchemaError) internal.FieldError {
+	s := err.Schema
+	f := internal.FieldError{Field: field, Code: err.SchemaField, Message: err.Reason}
+	switch err.SchemaField {
+	case "required":
+		f.Message = "is required"
+	case "type":
+		expected := jsonType(s)
+		f.Message, f.Params = "must be a JSON "+expected, map[string]string{"expected": expected}
+	case "enum":
+		values := make([]string, len(s.Enum))
+		for i, v := range s.Enum {
+			values[i] = toString(v)
+		}
+		f.Code, f.Message = "one_of", "must be one of: "+strings.Join(values, ", ")
+		f.Params = map[string]string{"values": strings.Join(values, ",")}
+	case "minimum":
+		f.Code, f.Params = "min", map[string]string{"min": formatNumber(*s.Min)}
+		f.Message = "must be at least " + f.Params["min"]
+	case "maximum":
+		f.Code, f.Params = "max", map[string]string{"max": formatNumber(*s.Max)}
+		f.Message = "must be at most " + f.Params["max"]
+	case "minLength", "minItems":
+		n, unit := s.MinLength, "characters"
+		if err.SchemaField == "minItems" {
+			n, unit = s.MinItems, "items"
+		}
+		f.Code, f.Params = "min", map[string]string{"min": strconv.FormatUint(n, 10)}
+		f.Message = "must have at least " + f.Params["min"] + " " + unit
+	case "maxLength", "maxItems":
+		n, unit := s.MaxLength, "characters"
+		if err.SchemaField == "maxItems" {
+			n, unit = s.MaxItems, "items"
+		}
+		f.Code, f.Params = "max", map[string]string{"max": strconv.FormatUint(*n, 10)}
+		f.Message = "must have at most " + f.Params["max"] + " " + unit
+	case "format":
+		f.Params = map[string]string{"format": s.Format}
+		f.Message = "must be a valid " + s.Format
+	}
+	return f
+}
+
+// jsonType names the JSON type a schema expects. JSON has no integers, so
+// those are numbers.
+func jsonType(s *openapi3.Schema) string {
+	if s.Type == nil || len(s.Type.Slice()) == 0 {
+		return "value"
+	}
+	t := s.Type.Slice()[0]
+	if t == openapi3.TypeInteger {
+		return openapi3.TypeNumber
+	}
+	return t
+}
+
+func formatNumber(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+func toString(v any) string {
+	if f, ok := v.(float64); ok {
+		return formatNumber(f)
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,189 @@
+// Package openapi checks API traffic against the OpenAPI document that
+// describes it.
+package openapi
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/gin-gonic/gin"
+	"github.com/yourname/sleeptracker/internal"
+	"github.com/yourname/sleeptracker/internal/response"
+)
+
+// Spec is the OpenAPI document for the /api/v1 routes.
+//
+//go:embed openapi.yaml
+var Spec []byte
+
+// Validator rejects requests that do not conform to Spec before they reach
+// a handler.
+type Validator struct {
+	doc     *openapi3.T
+	logger  internal.Logger
+	options *openapi3filter.Options
+	// CheckResponses also checks what handlers send, replacing a response
+	// that does not conform with a 500. It is on in gin's test mode, so that
+	// drift between the handlers and Spec fails the tests.
+	CheckResponses bool
+}
+
+// NewValidator loads Spec, failing if it is not a valid OpenAPI document.
+func NewValidator(logger internal.Logger) (*Validator, error) {
+	loader := openapi3.NewLoader()
+	doc, err := loader.LoadFromData(Spec)
+	if err != nil {
+		return nil, fmt.Errorf("openapi: load spec: %w", err)
+	}
+	if err := doc.Validate(loader.Context); err != nil {
+		return nil, fmt.Errorf("openapi: invalid spec: %w", err)
+	}
+	return &Validator{
+		doc:    doc,
+		logger: logger,
+		options: &openapi3filter.Options{
+			MultiError:            true,
+			IncludeResponseStatus: true,
+			SkipSettingDefaults:   true,
+			// Credentials are checked by auth.AuthMiddleware.
+			AuthenticationFunc: openapi3filter.NoopAuthenticationFunc,
+		},
+		CheckResponses: gin.Mode() == gin.TestMode,
+	}, nil
+}
+
+var (
+	ginParam  = regexp.MustCompile(`:(\w+)`)
+	specParam = regexp.MustCompile(`\{(\w+)\}`)
+)
+
+// Operations lists the documented operations as "METHOD /path", with path
+// parameters written the way gin writes them (/sleep-logs/:id).
+func (v *Validator) Operations() []string {
+	var ops []string
+	for path, item := range v.doc.Paths.Map() {
+		for method := range item.Operations() {
+			ops = append(ops, method+" "+specParam.ReplaceAllString(path, ":$1"))
+		}
+	}
+	sort.Strings(ops)
+	return ops
+}
+
+// Middleware returns the handler that validates requests for the route
+// method and path, a gin pattern relative to the server URL in Spec, or nil
+// if Spec does not document that route.
+func (v *Validator) Middleware(method, path string) gin.HandlerFunc {
+	specPath := ginParam.ReplaceAllString(path, "{$1}")
+	item := v.doc.Paths.Value(specPath)
+	if item == nil {
+		return nil
+	}
+	op := item.GetOperation(method)
+	if op == nil {
+		return nil
+	}
+	route := &routers.Route{Spec: v.doc, Path: specPath, PathItem: item, Method: method, Operation: op}
+	options := v.options
+	if binaryBody(op) {
+		// Binary bodies, such as exports from other apps, have no schema to
+		// check, and reading them here would hold them in memory.
+		o := *v.options
+		o.ExcludeRequestBody = true
+		options = &o
+	}
+
+	return func(c *gin.Context) {
+		requestID := c.GetString("request_id")
+		params := make(map[string]string, len(c.Params))
+		for _, p := range c.Params {
+			params[p.Key] = p.Value
+		}
+		input := &openapi3filter.RequestValidationInput{
+			Request:    c.Request,
+			PathParams: params,
+			Route:      route,
+			Options:    options,
+		}
+		if err := openapi3filter.ValidateRequest(c.Request.Context(), input); err != nil {
+			v.logger.Infof("[request_id=%s] request does not match the spec: %v", requestID, err)
+			response.Error(c, requestError(err), "Invalid request")
+			return
+		}
+		if !v.CheckResponses {
+			c.Next()
+			return
+		}
+
+		rec := &recorder{ResponseWriter: c.Writer}
+		c.Writer = rec
+		c.Next()
+		c.Writer = rec.ResponseWriter
+
+		out := &openapi3filter.ResponseValidationInput{
+			RequestValidationInput: input,
+			Status:                 c.Writer.Status(),
+			Header:                 c.Writer.Header(),
+			Options:                v.options,
+		}
+		if err := openapi3filter.ValidateResponse(c.Request.Context(), out.SetBodyBytes(rec.body.Bytes())); err != nil {
+			v.logger.Errorf("[request_id=%s] %s %s: response does not match the spec: %v", requestID, method, path, err)
+			c.Writer.Header().Del("ETag")
+			c.Writer.Header().Del("Location")
+			response.Error(c, internal.NewAppError(http.StatusInternalServerError, err.Error()), "Response does not match the OpenAPI spec")
+			return
+		}
+		if rec.body.Len() > 0 {
+			_, _ = c.Writer.Write(rec.body.Bytes())
+		}
+	}
+}
+
+// binaryBody reports whether every media type op accepts is a binary
+// format.
+func binaryBody(op *openapi3.Operation) bool {
+	if op.RequestBody == nil || op.RequestBody.Value == nil || len(op.RequestBody.Value.Content) == 0 {
+		return false
+	}
+	for _, media := range op.RequestBody.Value.Content {
+		if media.Schema == nil || media.Schema.Value == nil || media.Schema.Value.Format != "binary" {
+			return false
+		}
+	}
+	return true
+}
+
+// recorder holds back the response body so it can be checked before it is
+// sent. The status and headers go to the underlying writer, which does not
+// send them until the body is written.
+type recorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (r *recorder) Write(b []byte) (int, error) {
+	return r.body.Write(b)
+}
+
+func (r *recorder) WriteString(s string) (int, error) {
+	return r.body.WriteString(s)
+}
+
+// Flush does nothing: flushing would send the status and headers before the
+// body has been checked.
+func (r *recorder) Flush() {}
+
+func (r *recorder) Written() bool {
+	return r.ResponseWriter.Written() || r.body.Len() > 0
+}
+
+func (r *recorder) Size() int {
+	return r.body.Len()
+}
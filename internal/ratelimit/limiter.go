@@ -0,0 +1,142 @@
+// Package ratelimit provides token-bucket rate limiting for HTTP routes.
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Limit allows Requests per Period, in bursts of up to Requests. The zero
+// Limit disables limiting.
+type Limit struct {
+	Requests int
+	Period   time.Duration
+}
+
+func (l Limit) Enabled() bool {
+	return l.Requests > 0 && l.Period > 0
+}
+
+func (l Limit) String() string {
+	if !l.Enabled() {
+		return "off"
+	}
+	return fmt.Sprintf("%d/%s", l.Requests, l.Period)
+}
+
+// ParseLimit parses "N/period", such as "60/1m". An empty string, "0" or
+// "off" is the zero Limit.
+func ParseLimit(s string) (Limit, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || s == "0" || s == "off" {
+		return Limit{}, nil
+	}
+	n, period, ok := strings.Cut(s, "/")
+	if !ok {
+		return Limit{}, errors.New("rate limit must look like 60/1m")
+	}
+	requests, err := strconv.Atoi(n)
+	if err != nil || requests < 0 {
+		return Limit{}, errors.New("rate limit must start with a request count")
+	}
+	d, err := time.ParseDuration(period)
+	if err != nil || d <= 0 {
+		return Limit{}, errors.New("rate limit period must be a positive duration such as 1m")
+	}
+	return Limit{Requests: requests, Period: d}, nil
+}
+
+// Result describes a key's bucket after a Take.
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is how long until the bucket is full again.
+	Reset time.Duration
+	// RetryAfter is how long until a request would be allowed; zero when
+	// Allowed.
+	RetryAfter time.Duration
+}
+
+// Limiter holds the buckets. Implementations must be safe for concurrent use;
+// MemoryLimiter keeps them in process, and a shared store can implement the
+// same interface when the API runs on several instances.
+type Limiter interface {
+	// Take removes n tokens from key's bucket if it holds at least n. With
+	// n = 0 it only reports whether a request would currently be allowed.
+	Take(ctx context.Context, key string, limit Limit, n int) (Result, error)
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	period  time.Duration // an idle bucket is full again after period
+}
+
+// MemoryLimiter is an in-process Limiter.
+type MemoryLimiter struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+// sweepInterval is how often buckets that have refilled completely, and so
+// carry no state, are dropped.
+const sweepInterval = time.Minute
+
+func NewMemoryLimiter() *MemoryLimiter {
+	return &MemoryLimiter{buckets: make(map[string]*bucket), lastSweep: time.Now()}
+}
+
+func (m *MemoryLimiter) Take(ctx context.Context, key string, limit Limit, n int) (Result, error) {
+	if !limit.Enabled() {
+		return Result{Allowed: true}, nil
+	}
+	now := time.Now()
+	capacity := float64(limit.Requests)
+	perToken := limit.Period / time.Duration(limit.Requests)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if now.Sub(m.lastSweep) > sweepInterval {
+		m.sweep(now)
+	}
+
+	b, ok := m.buckets[key]
+	if !ok {
+		b = &bucket{tokens: capacity, updated: now, period: limit.Period}
+		m.buckets[key] = b
+	}
+	b.tokens = math.Min(capacity, b.tokens+float64(now.Sub(b.updated))/float64(perToken))
+	b.updated = now
+
+	res := Result{Limit: limit.Requests}
+	need := math.Max(float64(n), 1)
+	if b.tokens >= need {
+		res.Allowed = true
+		b.tokens -= float64(n)
+	} else {
+		res.RetryAfter = time.Duration((need - b.tokens) * float64(perToken))
+	}
+	res.Remaining = int(b.tokens)
+	res.Reset = time.Duration((capacity - b.tokens) * float64(perToken))
+	return res, nil
+}
+
+// sweep drops buckets idle long enough to have refilled.
+func (m *MemoryLimiter) sweep(now time.Time) {
+	for key, b := range m.buckets {
+		if now.Sub(b.updated) > b.period {
+			delete(m.buckets, key)
+		}
+	}
+	m.lastSweep = now
+}
+
+var _ Limiter = (*MemoryLimiter)(nil)
@@ -0,0 +1,88 @@
+package ratelimit
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yourname/sleeptracker/internal"
+	"github.com/yourname/sleeptracker/internal/response"
+)
+
+// Middleware limits requests to the route group name. Requests are keyed by
+// the authenticated user when AuthMiddleware has run, otherwise by client IP.
+// If the limiter fails, requests are let through.
+func Middleware(l Limiter, name string, limit Limit, logger internal.Logger) gin.HandlerFunc {
+	if !limit.Enabled() {
+		return func(c *gin.Context) { c.Next() }
+	}
+	return func(c *gin.Context) {
+		res, err := l.Take(c.Request.Context(), key(c, name), limit, 1)
+		if err != nil {
+			logger.Errorf("rate limiter %s: %v", name, err)
+			c.Next()
+			return
+		}
+		setHeaders(c, limit, res)
+		if !res.Allowed {
+			reject(c, res)
+			return
+		}
+		c.Next()
+	}
+}
+
+// FailureMiddleware limits failed authentication per client IP: each request
+// answered with 401 takes a token, and once the bucket is empty the client is
+// turned away before its credentials are checked.
+func FailureMiddleware(l Limiter, name string, limit Limit, logger internal.Logger) gin.HandlerFunc {
+	if !limit.Enabled() {
+		return func(c *gin.Context) { c.Next() }
+	}
+	return func(c *gin.Context) {
+		k := name + ":ip:" + c.ClientIP()
+		res, err := l.Take(c.Request.Context(), k, limit, 0)
+		if err != nil {
+			logger.Errorf("rate limiter %s: %v", name, err)
+			c.Next()
+			return
+		}
+		if !res.Allowed {
+			setHeaders(c, limit, res)
+			reject(c, res)
+			return
+		}
+		c.Next()
+		if c.Writer.Status() == http.StatusUnauthorized {
+			if _, err := l.Take(c.Request.Context(), k, limit, 1); err != nil {
+				logger.Errorf("rate limiter %s: %v", name, err)
+			}
+		}
+	}
+}
+
+func key(c *gin.Context, name string) string {
+	if u, ok := c.Value("user").(*internal.User); ok {
+		return name + ":user:" + u.ID
+	}
+	return name + ":ip:" + c.ClientIP()
+}
+
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
+
+func setHeaders(c *gin.Context, limit Limit, res Result) {
+	h := c.Writer.Header()
+	h.Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+	h.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+	h.Set("RateLimit-Reset", seconds(res.Reset))
+	h.Set("RateLimit-Policy", strconv.Itoa(limit.Requests)+";w="+seconds(limit.Period))
+}
+
+func reject(c *gin.Context, res Result) {
+	c.Header("Retry-After", seconds(res.RetryAfter))
+	response.Error(c, internal.NewAppError(http.StatusTooManyRequests, "retry in "+seconds(res.RetryAfter)+"s"), "Too many requests")
+}
@@ -0,0 +1,92 @@
+package response
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yourname/sleeptracker/internal"
+)
+
+// ProblemContentType is the media type of RFC 7807 problem details. Clients
+// that accept it get errors in that form instead of the usual envelope.
+const ProblemContentType = "application/problem+json"
+
+// problemTypeBase prefixes the type URI of each error kind.
+const problemTypeBase = "urn:sleeptracker:problem:"
+
+// Problem is an RFC 7807 problem details object.
+type Problem struct {
+	Type          string                `json:"type"`
+	Title         string                `json:"title"`
+	Status        int                   `json:"status"`
+	Detail        string                `json:"detail,omitempty"`
+	Instance      string                `json:"instance,omitempty"`
+	RequestID     string                `json:"request_id,omitempty"`
+	InvalidParams []internal.FieldError `json:"invalid_params,omitempty"`
+}
+
+var errorKinds = []struct {
+	kind   error
+	status int
+	name   string
+}{
+	{internal.ErrValidation, http.StatusBadRequest, "validation"},
+	{internal.ErrUnauthorized, http.StatusUnauthorized, "unauthorized"},
+	{internal.ErrForbidden, http.StatusForbidden, "forbidden"},
+	{internal.ErrNotFound, http.StatusNotFound, "not-found"},
+	{internal.ErrConflict, http.StatusConflict, "conflict"},
+}
+
+// Classify maps err to its HTTP status, problem type URI and the detail that
+// may be shown to clients. An AppError keeps its own code and message, an
+// error of one of the internal kinds gets that kind's status and its own
+// message, and anything else is a 500 with no detail, since its text may
+// expose internals such as SQL errors.
+func Classify(err error) (status int, problemType, detail string) {
+	var appErr *internal.AppError
+	if errors.As(err, &appErr) {
+		return appErr.Code, "about:blank", appErr.Message
+	}
+	for _, k := range errorKinds {
+		if errors.Is(err, k.kind) {
+			return k.status, problemTypeBase + k.name, err.Error()
+		}
+	}
+	return http.StatusInternalServerError, "about:blank", ""
+}
+
+// Error aborts the request with err, described by msg. The body is problem
+// details if the client accepts them, and the usual envelope otherwise.
+func Error(c *gin.Context, err error, msg string) {
+	status, problemType, detail := Classify(err)
+	message := msg
+	if detail != "" {
+		message += ": " + detail
+	}
+
+	var fields []internal.FieldError
+	var validationErr *internal.ValidationError
+	if errors.As(err, &validationErr) {
+		fields = validationErr.Fields
+	}
+
+	if !strings.Contains(c.GetHeader("Accept"), ProblemContentType) {
+		resp := NewAppError(status, message)
+		resp.Error.Fields = fields
+		c.AbortWithStatusJSON(status, resp)
+		return
+	}
+	problem := Problem{
+		Type:          problemType,
+		Title:         http.StatusText(status),
+		Status:        status,
+		Detail:        message,
+		Instance:      c.Request.URL.Path,
+		RequestID:     c.GetString("request_id"),
+		InvalidParams: fields,
+	}
+	c.Header("Content-Type", ProblemContentType)
+	c.AbortWithStatusJSON(status, problem)
+}
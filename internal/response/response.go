@@ -20,6 +20,10 @@ func InternalError(msg string) APIResponse {
 	return APIResponse{Error: internal.NewAppError(500, msg)}
 }
 
+func Forbidden(msg string) APIResponse {
+	return APIResponse{Error: internal.NewAppError(403, msg)}
+}
+
 func NotFound(msg string) APIResponse {
 	return APIResponse{Error: internal.NewAppError(404, msg)}
 }
@@ -0,0 +1,110 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/yourname/sleeptracker/internal"
+	"github.com/yourname/sleeptracker/internal/storage"
+)
+
+var (
+	ErrUserNotFound     = internal.NewError(internal.ErrNotFound, "user not found")
+	ErrCannotTargetSelf = internal.NewError(internal.ErrConflict, "admins cannot disable or demote themselves")
+)
+
+type RoleRequest struct {
+	Role string `json:"role" validate:"required,oneof=user viewer admin"`
+}
+
+func ValidateRoleRequest(req *RoleRequest) error {
+	return validateStruct(req)
+}
+
+// BootstrapAdmins grants the admin role to existing accounts whose email is
+// in adminEmails. Emails without an account are skipped; once registered,
+// they are promoted at the next start, or by an admin.
+func BootstrapAdmins(ctx context.Context, userRepo storage.UserRepository, adminEmails []string, logger internal.Logger) error {
+	for _, email := range adminEmails {
+		user, err := userRepo.GetUserByEmail(ctx, normalizeEmail(email))
+		if errors.Is(err, storage.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if user.Role == internal.RoleAdmin {
+			continue
+		}
+		user.Role = internal.RoleAdmin
+		if err := userRepo.UpdateUser(ctx, user); err != nil {
+			return err
+		}
+		logger.Infof("granted admin role to %s", user.Email)
+	}
+	return nil
+}
+
+func ListUsers(ctx context.Context, userRepo storage.UserRepository) ([]internal.User, error) {
+	return userRepo.ListUsers(ctx)
+}
+
+func getTargetUser(ctx context.Context, userRepo storage.UserRepository, actor *internal.User, id string) (*internal.User, error) {
+	if id == actor.ID {
+		return nil, ErrCannotTargetSelf
+	}
+	user, err := userRepo.GetUserByID(ctx, id)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, ErrUserNotFound
+	}
+	return user, err
+}
+
+// SetUserDisabled disables or re-enables an account. Disabling also revokes
+// every active session of the account.
+func SetUserDisabled(ctx context.Context, userRepo storage.UserRepository, sessionRepo storage.SessionRepository, actor *internal.User, id string, disabled bool) (*internal.User, error) {
+	user, err := getTargetUser(ctx, userRepo, actor, id)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	switch {
+	case disabled && user.DisabledAt == nil:
+		user.DisabledAt = &now
+	case !disabled:
+		user.DisabledAt = nil
+	}
+	if err := userRepo.UpdateUser(ctx, user); err != nil {
+		return nil, err
+	}
+	if !disabled {
+		return user, nil
+	}
+
+	sessions, err := sessionRepo.ListSessions(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range sessions {
+		if s.Active(now) {
+			if err := sessionRepo.RevokeSession(ctx, s.ID, now); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return user, nil
+}
+
+func SetUserRole(ctx context.Context, userRepo storage.UserRepository, actor *internal.User, id string, req *RoleRequest) (*internal.User, error) {
+	user, err := getTargetUser(ctx, userRepo, actor, id)
+	if err != nil {
+		return nil, err
+	}
+	user.Role = strings.ToLower(req.Role)
+	if err := userRepo.UpdateUser(ctx, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
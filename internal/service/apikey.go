@@ -0,0 +1,107 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yourname/sleeptracker/internal"
+	"github.com/yourname/sleeptracker/internal/auth"
+	"github.com/yourname/sleeptracker/internal/storage"
+)
+
+var ErrAPIKeyNotFound = internal.NewError(internal.ErrNotFound, "api key not found")
+
+type APIKeyRequest struct {
+	Name   string   `json:"name" validate:"required,max=100"`
+	Scopes []string `json:"scopes" validate:"required,min=1,dive,oneof=sleep:read sleep:write goals:read goals:write stats:read"`
+}
+
+// APIKeyView is an API key as shown to its owner, without the hash.
+type APIKeyView struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+// CreatedAPIKey carries the full key, which is only ever shown once.
+type CreatedAPIKey struct {
+	APIKeyView
+	Key string `json:"key"`
+}
+
+func ValidateAPIKeyRequest(req *APIKeyRequest) error {
+	return validateStruct(req)
+}
+
+func newAPIKeyView(k internal.APIKey) APIKeyView {
+	return APIKeyView{ID: k.ID, Name: k.Name, Prefix: k.Prefix, Scopes: k.Scopes, CreatedAt: k.CreatedAt, LastUsedAt: k.LastUsedAt}
+}
+
+func CreateAPIKey(ctx context.Context, keyRepo storage.APIKeyRepository, user *internal.User, req *APIKeyRequest) (*CreatedAPIKey, error) {
+	key, prefix, hash, err := auth.NewAPIKey()
+	if err != nil {
+		return nil, err
+	}
+	// Drop duplicate scopes but keep the order they were given in.
+	scopes := []string{}
+	seen := make(map[string]bool)
+	for _, s := range req.Scopes {
+		if !seen[s] {
+			seen[s] = true
+			scopes = append(scopes, s)
+		}
+	}
+	apiKey := &internal.APIKey{
+		ID:        uuid.NewString(),
+		UserID:    user.ID,
+		Name:      strings.TrimSpace(req.Name),
+		Prefix:    prefix,
+		Hash:      hash,
+		Scopes:    scopes,
+		CreatedAt: time.Now(),
+	}
+	if err := keyRepo.CreateAPIKey(ctx, apiKey); err != nil {
+		return nil, err
+	}
+	return &CreatedAPIKey{APIKeyView: newAPIKeyView(*apiKey), Key: key}, nil
+}
+
+func ListAPIKeys(ctx context.Context, keyRepo storage.APIKeyRepository, user *internal.User) ([]APIKeyView, error) {
+	keys, err := keyRepo.ListAPIKeys(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	views := make([]APIKeyView, len(keys))
+	for i, k := range keys {
+		views[i] = newAPIKeyView(k)
+	}
+	return views, nil
+}
+
+func GetAPIKey(ctx context.Context, keyRepo storage.APIKeyRepository, user *internal.User, id string) (*APIKeyView, error) {
+	keys, err := keyRepo.ListAPIKeys(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, k := range keys {
+		if k.ID == id {
+			view := newAPIKeyView(k)
+			return &view, nil
+		}
+	}
+	return nil, ErrAPIKeyNotFound
+}
+
+func RevokeAPIKey(ctx context.Context, keyRepo storage.APIKeyRepository, user *internal.User, id string) error {
+	err := keyRepo.DeleteAPIKey(ctx, user.ID, id)
+	if errors.Is(err, storage.ErrNotFound) {
+		return ErrAPIKeyNotFound
+	}
+	return err
+}
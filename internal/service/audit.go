@@ -0,0 +1,100 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yourname/sleeptracker/internal"
+	"github.com/yourname/sleeptracker/internal/storage"
+)
+
+const defaultAuditLimit = 100
+
+var ErrAuditRange error = &internal.ValidationError{Fields: []internal.FieldError{{
+	Field: "until", Code: "after", Message: "must be after since", Params: map[string]string{"field": "since"},
+}}}
+
+// AuditQuery filters the audit log. Times are RFC 3339.
+type AuditQuery struct {
+	UserID string    `form:"user_id"`
+	Action string    `form:"action"`
+	Since  time.Time `form:"since" time_format:"2006-01-02T15:04:05Z07:00"`
+	Until  time.Time `form:"until" time_format:"2006-01-02T15:04:05Z07:00"`
+	Limit  int       `form:"limit" validate:"omitempty,min=1,max=1000"`
+}
+
+func ValidateAuditQuery(q *AuditQuery) error {
+	if err := validateStruct(q); err != nil {
+		return err
+	}
+	if !q.Since.IsZero() && !q.Until.IsZero() && !q.Until.After(q.Since) {
+		return ErrAuditRange
+	}
+	return nil
+}
+
+func (q *AuditQuery) filter() storage.AuditFilter {
+	f := storage.AuditFilter{UserID: q.UserID, Action: q.Action, Since: q.Since, Until: q.Until, Limit: q.Limit}
+	if f.Limit == 0 {
+		f.Limit = defaultAuditLimit
+	}
+	return f
+}
+
+// RecordAuditEvent appends the event to the audit log, stamping its ID and
+// time.
+func RecordAuditEvent(ctx context.Context, auditRepo storage.AuditRepository, event *internal.AuditEvent) error {
+	event.ID = uuid.NewString()
+	event.At = time.Now()
+	return auditRepo.AppendAuditEvent(ctx, event)
+}
+
+// ListAuditEvents returns matching events from the whole audit log, newest
+// first.
+func ListAuditEvents(ctx context.Context, auditRepo storage.AuditRepository, q *AuditQuery) ([]internal.AuditEvent, error) {
+	return auditRepo.ListAuditEvents(ctx, q.filter())
+}
+
+// ListOwnAuditEvents returns matching events the user performed or was the
+// target of, newest first. q.UserID is ignored. Events someone else
+// performed lose their IP, and admin actions their actor too.
+func ListOwnAuditEvents(ctx context.Context, auditRepo storage.AuditRepository, user *internal.User, q *AuditQuery) ([]internal.AuditEvent, error) {
+	f := q.filter()
+	f.UserID = user.ID
+	events, err := auditRepo.ListAuditEvents(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+	for i := range events {
+		redactAuditEvent(&events[i], user.ID)
+	}
+	return events, nil
+}
+
+// adminAuditActions are the actions only admins perform on other accounts.
+var adminAuditActions = map[string]bool{
+	internal.AuditUserDisable: true,
+	internal.AuditUserEnable:  true,
+	internal.AuditUserRole:    true,
+}
+
+func redactAuditEvent(event *internal.AuditEvent, userID string) {
+	if event.ActorID == userID {
+		return
+	}
+	event.IP = ""
+	if adminAuditActions[event.Action] {
+		event.ActorID = ""
+	}
+}
+
+// UserIDByEmail returns the ID of the account with the email, or "" if there
+// is none. It lets failed logins be recorded against the targeted account.
+func UserIDByEmail(ctx context.Context, userRepo storage.UserRepository, email string) string {
+	user, err := UserByEmail(ctx, userRepo, email)
+	if err != nil {
+		return ""
+	}
+	return user.ID
+}
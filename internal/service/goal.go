@@ -2,6 +2,7 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
@@ -10,20 +11,22 @@ import (
 	"github.com/yourname/sleeptracker/internal/storage"
 )
 
+var ErrGoalNotFound = internal.NewError(internal.ErrNotFound, "no goal set")
+
 type GoalRequest struct {
-	Type  string `validate:"required,oneof=duration consistency quality"`
-	Value string `validate:"required"`
+	Type  string `json:"type" validate:"required,oneof=duration consistency quality"`
+	Value string `json:"value" validate:"required"`
 }
 
 type GoalProgress struct {
-	Goal      *internal.Goal
-	Progress  []map[string]interface{}
-	MetDays   int
-	TotalDays int
+	Goal      *internal.Goal           `json:"goal"`
+	Progress  []map[string]interface{} `json:"progress"`
+	MetDays   int                      `json:"met_days"`
+	TotalDays int                      `json:"total_days"`
 }
 
 func ValidateGoalRequest(req *GoalRequest) error {
-	if err := validate.Struct(req); err != nil {
+	if err := validateStruct(req); err != nil {
 		return err
 	}
 	return nil
@@ -43,13 +46,17 @@ func CreateGoal(ctx context.Context, goalRepo storage.GoalRepository, user *inte
 	return goal, nil
 }
 
-func CalculateGoalProgress(goal *internal.Goal, logs []internal.SleepLog) GoalProgress {
-	cutoff := time.Now().AddDate(0, 0, -7)
-	days := []map[string]interface{}{}
+// CalculateGoalProgress evaluates the goal for each day of the last week,
+// not for each log: a day meets a duration goal on its total sleep, a
+// quality goal on its average quality, and a consistency goal on its
+// earliest bedtime, on the clock of the log it came from.
+func CalculateGoalProgress(goal *internal.Goal, days []internal.DailyAggregate) GoalProgress {
+	cutoff := storage.DayOf(time.Now().AddDate(0, 0, -statsWindow))
+	progress := []map[string]interface{}{}
 	metCount := 0
 
-	for _, l := range logs {
-		if l.StartTime.Before(cutoff) {
+	for _, d := range days {
+		if d.Date <= cutoff {
 			break
 		}
 
@@ -58,35 +65,55 @@ func CalculateGoalProgress(goal *internal.Goal, logs []internal.SleepLog) GoalPr
 		case "duration":
 			var durGoal float64
 			fmt.Sscanf(goal.Value, "%fh", &durGoal)
-			sleepDur := l.EndTime.Sub(l.StartTime).Hours()
-			met = sleepDur >= durGoal
+			met = d.TotalSleep().Hours() >= durGoal
 		case "consistency":
 			var hour int
 			fmt.Sscanf(goal.Value, "before %d", &hour)
 			if hour == 0 {
 				hour = 23
 			}
-			met = l.StartTime.Hour() < hour
+			met = d.LocalBedtime().Hour() < hour
 		case "quality":
 			var qualGoal int
 			fmt.Sscanf(goal.Value, "> %d", &qualGoal)
-			met = l.Quality > qualGoal
+			met = d.AverageQuality() > float64(qualGoal)
 		}
 
 		if met {
 			metCount++
 		}
 
-		days = append(days, map[string]interface{}{
-			"date": l.StartTime.Format("2006-01-02"),
+		progress = append(progress, map[string]interface{}{
+			"date": d.Date,
 			"met":  met,
 		})
 	}
 
 	return GoalProgress{
 		Goal:      goal,
-		Progress:  days,
+		Progress:  progress,
 		MetDays:   metCount,
-		TotalDays: len(days),
+		TotalDays: len(progress),
+	}
+}
+
+// GetGoal returns the user's current goal.
+func GetGoal(ctx context.Context, goalRepo storage.GoalRepository, userID string) (*internal.Goal, error) {
+	goal, err := goalRepo.GetGoal(ctx, userID)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, ErrGoalNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return goal, nil
+}
+
+// GetGoalProgress evaluates the user's current goal against recent aggregates.
+func GetGoalProgress(ctx context.Context, goal *internal.Goal, aggRepo storage.AggregateRepository) (GoalProgress, error) {
+	days, err := recentAggregates(ctx, aggRepo, goal.UserID)
+	if err != nil {
+		return GoalProgress{}, err
 	}
+	return CalculateGoalProgress(goal, days), nil
 }
@@ -0,0 +1,472 @@
+package service
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yourname/sleeptracker/internal"
+	"github.com/yourname/sleeptracker/internal/importer"
+	"github.com/yourname/sleeptracker/internal/storage"
+)
+
+// MaxImportRows is the most rows one import may hold.
+const MaxImportRows = 5000
+
+// importFields are the sleep log fields an import reads, in the order
+// they are reported.
+var importFields = []string{"start_time", "end_time", "quality", "reason", "interruptions"}
+
+// ImportOptions controls an import. Columns maps sleep log fields to the
+// CSV header of the column holding them; unmapped fields are read from the
+// column named after the field. Timestamps without a UTC offset are read
+// in TZ, UTC by default.
+type ImportOptions struct {
+	DryRun  bool              `form:"dry_run"`
+	TZ      string            `form:"tz" validate:"max=64"`
+	Columns map[string]string `form:"-"`
+}
+
+// ValidateImportOptions checks o and returns the time zone it names.
+func ValidateImportOptions(o *ImportOptions) (*time.Location, error) {
+	if err := validateStruct(o); err != nil {
+		return nil, err
+	}
+	verr := &internal.ValidationError{}
+	for field := range o.Columns {
+		if !isImportField(field) {
+			verr.Fields = append(verr.Fields, internal.FieldError{
+				Field:   "columns[" + field + "]",
+				Code:    "one_of",
+				Message: "must be one of: " + strings.Join(importFields, ", "),
+				Params:  map[string]string{"values": strings.Join(importFields, ",")},
+			})
+		}
+	}
+	if len(verr.Fields) > 0 {
+		sort.Slice(verr.Fields, func(i, j int) bool { return verr.Fields[i].Field < verr.Fields[j].Field })
+		return nil, verr
+	}
+	return loadTimeZone(o.TZ)
+}
+
+func isImportField(field string) bool {
+	for _, f := range importFields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// ImportRow is one parsed row of an import. Row is its 1-based position in
+// a JSON array, or its line in a CSV file, counting the header as line 1.
+type ImportRow struct {
+	Row     int
+	Request SleepLogRequest
+	// Errors lists fields that could not be parsed.
+	Errors []internal.FieldError
+	// Source is where a row imported from another app came from.
+	Source *internal.SleepLogSource
+}
+
+// ImportRowError says why one row was not imported.
+type ImportRowError struct {
+	Row    int                   `json:"row"`
+	Fields []internal.FieldError `json:"fields"`
+}
+
+// ImportResult reports an import. Rows imported from another app before
+// update the log they created, or are skipped if nothing changed. In a dry
+// run Imported and Updated are zero and Valid is what would have been
+// imported or updated.
+type ImportResult struct {
+	DryRun   bool             `json:"dry_run"`
+	Rows     int              `json:"rows"`
+	Valid    int              `json:"valid"`
+	Imported int              `json:"imported"`
+	Updated  int              `json:"updated"`
+	Skipped  int              `json:"skipped"`
+	Errors   []ImportRowError `json:"errors"`
+}
+
+var errTooManyRows = internal.NewFieldError("rows", "max", "must have at most "+strconv.Itoa(MaxImportRows)+" rows")
+
+// ParseImportJSON reads a JSON array of sleep logs in the shape
+// POST /sleep-logs accepts.
+func ParseImportJSON(r io.Reader, loc *time.Location) ([]ImportRow, error) {
+	var raw []json.RawMessage
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return nil, internal.WrapError(internal.ErrValidation, err)
+	}
+	if len(raw) > MaxImportRows {
+		return nil, errTooManyRows
+	}
+	rows := make([]ImportRow, len(raw))
+	for i, item := range raw {
+		rows[i] = parseJSONRow(i+1, item, loc)
+	}
+	return rows, nil
+}
+
+func parseJSONRow(n int, item json.RawMessage, loc *time.Location) ImportRow {
+	row := ImportRow{Row: n}
+	var in struct {
+		StartTime     string   `json:"start_time"`
+		EndTime       string   `json:"end_time"`
+		Quality       int      `json:"quality"`
+		Reason        string   `json:"reason"`
+		Interruptions []string `json:"interruptions"`
+	}
+	if err := json.Unmarshal(item, &in); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if !errors.As(err, &typeErr) {
+			return rowError(row, "row", "type", "must be a JSON object", map[string]string{"expected": "object"})
+		}
+		field, expected := typeErr.Field, jsonKind(typeErr.Type)
+		if field == "" {
+			field = "row"
+		}
+		return rowError(row, field, "type", "must be a JSON "+expected, map[string]string{"expected": expected})
+	}
+	row.Request = SleepLogRequest{Quality: in.Quality, Reason: in.Reason, Interruptions: in.Interruptions}
+	row.Request.StartTime = parseImportTime(&row, "start_time", in.StartTime, loc)
+	row.Request.EndTime = parseImportTime(&row, "end_time", in.EndTime, loc)
+	return row
+}
+
+func jsonKind(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Int:
+		return "number"
+	case reflect.String:
+		return "string"
+	case reflect.Slice:
+		return "array"
+	}
+	return "object"
+}
+
+// ParseImportCSV reads CSV with a header row. Interruptions are separated
+// by semicolons, as CSV exports write them, and other columns are ignored.
+func ParseImportCSV(r io.Reader, columns map[string]string, loc *time.Location) ([]ImportRow, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	header, err := cr.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, internal.NewFieldError("rows", "required", "must have a header row")
+	}
+	if err != nil {
+		return nil, internal.WrapError(internal.ErrValidation, err)
+	}
+
+	index := make(map[string]int)
+	for i, name := range header {
+		if i == 0 {
+			// Spreadsheets often start UTF-8 files with a byte order mark.
+			name = strings.TrimPrefix(name, "\ufeff")
+		}
+		index[strings.TrimSpace(name)] = i
+	}
+	position := make(map[string]int)
+	verr := &internal.ValidationError{}
+	for _, field := range importFields {
+		name, mapped := columns[field]
+		if !mapped {
+			name = field
+		}
+		i, ok := index[name]
+		if !ok {
+			// Optional fields may be left out unless a column was named.
+			if !mapped && (field == "reason" || field == "interruptions") {
+				position[field] = -1
+				continue
+			}
+			verr.Fields = append(verr.Fields, internal.FieldError{
+				Field:   "columns[" + field + "]",
+				Code:    "required",
+				Message: `names no column of the CSV header: "` + name + `"`,
+			})
+		}
+		position[field] = i
+	}
+	if len(verr.Fields) > 0 {
+		return nil, verr
+	}
+
+	var rows []ImportRow
+	for {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, internal.WrapError(internal.ErrValidation, err)
+		}
+		if len(rows) == MaxImportRows {
+			return nil, errTooManyRows
+		}
+		line, _ := cr.FieldPos(0)
+		value := func(field string) string {
+			if i := position[field]; i >= 0 && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		rows = append(rows, parseCSVRow(line, value, loc))
+	}
+}
+
+func parseCSVRow(line int, value func(string) string, loc *time.Location) ImportRow {
+	row := ImportRow{Row: line}
+	row.Request.StartTime = parseImportTime(&row, "start_time", value("start_time"), loc)
+	row.Request.EndTime = parseImportTime(&row, "end_time", value("end_time"), loc)
+	if q := value("quality"); q != "" {
+		n, err := strconv.Atoi(q)
+		if err != nil {
+			row.Errors = append(row.Errors, internal.FieldError{Field: "quality", Code: "type", Message: "must be a number", Params: map[string]string{"expected": "number"}})
+		}
+		row.Request.Quality = n
+	}
+	row.Request.Reason = value("reason")
+	for _, item := range strings.Split(value("interruptions"), ";") {
+		if item = strings.TrimSpace(item); item != "" {
+			row.Request.Interruptions = append(row.Request.Interruptions, item)
+		}
+	}
+	return row
+}
+
+// importTimeLayouts are the timestamp formats an import accepts. All but
+// the first have no offset and are read in the import's time zone.
+var importTimeLayouts = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02T15:04", "2006-01-02 15:04"}
+
+// parseImportTime parses s, recording a format error on row if it cannot.
+// An empty s is the zero time, which validation rejects as missing.
+func parseImportTime(row *ImportRow, field, s string, loc *time.Location) time.Time {
+	if s == "" {
+		return time.Time{}
+	}
+	for i, layout := range importTimeLayouts {
+		var t time.Time
+		var err error
+		if i == 0 {
+			t, err = time.Parse(layout, s)
+		} else {
+			t, err = time.ParseInLocation(layout, s, loc)
+		}
+		if err == nil {
+			return t
+		}
+	}
+	row.Errors = append(row.Errors, internal.FieldError{Field: field, Code: "format", Message: "must be a valid date-time", Params: map[string]string{"format": "date-time"}})
+	return time.Time{}
+}
+
+func rowError(row ImportRow, field, code, message string, params map[string]string) ImportRow {
+	row.Errors = append(row.Errors, internal.FieldError{Field: field, Code: code, Message: message, Params: params})
+	return row
+}
+
+// ImportSleepLogs validates every row as POST /sleep-logs would and rejects
+// rows that duplicate or overlap the user's logs or an earlier row. A row
+// with a source replaces the log imported from the same source and ID, if
+// there is one. Unless the import is a dry run, the valid rows are saved in
+// one batch.
+func ImportSleepLogs(ctx context.Context, sleepRepo storage.SleepLogRepository, user *internal.User, rows []ImportRow, opts *ImportOptions) (*ImportResult, error) {
+	existing, err := sleepRepo.ListSleepLogs(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	taken := make([]interval, 0, len(existing)+len(rows))
+	imported := make(map[sourceKey]internal.SleepLog)
+	for _, l := range existing {
+		taken = append(taken, interval{start: l.StartTime, end: l.EndTime, id: l.ID})
+		if l.Source != nil {
+			imported[sourceKey{l.Source.Name, l.Source.ID}] = l
+		}
+	}
+
+	result := &ImportResult{DryRun: opts.DryRun, Rows: len(rows), Errors: []ImportRowError{}}
+	now := time.Now()
+	var logs []internal.SleepLog
+	updates := 0
+	sourceRows := make(map[sourceKey]int)
+	for _, row := range rows {
+		fields := row.Errors
+		if len(fields) == 0 {
+			fields = rowValidationErrors(&row.Request)
+		}
+		log := internal.SleepLog{
+			ID:            uuid.NewString(),
+			UserID:        user.ID,
+			StartTime:     row.Request.StartTime,
+			EndTime:       row.Request.EndTime,
+			Quality:       row.Request.Quality,
+			Reason:        row.Request.Reason,
+			Interruptions: row.Request.Interruptions,
+			Source:        row.Source,
+			CreatedAt:     now,
+		}
+		var previous *internal.SleepLog
+		if len(fields) == 0 && row.Source != nil {
+			key := sourceKey{row.Source.Name, row.Source.ID}
+			if first, ok := sourceRows[key]; ok {
+				fields = []internal.FieldError{{Field: "source", Code: "duplicate", Message: "duplicates row " + strconv.Itoa(first),
+					Params: map[string]string{"row": strconv.Itoa(first)}}}
+			} else {
+				sourceRows[key] = row.Row
+			}
+			if prev, ok := imported[key]; ok {
+				previous = &prev
+				log.ID, log.CreatedAt = prev.ID, prev.CreatedAt
+			}
+		}
+		if len(fields) == 0 && previous != nil && sameSleepLog(*previous, log) {
+			result.Skipped++
+			continue
+		}
+		if len(fields) == 0 {
+			next := interval{start: log.StartTime, end: log.EndTime, row: row.Row, id: log.ID}
+			if prev, ok := conflict(taken, next); ok {
+				fields = []internal.FieldError{conflictError(prev, next)}
+			} else {
+				taken = append(taken, next)
+			}
+		}
+		if len(fields) > 0 {
+			result.Errors = append(result.Errors, ImportRowError{Row: row.Row, Fields: fields})
+			continue
+		}
+		if previous != nil {
+			updates++
+		}
+		logs = append(logs, log)
+	}
+
+	result.Valid = len(logs)
+	if opts.DryRun || len(logs) == 0 {
+		return result, nil
+	}
+	if err := sleepRepo.SaveSleepLogs(ctx, logs); errors.Is(err, storage.ErrSourceTaken) {
+		return nil, ErrImportConflict
+	} else if err != nil {
+		return nil, err
+	}
+	result.Imported, result.Updated = len(logs)-updates, updates
+	return result, nil
+}
+
+// ErrImportConflict is returned when another import saved a log from the
+// same source first, as when the same export is imported twice at once.
+var ErrImportConflict = internal.NewError(internal.ErrConflict, "logs from this source were imported concurrently; retry the import")
+
+// sourceKey identifies an imported log by where it came from.
+type sourceKey struct {
+	name, id string
+}
+
+// sameSleepLog reports whether importing b over a would change nothing.
+func sameSleepLog(a, b internal.SleepLog) bool {
+	return a.StartTime.Equal(b.StartTime) && a.EndTime.Equal(b.EndTime) && a.Quality == b.Quality && a.Reason == b.Reason &&
+		reflect.DeepEqual(a.Interruptions, b.Interruptions) && reflect.DeepEqual(a.Source, b.Source)
+}
+
+func rowValidationErrors(req *SleepLogRequest) []internal.FieldError {
+	var verr *internal.ValidationError
+	if err := ValidateSleepLogRequest(req); errors.As(err, &verr) {
+		return verr.Fields
+	}
+	return nil
+}
+
+// interval is the time a stored log, or an earlier imported row, covers.
+// row is zero for stored logs.
+type interval struct {
+	start, end time.Time
+	row        int
+	id         string
+}
+
+func (i interval) same(o interval) bool {
+	return i.start.Equal(o.start) && i.end.Equal(o.end)
+}
+
+// conflict returns the first interval in taken that next duplicates or
+// overlaps, other than the log next replaces. Intervals that only touch,
+// one ending as the next starts, do not overlap.
+func conflict(taken []interval, next interval) (interval, bool) {
+	for _, t := range taken {
+		if t.id == next.id && t.row == 0 {
+			continue
+		}
+		if t.start.Before(next.end) && next.start.Before(t.end) {
+			return t, true
+		}
+	}
+	return interval{}, false
+}
+
+// conflictError describes why next clashes with prev.
+func conflictError(prev, next interval) internal.FieldError {
+	what := "an existing sleep log"
+	params := map[string]string{"start_time": prev.start.UTC().Format(time.RFC3339), "end_time": prev.end.UTC().Format(time.RFC3339)}
+	if prev.row > 0 {
+		what = "row " + strconv.Itoa(prev.row)
+		params["row"] = strconv.Itoa(prev.row)
+	}
+	if prev.same(next) {
+		return internal.FieldError{Field: "start_time", Code: "duplicate", Message: "duplicates " + what, Params: params}
+	}
+	return internal.FieldError{Field: "start_time", Code: "overlap", Message: "overlaps " + what, Params: params}
+}
+
+// ErrImportSourceNotFound is returned for a source no importer is
+// registered under.
+var ErrImportSourceNotFound = internal.NewError(internal.ErrNotFound, "unknown import source")
+
+// ImportSource returns the importer of the app name identifies.
+func ImportSource(name string) (importer.Source, error) {
+	src, ok := importer.Lookup(name)
+	if !ok {
+		return nil, ErrImportSourceNotFound
+	}
+	return src, nil
+}
+
+// ParseSource reads one file exported by another app into rows, one per
+// sleep log, numbered from 1.
+func ParseSource(src importer.Source, r io.Reader, loc *time.Location) ([]ImportRow, error) {
+	logs, err := src.Parse(r, loc)
+	if err != nil {
+		return nil, internal.WrapError(internal.ErrValidation, err)
+	}
+	return SourceRows(logs)
+}
+
+// SourceRows numbers sleep logs read from other apps as rows.
+func SourceRows(logs []internal.SleepLog) ([]ImportRow, error) {
+	if len(logs) > MaxImportRows {
+		return nil, errTooManyRows
+	}
+	rows := make([]ImportRow, len(logs))
+	for i, l := range logs {
+		rows[i] = ImportRow{Row: i + 1, Source: l.Source, Request: SleepLogRequest{
+			StartTime:     l.StartTime,
+			EndTime:       l.EndTime,
+			Quality:       l.Quality,
+			Reason:        l.Reason,
+			Interruptions: l.Interruptions,
+		}}
+	}
+	return rows, nil
+}
@@ -0,0 +1,158 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/yourname/sleeptracker/internal"
+	"github.com/yourname/sleeptracker/internal/auth"
+	"github.com/yourname/sleeptracker/internal/storage"
+)
+
+var (
+	ErrInvalidRefreshToken = internal.NewError(internal.ErrUnauthorized, "invalid or expired refresh token")
+	ErrSessionNotFound     = internal.NewError(internal.ErrNotFound, "session not found")
+)
+
+// TokenPolicy controls the lifetime of issued tokens. A session expires when
+// it has not been refreshed for RefreshTTL.
+type TokenPolicy struct {
+	AccessTTL  time.Duration
+	RefreshTTL time.Duration
+}
+
+// ClientInfo describes the client a session was started from.
+type ClientInfo struct {
+	UserAgent string
+	IP        string
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+type SessionView struct {
+	internal.Session
+	Current bool `json:"current"`
+}
+
+func ValidateRefreshRequest(req *RefreshRequest) error {
+	return validateStruct(req)
+}
+
+func issueTokens(ctx context.Context, userRepo storage.UserRepository, sessionRepo storage.SessionRepository, session *internal.Session, policy TokenPolicy, now time.Time) (*LoginResult, error) {
+	access, accessHash, err := auth.NewOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+	refresh, refreshHash, err := auth.NewOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+	accessToken := &internal.AccessToken{
+		Hash:      accessHash,
+		UserID:    session.UserID,
+		SessionID: session.ID,
+		CreatedAt: now,
+		ExpiresAt: now.Add(policy.AccessTTL),
+	}
+	refreshToken := &internal.RefreshToken{
+		Hash:      refreshHash,
+		SessionID: session.ID,
+		UserID:    session.UserID,
+		CreatedAt: now,
+		ExpiresAt: now.Add(policy.RefreshTTL),
+	}
+	if err := sessionRepo.SaveRefreshToken(ctx, refreshToken); err != nil {
+		return nil, err
+	}
+	if err := userRepo.SaveAccessToken(ctx, accessToken); err != nil {
+		return nil, err
+	}
+	return &LoginResult{
+		AccessToken:      access,
+		TokenType:        "Bearer",
+		ExpiresAt:        accessToken.ExpiresAt,
+		RefreshToken:     refresh,
+		RefreshExpiresAt: refreshToken.ExpiresAt,
+		SessionID:        session.ID,
+		UserID:           session.UserID,
+	}, nil
+}
+
+// Refresh rotates a refresh token: it is consumed and a new access and
+// refresh token pair is issued for the same session. Presenting a token that
+// was already rotated means it leaked, so the whole session is revoked.
+func Refresh(ctx context.Context, userRepo storage.UserRepository, sessionRepo storage.SessionRepository, req *RefreshRequest, policy TokenPolicy, logger internal.Logger) (*LoginResult, error) {
+	now := time.Now()
+	token, err := sessionRepo.ConsumeRefreshToken(ctx, auth.HashToken(req.RefreshToken), now)
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		return nil, ErrInvalidRefreshToken
+	case errors.Is(err, storage.ErrTokenReused):
+		logger.Warnf("refresh token reused for session %s; revoking session", token.SessionID)
+		if err := sessionRepo.RevokeSession(ctx, token.SessionID, now); err != nil && !errors.Is(err, storage.ErrNotFound) {
+			return nil, err
+		}
+		return nil, ErrInvalidRefreshToken
+	case err != nil:
+		return nil, err
+	}
+	if now.After(token.ExpiresAt) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	session, err := sessionRepo.GetSession(ctx, token.SessionID)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !session.Active(now) {
+		return nil, ErrInvalidRefreshToken
+	}
+	user, err := userRepo.GetUserByID(ctx, session.UserID)
+	if err != nil || user.DisabledAt != nil {
+		return nil, ErrInvalidRefreshToken
+	}
+	if err := sessionRepo.TouchSession(ctx, session.ID, now, now.Add(policy.RefreshTTL)); err != nil {
+		return nil, err
+	}
+	return issueTokens(ctx, userRepo, sessionRepo, session, policy, now)
+}
+
+// ListSessions returns the user's active sessions, flagging the one the
+// request was authenticated with.
+func ListSessions(ctx context.Context, userRepo storage.UserRepository, sessionRepo storage.SessionRepository, user *internal.User) ([]SessionView, error) {
+	sessions, err := sessionRepo.ListSessions(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	current := ""
+	if issued, err := userRepo.GetAccessToken(ctx, auth.HashToken(user.Token)); err == nil {
+		current = issued.SessionID
+	}
+	now := time.Now()
+	views := []SessionView{}
+	for _, s := range sessions {
+		if s.Active(now) {
+			views = append(views, SessionView{Session: s, Current: s.ID == current})
+		}
+	}
+	return views, nil
+}
+
+// RevokeSession revokes one of the user's sessions. Sessions of other users
+// are reported as not found.
+func RevokeSession(ctx context.Context, sessionRepo storage.SessionRepository, user *internal.User, id string) error {
+	session, err := sessionRepo.GetSession(ctx, id)
+	if errors.Is(err, storage.ErrNotFound) || (err == nil && session.UserID != user.ID) {
+		return ErrSessionNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return sessionRepo.RevokeSession(ctx, id, time.Now())
+}
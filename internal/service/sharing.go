@@ -0,0 +1,215 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yourname/sleeptracker/internal"
+	"github.com/yourname/sleeptracker/internal/storage"
+)
+
+var (
+	ErrGrantNotFound    = internal.NewError(internal.ErrNotFound, "grant not found")
+	ErrShareWithSelf    = internal.NewFieldError("email", "not_self", "cannot share data with yourself")
+	ErrGrantExists      = internal.NewError(internal.ErrConflict, "a grant to this user is already pending or active")
+	ErrGrantExpiresPast = internal.NewFieldError("expires_at", "future", "must be in the future")
+	ErrNoGrant          = internal.NewError(internal.ErrForbidden, "no active grant for this user's data")
+)
+
+type ShareRequest struct {
+	Email     string     `json:"email" validate:"required,email"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// GrantView is a grant with its status, as shown to the owner or grantee.
+type GrantView struct {
+	internal.Grant
+	Status string `json:"status"`
+}
+
+func ValidateShareRequest(req *ShareRequest) error {
+	if err := validateStruct(req); err != nil {
+		return err
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return ErrGrantExpiresPast
+	}
+	return nil
+}
+
+func newGrantViews(grants []internal.Grant, now time.Time) []GrantView {
+	views := make([]GrantView, len(grants))
+	for i, g := range grants {
+		views[i] = GrantView{Grant: g, Status: g.Status(now)}
+	}
+	return views
+}
+
+// CreateGrant invites req.Email to read the owner's data. The grant is
+// pending until the account with that email accepts it. The response is the
+// same whether or not there is such an account, so sharing does not reveal
+// which emails have accounts; an account registered later cannot accept it.
+func CreateGrant(ctx context.Context, grantRepo storage.SharingRepository, owner *internal.User, req *ShareRequest) (*GrantView, error) {
+	email := normalizeEmail(req.Email)
+	if email == owner.Email {
+		return nil, ErrShareWithSelf
+	}
+
+	now := time.Now()
+	existing, err := grantRepo.ListGrantsByOwner(ctx, owner.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, g := range existing {
+		if status := g.Status(now); g.GranteeEmail == email && (status == internal.GrantPending || status == internal.GrantActive) {
+			return nil, ErrGrantExists
+		}
+	}
+
+	grant := &internal.Grant{
+		ID:           uuid.NewString(),
+		OwnerID:      owner.ID,
+		GranteeEmail: email,
+		CreatedAt:    now,
+		ExpiresAt:    req.ExpiresAt,
+	}
+	if err := grantRepo.CreateGrant(ctx, grant); err != nil {
+		return nil, err
+	}
+	return &GrantView{Grant: *grant, Status: grant.Status(now)}, nil
+}
+
+// ListGrants returns the grants the user has given.
+func ListGrants(ctx context.Context, grantRepo storage.SharingRepository, owner *internal.User) ([]GrantView, error) {
+	grants, err := grantRepo.ListGrantsByOwner(ctx, owner.ID)
+	if err != nil {
+		return nil, err
+	}
+	return newGrantViews(grants, time.Now()), nil
+}
+
+// ListIncomingGrants returns the grants the user has accepted and those
+// still waiting for their email, newest first.
+func ListIncomingGrants(ctx context.Context, grantRepo storage.SharingRepository, grantee *internal.User) ([]GrantView, error) {
+	grants, err := grantRepo.ListGrantsByGrantee(ctx, grantee.ID)
+	if err != nil {
+		return nil, err
+	}
+	if grantee.Email != "" {
+		invited, err := grantRepo.ListGrantsByEmail(ctx, grantee.Email)
+		if err != nil {
+			return nil, err
+		}
+		for i := range invited {
+			if invited[i].GranteeID == "" && receivedBy(&invited[i], grantee) {
+				grants = append(grants, invited[i])
+			}
+		}
+		sort.SliceStable(grants, func(i, j int) bool { return grants[i].CreatedAt.After(grants[j].CreatedAt) })
+	}
+	return newGrantViews(grants, time.Now()), nil
+}
+
+// receivedBy reports whether user is the grant's grantee: the account that
+// accepted it or, until one has, the account with its email. Emails are not
+// verified, so only an account that already existed when the grant was
+// made counts; otherwise whoever registered the address first could claim
+// an invitation meant for someone else.
+func receivedBy(grant *internal.Grant, user *internal.User) bool {
+	if grant.GranteeID != "" {
+		return grant.GranteeID == user.ID
+	}
+	return user.Email != "" && grant.GranteeEmail == user.Email &&
+		!user.CreatedAt.IsZero() && user.CreatedAt.Before(grant.CreatedAt)
+}
+
+// GetGrant returns a grant the user has given or received.
+func GetGrant(ctx context.Context, grantRepo storage.SharingRepository, user *internal.User, id string) (*GrantView, error) {
+	grant, err := grantRepo.GetGrant(ctx, id)
+	if errors.Is(err, storage.ErrNotFound) || (err == nil && grant.OwnerID != user.ID && !receivedBy(grant, user)) {
+		return nil, ErrGrantNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &GrantView{Grant: *grant, Status: grant.Status(time.Now())}, nil
+}
+
+// AcceptGrant activates a pending grant the user has received, binding it
+// to their account.
+func AcceptGrant(ctx context.Context, grantRepo storage.SharingRepository, grantee *internal.User, id string) (*GrantView, error) {
+	grant, err := grantRepo.GetGrant(ctx, id)
+	if errors.Is(err, storage.ErrNotFound) || (err == nil && !receivedBy(grant, grantee)) {
+		return nil, ErrGrantNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if grant.Status(now) != internal.GrantPending {
+		return nil, ErrGrantNotFound
+	}
+	grant.GranteeID, grant.AcceptedAt = grantee.ID, &now
+	if err := grantRepo.UpdateGrant(ctx, grant); err != nil {
+		return nil, err
+	}
+	return &GrantView{Grant: *grant, Status: grant.Status(now)}, nil
+}
+
+// RevokeGrant ends a grant and returns it. The owner revokes it; the grantee
+// may also use this to decline or give up access.
+func RevokeGrant(ctx context.Context, grantRepo storage.SharingRepository, user *internal.User, id string) (*internal.Grant, error) {
+	grant, err := grantRepo.GetGrant(ctx, id)
+	if errors.Is(err, storage.ErrNotFound) || (err == nil && grant.OwnerID != user.ID && !receivedBy(grant, user)) {
+		return nil, ErrGrantNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if grant.RevokedAt != nil {
+		return grant, nil
+	}
+	now := time.Now()
+	grant.RevokedAt = &now
+	if err := grantRepo.UpdateGrant(ctx, grant); err != nil {
+		return nil, err
+	}
+	return grant, nil
+}
+
+// AuthorizeGrant returns the active grant that lets grantee read ownerID's
+// data, or ErrNoGrant.
+func AuthorizeGrant(ctx context.Context, grantRepo storage.SharingRepository, grantee *internal.User, ownerID string) (*internal.Grant, error) {
+	grants, err := grantRepo.ListGrantsByGrantee(ctx, grantee.ID)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	for i := range grants {
+		if grants[i].OwnerID == ownerID && grants[i].Status(now) == internal.GrantActive {
+			return &grants[i], nil
+		}
+	}
+	return nil, ErrNoGrant
+}
+
+// RecordGrantAccess logs a grantee's request for the owner's data.
+func RecordGrantAccess(ctx context.Context, grantRepo storage.SharingRepository, grant *internal.Grant, method, path string) error {
+	return grantRepo.RecordGrantAccess(ctx, &internal.GrantAccess{
+		ID:        uuid.NewString(),
+		GrantID:   grant.ID,
+		OwnerID:   grant.OwnerID,
+		GranteeID: grant.GranteeID,
+		Method:    method,
+		Path:      path,
+		At:        time.Now(),
+	})
+}
+
+// ListGrantAccesses returns who accessed the owner's data, newest first.
+func ListGrantAccesses(ctx context.Context, grantRepo storage.SharingRepository, owner *internal.User) ([]internal.GrantAccess, error) {
+	return grantRepo.ListGrantAccesses(ctx, owner.ID)
+}
@@ -2,26 +2,71 @@ package service
 
 import (
 	"context"
+	"encoding/base64"
+	"errors"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
+	// Embedded so that ExportOptions.TZ works on hosts without a zoneinfo database.
+	_ "time/tzdata"
 
-	"github.com/go-playground/validator/v10"
 	"github.com/google/uuid"
 	"github.com/yourname/sleeptracker/internal"
 	"github.com/yourname/sleeptracker/internal/storage"
 )
 
-var validate = validator.New()
+var ErrSleepLogNotFound = internal.NewError(internal.ErrNotFound, "sleep log not found")
+
+const (
+	// MaxSleepDuration is the longest sleep a single log may record.
+	MaxSleepDuration = 24 * time.Hour
+	// maxClockSkew is how far in the future an end time may be, to allow
+	// for clients whose clocks run fast.
+	maxClockSkew = 5 * time.Minute
+)
 
 type SleepLogRequest struct {
 	StartTime     time.Time `json:"start_time" validate:"required"`
 	EndTime       time.Time `json:"end_time" validate:"required,gtfield=StartTime"`
 	Quality       int       `json:"quality" validate:"required,gte=1,lte=10"`
-	Reason        string    `json:"reason,omitempty" validate:"omitempty"`
-	Interruptions []string  `json:"interruptions,omitempty" validate:"dive,required"`
+	Reason        string    `json:"reason,omitempty" validate:"max=500"`
+	Interruptions []string  `json:"interruptions,omitempty" validate:"max=20,dive,required,max=100"`
 }
 
+// ValidateSleepLogRequest checks the request's fields, then that the sleep
+// is no longer than MaxSleepDuration and has already ended. Every rejected
+// field is reported, not just the first.
 func ValidateSleepLogRequest(body *SleepLogRequest) error {
-	return validate.Struct(body)
+	verr := &internal.ValidationError{}
+	if err := validateStruct(body); err != nil && !errors.As(err, &verr) {
+		return err
+	}
+	if !body.StartTime.IsZero() && body.EndTime.Sub(body.StartTime) > MaxSleepDuration {
+		verr.Fields = append(verr.Fields, internal.FieldError{
+			Field:   "end_time",
+			Code:    "max_duration",
+			Message: "must be at most " + formatHours(MaxSleepDuration) + " after start_time",
+			Params:  map[string]string{"max": formatHours(MaxSleepDuration)},
+		})
+	}
+	if body.EndTime.After(time.Now().Add(maxClockSkew)) {
+		verr.Fields = append(verr.Fields, internal.FieldError{
+			Field:   "end_time",
+			Code:    "not_future",
+			Message: "must not be in the future",
+		})
+	}
+	if len(verr.Fields) > 0 {
+		return verr
+	}
+	return nil
+}
+
+// formatHours writes a whole number of hours as "24h".
+func formatHours(d time.Duration) string {
+	return strconv.Itoa(int(d.Hours())) + "h"
 }
 
 func CreateSleepLog(ctx context.Context, sleepRepo storage.SleepLogRepository, user *internal.User, body *SleepLogRequest) (*internal.SleepLog, error) {
@@ -41,17 +86,165 @@ func CreateSleepLog(ctx context.Context, sleepRepo storage.SleepLogRepository, u
 	return log, nil
 }
 
-func CalculateSleepStats(logs []internal.SleepLog) (float64, []int) {
-	cutoff := time.Now().AddDate(0, 0, -7)
+// GetSleepLog returns one of the user's sleep logs.
+func GetSleepLog(ctx context.Context, sleepRepo storage.SleepLogRepository, userID, id string) (*internal.SleepLog, error) {
+	log, err := sleepRepo.GetSleepLog(ctx, id)
+	if errors.Is(err, storage.ErrNotFound) || (err == nil && log.UserID != userID) {
+		return nil, ErrSleepLogNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return log, nil
+}
+
+// SleepLogPage selects a page of sleep logs. Without a Limit every log after
+// Cursor is returned.
+type SleepLogPage struct {
+	Limit  int    `form:"limit" validate:"omitempty,min=1,max=500"`
+	Cursor string `form:"cursor"`
+}
+
+var errInvalidCursor = internal.NewFieldError("cursor", "invalid", "is not a cursor from a previous page")
+
+func ValidateSleepLogPage(p *SleepLogPage) error {
+	if err := validateStruct(p); err != nil {
+		return err
+	}
+	if p.Cursor != "" {
+		if _, _, err := decodeCursor(p.Cursor); err != nil {
+			return errInvalidCursor
+		}
+	}
+	return nil
+}
+
+// ListSleepLogs returns the user's sleep logs, newest first, one page at a
+// time. The cursor for the next page is empty on the last one.
+func ListSleepLogs(ctx context.Context, sleepRepo storage.SleepLogRepository, userID string, page *SleepLogPage) ([]internal.SleepLog, string, error) {
+	logs, err := sleepRepo.ListSleepLogs(ctx, userID)
+	if err != nil {
+		return nil, "", err
+	}
+	// Ties on start time are broken by ID so that pages do not overlap.
+	sort.Slice(logs, func(i, j int) bool {
+		if !logs[i].StartTime.Equal(logs[j].StartTime) {
+			return logs[i].StartTime.After(logs[j].StartTime)
+		}
+		return logs[i].ID < logs[j].ID
+	})
+
+	if page.Cursor != "" {
+		start, id, err := decodeCursor(page.Cursor)
+		if err != nil {
+			return nil, "", errInvalidCursor
+		}
+		i := sort.Search(len(logs), func(i int) bool {
+			l := logs[i]
+			return l.StartTime.Before(start) || (l.StartTime.Equal(start) && l.ID > id)
+		})
+		logs = logs[i:]
+	}
+	if page.Limit == 0 || len(logs) <= page.Limit {
+		return logs, "", nil
+	}
+	logs = logs[:page.Limit]
+	last := logs[len(logs)-1]
+	return logs, encodeCursor(last.StartTime, last.ID), nil
+}
+
+// IterateSleepLogs calls fn with each of the user's sleep logs after cursor,
+// in ListSleepLogs order, reading them from the repository as it goes.
+func IterateSleepLogs(ctx context.Context, sleepRepo storage.SleepLogRepository, userID, cursor string, fn func(internal.SleepLog) error) error {
+	var start time.Time
+	var id string
+	if cursor != "" {
+		var err error
+		if start, id, err = decodeCursor(cursor); err != nil {
+			return errInvalidCursor
+		}
+	}
+	return sleepRepo.IterateSleepLogs(ctx, userID, func(l internal.SleepLog) error {
+		if cursor != "" && (l.StartTime.After(start) || (l.StartTime.Equal(start) && l.ID <= id)) {
+			return nil
+		}
+		return fn(l)
+	})
+}
+
+// ExportOptions chooses how sleep logs and stats are rendered. Format
+// overrides the Accept header; TZ is the IANA time zone for timestamps in
+// CSV. Without it, times keep the offset they were recorded in.
+type ExportOptions struct {
+	Format string `form:"format" validate:"omitempty,oneof=json csv"`
+	TZ     string `form:"tz" validate:"max=64"`
+}
+
+var errInvalidTimeZone = internal.NewFieldError("tz", "invalid", "is not an IANA time zone such as Europe/London")
+
+// ValidateExportOptions checks o and returns the time zone it names, or nil
+// if it names none.
+func ValidateExportOptions(o *ExportOptions) (*time.Location, error) {
+	if err := validateStruct(o); err != nil {
+		return nil, err
+	}
+	if o.TZ == "" {
+		return nil, nil
+	}
+	return loadTimeZone(o.TZ)
+}
+
+// loadTimeZone returns the IANA time zone named tz, or UTC if tz is empty.
+func loadTimeZone(tz string) (*time.Location, error) {
+	if tz == "" {
+		return time.UTC, nil
+	}
+	// "Local" would be the server's zone, which means nothing to clients.
+	loc, err := time.LoadLocation(tz)
+	if err != nil || tz == "Local" {
+		return nil, errInvalidTimeZone
+	}
+	return loc, nil
+}
+
+// A cursor names the last log of a page by its start time and ID.
+func encodeCursor(start time.Time, id string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(start.UnixNano(), 10) + ":" + id))
+}
+
+func decodeCursor(cursor string) (time.Time, string, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, "", err
+	}
+	nanos, id, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return time.Time{}, "", errors.New("cursor has no ID")
+	}
+	n, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return time.Time{}, "", err
+	}
+	return time.Unix(0, n), id, nil
+}
+
+// statsWindow is how far back stats and goal progress look.
+const statsWindow = 7
+
+// CalculateSleepStats returns the average quality over the last week and the
+// trend, in the order the aggregates are given. The trend has one entry per
+// day, its average quality rounded, rather than one per log.
+func CalculateSleepStats(days []internal.DailyAggregate) (float64, []int) {
+	cutoff := statsCutoff()
 	totalQuality := 0
 	count := 0
 	trend := []int{}
 
-	for _, l := range logs {
-		if l.StartTime.After(cutoff) {
-			totalQuality += l.Quality
-			count++
-			trend = append(trend, l.Quality)
+	for _, d := range days {
+		if d.Date > cutoff {
+			totalQuality += d.QualitySum
+			count += d.LogCount
+			trend = append(trend, int(math.Round(d.AverageQuality())))
 		}
 	}
 
@@ -62,3 +255,48 @@ func CalculateSleepStats(logs []internal.SleepLog) (float64, []int) {
 
 	return avg, trend
 }
+
+// statsCutoff is the last day before the stats window.
+func statsCutoff() string {
+	return storage.DayOf(time.Now().AddDate(0, 0, -statsWindow))
+}
+
+// recentAggregates loads the daily aggregates covering the stats window.
+func recentAggregates(ctx context.Context, aggRepo storage.AggregateRepository, userID string) ([]internal.DailyAggregate, error) {
+	now := time.Now()
+	return aggRepo.ListDailyAggregates(ctx, userID, now.AddDate(0, 0, -statsWindow), now)
+}
+
+// GetSleepDays returns the daily aggregates behind the stats trend, newest
+// first.
+func GetSleepDays(ctx context.Context, aggRepo storage.AggregateRepository, userID string) ([]internal.DailyAggregate, error) {
+	days, err := recentAggregates(ctx, aggRepo, userID)
+	if err != nil {
+		return nil, err
+	}
+	cutoff := statsCutoff()
+	recent := days[:0]
+	for _, d := range days {
+		if d.Date > cutoff {
+			recent = append(recent, d)
+		}
+	}
+	return recent, nil
+}
+
+// GetSleepStats returns the user's stats computed from daily aggregates,
+// reusing a cached result when the sleep log repository supports it.
+func GetSleepStats(ctx context.Context, sleepRepo storage.SleepLogRepository, aggRepo storage.AggregateRepository, userID string) (*internal.SleepStats, error) {
+	compute := func() (*internal.SleepStats, error) {
+		days, err := recentAggregates(ctx, aggRepo, userID)
+		if err != nil {
+			return nil, err
+		}
+		avg, trend := CalculateSleepStats(days)
+		return &internal.SleepStats{AverageQuality: avg, Trend: trend}, nil
+	}
+	if c, ok := sleepRepo.(storage.StatsCache); ok {
+		return c.SleepStats(userID, compute)
+	}
+	return compute()
+}
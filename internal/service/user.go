@@ -0,0 +1,170 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yourname/sleeptracker/internal"
+	"github.com/yourname/sleeptracker/internal/auth"
+	"github.com/yourname/sleeptracker/internal/storage"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	ErrInvalidCredentials = internal.NewError(internal.ErrUnauthorized, "invalid email or password")
+	ErrAccountDisabled    = internal.NewError(internal.ErrForbidden, "account disabled")
+	ErrEmailTaken         = internal.NewError(internal.ErrConflict, "email already registered")
+)
+
+type RegisterRequest struct {
+	Email    string `json:"email" validate:"required,email"`
+	Password string `json:"password" validate:"required,min=8,max=72"`
+	Name     string `json:"name" validate:"max=100"`
+}
+
+type LoginRequest struct {
+	Email    string `json:"email" validate:"required"`
+	Password string `json:"password" validate:"required"`
+}
+
+type LoginResult struct {
+	AccessToken      string    `json:"access_token"`
+	TokenType        string    `json:"token_type"`
+	ExpiresAt        time.Time `json:"expires_at"`
+	RefreshToken     string    `json:"refresh_token"`
+	RefreshExpiresAt time.Time `json:"refresh_expires_at"`
+	SessionID        string    `json:"session_id"`
+	UserID           string    `json:"-"`
+}
+
+// dummyHash is compared against when the email is unknown, so a failed login
+// takes as long whether or not the account exists.
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("dummy password"), bcrypt.DefaultCost)
+
+// maxPasswordBytes is the longest password bcrypt accepts. The max tag
+// counts characters, so a multibyte password can pass it and still be
+// rejected by bcrypt.
+const maxPasswordBytes = 72
+
+func ValidateRegisterRequest(req *RegisterRequest) error {
+	verr := &internal.ValidationError{}
+	if err := validateStruct(req); err != nil && !errors.As(err, &verr) {
+		return err
+	}
+	if len(req.Password) > maxPasswordBytes && !hasFieldError(verr, "password") {
+		verr.Fields = append(verr.Fields, internal.FieldError{
+			Field:   "password",
+			Code:    "max",
+			Message: "must have at most " + strconv.Itoa(maxPasswordBytes) + " bytes",
+			Params:  map[string]string{"max": strconv.Itoa(maxPasswordBytes)},
+		})
+	}
+	if len(verr.Fields) > 0 {
+		return verr
+	}
+	return nil
+}
+
+func hasFieldError(verr *internal.ValidationError, field string) bool {
+	for _, f := range verr.Fields {
+		if f.Field == field {
+			return true
+		}
+	}
+	return false
+}
+
+func ValidateLoginRequest(req *LoginRequest) error {
+	return validateStruct(req)
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// UserByEmail looks up an account by email, matching it however it was
+// cased or padded when typed.
+func UserByEmail(ctx context.Context, userRepo storage.UserRepository, email string) (*internal.User, error) {
+	return userRepo.GetUserByEmail(ctx, normalizeEmail(email))
+}
+
+// Register creates an account with the user role. Registration never grants
+// more: nothing proves the caller owns the email, so ADMIN_EMAILS only
+// promotes accounts that already exist (see BootstrapAdmins).
+func Register(ctx context.Context, userRepo storage.UserRepository, req *RegisterRequest) (*internal.User, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+	user := &internal.User{
+		ID:           uuid.NewString(),
+		Name:         strings.TrimSpace(req.Name),
+		Email:        normalizeEmail(req.Email),
+		PasswordHash: string(hash),
+		CreatedAt:    time.Now(),
+		Role:         internal.RoleUser,
+	}
+	err = userRepo.CreateUser(ctx, user)
+	if errors.Is(err, storage.ErrEmailTaken) {
+		return nil, ErrEmailTaken
+	}
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// Login checks the password and starts a new session.
+func Login(ctx context.Context, userRepo storage.UserRepository, sessionRepo storage.SessionRepository, req *LoginRequest, policy TokenPolicy, client ClientInfo) (*LoginResult, error) {
+	user, err := userRepo.GetUserByEmail(ctx, normalizeEmail(req.Email))
+	if errors.Is(err, storage.ErrNotFound) {
+		bcrypt.CompareHashAndPassword(dummyHash, []byte(req.Password))
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)) != nil {
+		return nil, ErrInvalidCredentials
+	}
+	if user.DisabledAt != nil {
+		return nil, ErrAccountDisabled
+	}
+
+	now := time.Now()
+	session := &internal.Session{
+		ID:         uuid.NewString(),
+		UserID:     user.ID,
+		UserAgent:  client.UserAgent,
+		IP:         client.IP,
+		CreatedAt:  now,
+		LastUsedAt: now,
+		ExpiresAt:  now.Add(policy.RefreshTTL),
+	}
+	if err := sessionRepo.CreateSession(ctx, session); err != nil {
+		return nil, err
+	}
+	return issueTokens(ctx, userRepo, sessionRepo, session, policy, now)
+}
+
+// Logout revokes the session the user authenticated with, or just the access
+// token if it was issued without one. Tokens that were not issued at login
+// (such as JWTs) are left alone.
+func Logout(ctx context.Context, userRepo storage.UserRepository, sessionRepo storage.SessionRepository, user *internal.User) error {
+	hash := auth.HashToken(user.Token)
+	issued, err := userRepo.GetAccessToken(ctx, hash)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if issued.SessionID != "" {
+		return sessionRepo.RevokeSession(ctx, issued.SessionID, time.Now())
+	}
+	return userRepo.DeleteAccessToken(ctx, hash)
+}
@@ -0,0 +1,96 @@
+package service
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/yourname/sleeptracker/internal"
+)
+
+var validate = newValidator()
+
+// newValidator returns a validator that names fields as clients send them:
+// by their json tag, or their form tag for query parameters.
+func newValidator() *validator.Validate {
+	v := validator.New()
+	v.RegisterTagNameFunc(fieldName)
+	return v
+}
+
+func fieldName(f reflect.StructField) string {
+	for _, tag := range []string{"json", "form"} {
+		if name, _, _ := strings.Cut(f.Tag.Get(tag), ","); name != "" && name != "-" {
+			return name
+		}
+	}
+	return f.Name
+}
+
+// validateStruct checks v against its validate tags. Failures are returned
+// as an *internal.ValidationError naming each rejected field.
+func validateStruct(v interface{}) error {
+	err := validate.Struct(v)
+	var fieldErrs validator.ValidationErrors
+	if !errors.As(err, &fieldErrs) {
+		return err
+	}
+	verr := &internal.ValidationError{}
+	for _, fe := range fieldErrs {
+		verr.Fields = append(verr.Fields, translate(v, fe))
+	}
+	return verr
+}
+
+// translate turns a failed validate tag into a FieldError. Tags that mean
+// the same thing share a code: gte reports as min and lte as max.
+func translate(v interface{}, fe validator.FieldError) internal.FieldError {
+	f := internal.FieldError{Field: fe.Field(), Code: fe.Tag()}
+	switch fe.Tag() {
+	case "required":
+		f.Message = "is required"
+	case "email":
+		f.Message = "must be a valid email address"
+	case "oneof":
+		values := strings.Fields(fe.Param())
+		f.Code, f.Message = "one_of", "must be one of: "+strings.Join(values, ", ")
+		f.Params = map[string]string{"values": strings.Join(values, ",")}
+	case "min", "gte":
+		f.Code, f.Params = "min", map[string]string{"min": fe.Param()}
+		f.Message = "must be at least " + fe.Param()
+		if unit := lengthUnit(fe.Kind()); unit != "" {
+			f.Message = "must have at least " + fe.Param() + " " + unit
+		}
+	case "max", "lte":
+		f.Code, f.Params = "max", map[string]string{"max": fe.Param()}
+		f.Message = "must be at most " + fe.Param()
+		if unit := lengthUnit(fe.Kind()); unit != "" {
+			f.Message = "must have at most " + fe.Param() + " " + unit
+		}
+	case "gtfield":
+		other := fe.Param()
+		if sf, ok := reflect.Indirect(reflect.ValueOf(v)).Type().FieldByName(other); ok {
+			other = fieldName(sf)
+		}
+		f.Code, f.Params = "after", map[string]string{"field": other}
+		f.Message = "must be after " + other
+	default:
+		f.Message = "failed the " + fe.Tag() + " rule"
+		if fe.Param() != "" {
+			f.Params = map[string]string{"param": fe.Param()}
+		}
+	}
+	return f
+}
+
+// lengthUnit is what min and max count for a kind, or "" for numbers.
+func lengthUnit(kind reflect.Kind) string {
+	switch kind {
+	case reflect.String:
+		return "characters"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return "items"
+	}
+	return ""
+}
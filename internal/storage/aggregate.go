@@ -0,0 +1,59 @@
+package storage
+
+import (
+	"sort"
+	"time"
+
+	"github.com/yourname/sleeptracker/internal"
+)
+
+const dayLayout = "2006-01-02"
+
+// DayOf returns the UTC calendar day a log starting at t is aggregated under.
+func DayOf(t time.Time) string {
+	return t.UTC().Format(dayLayout)
+}
+
+func addToAggregate(agg *internal.DailyAggregate, log *internal.SleepLog) {
+	if agg.LogCount == 0 || log.StartTime.Before(agg.Bedtime) {
+		agg.Bedtime = log.StartTime.UTC()
+		_, agg.BedtimeOffset = log.StartTime.Zone()
+	}
+	if agg.LogCount == 0 || log.EndTime.After(agg.WakeTime) {
+		agg.WakeTime = log.EndTime.UTC()
+	}
+	agg.LogCount++
+	agg.TotalSleepSeconds += int64(log.EndTime.Sub(log.StartTime) / time.Second)
+	agg.QualitySum += log.Quality
+	agg.InterruptionCount += len(log.Interruptions)
+}
+
+// BuildDailyAggregates computes aggregates from raw logs, most recent day first.
+func BuildDailyAggregates(logs []internal.SleepLog) []internal.DailyAggregate {
+	byKey := make(map[[2]string]*internal.DailyAggregate)
+	for i := range logs {
+		l := &logs[i]
+		key := [2]string{l.UserID, DayOf(l.StartTime)}
+		agg, ok := byKey[key]
+		if !ok {
+			agg = &internal.DailyAggregate{UserID: l.UserID, Date: key[1]}
+			byKey[key] = agg
+		}
+		addToAggregate(agg, l)
+	}
+	out := make([]internal.DailyAggregate, 0, len(byKey))
+	for _, agg := range byKey {
+		out = append(out, *agg)
+	}
+	sortAggregates(out)
+	return out
+}
+
+func sortAggregates(aggs []internal.DailyAggregate) {
+	sort.Slice(aggs, func(i, j int) bool {
+		if aggs[i].Date != aggs[j].Date {
+			return aggs[i].Date > aggs[j].Date
+		}
+		return aggs[i].UserID < aggs[j].UserID
+	})
+}
@@ -0,0 +1,164 @@
+package storage
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/yourname/sleeptracker/internal"
+	"github.com/yourname/sleeptracker/internal/cache"
+)
+
+// StatsCache is implemented by repositories that can memoize computed
+// per-user stats alongside the logs they were derived from.
+type StatsCache interface {
+	SleepStats(userID string, compute func() (*internal.SleepStats, error)) (*internal.SleepStats, error)
+}
+
+type CacheStats struct {
+	SleepLogs  cache.Stats `json:"sleep_logs"`
+	SleepStats cache.Stats `json:"sleep_stats"`
+}
+
+// CachedSleepLogRepository is a read-through cache in front of any
+// SleepLogRepository. Entries are per user, bounded by an LRU and a TTL, and
+// dropped whenever the user saves a log through the decorator.
+type CachedSleepLogRepository struct {
+	next  SleepLogRepository
+	logs  *cache.LRU[string, []internal.SleepLog]
+	stats *cache.LRU[string, *internal.SleepStats]
+
+	// reads guard against caching a read that raced with a write: a result
+	// is only stored if no save happened while it was computed. A user has
+	// an entry only while reads of theirs are in flight, so the map is
+	// bounded by concurrency rather than by the number of users.
+	mu    sync.Mutex
+	reads map[string]*inflightReads
+}
+
+// inflightReads counts a user's reads in progress and the saves made since
+// the first of them began.
+type inflightReads struct {
+	readers    int
+	generation uint64
+}
+
+func NewCachedSleepLogRepository(next SleepLogRepository, size int, ttl time.Duration) *CachedSleepLogRepository {
+	return &CachedSleepLogRepository{
+		next:  next,
+		logs:  cache.New[string, []internal.SleepLog](size, ttl),
+		stats: cache.New[string, *internal.SleepStats](size, ttl),
+		reads: make(map[string]*inflightReads),
+	}
+}
+
+// beginRead registers a read of the user's data and returns the generation
+// to pass to endRead.
+func (r *CachedSleepLogRepository) beginRead(userID string) uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	in, ok := r.reads[userID]
+	if !ok {
+		in = &inflightReads{}
+		r.reads[userID] = in
+	}
+	in.readers++
+	return in.generation
+}
+
+// endRead reports whether the read that began at gen may be cached, that
+// is, whether no save happened meanwhile.
+func (r *CachedSleepLogRepository) endRead(userID string, gen uint64) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	in := r.reads[userID]
+	in.readers--
+	if in.readers == 0 {
+		delete(r.reads, userID)
+	}
+	return in.generation == gen
+}
+
+func (r *CachedSleepLogRepository) invalidate(userID string) {
+	r.mu.Lock()
+	if in, ok := r.reads[userID]; ok {
+		in.generation++
+	}
+	r.mu.Unlock()
+	r.logs.Delete(userID)
+	r.stats.Delete(userID)
+}
+
+func (r *CachedSleepLogRepository) SaveSleepLog(ctx context.Context, log *internal.SleepLog) error {
+	err := r.next.SaveSleepLog(ctx, log)
+	r.invalidate(log.UserID)
+	return err
+}
+
+func (r *CachedSleepLogRepository) SaveSleepLogs(ctx context.Context, logs []internal.SleepLog) error {
+	err := r.next.SaveSleepLogs(ctx, logs)
+	users := make(map[string]bool)
+	for _, log := range logs {
+		if !users[log.UserID] {
+			users[log.UserID] = true
+			r.invalidate(log.UserID)
+		}
+	}
+	return err
+}
+
+// GetSleepLog is not cached.
+func (r *CachedSleepLogRepository) GetSleepLog(ctx context.Context, id string) (*internal.SleepLog, error) {
+	return r.next.GetSleepLog(ctx, id)
+}
+
+func (r *CachedSleepLogRepository) ListSleepLogs(ctx context.Context, userID string) ([]internal.SleepLog, error) {
+	if logs, ok := r.logs.Get(userID); ok {
+		return copyLogs(logs), nil
+	}
+	gen := r.beginRead(userID)
+	logs, err := r.next.ListSleepLogs(ctx, userID)
+	if r.endRead(userID, gen) && err == nil {
+		r.logs.Set(userID, copyLogs(logs))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return logs, nil
+}
+
+// IterateSleepLogs is not cached: it serves histories too long to keep.
+func (r *CachedSleepLogRepository) IterateSleepLogs(ctx context.Context, userID string, fn func(internal.SleepLog) error) error {
+	return r.next.IterateSleepLogs(ctx, userID, fn)
+}
+
+func (r *CachedSleepLogRepository) SleepStats(userID string, compute func() (*internal.SleepStats, error)) (*internal.SleepStats, error) {
+	if stats, ok := r.stats.Get(userID); ok {
+		return stats, nil
+	}
+	gen := r.beginRead(userID)
+	stats, err := compute()
+	if r.endRead(userID, gen) && err == nil {
+		r.stats.Set(userID, stats)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+func (r *CachedSleepLogRepository) CacheStats() CacheStats {
+	return CacheStats{SleepLogs: r.logs.Stats(), SleepStats: r.stats.Stats()}
+}
+
+// copyLogs keeps callers that sort or modify the result from touching the
+// cached slice.
+func copyLogs(logs []internal.SleepLog) []internal.SleepLog {
+	out := make([]internal.SleepLog, len(logs))
+	copy(out, logs)
+	return out
+}
+
+// --- Compile-time assertions ---
+var _ SleepLogRepository = (*CachedSleepLogRepository)(nil)
+var _ StatsCache = (*CachedSleepLogRepository)(nil)
@@ -1,6 +1,12 @@
 package storage
 
-import "github.com/yourname/sleeptracker/internal"
+import (
+	"errors"
+	"fmt"
+
+	"github.com/yourname/sleeptracker/internal"
+	"github.com/yourname/sleeptracker/internal/config"
+)
 
 func NewFileRepositories(sleepFile, goalsFile string, logger internal.Logger) (SleepLogRepository, GoalRepository, error) {
 	storage, err := NewFileStorage(sleepFile, goalsFile, logger)
@@ -17,3 +23,58 @@ func NewPostgresRepositories(dsn string, logger internal.Logger) (SleepLogReposi
 	}
 	return storage, storage, nil
 }
+
+func NewMemoryRepositories(fixture string, logger internal.Logger) (SleepLogRepository, GoalRepository, error) {
+	storage, err := NewMemoryStorageFromFixture(fixture, logger)
+	if err != nil {
+		return nil, nil, err
+	}
+	return storage, storage, nil
+}
+
+// Repositories bundles every repository one backend provides.
+type Repositories struct {
+	Sleep      SleepLogRepository
+	Goals      GoalRepository
+	Aggregates AggregateRepository
+	Users      UserRepository
+	Sessions   SessionRepository
+	APIKeys    APIKeyRepository
+	Sharing    SharingRepository
+	Audit      AuditRepository
+	Health     HealthChecker
+	// Close flushes pending writes and releases the backend.
+	Close func() error
+	// Files is set when the backend keeps its data in local JSON files.
+	Files *FileStorage
+}
+
+// Open opens the backend selected by cfg.DBType.
+func Open(cfg *config.Config, logger internal.Logger) (*Repositories, error) {
+	switch cfg.DBType {
+	case "file":
+		s, err := NewFileStorage(cfg.FileSleep, cfg.FileGoals, logger)
+		if err != nil {
+			return nil, err
+		}
+		return &Repositories{Sleep: s, Goals: s, Aggregates: s, Users: s, Sessions: s, APIKeys: s, Sharing: s, Audit: s, Health: s, Close: s.Close, Files: s}, nil
+	case "postgres":
+		if cfg.DBDSN == "" {
+			return nil, errors.New("POSTGRES_DSN env var required for postgres backend")
+		}
+		// Make sure to run migrations/*.sql before starting the app
+		s, err := NewPostgresStorage(cfg.DBDSN, logger)
+		if err != nil {
+			return nil, err
+		}
+		return &Repositories{Sleep: s, Goals: s, Aggregates: s, Users: s, Sessions: s, APIKeys: s, Sharing: s, Audit: s, Health: s, Close: s.Close}, nil
+	case "memory":
+		s, err := NewMemoryStorageFromFixture(cfg.MemoryFixture, logger)
+		if err != nil {
+			return nil, err
+		}
+		return &Repositories{Sleep: s, Goals: s, Aggregates: s, Users: s, Sessions: s, APIKeys: s, Sharing: s, Audit: s, Health: s, Close: func() error { return nil }}, nil
+	default:
+		return nil, fmt.Errorf("unsupported STORAGE_BACKEND: %s", cfg.DBType)
+	}
+}
@@ -1,46 +1,166 @@
 package storage
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"os"
-	"sort"
+	"path/filepath"
 	"sync"
 	"time"
 
 	"github.com/yourname/sleeptracker/internal"
 )
 
+// FileStorage persists a MemoryStorage to JSON files. Writes are applied in
+// memory first and flushed to disk by debounced background workers.
+//
+// Besides SLEEP_FILE and GOALS_FILE, derived collections such as the daily
+// aggregates are kept in files next to the sleep log file.
 type FileStorage struct {
-	sleepLogs      map[string]*internal.SleepLog        // id -> SleepLog
-	userSleepIndex map[string][]*internal.SleepLog      // userID -> slice of SleepLogs (sorted descending)
-	goals          map[string]map[string]*internal.Goal // userID -> type -> Goal
-	mu             sync.RWMutex
-	sleepFile      string
-	goalsFile      string
-	saveLogsChan   chan struct{}
-	saveGoalsChan  chan struct{}
+	*MemoryStorage
+	sleepFile      *jsonFile
+	goalsFile      *jsonFile
+	aggregatesFile *jsonFile
+	usersFile      *jsonFile
+	tokensFile     *jsonFile
+	sessionsFile   *jsonFile
+	refreshFile    *jsonFile
+	apiKeysFile    *jsonFile
+	grantsFile     *jsonFile
+	accessLogFile  *jsonFile
+	auditLog       *auditLog
 	shutdownChan   chan struct{}
-	saveLogsDelay  time.Duration
-	saveGoalsDelay time.Duration
 	logger         internal.Logger
 }
 
+// jsonFile is one persisted collection. Change notifications are coalesced
+// so the file is rewritten once no further change arrived for delay.
+type jsonFile struct {
+	name     string
+	path     string
+	delay    time.Duration
+	changed  chan struct{}
+	snapshot func() interface{}
+	mu       sync.Mutex // serialises writers of the same temp file
+	savedAt  time.Time  // guarded by mu
+	saveErr  error      // guarded by mu; result of the last save
+}
+
+func newJSONFile(name, path string, snapshot func() interface{}) *jsonFile {
+	return &jsonFile{
+		name:     name,
+		path:     path,
+		delay:    500 * time.Millisecond,
+		changed:  make(chan struct{}, 1),
+		snapshot: snapshot,
+	}
+}
+
+func (f *jsonFile) markChanged() {
+	select {
+	case f.changed <- struct{}{}:
+	default:
+	}
+}
+
+func (f *jsonFile) save() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.saveErr = atomicWriteFileJSON(f.path, f.snapshot())
+	if f.saveErr == nil {
+		f.savedAt = time.Now()
+	}
+	return f.saveErr
+}
+
+func (f *jsonFile) status() (time.Time, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.savedAt, f.saveErr
+}
+
+// auditLog appends audit events to a JSON Lines file as they are recorded,
+// so entries already written are never rewritten.
+type auditLog struct {
+	path string
+	mu   sync.Mutex
+	file *os.File
+}
+
+// openAuditLog opens the log for appending and returns the events already in
+// it.
+func openAuditLog(path string) (*auditLog, []*internal.AuditEvent, error) {
+	var events []*internal.AuditEvent
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	for {
+		var e internal.AuditEvent
+		if err := dec.Decode(&e); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", path, err)
+		}
+		events = append(events, &e)
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &auditLog{path: path, file: file}, events, nil
+}
+
+func (l *auditLog) append(e *internal.AuditEvent) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, err = l.file.Write(append(line, '\n'))
+	return err
+}
+
+func (l *auditLog) close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := l.file.Sync(); err != nil {
+		l.file.Close()
+		return err
+	}
+	return l.file.Close()
+}
+
 func NewFileStorage(sleepFile, goalsFile string, logger internal.Logger) (*FileStorage, error) {
+	mem := NewMemoryStorage(logger)
 	s := &FileStorage{
-		sleepLogs:      make(map[string]*internal.SleepLog),
-		userSleepIndex: make(map[string][]*internal.SleepLog),
-		goals:          make(map[string]map[string]*internal.Goal),
-		sleepFile:      sleepFile,
-		goalsFile:      goalsFile,
-		saveLogsChan:   make(chan struct{}, 1),
-		saveGoalsChan:  make(chan struct{}, 1),
-		shutdownChan:   make(chan struct{}),
-		saveLogsDelay:  500 * time.Millisecond,
-		saveGoalsDelay: 500 * time.Millisecond,
-		logger:         logger,
+		MemoryStorage: mem,
+		sleepFile:     newJSONFile("sleep logs", sleepFile, func() interface{} { return mem.allSleepLogs() }),
+		goalsFile:     newJSONFile("goals", goalsFile, func() interface{} { return mem.allGoals() }),
+		aggregatesFile: newJSONFile("daily aggregates", siblingFile(sleepFile, aggregatesFileName),
+			func() interface{} { return mem.allAggregates() }),
+		usersFile: newJSONFile("users", siblingFile(sleepFile, usersFileName),
+			func() interface{} { return toUserRecords(mem.allUsers()) }),
+		tokensFile: newJSONFile("access tokens", siblingFile(sleepFile, accessTokensFileName),
+			func() interface{} { return mem.allAccessTokens() }),
+		sessionsFile: newJSONFile("sessions", siblingFile(sleepFile, sessionsFileName),
+			func() interface{} { return mem.allSessions() }),
+		refreshFile: newJSONFile("refresh tokens", siblingFile(sleepFile, refreshTokensFileName),
+			func() interface{} { return mem.allRefreshTokens() }),
+		apiKeysFile: newJSONFile("api keys", siblingFile(sleepFile, apiKeysFileName),
+			func() interface{} { return mem.allAPIKeys() }),
+		grantsFile: newJSONFile("grants", siblingFile(sleepFile, grantsFileName),
+			func() interface{} { return mem.allGrants() }),
+		accessLogFile: newJSONFile("grant access log", siblingFile(sleepFile, grantAccessesFileName),
+			func() interface{} { return mem.allGrantAccesses() }),
+		shutdownChan: make(chan struct{}),
+		logger:       logger,
 	}
 
 	if err := s.loadSleepLogs(); err != nil {
@@ -51,50 +171,168 @@ func NewFileStorage(sleepFile, goalsFile string, logger internal.Logger) (*FileS
 		logger.Errorf("storage: failed to load goals: %v", err)
 		return nil, err
 	}
+	if err := s.loadAggregates(); err != nil {
+		logger.Errorf("storage: failed to load daily aggregates: %v", err)
+		return nil, err
+	}
+	if err := s.loadAccounts(); err != nil {
+		logger.Errorf("storage: failed to load accounts: %v", err)
+		return nil, err
+	}
+	auditLog, events, err := openAuditLog(siblingFile(sleepFile, auditLogFileName))
+	if err != nil {
+		logger.Errorf("storage: failed to load audit log: %v", err)
+		return nil, err
+	}
+	s.auditLog = auditLog
+	s.MemoryStorage.loadAuditEvents(events)
 
-	go s.saveLogsWorker()
-	go s.saveGoalsWorker()
+	for _, f := range s.files() {
+		go s.saveWorker(f)
+	}
 
 	return s, nil
 }
 
+const (
+	aggregatesFileName    = "daily_aggregates.json"
+	usersFileName         = "users.json"
+	accessTokensFileName  = "access_tokens.json"
+	sessionsFileName      = "sessions.json"
+	refreshTokensFileName = "refresh_tokens.json"
+	apiKeysFileName       = "api_keys.json"
+	grantsFileName        = "grants.json"
+	grantAccessesFileName = "grant_accesses.json"
+	auditLogFileName      = "audit.jsonl"
+)
+
+// FileDataFiles returns the files a FileStorage opened with sleepFile and
+// goalsFile persists to, without loading them. These are the files a restore
+// replaces, so the audit log is left out: it is included in snapshots but a
+// restore never rolls it back.
+func FileDataFiles(sleepFile, goalsFile string) []string {
+	return []string{
+		sleepFile,
+		goalsFile,
+		siblingFile(sleepFile, aggregatesFileName),
+		siblingFile(sleepFile, usersFileName),
+		siblingFile(sleepFile, accessTokensFileName),
+		siblingFile(sleepFile, sessionsFileName),
+		siblingFile(sleepFile, refreshTokensFileName),
+		siblingFile(sleepFile, apiKeysFileName),
+		siblingFile(sleepFile, grantsFileName),
+		siblingFile(sleepFile, grantAccessesFileName),
+	}
+}
+
+// siblingFile returns the path of name in the same directory as path.
+func siblingFile(path, name string) string {
+	return filepath.Join(filepath.Dir(path), name)
+}
+
+func (s *FileStorage) files() []*jsonFile {
+	return []*jsonFile{s.sleepFile, s.goalsFile, s.aggregatesFile, s.usersFile, s.tokensFile, s.sessionsFile, s.refreshFile, s.apiKeysFile, s.grantsFile, s.accessLogFile}
+}
+
 func (s *FileStorage) loadSleepLogs() error {
-	file, err := os.Open(s.sleepFile)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return nil
-		}
+	var logs []*internal.SleepLog
+	if err := readFileJSON(s.sleepFile.path, &logs); err != nil {
 		return err
 	}
-	defer file.Close()
+	s.MemoryStorage.loadSleepLogs(logs)
+	return nil
+}
 
-	var logs []*internal.SleepLog
-	if err := json.NewDecoder(file).Decode(&logs); err != nil {
-		if errors.Is(err, io.EOF) {
-			return nil
-		}
+func (s *FileStorage) loadGoals() error {
+	var goals []*internal.Goal
+	if err := readFileJSON(s.goalsFile.path, &goals); err != nil {
+		return err
+	}
+	s.MemoryStorage.loadGoals(goals)
+	return nil
+}
+
+func (s *FileStorage) loadAggregates() error {
+	if _, err := os.Stat(s.aggregatesFile.path); os.IsNotExist(err) {
+		// Data written before aggregates existed: derive them from the logs.
+		s.rebuildAggregates()
+		s.aggregatesFile.markChanged()
+		return nil
+	}
+	var aggs []*internal.DailyAggregate
+	if err := readFileJSON(s.aggregatesFile.path, &aggs); err != nil {
+		return err
+	}
+	s.MemoryStorage.loadAggregates(aggs)
+	return nil
+}
+
+// userRecord is the persisted form of a user; internal.User keeps the
+// password hash out of its JSON encoding.
+type userRecord struct {
+	*internal.User
+	PasswordHash string `json:"password_hash"`
+}
+
+func toUserRecords(users []*internal.User) []userRecord {
+	records := make([]userRecord, len(users))
+	for i, u := range users {
+		records[i] = userRecord{User: u, PasswordHash: u.PasswordHash}
+	}
+	return records
+}
+
+// loadAccounts loads users, the tokens and sessions issued to them and the
+// grants between them.
+func (s *FileStorage) loadAccounts() error {
+	var records []userRecord
+	if err := readFileJSON(s.usersFile.path, &records); err != nil {
 		return err
 	}
+	users := make([]*internal.User, len(records))
+	for i, r := range records {
+		r.User.PasswordHash = r.PasswordHash
+		users[i] = r.User
+	}
+	s.MemoryStorage.loadUsers(users)
+
+	var tokens []*internal.AccessToken
+	if err := readFileJSON(s.tokensFile.path, &tokens); err != nil {
+		return err
+	}
+	s.MemoryStorage.loadAccessTokens(tokens)
 
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	for _, l := range logs {
-		s.sleepLogs[l.ID] = l
-		s.userSleepIndex[l.UserID] = append(s.userSleepIndex[l.UserID], l)
+	var sessions []*internal.Session
+	if err := readFileJSON(s.sessionsFile.path, &sessions); err != nil {
+		return err
+	}
+	var refreshTokens []*internal.RefreshToken
+	if err := readFileJSON(s.refreshFile.path, &refreshTokens); err != nil {
+		return err
 	}
+	s.MemoryStorage.loadSessions(sessions, refreshTokens)
 
-	// Sort each user's logs descending by StartTime
-	for userID := range s.userSleepIndex {
-		sort.Slice(s.userSleepIndex[userID], func(i, j int) bool {
-			return s.userSleepIndex[userID][i].StartTime.After(s.userSleepIndex[userID][j].StartTime)
-		})
+	var apiKeys []*internal.APIKey
+	if err := readFileJSON(s.apiKeysFile.path, &apiKeys); err != nil {
+		return err
 	}
+	s.MemoryStorage.loadAPIKeys(apiKeys)
 
+	var grants []*internal.Grant
+	if err := readFileJSON(s.grantsFile.path, &grants); err != nil {
+		return err
+	}
+	var accesses []*internal.GrantAccess
+	if err := readFileJSON(s.accessLogFile.path, &accesses); err != nil {
+		return err
+	}
+	s.MemoryStorage.loadGrants(grants, accesses)
 	return nil
 }
 
-func (s *FileStorage) loadGoals() error {
-	file, err := os.Open(s.goalsFile)
+// readFileJSON decodes filePath into v. A missing or empty file leaves v untouched.
+func readFileJSON(filePath string, v interface{}) error {
+	file, err := os.Open(filePath)
 	if err != nil {
 		if os.IsNotExist(err) {
 			return nil
@@ -103,24 +341,12 @@ func (s *FileStorage) loadGoals() error {
 	}
 	defer file.Close()
 
-	var goals []*internal.Goal
-	if err := json.NewDecoder(file).Decode(&goals); err != nil {
+	if err := json.NewDecoder(file).Decode(v); err != nil {
 		if errors.Is(err, io.EOF) {
 			return nil
 		}
 		return err
 	}
-
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	s.goals = make(map[string]map[string]*internal.Goal)
-	for _, g := range goals {
-		if s.goals[g.UserID] == nil {
-			s.goals[g.UserID] = make(map[string]*internal.Goal)
-		}
-		s.goals[g.UserID][g.Type] = g
-	}
-
 	return nil
 }
 
@@ -153,43 +379,17 @@ func atomicWriteFileJSON(filePath string, data interface{}) error {
 	return os.Rename(tempFile, filePath)
 }
 
-func (s *FileStorage) saveSleepLogs() error {
-	s.mu.RLock()
-	logs := make([]*internal.SleepLog, 0, len(s.sleepLogs))
-	for _, l := range s.sleepLogs {
-		logs = append(logs, l)
-	}
-	s.mu.RUnlock()
-
-	return atomicWriteFileJSON(s.sleepFile, logs)
-}
-
-func (s *FileStorage) saveGoals() error {
-	s.mu.RLock()
-	var goals []*internal.Goal
-	for _, typeMap := range s.goals {
-		for _, g := range typeMap {
-			goals = append(goals, g)
-		}
-	}
-	s.mu.RUnlock()
-	if goals == nil {
-		goals = make([]*internal.Goal, 0)
-	}
-	return atomicWriteFileJSON(s.goalsFile, goals)
-}
-
-func (s *FileStorage) saveLogsWorker() {
-	timer := time.NewTimer(s.saveLogsDelay)
+func (s *FileStorage) saveWorker(f *jsonFile) {
+	timer := time.NewTimer(f.delay)
 	defer timer.Stop()
 
 	for {
 		select {
-		case <-s.saveLogsChan:
-			timer.Reset(s.saveLogsDelay)
+		case <-f.changed:
+			timer.Reset(f.delay)
 		case <-timer.C:
-			if err := s.saveSleepLogs(); err != nil {
-				s.logger.Errorf("storage: error saving sleep logs: %v", err)
+			if err := f.save(); err != nil {
+				s.logger.Errorf("storage: error saving %s: %v", f.name, err)
 			}
 		case <-s.shutdownChan:
 			return
@@ -197,109 +397,239 @@ func (s *FileStorage) saveLogsWorker() {
 	}
 }
 
-func (s *FileStorage) saveGoalsWorker() {
-	timer := time.NewTimer(s.saveGoalsDelay)
-	defer timer.Stop()
+func (s *FileStorage) Close() error {
+	close(s.shutdownChan)
 
-	for {
-		select {
-		case <-s.saveGoalsChan:
-			timer.Reset(s.saveGoalsDelay)
-		case <-timer.C:
-			if err := s.saveGoals(); err != nil {
-				s.logger.Errorf("storage: error saving goals: %v", err)
-			}
-		case <-s.shutdownChan:
-			return
+	// Save pending data synchronously on shutdown
+	err := s.Flush()
+	if cerr := s.auditLog.close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// Flush synchronously writes every collection to disk.
+func (s *FileStorage) Flush() error {
+	for _, f := range s.files() {
+		if err := f.save(); err != nil {
+			s.logger.Errorf("storage: error saving %s: %v", f.name, err)
+			return err
 		}
 	}
+	return nil
 }
 
-func (s *FileStorage) Close() error {
-	close(s.shutdownChan)
+// DataFiles returns the paths of every file the storage persists to.
+func (s *FileStorage) DataFiles() []string {
+	files := s.files()
+	paths := make([]string, len(files), len(files)+1)
+	for i, f := range files {
+		paths[i] = f.path
+	}
+	return append(paths, s.auditLog.path)
+}
 
-	// Save pending data synchronously on shutdown
-	if err := s.saveSleepLogs(); err != nil {
+// --- SleepLogRepository ---
+func (s *FileStorage) SaveSleepLog(ctx context.Context, log *internal.SleepLog) error {
+	if err := s.MemoryStorage.SaveSleepLog(ctx, log); err != nil {
 		return err
 	}
-	if err := s.saveGoals(); err != nil {
+	s.sleepFile.markChanged()
+	s.aggregatesFile.markChanged()
+	return nil
+}
+
+func (s *FileStorage) SaveSleepLogs(ctx context.Context, logs []internal.SleepLog) error {
+	if err := s.MemoryStorage.SaveSleepLogs(ctx, logs); err != nil {
 		return err
 	}
+	s.sleepFile.markChanged()
+	s.aggregatesFile.markChanged()
 	return nil
 }
 
-// --- SleepLogRepository ---
-func (s *FileStorage) SaveSleepLog(ctx context.Context, log *internal.SleepLog) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	s.sleepLogs[log.ID] = log
-	logs := s.userSleepIndex[log.UserID]
-	inserted := false
-	for i, existing := range logs {
-		if existing.StartTime.Before(log.StartTime) {
-			logs = append(logs[:i], append([]*internal.SleepLog{log}, logs[i:]...)...)
-			inserted = true
-			break
-		}
+// --- GoalRepository ---
+func (s *FileStorage) SetGoal(ctx context.Context, goal *internal.Goal) error {
+	if err := s.MemoryStorage.SetGoal(ctx, goal); err != nil {
+		return err
 	}
-	if !inserted {
-		logs = append(logs, log)
+	s.goalsFile.markChanged()
+	return nil
+}
+
+// --- AggregateRepository ---
+func (s *FileStorage) RebuildDailyAggregates(ctx context.Context) error {
+	if err := s.MemoryStorage.RebuildDailyAggregates(ctx); err != nil {
+		return err
 	}
-	s.userSleepIndex[log.UserID] = logs
-	select {
-	case s.saveLogsChan <- struct{}{}:
-	default:
+	return s.aggregatesFile.save()
+}
+
+// --- UserRepository ---
+func (s *FileStorage) CreateUser(ctx context.Context, user *internal.User) error {
+	if err := s.MemoryStorage.CreateUser(ctx, user); err != nil {
+		return err
 	}
+	s.usersFile.markChanged()
 	return nil
 }
 
-func (s *FileStorage) ListSleepLogs(ctx context.Context, userID string) ([]internal.SleepLog, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	logsPtr, ok := s.userSleepIndex[userID]
-	if !ok {
-		return []internal.SleepLog{}, nil
+func (s *FileStorage) UpdateUser(ctx context.Context, user *internal.User) error {
+	if err := s.MemoryStorage.UpdateUser(ctx, user); err != nil {
+		return err
 	}
-	logs := make([]internal.SleepLog, len(logsPtr))
-	for i, l := range logsPtr {
-		logs[i] = *l
+	s.usersFile.markChanged()
+	return nil
+}
+
+func (s *FileStorage) SaveAccessToken(ctx context.Context, token *internal.AccessToken) error {
+	if err := s.MemoryStorage.SaveAccessToken(ctx, token); err != nil {
+		return err
 	}
-	return logs, nil
+	s.tokensFile.markChanged()
+	return nil
 }
 
-// --- GoalRepository ---
-func (s *FileStorage) SetGoal(ctx context.Context, goal *internal.Goal) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	if s.goals[goal.UserID] == nil {
-		s.goals[goal.UserID] = make(map[string]*internal.Goal)
+func (s *FileStorage) DeleteAccessToken(ctx context.Context, hash string) error {
+	if err := s.MemoryStorage.DeleteAccessToken(ctx, hash); err != nil {
+		return err
 	}
-	s.goals[goal.UserID][goal.Type] = goal
-	select {
-	case s.saveGoalsChan <- struct{}{}:
-	default:
+	s.tokensFile.markChanged()
+	return nil
+}
+
+// --- SessionRepository ---
+func (s *FileStorage) CreateSession(ctx context.Context, session *internal.Session) error {
+	if err := s.MemoryStorage.CreateSession(ctx, session); err != nil {
+		return err
+	}
+	s.sessionsFile.markChanged()
+	return nil
+}
+
+func (s *FileStorage) TouchSession(ctx context.Context, id string, at, expiresAt time.Time) error {
+	if err := s.MemoryStorage.TouchSession(ctx, id, at, expiresAt); err != nil {
+		return err
+	}
+	s.sessionsFile.markChanged()
+	return nil
+}
+
+func (s *FileStorage) RevokeSession(ctx context.Context, id string, at time.Time) error {
+	if err := s.MemoryStorage.RevokeSession(ctx, id, at); err != nil {
+		return err
+	}
+	s.sessionsFile.markChanged()
+	s.tokensFile.markChanged()
+	return nil
+}
+
+func (s *FileStorage) SaveRefreshToken(ctx context.Context, token *internal.RefreshToken) error {
+	if err := s.MemoryStorage.SaveRefreshToken(ctx, token); err != nil {
+		return err
+	}
+	s.refreshFile.markChanged()
+	return nil
+}
+
+func (s *FileStorage) ConsumeRefreshToken(ctx context.Context, hash string, at time.Time) (*internal.RefreshToken, error) {
+	token, err := s.MemoryStorage.ConsumeRefreshToken(ctx, hash, at)
+	if err == nil {
+		s.refreshFile.markChanged()
+	}
+	return token, err
+}
+
+// --- APIKeyRepository ---
+func (s *FileStorage) CreateAPIKey(ctx context.Context, key *internal.APIKey) error {
+	if err := s.MemoryStorage.CreateAPIKey(ctx, key); err != nil {
+		return err
+	}
+	s.apiKeysFile.markChanged()
+	return nil
+}
+
+func (s *FileStorage) TouchAPIKey(ctx context.Context, id string, at time.Time) error {
+	if err := s.MemoryStorage.TouchAPIKey(ctx, id, at); err != nil {
+		return err
+	}
+	s.apiKeysFile.markChanged()
+	return nil
+}
+
+func (s *FileStorage) DeleteAPIKey(ctx context.Context, userID, id string) error {
+	if err := s.MemoryStorage.DeleteAPIKey(ctx, userID, id); err != nil {
+		return err
 	}
+	s.apiKeysFile.markChanged()
 	return nil
 }
 
-func (s *FileStorage) GetGoal(ctx context.Context, userID string) (*internal.Goal, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	typeMap, ok := s.goals[userID]
-	if !ok || len(typeMap) == 0 {
-		return nil, errors.New("storage: goal not found")
+// --- SharingRepository ---
+func (s *FileStorage) CreateGrant(ctx context.Context, grant *internal.Grant) error {
+	if err := s.MemoryStorage.CreateGrant(ctx, grant); err != nil {
+		return err
+	}
+	s.grantsFile.markChanged()
+	return nil
+}
+
+func (s *FileStorage) UpdateGrant(ctx context.Context, grant *internal.Grant) error {
+	if err := s.MemoryStorage.UpdateGrant(ctx, grant); err != nil {
+		return err
 	}
-	// Return the most recently created goal (by CreatedAt) among all types
-	var latest *internal.Goal
-	for _, g := range typeMap {
-		if latest == nil || g.CreatedAt.After(latest.CreatedAt) {
-			latest = g
+	s.grantsFile.markChanged()
+	return nil
+}
+
+func (s *FileStorage) RecordGrantAccess(ctx context.Context, access *internal.GrantAccess) error {
+	if err := s.MemoryStorage.RecordGrantAccess(ctx, access); err != nil {
+		return err
+	}
+	s.accessLogFile.markChanged()
+	return nil
+}
+
+// --- AuditRepository ---
+// AppendAuditEvent writes the event to the audit log before it becomes
+// visible, rather than in the background like other collections.
+func (s *FileStorage) AppendAuditEvent(ctx context.Context, event *internal.AuditEvent) error {
+	if err := s.auditLog.append(event); err != nil {
+		s.logger.Errorf("storage: failed to append audit event: %v", err)
+		return err
+	}
+	return s.MemoryStorage.AppendAuditEvent(ctx, event)
+}
+
+// --- HealthChecker ---
+// Health reports unhealthy if the last write of any data file failed.
+func (s *FileStorage) Health(ctx context.Context) Health {
+	h := Health{Backend: "file", OK: true, Details: s.counts()}
+	files := map[string]any{}
+	for _, f := range s.files() {
+		savedAt, err := f.status()
+		status := map[string]any{"path": f.path}
+		if !savedAt.IsZero() {
+			status["saved_at"] = savedAt
+		}
+		if err != nil {
+			status["error"] = err.Error()
+			h.OK = false
+			h.Error = "failed to save " + f.name
 		}
+		files[f.name] = status
 	}
-	return latest, nil
+	h.Details["files"] = files
+	return h
 }
 
 // --- Compile-time assertions ---
 var _ SleepLogRepository = (*FileStorage)(nil)
 var _ GoalRepository = (*FileStorage)(nil)
+var _ AggregateRepository = (*FileStorage)(nil)
+var _ UserRepository = (*FileStorage)(nil)
+var _ SessionRepository = (*FileStorage)(nil)
+var _ APIKeyRepository = (*FileStorage)(nil)
+var _ SharingRepository = (*FileStorage)(nil)
+var _ AuditRepository = (*FileStorage)(nil)
+var _ HealthChecker = (*FileStorage)(nil)
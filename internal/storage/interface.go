@@ -2,21 +2,164 @@ package storage
 
 import (
 	"context"
+	"time"
 
 	"github.com/yourname/sleeptracker/internal"
 )
 
 type SleepLogRepository interface {
 	SaveSleepLog(ctx context.Context, log *internal.SleepLog) error
+	// SaveSleepLogs saves every log or, on error, none of them. A log with
+	// the ID of a stored one replaces it. Both fail with ErrSourceTaken if
+	// another of the user's logs has the same source name and ID.
+	SaveSleepLogs(ctx context.Context, logs []internal.SleepLog) error
 	ListSleepLogs(ctx context.Context, userID string) ([]internal.SleepLog, error)
+	// IterateSleepLogs calls fn with each of the user's logs, newest first
+	// and ties broken by ID, without loading them all at once. It stops at
+	// the first error fn returns and returns it.
+	IterateSleepLogs(ctx context.Context, userID string, fn func(internal.SleepLog) error) error
+	// GetSleepLog returns ErrNotFound if no log has the ID.
+	GetSleepLog(ctx context.Context, id string) (*internal.SleepLog, error)
 }
 
 type GoalRepository interface {
 	SetGoal(ctx context.Context, goal *internal.Goal) error
+	// GetGoal returns ErrNotFound if the user has no goal.
 	GetGoal(ctx context.Context, userID string) (*internal.Goal, error)
 }
 
-type AuthProvider interface {
-	ValidateTokenLocal(token string) (*internal.User, error)
-	ValidateTokenRemote(ctx context.Context, token string) (*internal.User, error)
+// AggregateRepository exposes per-user, per-day aggregates that backends
+// maintain whenever a sleep log is written.
+type AggregateRepository interface {
+	// ListDailyAggregates returns the user's aggregates for UTC days in
+	// [from, to], most recent day first.
+	ListDailyAggregates(ctx context.Context, userID string, from, to time.Time) ([]internal.DailyAggregate, error)
+	// RebuildDailyAggregates regenerates every aggregate from the raw logs.
+	RebuildDailyAggregates(ctx context.Context) error
+}
+
+// Storage errors are of the internal error kinds, so callers that do not
+// translate them still report the right status.
+var (
+	ErrNotFound    = internal.NewError(internal.ErrNotFound, "storage: not found")
+	ErrEmailTaken  = internal.NewError(internal.ErrConflict, "storage: email already registered")
+	ErrSourceTaken = internal.NewError(internal.ErrConflict, "storage: a log from this source is already stored")
+	ErrTokenReused = internal.NewError(internal.ErrConflict, "storage: refresh token already used")
+)
+
+// UserRepository stores registered accounts and the access tokens issued to
+// them. Lookups return ErrNotFound when nothing matches.
+type UserRepository interface {
+	// CreateUser fails with ErrEmailTaken if the email is already registered.
+	CreateUser(ctx context.Context, user *internal.User) error
+	GetUserByID(ctx context.Context, id string) (*internal.User, error)
+	GetUserByEmail(ctx context.Context, email string) (*internal.User, error)
+	// ListUsers returns every user, oldest first.
+	ListUsers(ctx context.Context) ([]internal.User, error)
+	// UpdateUser saves the user's name, role and disabled state. Email and
+	// password cannot be changed this way.
+	UpdateUser(ctx context.Context, user *internal.User) error
+
+	SaveAccessToken(ctx context.Context, token *internal.AccessToken) error
+	GetAccessToken(ctx context.Context, hash string) (*internal.AccessToken, error)
+	DeleteAccessToken(ctx context.Context, hash string) error
+}
+
+// SessionRepository stores login sessions and their refresh tokens.
+type SessionRepository interface {
+	CreateSession(ctx context.Context, session *internal.Session) error
+	GetSession(ctx context.Context, id string) (*internal.Session, error)
+	// ListSessions returns the user's sessions, most recently used first.
+	ListSessions(ctx context.Context, userID string) ([]internal.Session, error)
+	// TouchSession records a refresh: the session was used at and now
+	// expires at expiresAt.
+	TouchSession(ctx context.Context, id string, at, expiresAt time.Time) error
+	// RevokeSession marks the session revoked and deletes its access tokens.
+	RevokeSession(ctx context.Context, id string, at time.Time) error
+
+	SaveRefreshToken(ctx context.Context, token *internal.RefreshToken) error
+	// ConsumeRefreshToken atomically marks the token used. If it was already
+	// used it returns the token together with ErrTokenReused.
+	ConsumeRefreshToken(ctx context.Context, hash string, at time.Time) (*internal.RefreshToken, error)
+}
+
+// APIKeyRepository stores users' API keys.
+type APIKeyRepository interface {
+	CreateAPIKey(ctx context.Context, key *internal.APIKey) error
+	GetAPIKeyByHash(ctx context.Context, hash string) (*internal.APIKey, error)
+	// ListAPIKeys returns the user's keys, newest first.
+	ListAPIKeys(ctx context.Context, userID string) ([]internal.APIKey, error)
+	// TouchAPIKey records that the key was used at the given time.
+	TouchAPIKey(ctx context.Context, id string, at time.Time) error
+	// DeleteAPIKey deletes one of the user's keys, returning ErrNotFound if
+	// the user has no key with that ID.
+	DeleteAPIKey(ctx context.Context, userID, id string) error
+}
+
+// SharingRepository stores grants of access to a user's data and the log of
+// accesses made under them.
+type SharingRepository interface {
+	CreateGrant(ctx context.Context, grant *internal.Grant) error
+	GetGrant(ctx context.Context, id string) (*internal.Grant, error)
+	// ListGrantsByOwner returns the grants a user has given, newest first.
+	ListGrantsByOwner(ctx context.Context, ownerID string) ([]internal.Grant, error)
+	// ListGrantsByGrantee returns the grants a user has accepted, newest
+	// first.
+	ListGrantsByGrantee(ctx context.Context, granteeID string) ([]internal.Grant, error)
+	// ListGrantsByEmail returns the grants made out to an email, newest
+	// first.
+	ListGrantsByEmail(ctx context.Context, email string) ([]internal.Grant, error)
+	// UpdateGrant saves the grant's grantee ID and accepted and revoked
+	// times.
+	UpdateGrant(ctx context.Context, grant *internal.Grant) error
+
+	RecordGrantAccess(ctx context.Context, access *internal.GrantAccess) error
+	// ListGrantAccesses returns accesses to the owner's data, newest first.
+	ListGrantAccesses(ctx context.Context, ownerID string) ([]internal.GrantAccess, error)
+}
+
+// Health describes the state of a storage backend.
+type Health struct {
+	Backend string         `json:"backend"`
+	OK      bool           `json:"ok"`
+	Error   string         `json:"error,omitempty"`
+	Details map[string]any `json:"details,omitempty"`
+}
+
+// HealthChecker is implemented by backends that can report their health.
+type HealthChecker interface {
+	Health(ctx context.Context) Health
+}
+
+// AuditRepository is an append-only store of audit events: events can be
+// added and queried but never changed or removed.
+type AuditRepository interface {
+	AppendAuditEvent(ctx context.Context, event *internal.AuditEvent) error
+	// ListAuditEvents returns events matching the filter, newest first.
+	ListAuditEvents(ctx context.Context, filter AuditFilter) ([]internal.AuditEvent, error)
+}
+
+// AuditFilter selects audit events. Zero fields match everything; Limit is
+// required.
+type AuditFilter struct {
+	UserID string // events the user performed or was the target of
+	Action string
+	Since  time.Time // inclusive
+	Until  time.Time // exclusive
+	Limit  int
+}
+
+// match reports whether e passes every filter condition except Limit.
+func (f AuditFilter) match(e *internal.AuditEvent) bool {
+	switch {
+	case f.UserID != "" && e.ActorID != f.UserID && e.TargetUserID != f.UserID:
+		return false
+	case f.Action != "" && e.Action != f.Action:
+		return false
+	case !f.Since.IsZero() && e.At.Before(f.Since):
+		return false
+	case !f.Until.IsZero() && !e.At.Before(f.Until):
+		return false
+	}
+	return true
 }
@@ -0,0 +1,926 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/yourname/sleeptracker/internal"
+)
+
+// MemoryStorage keeps all data in process memory. It has no background
+// goroutines and nothing survives the process, which makes it a good fit for
+// tests and ephemeral demos. FileStorage builds its persistence on top of it.
+type MemoryStorage struct {
+	sleepLogs      map[string]*internal.SleepLog                  // id -> SleepLog
+	userSleepIndex map[string][]*internal.SleepLog                // userID -> slice of SleepLogs (see sleepLogBefore)
+	goals          map[string]map[string]*internal.Goal           // userID -> type -> Goal
+	aggregates     map[string]map[string]*internal.DailyAggregate // userID -> date -> aggregate
+	users          map[string]*internal.User                      // id -> User
+	userEmails     map[string]string                              // email -> user id
+	sleepSources   map[[3]string]string                           // userID, source name, source ID -> sleep log id
+	accessTokens   map[string]*internal.AccessToken               // hash -> AccessToken
+	sessions       map[string]*internal.Session                   // id -> Session
+	refreshTokens  map[string]*internal.RefreshToken              // hash -> RefreshToken
+	apiKeys        map[string]*internal.APIKey                    // id -> APIKey
+	apiKeyHashes   map[string]string                              // hash -> api key id
+	grants         map[string]*internal.Grant                     // id -> Grant
+	grantAccesses  []*internal.GrantAccess                        // oldest first
+	auditEvents    []*internal.AuditEvent                         // oldest first
+	mu             sync.RWMutex
+	logger         internal.Logger
+}
+
+// Fixture is the on-disk format used to seed a MemoryStorage.
+type Fixture struct {
+	SleepLogs []*internal.SleepLog `json:"sleep_logs"`
+	Goals     []*internal.Goal     `json:"goals"`
+}
+
+func NewMemoryStorage(logger internal.Logger) *MemoryStorage {
+	return &MemoryStorage{
+		sleepLogs:      make(map[string]*internal.SleepLog),
+		userSleepIndex: make(map[string][]*internal.SleepLog),
+		goals:          make(map[string]map[string]*internal.Goal),
+		aggregates:     make(map[string]map[string]*internal.DailyAggregate),
+		users:          make(map[string]*internal.User),
+		userEmails:     make(map[string]string),
+		sleepSources:   make(map[[3]string]string),
+		accessTokens:   make(map[string]*internal.AccessToken),
+		sessions:       make(map[string]*internal.Session),
+		refreshTokens:  make(map[string]*internal.RefreshToken),
+		apiKeys:        make(map[string]*internal.APIKey),
+		apiKeyHashes:   make(map[string]string),
+		grants:         make(map[string]*internal.Grant),
+		logger:         logger,
+	}
+}
+
+// NewMemoryStorageFromFixture returns a MemoryStorage seeded from a JSON
+// fixture file. An empty path yields an empty store.
+func NewMemoryStorageFromFixture(path string, logger internal.Logger) (*MemoryStorage, error) {
+	s := NewMemoryStorage(logger)
+	if path == "" {
+		return s, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		logger.Errorf("storage: failed to read fixture: %v", err)
+		return nil, err
+	}
+	var fixture Fixture
+	if err := json.Unmarshal(data, &fixture); err != nil {
+		logger.Errorf("storage: failed to decode fixture: %v", err)
+		return nil, err
+	}
+	s.loadSleepLogs(fixture.SleepLogs)
+	s.loadGoals(fixture.Goals)
+	s.rebuildAggregates()
+	return s, nil
+}
+
+func (s *MemoryStorage) loadSleepLogs(logs []*internal.SleepLog) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, l := range logs {
+		s.sleepLogs[l.ID] = l
+		s.userSleepIndex[l.UserID] = append(s.userSleepIndex[l.UserID], l)
+		if key, ok := sourceKey(l); ok {
+			s.sleepSources[key] = l.ID
+		}
+	}
+
+	for userID := range s.userSleepIndex {
+		logs := s.userSleepIndex[userID]
+		sort.Slice(logs, func(i, j int) bool { return sleepLogBefore(logs[i], logs[j]) })
+	}
+}
+
+// sleepLogBefore orders a user's index: descending by StartTime, ties
+// broken by ID so that iteration can resume after a given log.
+func sleepLogBefore(a, b *internal.SleepLog) bool {
+	if !a.StartTime.Equal(b.StartTime) {
+		return a.StartTime.After(b.StartTime)
+	}
+	return a.ID < b.ID
+}
+
+func (s *MemoryStorage) loadGoals(goals []*internal.Goal) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.goals = make(map[string]map[string]*internal.Goal)
+	for _, g := range goals {
+		if s.goals[g.UserID] == nil {
+			s.goals[g.UserID] = make(map[string]*internal.Goal)
+		}
+		s.goals[g.UserID][g.Type] = g
+	}
+}
+
+func (s *MemoryStorage) allSleepLogs() []*internal.SleepLog {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	logs := make([]*internal.SleepLog, 0, len(s.sleepLogs))
+	for _, l := range s.sleepLogs {
+		logs = append(logs, l)
+	}
+	return logs
+}
+
+func (s *MemoryStorage) allGoals() []*internal.Goal {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	goals := make([]*internal.Goal, 0)
+	for _, typeMap := range s.goals {
+		for _, g := range typeMap {
+			goals = append(goals, g)
+		}
+	}
+	return goals
+}
+
+// --- SleepLogRepository ---
+func (s *MemoryStorage) SaveSleepLog(ctx context.Context, log *internal.SleepLog) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.checkSources([]internal.SleepLog{*log}); err != nil {
+		return err
+	}
+	for _, day := range s.saveSleepLog(*log) {
+		s.refreshAggregate(day[0], day[1])
+	}
+	return nil
+}
+
+func (s *MemoryStorage) SaveSleepLogs(ctx context.Context, logs []internal.SleepLog) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.checkSources(logs); err != nil {
+		return err
+	}
+	days := make(map[[2]string]bool)
+	for _, log := range logs {
+		for _, day := range s.saveSleepLog(log) {
+			days[day] = true
+		}
+	}
+	for day := range days {
+		s.refreshAggregate(day[0], day[1])
+	}
+	return nil
+}
+
+// sourceKey identifies an imported log by its user and where it came from.
+func sourceKey(log *internal.SleepLog) ([3]string, bool) {
+	if log.Source == nil {
+		return [3]string{}, false
+	}
+	return [3]string{log.UserID, log.Source.Name, log.Source.ID}, true
+}
+
+// checkSources fails with ErrSourceTaken if saving logs would give a user
+// two logs with the same source, as the unique index does in Postgres.
+// Callers must hold s.mu.
+func (s *MemoryStorage) checkSources(logs []internal.SleepLog) error {
+	batch := make(map[[3]string]string)
+	for i := range logs {
+		key, ok := sourceKey(&logs[i])
+		if !ok {
+			continue
+		}
+		if id, ok := s.sleepSources[key]; ok && id != logs[i].ID {
+			return ErrSourceTaken
+		}
+		if id, ok := batch[key]; ok && id != logs[i].ID {
+			return ErrSourceTaken
+		}
+		batch[key] = logs[i].ID
+	}
+	return nil
+}
+
+// saveSleepLog stores log and returns the user and day of each aggregate
+// that needs refreshing. Callers must hold s.mu for writing.
+func (s *MemoryStorage) saveSleepLog(stored internal.SleepLog) [][2]string {
+	days := [][2]string{{stored.UserID, DayOf(stored.StartTime)}}
+	// Saving an existing ID replaces the previous version of the log.
+	if previous, ok := s.sleepLogs[stored.ID]; ok {
+		s.removeFromIndex(previous)
+		if key, ok := sourceKey(previous); ok {
+			delete(s.sleepSources, key)
+		}
+		days = append(days, [2]string{previous.UserID, DayOf(previous.StartTime)})
+	}
+
+	s.sleepLogs[stored.ID] = &stored
+	if key, ok := sourceKey(&stored); ok {
+		s.sleepSources[key] = stored.ID
+	}
+	logs := s.userSleepIndex[stored.UserID]
+	inserted := false
+	for i, existing := range logs {
+		if sleepLogBefore(&stored, existing) {
+			logs = append(logs[:i], append([]*internal.SleepLog{&stored}, logs[i:]...)...)
+			inserted = true
+			break
+		}
+	}
+	if !inserted {
+		logs = append(logs, &stored)
+	}
+	s.userSleepIndex[stored.UserID] = logs
+	return days
+}
+
+func (s *MemoryStorage) removeFromIndex(log *internal.SleepLog) {
+	logs := s.userSleepIndex[log.UserID]
+	for i, existing := range logs {
+		if existing.ID == log.ID {
+			s.userSleepIndex[log.UserID] = append(logs[:i], logs[i+1:]...)
+			return
+		}
+	}
+}
+
+// refreshAggregate recomputes one day's aggregate from the user's logs.
+// Callers must hold s.mu for writing.
+func (s *MemoryStorage) refreshAggregate(userID, date string) {
+	agg := &internal.DailyAggregate{UserID: userID, Date: date}
+	for _, l := range s.userSleepIndex[userID] {
+		if DayOf(l.StartTime) == date {
+			addToAggregate(agg, l)
+		}
+	}
+	if agg.LogCount == 0 {
+		delete(s.aggregates[userID], date)
+		return
+	}
+	if s.aggregates[userID] == nil {
+		s.aggregates[userID] = make(map[string]*internal.DailyAggregate)
+	}
+	s.aggregates[userID][date] = agg
+}
+
+func (s *MemoryStorage) rebuildAggregates() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	logs := make([]internal.SleepLog, 0, len(s.sleepLogs))
+	for _, l := range s.sleepLogs {
+		logs = append(logs, *l)
+	}
+	s.aggregates = make(map[string]map[string]*internal.DailyAggregate)
+	for _, agg := range BuildDailyAggregates(logs) {
+		agg := agg
+		if s.aggregates[agg.UserID] == nil {
+			s.aggregates[agg.UserID] = make(map[string]*internal.DailyAggregate)
+		}
+		s.aggregates[agg.UserID][agg.Date] = &agg
+	}
+}
+
+func (s *MemoryStorage) loadAggregates(aggs []*internal.DailyAggregate) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.aggregates = make(map[string]map[string]*internal.DailyAggregate)
+	for _, agg := range aggs {
+		if s.aggregates[agg.UserID] == nil {
+			s.aggregates[agg.UserID] = make(map[string]*internal.DailyAggregate)
+		}
+		s.aggregates[agg.UserID][agg.Date] = agg
+	}
+}
+
+func (s *MemoryStorage) allAggregates() []*internal.DailyAggregate {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	aggs := make([]*internal.DailyAggregate, 0)
+	for _, byDate := range s.aggregates {
+		for _, agg := range byDate {
+			aggs = append(aggs, agg)
+		}
+	}
+	return aggs
+}
+
+func (s *MemoryStorage) ListSleepLogs(ctx context.Context, userID string) ([]internal.SleepLog, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	logsPtr, ok := s.userSleepIndex[userID]
+	if !ok {
+		return []internal.SleepLog{}, nil
+	}
+	logs := make([]internal.SleepLog, len(logsPtr))
+	for i, l := range logsPtr {
+		logs[i] = *l
+	}
+	return logs, nil
+}
+
+// iterateChunk is how many logs IterateSleepLogs copies per lock.
+const iterateChunk = 500
+
+// IterateSleepLogs walks the index a chunk at a time, so that fn runs
+// without the lock held. Logs saved meanwhile are seen if they sort after
+// the last one fn was given.
+func (s *MemoryStorage) IterateSleepLogs(ctx context.Context, userID string, fn func(internal.SleepLog) error) error {
+	var after *internal.SleepLog
+	for {
+		chunk := s.sleepLogChunk(userID, after)
+		for _, l := range chunk {
+			if err := fn(l); err != nil {
+				return err
+			}
+		}
+		if len(chunk) < iterateChunk {
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		after = &chunk[len(chunk)-1]
+	}
+}
+
+// sleepLogChunk copies up to iterateChunk of the user's logs, starting
+// after the given one, or at the newest if it is nil.
+func (s *MemoryStorage) sleepLogChunk(userID string, after *internal.SleepLog) []internal.SleepLog {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	logs := s.userSleepIndex[userID]
+	start := 0
+	if after != nil {
+		start = sort.Search(len(logs), func(i int) bool { return sleepLogBefore(after, logs[i]) })
+	}
+	end := min(start+iterateChunk, len(logs))
+	chunk := make([]internal.SleepLog, 0, end-start)
+	for _, l := range logs[start:end] {
+		chunk = append(chunk, *l)
+	}
+	return chunk
+}
+
+func (s *MemoryStorage) GetSleepLog(ctx context.Context, id string) (*internal.SleepLog, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	l, ok := s.sleepLogs[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	log := *l
+	return &log, nil
+}
+
+// --- GoalRepository ---
+func (s *MemoryStorage) SetGoal(ctx context.Context, goal *internal.Goal) error {
+	stored := *goal
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.goals[stored.UserID] == nil {
+		s.goals[stored.UserID] = make(map[string]*internal.Goal)
+	}
+	s.goals[stored.UserID][stored.Type] = &stored
+	return nil
+}
+
+func (s *MemoryStorage) GetGoal(ctx context.Context, userID string) (*internal.Goal, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	typeMap, ok := s.goals[userID]
+	if !ok || len(typeMap) == 0 {
+		return nil, ErrNotFound
+	}
+	// Return the most recently created goal (by CreatedAt) among all types
+	var latest *internal.Goal
+	for _, g := range typeMap {
+		if latest == nil || g.CreatedAt.After(latest.CreatedAt) {
+			latest = g
+		}
+	}
+	goal := *latest
+	return &goal, nil
+}
+
+// --- AggregateRepository ---
+func (s *MemoryStorage) ListDailyAggregates(ctx context.Context, userID string, from, to time.Time) ([]internal.DailyAggregate, error) {
+	fromDay, toDay := DayOf(from), DayOf(to)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	aggs := []internal.DailyAggregate{}
+	for date, agg := range s.aggregates[userID] {
+		if date >= fromDay && date <= toDay {
+			aggs = append(aggs, *agg)
+		}
+	}
+	sortAggregates(aggs)
+	return aggs, nil
+}
+
+func (s *MemoryStorage) RebuildDailyAggregates(ctx context.Context) error {
+	s.rebuildAggregates()
+	return nil
+}
+
+func (s *MemoryStorage) loadUsers(users []*internal.User) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, u := range users {
+		s.users[u.ID] = u
+		s.userEmails[u.Email] = u.ID
+	}
+}
+
+func (s *MemoryStorage) loadAccessTokens(tokens []*internal.AccessToken) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, t := range tokens {
+		s.accessTokens[t.Hash] = t
+	}
+}
+
+func (s *MemoryStorage) allUsers() []*internal.User {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	users := make([]*internal.User, 0, len(s.users))
+	for _, u := range s.users {
+		users = append(users, u)
+	}
+	return users
+}
+
+func (s *MemoryStorage) allAccessTokens() []*internal.AccessToken {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	tokens := make([]*internal.AccessToken, 0, len(s.accessTokens))
+	for _, t := range s.accessTokens {
+		tokens = append(tokens, t)
+	}
+	return tokens
+}
+
+// --- UserRepository ---
+func (s *MemoryStorage) CreateUser(ctx context.Context, user *internal.User) error {
+	stored := *user
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.userEmails[stored.Email]; ok {
+		return ErrEmailTaken
+	}
+	s.users[stored.ID] = &stored
+	s.userEmails[stored.Email] = stored.ID
+	return nil
+}
+
+func (s *MemoryStorage) GetUserByID(ctx context.Context, id string) (*internal.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	u, ok := s.users[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	user := *u
+	return &user, nil
+}
+
+func (s *MemoryStorage) GetUserByEmail(ctx context.Context, email string) (*internal.User, error) {
+	s.mu.RLock()
+	id, ok := s.userEmails[email]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return s.GetUserByID(ctx, id)
+}
+
+func (s *MemoryStorage) ListUsers(ctx context.Context) ([]internal.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	users := make([]internal.User, 0, len(s.users))
+	for _, u := range s.users {
+		users = append(users, *u)
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].CreatedAt.Before(users[j].CreatedAt) })
+	return users, nil
+}
+
+func (s *MemoryStorage) UpdateUser(ctx context.Context, user *internal.User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.users[user.ID]
+	if !ok {
+		return ErrNotFound
+	}
+	updated := *u
+	updated.Name, updated.Role, updated.DisabledAt = user.Name, user.Role, user.DisabledAt
+	s.users[user.ID] = &updated
+	return nil
+}
+
+func (s *MemoryStorage) SaveAccessToken(ctx context.Context, token *internal.AccessToken) error {
+	stored := *token
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// Expired tokens are useless; drop them so the map does not grow forever.
+	for hash, t := range s.accessTokens {
+		if t.ExpiresAt.Before(now) {
+			delete(s.accessTokens, hash)
+		}
+	}
+	s.accessTokens[stored.Hash] = &stored
+	return nil
+}
+
+func (s *MemoryStorage) GetAccessToken(ctx context.Context, hash string) (*internal.AccessToken, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	t, ok := s.accessTokens[hash]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	token := *t
+	return &token, nil
+}
+
+func (s *MemoryStorage) DeleteAccessToken(ctx context.Context, hash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.accessTokens, hash)
+	return nil
+}
+
+func (s *MemoryStorage) loadSessions(sessions []*internal.Session, tokens []*internal.RefreshToken) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, sess := range sessions {
+		s.sessions[sess.ID] = sess
+	}
+	for _, t := range tokens {
+		s.refreshTokens[t.Hash] = t
+	}
+}
+
+func (s *MemoryStorage) allSessions() []*internal.Session {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	sessions := make([]*internal.Session, 0, len(s.sessions))
+	for _, sess := range s.sessions {
+		sessions = append(sessions, sess)
+	}
+	return sessions
+}
+
+func (s *MemoryStorage) allRefreshTokens() []*internal.RefreshToken {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	tokens := make([]*internal.RefreshToken, 0, len(s.refreshTokens))
+	for _, t := range s.refreshTokens {
+		tokens = append(tokens, t)
+	}
+	return tokens
+}
+
+// --- SessionRepository ---
+func (s *MemoryStorage) CreateSession(ctx context.Context, session *internal.Session) error {
+	stored := *session
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, sess := range s.sessions {
+		if sess.ExpiresAt.Before(now) {
+			delete(s.sessions, id)
+		}
+	}
+	s.sessions[stored.ID] = &stored
+	return nil
+}
+
+func (s *MemoryStorage) GetSession(ctx context.Context, id string) (*internal.Session, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	sess, ok := s.sessions[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	session := *sess
+	return &session, nil
+}
+
+func (s *MemoryStorage) ListSessions(ctx context.Context, userID string) ([]internal.Session, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	sessions := []internal.Session{}
+	for _, sess := range s.sessions {
+		if sess.UserID == userID {
+			sessions = append(sessions, *sess)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].LastUsedAt.After(sessions[j].LastUsedAt) })
+	return sessions, nil
+}
+
+func (s *MemoryStorage) TouchSession(ctx context.Context, id string, at, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess, ok := s.sessions[id]
+	if !ok {
+		return ErrNotFound
+	}
+	updated := *sess
+	updated.LastUsedAt, updated.ExpiresAt = at, expiresAt
+	s.sessions[id] = &updated
+	return nil
+}
+
+func (s *MemoryStorage) RevokeSession(ctx context.Context, id string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess, ok := s.sessions[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if sess.RevokedAt == nil {
+		updated := *sess
+		updated.RevokedAt = &at
+		s.sessions[id] = &updated
+	}
+	for hash, t := range s.accessTokens {
+		if t.SessionID == id {
+			delete(s.accessTokens, hash)
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStorage) SaveRefreshToken(ctx context.Context, token *internal.RefreshToken) error {
+	stored := *token
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for hash, t := range s.refreshTokens {
+		if t.ExpiresAt.Before(now) {
+			delete(s.refreshTokens, hash)
+		}
+	}
+	s.refreshTokens[stored.Hash] = &stored
+	return nil
+}
+
+func (s *MemoryStorage) ConsumeRefreshToken(ctx context.Context, hash string, at time.Time) (*internal.RefreshToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.refreshTokens[hash]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	token := *t
+	if token.UsedAt != nil {
+		return &token, ErrTokenReused
+	}
+	used := token
+	used.UsedAt = &at
+	s.refreshTokens[hash] = &used
+	return &token, nil
+}
+
+func (s *MemoryStorage) loadAPIKeys(keys []*internal.APIKey) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, k := range keys {
+		s.apiKeys[k.ID] = k
+		s.apiKeyHashes[k.Hash] = k.ID
+	}
+}
+
+func (s *MemoryStorage) allAPIKeys() []*internal.APIKey {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	keys := make([]*internal.APIKey, 0, len(s.apiKeys))
+	for _, k := range s.apiKeys {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// --- APIKeyRepository ---
+func (s *MemoryStorage) CreateAPIKey(ctx context.Context, key *internal.APIKey) error {
+	stored := *key
+	stored.Scopes = append([]string(nil), key.Scopes...)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.apiKeys[stored.ID] = &stored
+	s.apiKeyHashes[stored.Hash] = stored.ID
+	return nil
+}
+
+func (s *MemoryStorage) GetAPIKeyByHash(ctx context.Context, hash string) (*internal.APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	k, ok := s.apiKeys[s.apiKeyHashes[hash]]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	key := *k
+	return &key, nil
+}
+
+func (s *MemoryStorage) ListAPIKeys(ctx context.Context, userID string) ([]internal.APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	keys := []internal.APIKey{}
+	for _, k := range s.apiKeys {
+		if k.UserID == userID {
+			keys = append(keys, *k)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].CreatedAt.After(keys[j].CreatedAt) })
+	return keys, nil
+}
+
+func (s *MemoryStorage) TouchAPIKey(ctx context.Context, id string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	k, ok := s.apiKeys[id]
+	if !ok {
+		return ErrNotFound
+	}
+	updated := *k
+	updated.LastUsedAt = &at
+	s.apiKeys[id] = &updated
+	return nil
+}
+
+func (s *MemoryStorage) DeleteAPIKey(ctx context.Context, userID, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	k, ok := s.apiKeys[id]
+	if !ok || k.UserID != userID {
+		return ErrNotFound
+	}
+	delete(s.apiKeys, id)
+	delete(s.apiKeyHashes, k.Hash)
+	return nil
+}
+
+func (s *MemoryStorage) loadGrants(grants []*internal.Grant, accesses []*internal.GrantAccess) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, g := range grants {
+		s.grants[g.ID] = g
+	}
+	sort.SliceStable(accesses, func(i, j int) bool { return accesses[i].At.Before(accesses[j].At) })
+	s.grantAccesses = accesses
+}
+
+func (s *MemoryStorage) allGrants() []*internal.Grant {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	grants := make([]*internal.Grant, 0, len(s.grants))
+	for _, g := range s.grants {
+		grants = append(grants, g)
+	}
+	return grants
+}
+
+func (s *MemoryStorage) allGrantAccesses() []*internal.GrantAccess {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]*internal.GrantAccess{}, s.grantAccesses...)
+}
+
+// --- SharingRepository ---
+func (s *MemoryStorage) CreateGrant(ctx context.Context, grant *internal.Grant) error {
+	stored := *grant
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.grants[stored.ID] = &stored
+	return nil
+}
+
+func (s *MemoryStorage) GetGrant(ctx context.Context, id string) (*internal.Grant, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	g, ok := s.grants[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	grant := *g
+	return &grant, nil
+}
+
+func (s *MemoryStorage) ListGrantsByOwner(ctx context.Context, ownerID string) ([]internal.Grant, error) {
+	return s.listGrants(func(g *internal.Grant) bool { return g.OwnerID == ownerID }), nil
+}
+
+func (s *MemoryStorage) ListGrantsByGrantee(ctx context.Context, granteeID string) ([]internal.Grant, error) {
+	return s.listGrants(func(g *internal.Grant) bool { return g.GranteeID == granteeID }), nil
+}
+
+func (s *MemoryStorage) ListGrantsByEmail(ctx context.Context, email string) ([]internal.Grant, error) {
+	return s.listGrants(func(g *internal.Grant) bool { return g.GranteeEmail == email }), nil
+}
+
+func (s *MemoryStorage) listGrants(match func(*internal.Grant) bool) []internal.Grant {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	grants := []internal.Grant{}
+	for _, g := range s.grants {
+		if match(g) {
+			grants = append(grants, *g)
+		}
+	}
+	sort.Slice(grants, func(i, j int) bool { return grants[i].CreatedAt.After(grants[j].CreatedAt) })
+	return grants
+}
+
+func (s *MemoryStorage) UpdateGrant(ctx context.Context, grant *internal.Grant) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	g, ok := s.grants[grant.ID]
+	if !ok {
+		return ErrNotFound
+	}
+	updated := *g
+	updated.GranteeID, updated.AcceptedAt, updated.RevokedAt = grant.GranteeID, grant.AcceptedAt, grant.RevokedAt
+	s.grants[grant.ID] = &updated
+	return nil
+}
+
+func (s *MemoryStorage) RecordGrantAccess(ctx context.Context, access *internal.GrantAccess) error {
+	stored := *access
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.grantAccesses = append(s.grantAccesses, &stored)
+	return nil
+}
+
+func (s *MemoryStorage) ListGrantAccesses(ctx context.Context, ownerID string) ([]internal.GrantAccess, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	accesses := []internal.GrantAccess{}
+	for i := len(s.grantAccesses) - 1; i >= 0; i-- {
+		if a := s.grantAccesses[i]; a.OwnerID == ownerID {
+			accesses = append(accesses, *a)
+		}
+	}
+	return accesses, nil
+}
+
+func (s *MemoryStorage) loadAuditEvents(events []*internal.AuditEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sort.SliceStable(events, func(i, j int) bool { return events[i].At.Before(events[j].At) })
+	s.auditEvents = events
+}
+
+// --- AuditRepository ---
+func (s *MemoryStorage) AppendAuditEvent(ctx context.Context, event *internal.AuditEvent) error {
+	stored := *event
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.auditEvents = append(s.auditEvents, &stored)
+	return nil
+}
+
+func (s *MemoryStorage) ListAuditEvents(ctx context.Context, filter AuditFilter) ([]internal.AuditEvent, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	events := []internal.AuditEvent{}
+	for i := len(s.auditEvents) - 1; i >= 0 && len(events) < filter.Limit; i-- {
+		if e := s.auditEvents[i]; filter.match(e) {
+			events = append(events, *e)
+		}
+	}
+	return events, nil
+}
+
+// --- HealthChecker ---
+func (s *MemoryStorage) Health(ctx context.Context) Health {
+	return Health{Backend: "memory", OK: true, Details: s.counts()}
+}
+
+func (s *MemoryStorage) counts() map[string]any {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return map[string]any{
+		"sleep_logs": len(s.sleepLogs),
+		"users":      len(s.users),
+		"sessions":   len(s.sessions),
+		"api_keys":   len(s.apiKeys),
+		"grants":     len(s.grants),
+		"audit":      len(s.auditEvents),
+	}
+}
+
+// --- Compile-time assertions ---
+var _ SleepLogRepository = (*MemoryStorage)(nil)
+var _ GoalRepository = (*MemoryStorage)(nil)
+var _ AggregateRepository = (*MemoryStorage)(nil)
+var _ UserRepository = (*MemoryStorage)(nil)
+var _ SessionRepository = (*MemoryStorage)(nil)
+var _ APIKeyRepository = (*MemoryStorage)(nil)
+var _ SharingRepository = (*MemoryStorage)(nil)
+var _ AuditRepository = (*MemoryStorage)(nil)
+var _ HealthChecker = (*MemoryStorage)(nil)
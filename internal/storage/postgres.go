@@ -2,7 +2,13 @@ package storage
 
 import (
 	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"time"
 
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/yourname/sleeptracker/internal"
 )
@@ -22,36 +28,194 @@ func NewPostgresStorage(dsn string, logger internal.Logger) (*PostgresStorage, e
 	return &PostgresStorage{pool: pool, logger: logger}, nil
 }
 
+// uniqueViolation is the SQLSTATE Postgres reports for a duplicate key.
+const uniqueViolation = "23505"
+
+// sourceTaken maps a duplicate of sleep_logs_source_idx to ErrSourceTaken,
+// so that the SQL error is not passed on.
+func sourceTaken(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation && pgErr.ConstraintName == "sleep_logs_source_idx" {
+		return ErrSourceTaken
+	}
+	return err
+}
+
+func (p *PostgresStorage) Close() error {
+	p.pool.Close()
+	return nil
+}
+
 // --- SleepLogRepository ---
 func (p *PostgresStorage) SaveSleepLog(ctx context.Context, log *internal.SleepLog) error {
-	_, err := p.pool.Exec(ctx, `INSERT INTO sleep_logs (id, user_id, start_time, end_time, quality, reason, interruptions, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
-		log.ID, log.UserID, log.StartTime, log.EndTime, log.Quality, log.Reason, log.Interruptions, log.CreatedAt)
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		p.logger.Errorf("failed to begin transaction: %v", err)
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `INSERT INTO sleep_logs (id, user_id, start_time, start_offset, end_time, quality, reason, interruptions, source, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
+		log.ID, log.UserID, log.StartTime, startOffset(log), log.EndTime, log.Quality, log.Reason, log.Interruptions, log.Source, log.CreatedAt)
+	if err := sourceTaken(err); errors.Is(err, ErrSourceTaken) {
+		return err
+	}
 	if err != nil {
 		p.logger.Errorf("failed to insert sleep log: %v", err)
 		return err
 	}
-	return nil
+	if err := refreshDailyAggregate(ctx, tx, log.UserID, log.StartTime); err != nil {
+		p.logger.Errorf("failed to update daily aggregate: %v", err)
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// SaveSleepLogs upserts the logs in one transaction, then refreshes the
+// aggregate of each day they touch once, including the days replaced logs
+// were on.
+func (p *PostgresStorage) SaveSleepLogs(ctx context.Context, logs []internal.SleepLog) error {
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		p.logger.Errorf("failed to begin transaction: %v", err)
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	type userDay struct {
+		userID string
+		day    string
+	}
+	days := make(map[userDay]time.Time)
+	ids := make([]string, len(logs))
+	for i, log := range logs {
+		ids[i] = log.ID
+	}
+	rows, err := tx.Query(ctx, `SELECT user_id, start_time FROM sleep_logs WHERE id = ANY($1) FOR UPDATE`, ids)
+	if err != nil {
+		p.logger.Errorf("failed to query replaced sleep logs: %v", err)
+		return err
+	}
+	for rows.Next() {
+		var userID string
+		var start time.Time
+		if err := rows.Scan(&userID, &start); err != nil {
+			rows.Close()
+			p.logger.Errorf("failed to scan replaced sleep log: %v", err)
+			return err
+		}
+		days[userDay{userID, DayOf(start)}] = start
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		p.logger.Errorf("failed to iterate replaced sleep logs: %v", err)
+		return err
+	}
+
+	batch := &pgx.Batch{}
+	for _, log := range logs {
+		batch.Queue(`INSERT INTO sleep_logs (id, user_id, start_time, start_offset, end_time, quality, reason, interruptions, source, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+			ON CONFLICT (id) DO UPDATE SET start_time = EXCLUDED.start_time, start_offset = EXCLUDED.start_offset, end_time = EXCLUDED.end_time,
+				quality = EXCLUDED.quality, reason = EXCLUDED.reason, interruptions = EXCLUDED.interruptions, source = EXCLUDED.source`,
+			log.ID, log.UserID, log.StartTime, startOffset(&log), log.EndTime, log.Quality, log.Reason, log.Interruptions, log.Source, log.CreatedAt)
+		days[userDay{log.UserID, DayOf(log.StartTime)}] = log.StartTime
+	}
+	if err := sourceTaken(tx.SendBatch(ctx, batch).Close()); errors.Is(err, ErrSourceTaken) {
+		return err
+	} else if err != nil {
+		p.logger.Errorf("failed to insert sleep logs: %v", err)
+		return err
+	}
+	// Refresh in a fixed order so that two batches touching the same days
+	// take their aggregate locks in the same order and cannot deadlock.
+	keys := make([]userDay, 0, len(days))
+	for key := range days {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].userID != keys[j].userID {
+			return keys[i].userID < keys[j].userID
+		}
+		return keys[i].day < keys[j].day
+	})
+	for _, key := range keys {
+		if err := refreshDailyAggregate(ctx, tx, key.userID, days[key]); err != nil {
+			p.logger.Errorf("failed to update daily aggregate: %v", err)
+			return err
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+// startOffset is the UTC offset, in seconds, the log's start time was given in.
+func startOffset(log *internal.SleepLog) int {
+	_, offset := log.StartTime.Zone()
+	return offset
+}
+
+const sleepLogColumns = `id, user_id, start_time, start_offset, end_time, quality, reason, interruptions, source, created_at`
+
+// scanSleepLog reads a row of sleepLogColumns, putting the start time back
+// in the offset it was saved with.
+func scanSleepLog(row pgx.Row) (internal.SleepLog, error) {
+	var l internal.SleepLog
+	var offset int
+	err := row.Scan(&l.ID, &l.UserID, &l.StartTime, &offset, &l.EndTime, &l.Quality, &l.Reason, &l.Interruptions, &l.Source, &l.CreatedAt)
+	if err == nil {
+		l.StartTime = l.StartTime.In(time.FixedZone("", offset))
+	}
+	return l, err
 }
 
 func (p *PostgresStorage) ListSleepLogs(ctx context.Context, userID string) ([]internal.SleepLog, error) {
-	rows, err := p.pool.Query(ctx, `SELECT id, user_id, start_time, end_time, quality, reason, interruptions, created_at FROM sleep_logs WHERE user_id = $1 ORDER BY start_time DESC`, userID)
+	logs := []internal.SleepLog{}
+	err := p.IterateSleepLogs(ctx, userID, func(l internal.SleepLog) error {
+		logs = append(logs, l)
+		return nil
+	})
 	if err != nil {
-		p.logger.Errorf("failed to query sleep logs: %v", err)
 		return nil, err
 	}
+	return logs, nil
+}
+
+// IterateSleepLogs scans the rows as they arrive. IDs are compared
+// byte-wise, as Go compares strings, whatever the database's collation.
+func (p *PostgresStorage) IterateSleepLogs(ctx context.Context, userID string, fn func(internal.SleepLog) error) error {
+	rows, err := p.pool.Query(ctx, `SELECT `+sleepLogColumns+` FROM sleep_logs WHERE user_id = $1 ORDER BY start_time DESC, id COLLATE "C"`, userID)
+	if err != nil {
+		p.logger.Errorf("failed to query sleep logs: %v", err)
+		return err
+	}
 	defer rows.Close()
 
-	var logs []internal.SleepLog
 	for rows.Next() {
-		var l internal.SleepLog
-		err := rows.Scan(&l.ID, &l.UserID, &l.StartTime, &l.EndTime, &l.Quality, &l.Reason, &l.Interruptions, &l.CreatedAt)
+		l, err := scanSleepLog(rows)
 		if err != nil {
 			p.logger.Errorf("failed to scan sleep log: %v", err)
-			return nil, err
+			return err
+		}
+		if err := fn(l); err != nil {
+			return err
 		}
-		logs = append(logs, l)
 	}
-	return logs, nil
+	if err := rows.Err(); err != nil {
+		p.logger.Errorf("failed to iterate sleep logs: %v", err)
+		return err
+	}
+	return nil
+}
+
+func (p *PostgresStorage) GetSleepLog(ctx context.Context, id string) (*internal.SleepLog, error) {
+	l, err := scanSleepLog(p.pool.QueryRow(ctx, `SELECT `+sleepLogColumns+` FROM sleep_logs WHERE id = $1`, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		p.logger.Errorf("failed to fetch sleep log: %v", err)
+		return nil, err
+	}
+	return &l, nil
 }
 
 // --- GoalRepository ---
@@ -68,24 +232,588 @@ func (p *PostgresStorage) SetGoal(ctx context.Context, goal *internal.Goal) erro
 func (p *PostgresStorage) GetGoal(ctx context.Context, userID string) (*internal.Goal, error) {
 	row := p.pool.QueryRow(ctx, `SELECT id, user_id, type, value, created_at FROM goals WHERE user_id = $1 ORDER BY created_at DESC LIMIT 1`, userID)
 	var g internal.Goal
-	if err := row.Scan(&g.ID, &g.UserID, &g.Type, &g.Value, &g.CreatedAt); err != nil {
-		p.logger.Errorf("goal not found: %v", err)
+	err := row.Scan(&g.ID, &g.UserID, &g.Type, &g.Value, &g.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		p.logger.Errorf("failed to fetch goal: %v", err)
 		return nil, err
 	}
 	return &g, nil
 }
 
+// --- AggregateRepository ---
+const aggregateColumns = `user_id, day, log_count, total_sleep_seconds, quality_sum, interruption_count, bedtime, bedtime_offset, wake_time`
+
+// aggregateSelect computes aggregate rows from sleep_logs; callers append
+// their own WHERE clause before the GROUP BY. The bedtime offset is the
+// start offset of the day's earliest log.
+const aggregateSelect = `SELECT user_id, (start_time AT TIME ZONE 'UTC')::date, COUNT(*),
+	COALESCE(SUM(FLOOR(EXTRACT(EPOCH FROM (end_time - start_time)))), 0)::bigint,
+	SUM(quality), COALESCE(SUM(cardinality(interruptions)), 0), MIN(start_time),
+	(array_agg(start_offset ORDER BY start_time))[1], MAX(end_time)
+	FROM sleep_logs`
+
+// refreshDailyAggregate recomputes the aggregate row for the UTC day of t.
+// It holds an advisory lock on the user and day until the transaction ends:
+// otherwise two writers of the same day under READ COMMITTED could both
+// delete and insert the row, failing on its key or missing each other's
+// logs. Once the lock is held, the recompute sees every committed log.
+func refreshDailyAggregate(ctx context.Context, tx pgx.Tx, userID string, t time.Time) error {
+	dayStart := t.UTC().Truncate(24 * time.Hour)
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtext($1 || '/' || $2))`, userID, DayOf(t)); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM daily_sleep_aggregates WHERE user_id = $1 AND day = $2::date`, userID, DayOf(t)); err != nil {
+		return err
+	}
+	_, err := tx.Exec(ctx, `INSERT INTO daily_sleep_aggregates (`+aggregateColumns+`) `+aggregateSelect+`
+		WHERE user_id = $1 AND start_time >= $2 AND start_time < $3 GROUP BY 1, 2`,
+		userID, dayStart, dayStart.Add(24*time.Hour))
+	return err
+}
+
+func (p *PostgresStorage) ListDailyAggregates(ctx context.Context, userID string, from, to time.Time) ([]internal.DailyAggregate, error) {
+	rows, err := p.pool.Query(ctx, `SELECT `+aggregateColumns+` FROM daily_sleep_aggregates WHERE user_id = $1 AND day BETWEEN $2::date AND $3::date ORDER BY day DESC`,
+		userID, DayOf(from), DayOf(to))
+	if err != nil {
+		p.logger.Errorf("failed to query daily aggregates: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	aggs := []internal.DailyAggregate{}
+	for rows.Next() {
+		var a internal.DailyAggregate
+		var day time.Time
+		err := rows.Scan(&a.UserID, &day, &a.LogCount, &a.TotalSleepSeconds, &a.QualitySum, &a.InterruptionCount, &a.Bedtime, &a.BedtimeOffset, &a.WakeTime)
+		if err != nil {
+			p.logger.Errorf("failed to scan daily aggregate: %v", err)
+			return nil, err
+		}
+		a.Date = day.Format(dayLayout)
+		a.Bedtime, a.WakeTime = a.Bedtime.UTC(), a.WakeTime.UTC()
+		aggs = append(aggs, a)
+	}
+	if err := rows.Err(); err != nil {
+		p.logger.Errorf("failed to iterate daily aggregates: %v", err)
+		return nil, err
+	}
+	return aggs, nil
+}
+
+func (p *PostgresStorage) RebuildDailyAggregates(ctx context.Context) error {
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		p.logger.Errorf("failed to begin transaction: %v", err)
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `DELETE FROM daily_sleep_aggregates`); err != nil {
+		p.logger.Errorf("failed to clear daily aggregates: %v", err)
+		return err
+	}
+	if _, err := tx.Exec(ctx, `INSERT INTO daily_sleep_aggregates (`+aggregateColumns+`) `+aggregateSelect+` GROUP BY 1, 2`); err != nil {
+		p.logger.Errorf("failed to rebuild daily aggregates: %v", err)
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
 // --- UserRepository ---
-func (p *PostgresStorage) GetUserByToken(ctx context.Context, token string) (*internal.User, error) {
-	row := p.pool.QueryRow(ctx, `SELECT id, token, name FROM users WHERE token = $1`, token)
+func (p *PostgresStorage) CreateUser(ctx context.Context, user *internal.User) error {
+	_, err := p.pool.Exec(ctx, `INSERT INTO users (id, name, email, password_hash, created_at, role, disabled_at) VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		user.ID, user.Name, user.Email, user.PasswordHash, user.CreatedAt, user.EffectiveRole(), user.DisabledAt)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+		return ErrEmailTaken
+	}
+	if err != nil {
+		p.logger.Errorf("failed to create user: %v", err)
+		return err
+	}
+	return nil
+}
+
+const userColumns = `id, name, COALESCE(email, ''), password_hash, created_at, role, disabled_at`
+
+func scanUser(row pgx.Row) (*internal.User, error) {
 	var u internal.User
-	if err := row.Scan(&u.ID, &u.Token, &u.Name); err != nil {
-		p.logger.Errorf("user not found: %v", err)
+	err := row.Scan(&u.ID, &u.Name, &u.Email, &u.PasswordHash, &u.CreatedAt, &u.Role, &u.DisabledAt)
+	return &u, err
+}
+
+func (p *PostgresStorage) GetUserByID(ctx context.Context, id string) (*internal.User, error) {
+	return p.getUser(ctx, `SELECT `+userColumns+` FROM users WHERE id = $1`, id)
+}
+
+func (p *PostgresStorage) GetUserByEmail(ctx context.Context, email string) (*internal.User, error) {
+	return p.getUser(ctx, `SELECT `+userColumns+` FROM users WHERE email = $1`, email)
+}
+
+func (p *PostgresStorage) getUser(ctx context.Context, query string, arg string) (*internal.User, error) {
+	u, err := scanUser(p.pool.QueryRow(ctx, query, arg))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		p.logger.Errorf("failed to fetch user: %v", err)
+		return nil, err
+	}
+	return u, nil
+}
+
+func (p *PostgresStorage) ListUsers(ctx context.Context) ([]internal.User, error) {
+	rows, err := p.pool.Query(ctx, `SELECT `+userColumns+` FROM users ORDER BY created_at`)
+	if err != nil {
+		p.logger.Errorf("failed to list users: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+	users := []internal.User{}
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			p.logger.Errorf("failed to scan user: %v", err)
+			return nil, err
+		}
+		users = append(users, *u)
+	}
+	return users, rows.Err()
+}
+
+func (p *PostgresStorage) UpdateUser(ctx context.Context, user *internal.User) error {
+	tag, err := p.pool.Exec(ctx, `UPDATE users SET name = $2, role = $3, disabled_at = $4 WHERE id = $1`,
+		user.ID, user.Name, user.EffectiveRole(), user.DisabledAt)
+	if err != nil {
+		p.logger.Errorf("failed to update user: %v", err)
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (p *PostgresStorage) SaveAccessToken(ctx context.Context, token *internal.AccessToken) error {
+	_, err := p.pool.Exec(ctx, `INSERT INTO access_tokens (token_hash, user_id, session_id, created_at, expires_at) VALUES ($1, $2, NULLIF($3, ''), $4, $5)`,
+		token.Hash, token.UserID, token.SessionID, token.CreatedAt, token.ExpiresAt)
+	if err != nil {
+		p.logger.Errorf("failed to save access token: %v", err)
+		return err
+	}
+	// Opportunistically purge expired tokens.
+	if _, err := p.pool.Exec(ctx, `DELETE FROM access_tokens WHERE expires_at < now()`); err != nil {
+		p.logger.Warnf("failed to purge expired access tokens: %v", err)
+	}
+	return nil
+}
+
+func (p *PostgresStorage) GetAccessToken(ctx context.Context, hash string) (*internal.AccessToken, error) {
+	var t internal.AccessToken
+	err := p.pool.QueryRow(ctx, `SELECT token_hash, user_id, COALESCE(session_id, ''), created_at, expires_at FROM access_tokens WHERE token_hash = $1`, hash).
+		Scan(&t.Hash, &t.UserID, &t.SessionID, &t.CreatedAt, &t.ExpiresAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		p.logger.Errorf("failed to fetch access token: %v", err)
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (p *PostgresStorage) DeleteAccessToken(ctx context.Context, hash string) error {
+	if _, err := p.pool.Exec(ctx, `DELETE FROM access_tokens WHERE token_hash = $1`, hash); err != nil {
+		p.logger.Errorf("failed to delete access token: %v", err)
+		return err
+	}
+	return nil
+}
+
+// --- SessionRepository ---
+func (p *PostgresStorage) CreateSession(ctx context.Context, session *internal.Session) error {
+	_, err := p.pool.Exec(ctx, `INSERT INTO sessions (id, user_id, user_agent, ip, created_at, last_used_at, expires_at, revoked_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		session.ID, session.UserID, session.UserAgent, session.IP, session.CreatedAt, session.LastUsedAt, session.ExpiresAt, session.RevokedAt)
+	if err != nil {
+		p.logger.Errorf("failed to create session: %v", err)
+		return err
+	}
+	if _, err := p.pool.Exec(ctx, `DELETE FROM sessions WHERE expires_at < now()`); err != nil {
+		p.logger.Warnf("failed to purge expired sessions: %v", err)
+	}
+	return nil
+}
+
+const sessionColumns = `id, user_id, user_agent, ip, created_at, last_used_at, expires_at, revoked_at`
+
+func scanSession(row pgx.Row) (*internal.Session, error) {
+	var s internal.Session
+	err := row.Scan(&s.ID, &s.UserID, &s.UserAgent, &s.IP, &s.CreatedAt, &s.LastUsedAt, &s.ExpiresAt, &s.RevokedAt)
+	return &s, err
+}
+
+func (p *PostgresStorage) GetSession(ctx context.Context, id string) (*internal.Session, error) {
+	s, err := scanSession(p.pool.QueryRow(ctx, `SELECT `+sessionColumns+` FROM sessions WHERE id = $1`, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		p.logger.Errorf("failed to fetch session: %v", err)
+		return nil, err
+	}
+	return s, nil
+}
+
+func (p *PostgresStorage) ListSessions(ctx context.Context, userID string) ([]internal.Session, error) {
+	rows, err := p.pool.Query(ctx, `SELECT `+sessionColumns+` FROM sessions WHERE user_id = $1 ORDER BY last_used_at DESC`, userID)
+	if err != nil {
+		p.logger.Errorf("failed to list sessions: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+	sessions := []internal.Session{}
+	for rows.Next() {
+		s, err := scanSession(rows)
+		if err != nil {
+			p.logger.Errorf("failed to scan session: %v", err)
+			return nil, err
+		}
+		sessions = append(sessions, *s)
+	}
+	return sessions, rows.Err()
+}
+
+func (p *PostgresStorage) TouchSession(ctx context.Context, id string, at, expiresAt time.Time) error {
+	tag, err := p.pool.Exec(ctx, `UPDATE sessions SET last_used_at = $2, expires_at = $3 WHERE id = $1`, id, at, expiresAt)
+	if err != nil {
+		p.logger.Errorf("failed to update session: %v", err)
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (p *PostgresStorage) RevokeSession(ctx context.Context, id string, at time.Time) error {
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	tag, err := tx.Exec(ctx, `UPDATE sessions SET revoked_at = COALESCE(revoked_at, $2) WHERE id = $1`, id, at)
+	if err != nil {
+		p.logger.Errorf("failed to revoke session: %v", err)
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM access_tokens WHERE session_id = $1`, id); err != nil {
+		p.logger.Errorf("failed to delete session access tokens: %v", err)
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func (p *PostgresStorage) SaveRefreshToken(ctx context.Context, token *internal.RefreshToken) error {
+	_, err := p.pool.Exec(ctx, `INSERT INTO refresh_tokens (token_hash, session_id, user_id, created_at, expires_at, used_at) VALUES ($1, $2, $3, $4, $5, $6)`,
+		token.Hash, token.SessionID, token.UserID, token.CreatedAt, token.ExpiresAt, token.UsedAt)
+	if err != nil {
+		p.logger.Errorf("failed to save refresh token: %v", err)
+		return err
+	}
+	return nil
+}
+
+func (p *PostgresStorage) ConsumeRefreshToken(ctx context.Context, hash string, at time.Time) (*internal.RefreshToken, error) {
+	// The UPDATE only matches an unused token, so of two concurrent
+	// refreshes with the same token exactly one succeeds.
+	var t internal.RefreshToken
+	err := p.pool.QueryRow(ctx, `UPDATE refresh_tokens SET used_at = $2 WHERE token_hash = $1 AND used_at IS NULL
+		RETURNING token_hash, session_id, user_id, created_at, expires_at`, hash, at).
+		Scan(&t.Hash, &t.SessionID, &t.UserID, &t.CreatedAt, &t.ExpiresAt)
+	if err == nil {
+		return &t, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		p.logger.Errorf("failed to consume refresh token: %v", err)
+		return nil, err
+	}
+	err = p.pool.QueryRow(ctx, `SELECT token_hash, session_id, user_id, created_at, expires_at, used_at FROM refresh_tokens WHERE token_hash = $1`, hash).
+		Scan(&t.Hash, &t.SessionID, &t.UserID, &t.CreatedAt, &t.ExpiresAt, &t.UsedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		p.logger.Errorf("failed to fetch refresh token: %v", err)
+		return nil, err
+	}
+	return &t, ErrTokenReused
+}
+
+// --- APIKeyRepository ---
+func (p *PostgresStorage) CreateAPIKey(ctx context.Context, key *internal.APIKey) error {
+	_, err := p.pool.Exec(ctx, `INSERT INTO api_keys (id, user_id, name, prefix, key_hash, scopes, created_at, last_used_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		key.ID, key.UserID, key.Name, key.Prefix, key.Hash, key.Scopes, key.CreatedAt, key.LastUsedAt)
+	if err != nil {
+		p.logger.Errorf("failed to create api key: %v", err)
+		return err
+	}
+	return nil
+}
+
+const apiKeyColumns = `id, user_id, name, prefix, key_hash, scopes, created_at, last_used_at`
+
+func scanAPIKey(row pgx.Row) (*internal.APIKey, error) {
+	var k internal.APIKey
+	err := row.Scan(&k.ID, &k.UserID, &k.Name, &k.Prefix, &k.Hash, &k.Scopes, &k.CreatedAt, &k.LastUsedAt)
+	return &k, err
+}
+
+func (p *PostgresStorage) GetAPIKeyByHash(ctx context.Context, hash string) (*internal.APIKey, error) {
+	k, err := scanAPIKey(p.pool.QueryRow(ctx, `SELECT `+apiKeyColumns+` FROM api_keys WHERE key_hash = $1`, hash))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		p.logger.Errorf("failed to fetch api key: %v", err)
+		return nil, err
+	}
+	return k, nil
+}
+
+func (p *PostgresStorage) ListAPIKeys(ctx context.Context, userID string) ([]internal.APIKey, error) {
+	rows, err := p.pool.Query(ctx, `SELECT `+apiKeyColumns+` FROM api_keys WHERE user_id = $1 ORDER BY created_at DESC`, userID)
+	if err != nil {
+		p.logger.Errorf("failed to list api keys: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+	keys := []internal.APIKey{}
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			p.logger.Errorf("failed to scan api key: %v", err)
+			return nil, err
+		}
+		keys = append(keys, *k)
+	}
+	return keys, rows.Err()
+}
+
+func (p *PostgresStorage) TouchAPIKey(ctx context.Context, id string, at time.Time) error {
+	tag, err := p.pool.Exec(ctx, `UPDATE api_keys SET last_used_at = $2 WHERE id = $1`, id, at)
+	if err != nil {
+		p.logger.Errorf("failed to update api key: %v", err)
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (p *PostgresStorage) DeleteAPIKey(ctx context.Context, userID, id string) error {
+	tag, err := p.pool.Exec(ctx, `DELETE FROM api_keys WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		p.logger.Errorf("failed to delete api key: %v", err)
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// --- SharingRepository ---
+func (p *PostgresStorage) CreateGrant(ctx context.Context, grant *internal.Grant) error {
+	_, err := p.pool.Exec(ctx, `INSERT INTO grants (id, owner_id, grantee_id, grantee_email, created_at, expires_at, accepted_at, revoked_at) VALUES ($1, $2, NULLIF($3, ''), $4, $5, $6, $7, $8)`,
+		grant.ID, grant.OwnerID, grant.GranteeID, grant.GranteeEmail, grant.CreatedAt, grant.ExpiresAt, grant.AcceptedAt, grant.RevokedAt)
+	if err != nil {
+		p.logger.Errorf("failed to create grant: %v", err)
+		return err
+	}
+	return nil
+}
+
+// grantColumns reads grantee_id, which is NULL until the grant is accepted,
+// as "".
+const grantColumns = `id, owner_id, COALESCE(grantee_id, ''), grantee_email, created_at, expires_at, accepted_at, revoked_at`
+
+func scanGrant(row pgx.Row) (*internal.Grant, error) {
+	var g internal.Grant
+	err := row.Scan(&g.ID, &g.OwnerID, &g.GranteeID, &g.GranteeEmail, &g.CreatedAt, &g.ExpiresAt, &g.AcceptedAt, &g.RevokedAt)
+	return &g, err
+}
+
+func (p *PostgresStorage) GetGrant(ctx context.Context, id string) (*internal.Grant, error) {
+	g, err := scanGrant(p.pool.QueryRow(ctx, `SELECT `+grantColumns+` FROM grants WHERE id = $1`, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		p.logger.Errorf("failed to fetch grant: %v", err)
+		return nil, err
+	}
+	return g, nil
+}
+
+func (p *PostgresStorage) ListGrantsByOwner(ctx context.Context, ownerID string) ([]internal.Grant, error) {
+	return p.listGrants(ctx, `SELECT `+grantColumns+` FROM grants WHERE owner_id = $1 ORDER BY created_at DESC`, ownerID)
+}
+
+func (p *PostgresStorage) ListGrantsByGrantee(ctx context.Context, granteeID string) ([]internal.Grant, error) {
+	return p.listGrants(ctx, `SELECT `+grantColumns+` FROM grants WHERE grantee_id = $1 ORDER BY created_at DESC`, granteeID)
+}
+
+func (p *PostgresStorage) ListGrantsByEmail(ctx context.Context, email string) ([]internal.Grant, error) {
+	return p.listGrants(ctx, `SELECT `+grantColumns+` FROM grants WHERE grantee_email = $1 ORDER BY created_at DESC`, email)
+}
+
+func (p *PostgresStorage) listGrants(ctx context.Context, query, arg string) ([]internal.Grant, error) {
+	rows, err := p.pool.Query(ctx, query, arg)
+	if err != nil {
+		p.logger.Errorf("failed to list grants: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+	grants := []internal.Grant{}
+	for rows.Next() {
+		g, err := scanGrant(rows)
+		if err != nil {
+			p.logger.Errorf("failed to scan grant: %v", err)
+			return nil, err
+		}
+		grants = append(grants, *g)
+	}
+	return grants, rows.Err()
+}
+
+func (p *PostgresStorage) UpdateGrant(ctx context.Context, grant *internal.Grant) error {
+	tag, err := p.pool.Exec(ctx, `UPDATE grants SET grantee_id = NULLIF($2, ''), accepted_at = $3, revoked_at = $4 WHERE id = $1`,
+		grant.ID, grant.GranteeID, grant.AcceptedAt, grant.RevokedAt)
+	if err != nil {
+		p.logger.Errorf("failed to update grant: %v", err)
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (p *PostgresStorage) RecordGrantAccess(ctx context.Context, access *internal.GrantAccess) error {
+	_, err := p.pool.Exec(ctx, `INSERT INTO grant_accesses (id, grant_id, owner_id, grantee_id, method, path, at) VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		access.ID, access.GrantID, access.OwnerID, access.GranteeID, access.Method, access.Path, access.At)
+	if err != nil {
+		p.logger.Errorf("failed to record grant access: %v", err)
+		return err
+	}
+	return nil
+}
+
+func (p *PostgresStorage) ListGrantAccesses(ctx context.Context, ownerID string) ([]internal.GrantAccess, error) {
+	rows, err := p.pool.Query(ctx, `SELECT id, grant_id, owner_id, grantee_id, method, path, at FROM grant_accesses WHERE owner_id = $1 ORDER BY at DESC`, ownerID)
+	if err != nil {
+		p.logger.Errorf("failed to list grant accesses: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+	accesses := []internal.GrantAccess{}
+	for rows.Next() {
+		var a internal.GrantAccess
+		if err := rows.Scan(&a.ID, &a.GrantID, &a.OwnerID, &a.GranteeID, &a.Method, &a.Path, &a.At); err != nil {
+			p.logger.Errorf("failed to scan grant access: %v", err)
+			return nil, err
+		}
+		accesses = append(accesses, a)
+	}
+	return accesses, rows.Err()
+}
+
+// --- AuditRepository ---
+func (p *PostgresStorage) AppendAuditEvent(ctx context.Context, event *internal.AuditEvent) error {
+	_, err := p.pool.Exec(ctx, `INSERT INTO audit_events (id, at, action, actor_id, target_user_id, resource_id, request_id, ip, details) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+		event.ID, event.At, event.Action, event.ActorID, event.TargetUserID, event.ResourceID, event.RequestID, event.IP, event.Details)
+	if err != nil {
+		p.logger.Errorf("failed to append audit event: %v", err)
+		return err
+	}
+	return nil
+}
+
+func (p *PostgresStorage) ListAuditEvents(ctx context.Context, filter AuditFilter) ([]internal.AuditEvent, error) {
+	query := `SELECT id, at, action, actor_id, target_user_id, resource_id, request_id, ip, details FROM audit_events WHERE true`
+	var args []any
+	arg := func(v any) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+	if filter.UserID != "" {
+		n := arg(filter.UserID)
+		query += ` AND (actor_id = ` + n + ` OR target_user_id = ` + n + `)`
+	}
+	if filter.Action != "" {
+		query += ` AND action = ` + arg(filter.Action)
+	}
+	if !filter.Since.IsZero() {
+		query += ` AND at >= ` + arg(filter.Since)
+	}
+	if !filter.Until.IsZero() {
+		query += ` AND at < ` + arg(filter.Until)
+	}
+	query += ` ORDER BY at DESC LIMIT ` + arg(filter.Limit)
+
+	rows, err := p.pool.Query(ctx, query, args...)
+	if err != nil {
+		p.logger.Errorf("failed to list audit events: %v", err)
 		return nil, err
 	}
-	return &u, nil
+	defer rows.Close()
+	events := []internal.AuditEvent{}
+	for rows.Next() {
+		var e internal.AuditEvent
+		if err := rows.Scan(&e.ID, &e.At, &e.Action, &e.ActorID, &e.TargetUserID, &e.ResourceID, &e.RequestID, &e.IP, &e.Details); err != nil {
+			p.logger.Errorf("failed to scan audit event: %v", err)
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+// --- HealthChecker ---
+func (p *PostgresStorage) Health(ctx context.Context) Health {
+	h := Health{Backend: "postgres", OK: true}
+	start := time.Now()
+	if err := p.pool.Ping(ctx); err != nil {
+		h.OK, h.Error = false, err.Error()
+	}
+	stat := p.pool.Stat()
+	h.Details = map[string]any{
+		"ping_ms":          time.Since(start).Milliseconds(),
+		"total_conns":      stat.TotalConns(),
+		"idle_conns":       stat.IdleConns(),
+		"acquired_conns":   stat.AcquiredConns(),
+		"max_conns":        stat.MaxConns(),
+		"acquire_count":    stat.AcquireCount(),
+		"empty_acquires":   stat.EmptyAcquireCount(),
+		"canceled_acquire": stat.CanceledAcquireCount(),
+	}
+	return h
 }
 
 // --- Compile-time assertions ---
 var _ SleepLogRepository = (*PostgresStorage)(nil)
 var _ GoalRepository = (*PostgresStorage)(nil)
+var _ AggregateRepository = (*PostgresStorage)(nil)
+var _ UserRepository = (*PostgresStorage)(nil)
+var _ SessionRepository = (*PostgresStorage)(nil)
+var _ APIKeyRepository = (*PostgresStorage)(nil)
+var _ SharingRepository = (*PostgresStorage)(nil)
+var _ AuditRepository = (*PostgresStorage)(nil)
+var _ HealthChecker = (*PostgresStorage)(nil)
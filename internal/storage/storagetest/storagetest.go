@@ -0,0 +1,1084 @@
+// Package storagetest provides a conformance suite for implementations of the
+// storage repository interfaces. Every backend is expected to pass it.
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yourname/sleeptracker/internal"
+	"github.com/yourname/sleeptracker/internal/storage"
+)
+
+// Repositories is one open handle on a backend.
+type Repositories struct {
+	Sleep storage.SleepLogRepository
+	Goals storage.GoalRepository
+	// Aggregates is optional; aggregate tests are skipped when it is nil.
+	Aggregates storage.AggregateRepository
+	// Users is optional; user tests are skipped when it is nil.
+	Users storage.UserRepository
+	// Sessions is optional and needs Users; session tests are skipped when
+	// it is nil.
+	Sessions storage.SessionRepository
+	// APIKeys is optional and needs Users; API key tests are skipped when it
+	// is nil.
+	APIKeys storage.APIKeyRepository
+	// Sharing is optional and needs Users; sharing tests are skipped when it
+	// is nil.
+	Sharing storage.SharingRepository
+	// Audit is optional; audit tests are skipped when it is nil.
+	Audit storage.AuditRepository
+	// Close flushes and releases the handle. It may be nil.
+	Close func() error
+}
+
+// Backend describes a store under test. Open may be called several times;
+// each call after the previous handle was closed must see the same store.
+type Backend struct {
+	Open func(t *testing.T) Repositories
+	// Persistent reports whether data written before Close survives a reopen.
+	Persistent bool
+}
+
+// Run runs the conformance suite. newBackend is called once per subtest and
+// should return an empty store where possible; the suite only relies on user
+// IDs it generates itself, so shared databases work too.
+func Run(t *testing.T, newBackend func(t *testing.T) Backend) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, b Backend)
+	}{
+		{"ListOrderedByStartTimeDesc", testListOrdering},
+		{"ListEmptyForUnknownUser", testListEmpty},
+		{"UserIsolation", testUserIsolation},
+		{"SaveRoundTrip", testSaveRoundTrip},
+		{"SaveBatch", testSaveBatch},
+		{"SaveBatchReplaces", testSaveBatchReplaces},
+		{"SourceUniquePerUser", testSourceUnique},
+		{"IterateInOrder", testIterate},
+		{"GoalNotFound", testGoalNotFound},
+		{"GoalReplacement", testGoalReplacement},
+		{"ConcurrentWriters", testConcurrentWriters},
+		{"PersistenceAcrossReopen", testPersistence},
+		{"AggregatesMaintainedOnWrite", testAggregatesMaintained},
+		{"AggregatesRangeAndIsolation", testAggregatesRange},
+		{"AggregatesRebuild", testAggregatesRebuild},
+		{"AggregatesBedtimeOffset", testAggregatesBedtimeOffset},
+		{"UsersCreateAndLookup", testUsersCreateAndLookup},
+		{"AccessTokens", testAccessTokens},
+		{"UsersPersistAcrossReopen", testUsersPersistence},
+		{"UsersUpdateAndList", testUsersUpdateAndList},
+		{"SessionsLifecycle", testSessionsLifecycle},
+		{"RefreshTokensSingleUse", testRefreshTokensSingleUse},
+		{"APIKeys", testAPIKeys},
+		{"GrantsAndAccessLog", testGrants},
+		{"AuditLog", testAuditLog},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, newBackend(t))
+		})
+	}
+}
+
+func open(t *testing.T, b Backend) Repositories {
+	t.Helper()
+	repos := b.Open(t)
+	if repos.Close != nil {
+		closed := false
+		closeFn := repos.Close
+		repos.Close = func() error {
+			if closed {
+				return nil
+			}
+			closed = true
+			return closeFn()
+		}
+		t.Cleanup(func() { _ = repos.Close() })
+	}
+	return repos
+}
+
+func newUserID() string {
+	return "user-" + uuid.NewString()
+}
+
+func newLog(userID string, start time.Time, quality int) *internal.SleepLog {
+	return &internal.SleepLog{
+		ID:        uuid.NewString(),
+		UserID:    userID,
+		StartTime: start,
+		EndTime:   start.Add(8 * time.Hour),
+		Quality:   quality,
+		CreatedAt: time.Now().UTC().Truncate(time.Second),
+	}
+}
+
+func baseTime() time.Time {
+	return time.Now().UTC().Truncate(time.Second).AddDate(0, 0, -30)
+}
+
+func testListOrdering(t *testing.T, b Backend) {
+	repos := open(t, b)
+	ctx := context.Background()
+	userID := newUserID()
+	base := baseTime()
+
+	// Saved out of order on purpose.
+	for _, offset := range []int{2, 0, 4, 1, 3} {
+		require.NoError(t, repos.Sleep.SaveSleepLog(ctx, newLog(userID, base.AddDate(0, 0, offset), offset+1)))
+	}
+
+	logs, err := repos.Sleep.ListSleepLogs(ctx, userID)
+	require.NoError(t, err)
+	require.Len(t, logs, 5)
+	for i := 1; i < len(logs); i++ {
+		assert.True(t, logs[i-1].StartTime.After(logs[i].StartTime),
+			"logs must be ordered by start_time descending, got %v before %v", logs[i-1].StartTime, logs[i].StartTime)
+	}
+	assert.Equal(t, 5, logs[0].Quality)
+	assert.Equal(t, 1, logs[4].Quality)
+}
+
+func testListEmpty(t *testing.T, b Backend) {
+	repos := open(t, b)
+	logs, err := repos.Sleep.ListSleepLogs(context.Background(), newUserID())
+	require.NoError(t, err)
+	assert.NotNil(t, logs, "an empty result must be an empty slice, not nil")
+	assert.Empty(t, logs)
+}
+
+func testUserIsolation(t *testing.T, b Backend) {
+	repos := open(t, b)
+	ctx := context.Background()
+	alice, bob := newUserID(), newUserID()
+	base := baseTime()
+
+	require.NoError(t, repos.Sleep.SaveSleepLog(ctx, newLog(alice, base, 7)))
+	require.NoError(t, repos.Sleep.SaveSleepLog(ctx, newLog(alice, base.AddDate(0, 0, 1), 8)))
+	require.NoError(t, repos.Sleep.SaveSleepLog(ctx, newLog(bob, base, 3)))
+	require.NoError(t, repos.Goals.SetGoal(ctx, &internal.Goal{
+		ID: uuid.NewString(), UserID: alice, Type: "duration", Value: "8h", CreatedAt: base,
+	}))
+
+	logs, err := repos.Sleep.ListSleepLogs(ctx, alice)
+	require.NoError(t, err)
+	assert.Len(t, logs, 2)
+	for _, l := range logs {
+		assert.Equal(t, alice, l.UserID)
+	}
+
+	logs, err = repos.Sleep.ListSleepLogs(ctx, bob)
+	require.NoError(t, err)
+	require.Len(t, logs, 1)
+	assert.Equal(t, bob, logs[0].UserID)
+	assert.Equal(t, 3, logs[0].Quality)
+
+	_, err = repos.Goals.GetGoal(ctx, bob)
+	assert.Error(t, err, "goals must not leak between users")
+}
+
+func testSaveRoundTrip(t *testing.T, b Backend) {
+	repos := open(t, b)
+	ctx := context.Background()
+	userID := newUserID()
+
+	want := newLog(userID, baseTime(), 9)
+	want.Reason = "Felt rested"
+	want.Interruptions = []string{"bathroom", "noise"}
+	want.Source = &internal.SleepLogSource{Name: "fitbit", ID: "26589710670", Metadata: map[string]any{
+		"type":          "stages",
+		"minutesAsleep": float64(431),
+		"levels":        map[string]any{"summary": map[string]any{"deep": map[string]any{"count": float64(4)}}},
+	}}
+	require.NoError(t, repos.Sleep.SaveSleepLog(ctx, want))
+
+	logs, err := repos.Sleep.ListSleepLogs(ctx, userID)
+	require.NoError(t, err)
+	require.Len(t, logs, 1)
+	assertLogEqual(t, *want, logs[0])
+
+	got, err := repos.Sleep.GetSleepLog(ctx, want.ID)
+	require.NoError(t, err)
+	assertLogEqual(t, *want, *got)
+	_, err = repos.Sleep.GetSleepLog(ctx, uuid.NewString())
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+}
+
+func testSaveBatch(t *testing.T, b Backend) {
+	repos := open(t, b)
+	ctx := context.Background()
+	userID := newUserID()
+	day := baseTime().Truncate(24 * time.Hour)
+
+	var batch []internal.SleepLog
+	for _, offset := range []time.Duration{1, 14, 25} {
+		log := newLog(userID, day.Add(offset*time.Hour), int(offset%10)+1)
+		log.Interruptions = []string{"noise"}
+		batch = append(batch, *log)
+	}
+	require.NoError(t, repos.Sleep.SaveSleepLogs(ctx, batch))
+	require.NoError(t, repos.Sleep.SaveSleepLogs(ctx, nil))
+
+	logs, err := repos.Sleep.ListSleepLogs(ctx, userID)
+	require.NoError(t, err)
+	require.Len(t, logs, 3)
+	assertLogEqual(t, batch[2], logs[0])
+	assertLogEqual(t, batch[0], logs[2])
+
+	if repos.Aggregates == nil {
+		return
+	}
+	aggs, err := repos.Aggregates.ListDailyAggregates(ctx, userID, day, day.AddDate(0, 0, 1))
+	require.NoError(t, err)
+	require.Len(t, aggs, 2)
+	assert.Equal(t, 1, aggs[0].LogCount)
+	assert.Equal(t, 2, aggs[1].LogCount)
+	assert.Equal(t, 2, aggs[1].InterruptionCount)
+}
+
+// testSaveBatchReplaces saves a stored ID again, moving the log to another
+// day, as re-importing a log from another app does.
+func testSaveBatchReplaces(t *testing.T, b Backend) {
+	repos := open(t, b)
+	ctx := context.Background()
+	userID := newUserID()
+	day := baseTime().Truncate(24 * time.Hour)
+
+	first, second := newLog(userID, day.Add(time.Hour), 5), newLog(userID, day.Add(25*time.Hour), 6)
+	require.NoError(t, repos.Sleep.SaveSleepLogs(ctx, []internal.SleepLog{*first, *second}))
+
+	moved := *second
+	moved.StartTime = day.Add(14 * time.Hour)
+	moved.EndTime = moved.StartTime.Add(7 * time.Hour)
+	moved.Quality = 8
+	moved.Source = &internal.SleepLogSource{Name: "fitbit", ID: "1"}
+	require.NoError(t, repos.Sleep.SaveSleepLogs(ctx, []internal.SleepLog{moved}))
+
+	logs, err := repos.Sleep.ListSleepLogs(ctx, userID)
+	require.NoError(t, err)
+	require.Len(t, logs, 2)
+	assertLogEqual(t, moved, logs[0])
+	assertLogEqual(t, *first, logs[1])
+
+	if repos.Aggregates == nil {
+		return
+	}
+	aggs, err := repos.Aggregates.ListDailyAggregates(ctx, userID, day, day.AddDate(0, 0, 1))
+	require.NoError(t, err)
+	require.Len(t, aggs, 1, "the day the log moved from is empty")
+	assert.Equal(t, 2, aggs[0].LogCount)
+	assert.Equal(t, 13, aggs[0].QualitySum)
+}
+
+// testSourceUnique checks that a user has at most one log per source, and
+// that a batch breaking that saves nothing.
+func testSourceUnique(t *testing.T, b Backend) {
+	repos := open(t, b)
+	ctx := context.Background()
+	userID, otherID := newUserID(), newUserID()
+	day := baseTime().Truncate(24 * time.Hour)
+	source := &internal.SleepLogSource{Name: "fitbit", ID: "1"}
+
+	stored := newLog(userID, day.Add(time.Hour), 5)
+	stored.Source = source
+	require.NoError(t, repos.Sleep.SaveSleepLog(ctx, stored))
+
+	again := *stored
+	again.Quality = 7
+	require.NoError(t, repos.Sleep.SaveSleepLogs(ctx, []internal.SleepLog{again}), "saving the same log again replaces it")
+
+	other := newLog(otherID, day.Add(time.Hour), 5)
+	other.Source = source
+	require.NoError(t, repos.Sleep.SaveSleepLog(ctx, other), "another user may import the same source")
+
+	duplicate := newLog(userID, day.Add(25*time.Hour), 6)
+	duplicate.Source = source
+	assert.ErrorIs(t, repos.Sleep.SaveSleepLog(ctx, duplicate), storage.ErrSourceTaken)
+	unrelated := newLog(userID, day.Add(49*time.Hour), 6)
+	assert.ErrorIs(t, repos.Sleep.SaveSleepLogs(ctx, []internal.SleepLog{*unrelated, *duplicate}), storage.ErrSourceTaken)
+
+	first, second := newLog(userID, day.Add(73*time.Hour), 6), newLog(userID, day.Add(97*time.Hour), 6)
+	first.Source = &internal.SleepLogSource{Name: "fitbit", ID: "2"}
+	second.Source = first.Source
+	assert.ErrorIs(t, repos.Sleep.SaveSleepLogs(ctx, []internal.SleepLog{*first, *second}), storage.ErrSourceTaken)
+
+	logs, err := repos.Sleep.ListSleepLogs(ctx, userID)
+	require.NoError(t, err)
+	require.Len(t, logs, 1, "failed batches save nothing")
+	assertLogEqual(t, again, logs[0])
+}
+
+// testIterate walks more logs than a backend is likely to read at once,
+// with start times shared by several logs.
+func testIterate(t *testing.T, b Backend) {
+	repos := open(t, b)
+	ctx := context.Background()
+	userID := newUserID()
+	base := baseTime()
+
+	var batch []internal.SleepLog
+	for i := range 1201 {
+		batch = append(batch, *newLog(userID, base.Add(time.Duration(i/3)*time.Hour), i%10+1))
+	}
+	require.NoError(t, repos.Sleep.SaveSleepLogs(ctx, batch))
+	require.NoError(t, repos.Sleep.SaveSleepLog(ctx, newLog(newUserID(), base, 5)))
+
+	var seen []internal.SleepLog
+	require.NoError(t, repos.Sleep.IterateSleepLogs(ctx, userID, func(l internal.SleepLog) error {
+		seen = append(seen, l)
+		return nil
+	}))
+	require.Len(t, seen, len(batch))
+	for i := 1; i < len(seen); i++ {
+		prev, cur := seen[i-1], seen[i]
+		require.True(t, prev.StartTime.After(cur.StartTime) || (prev.StartTime.Equal(cur.StartTime) && prev.ID < cur.ID),
+			"logs must be ordered by start_time descending, then id, got %s before %s", prev.ID, cur.ID)
+	}
+
+	stop := errors.New("stop")
+	calls := 0
+	err := repos.Sleep.IterateSleepLogs(ctx, userID, func(internal.SleepLog) error {
+		calls++
+		if calls == 600 {
+			return stop
+		}
+		return nil
+	})
+	assert.ErrorIs(t, err, stop)
+	assert.Equal(t, 600, calls, "iteration stops at the first error")
+}
+
+func testGoalNotFound(t *testing.T, b Backend) {
+	repos := open(t, b)
+	goal, err := repos.Goals.GetGoal(context.Background(), newUserID())
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+	assert.Nil(t, goal)
+}
+
+func testGoalReplacement(t *testing.T, b Backend) {
+	repos := open(t, b)
+	ctx := context.Background()
+	userID := newUserID()
+	base := baseTime()
+
+	set := func(goalType, value string, at time.Time) {
+		require.NoError(t, repos.Goals.SetGoal(ctx, &internal.Goal{
+			ID: uuid.NewString(), UserID: userID, Type: goalType, Value: value, CreatedAt: at,
+		}))
+	}
+	current := func() *internal.Goal {
+		g, err := repos.Goals.GetGoal(ctx, userID)
+		require.NoError(t, err)
+		return g
+	}
+
+	set("duration", "7h", base)
+	assert.Equal(t, "7h", current().Value)
+
+	// A newer goal of a different type becomes the current goal.
+	set("quality", "> 6", base.Add(time.Minute))
+	g := current()
+	assert.Equal(t, "quality", g.Type)
+	assert.Equal(t, "> 6", g.Value)
+
+	// Setting a type again replaces the previous goal of that type.
+	set("duration", "8h", base.Add(2*time.Minute))
+	g = current()
+	assert.Equal(t, "duration", g.Type)
+	assert.Equal(t, "8h", g.Value)
+	assert.Equal(t, userID, g.UserID)
+	assert.True(t, g.CreatedAt.Equal(base.Add(2*time.Minute)))
+}
+
+func testConcurrentWriters(t *testing.T, b Backend) {
+	repos := open(t, b)
+	ctx := context.Background()
+	shared := newUserID()
+	base := baseTime()
+
+	const writers, perWriter = 8, 10
+	own := make([]string, writers)
+	for i := range own {
+		own[i] = newUserID()
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, writers*perWriter*3)
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < perWriter; i++ {
+				start := base.Add(time.Duration(w*perWriter+i) * time.Minute)
+				errs <- repos.Sleep.SaveSleepLog(ctx, newLog(shared, start, 5))
+				errs <- repos.Sleep.SaveSleepLog(ctx, newLog(own[w], start, 5))
+				errs <- repos.Goals.SetGoal(ctx, &internal.Goal{
+					ID: uuid.NewString(), UserID: own[w], Type: "quality", Value: fmt.Sprintf("> %d", i), CreatedAt: start,
+				})
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	logs, err := repos.Sleep.ListSleepLogs(ctx, shared)
+	require.NoError(t, err)
+	assert.Len(t, logs, writers*perWriter)
+	for i := 1; i < len(logs); i++ {
+		assert.False(t, logs[i-1].StartTime.Before(logs[i].StartTime), "ordering must hold under concurrent writes")
+	}
+
+	for w := 0; w < writers; w++ {
+		logs, err := repos.Sleep.ListSleepLogs(ctx, own[w])
+		require.NoError(t, err)
+		assert.Len(t, logs, perWriter)
+		g, err := repos.Goals.GetGoal(ctx, own[w])
+		require.NoError(t, err)
+		assert.Equal(t, fmt.Sprintf("> %d", perWriter-1), g.Value)
+	}
+}
+
+func testPersistence(t *testing.T, b Backend) {
+	if !b.Persistent {
+		t.Skip("backend does not persist data across reopen")
+	}
+	ctx := context.Background()
+	userID := newUserID()
+	base := baseTime()
+
+	first := open(t, b)
+	want := newLog(userID, base, 6)
+	want.Reason = "late coffee"
+	want.Interruptions = []string{"noise"}
+	require.NoError(t, first.Sleep.SaveSleepLog(ctx, want))
+	require.NoError(t, first.Sleep.SaveSleepLog(ctx, newLog(userID, base.AddDate(0, 0, 1), 8)))
+	require.NoError(t, first.Goals.SetGoal(ctx, &internal.Goal{
+		ID: uuid.NewString(), UserID: userID, Type: "duration", Value: "7h30m", CreatedAt: base,
+	}))
+	if first.Close != nil {
+		require.NoError(t, first.Close())
+	}
+
+	second := open(t, b)
+	logs, err := second.Sleep.ListSleepLogs(ctx, userID)
+	require.NoError(t, err)
+	require.Len(t, logs, 2)
+	assert.Equal(t, 8, logs[0].Quality)
+	assertLogEqual(t, *want, logs[1])
+
+	g, err := second.Goals.GetGoal(ctx, userID)
+	require.NoError(t, err)
+	assert.Equal(t, "7h30m", g.Value)
+
+	if second.Aggregates != nil {
+		aggs, err := second.Aggregates.ListDailyAggregates(ctx, userID, base.AddDate(0, 0, -1), base.AddDate(0, 0, 2))
+		require.NoError(t, err)
+		assert.Len(t, aggs, 2, "aggregates must survive a reopen")
+	}
+
+	// New writes after reopening must still land in the right place.
+	require.NoError(t, second.Sleep.SaveSleepLog(ctx, newLog(userID, base.AddDate(0, 0, 2), 9)))
+	logs, err = second.Sleep.ListSleepLogs(ctx, userID)
+	require.NoError(t, err)
+	require.Len(t, logs, 3)
+	assert.Equal(t, 9, logs[0].Quality)
+}
+
+func assertLogEqual(t *testing.T, want, got internal.SleepLog) {
+	t.Helper()
+	assert.Equal(t, want.ID, got.ID)
+	assert.Equal(t, want.UserID, got.UserID)
+	assert.True(t, want.StartTime.Equal(got.StartTime), "start_time: want %v, got %v", want.StartTime, got.StartTime)
+	assert.True(t, want.EndTime.Equal(got.EndTime), "end_time: want %v, got %v", want.EndTime, got.EndTime)
+	assert.Equal(t, want.Quality, got.Quality)
+	assert.Equal(t, want.Reason, got.Reason)
+	assert.Equal(t, want.Interruptions, got.Interruptions)
+	assert.Equal(t, want.Source, got.Source)
+	assert.True(t, want.CreatedAt.Equal(got.CreatedAt), "created_at: want %v, got %v", want.CreatedAt, got.CreatedAt)
+}
+
+func requireAggregates(t *testing.T, repos Repositories) storage.AggregateRepository {
+	t.Helper()
+	if repos.Aggregates == nil {
+		t.Skip("backend does not provide an AggregateRepository")
+	}
+	return repos.Aggregates
+}
+
+func testAggregatesMaintained(t *testing.T, b Backend) {
+	repos := open(t, b)
+	aggRepo := requireAggregates(t, repos)
+	ctx := context.Background()
+	userID := newUserID()
+	day := baseTime().Truncate(24 * time.Hour)
+
+	first := newLog(userID, day.Add(1*time.Hour), 6)
+	first.EndTime = first.StartTime.Add(5 * time.Hour)
+	first.Interruptions = []string{"noise", "bathroom"}
+	nap := newLog(userID, day.Add(14*time.Hour), 9)
+	nap.EndTime = nap.StartTime.Add(90 * time.Minute)
+	nap.Interruptions = []string{"phone"}
+	nextDay := newLog(userID, day.Add(25*time.Hour), 4)
+	for _, l := range []*internal.SleepLog{first, nap, nextDay} {
+		require.NoError(t, repos.Sleep.SaveSleepLog(ctx, l))
+	}
+
+	aggs, err := aggRepo.ListDailyAggregates(ctx, userID, day.AddDate(0, 0, -1), day.AddDate(0, 0, 2))
+	require.NoError(t, err)
+	require.Len(t, aggs, 2)
+	assert.Equal(t, storage.DayOf(nextDay.StartTime), aggs[0].Date, "aggregates must be ordered most recent day first")
+
+	agg := aggs[1]
+	assert.Equal(t, userID, agg.UserID)
+	assert.Equal(t, storage.DayOf(day), agg.Date)
+	assert.Equal(t, 2, agg.LogCount)
+	assert.Equal(t, int64((5*time.Hour+90*time.Minute)/time.Second), agg.TotalSleepSeconds)
+	assert.Equal(t, 15, agg.QualitySum)
+	assert.Equal(t, 7.5, agg.AverageQuality())
+	assert.Equal(t, 3, agg.InterruptionCount)
+	assert.True(t, agg.Bedtime.Equal(first.StartTime), "bedtime: want %v, got %v", first.StartTime, agg.Bedtime)
+	assert.True(t, agg.WakeTime.Equal(nap.EndTime), "wake_time: want %v, got %v", nap.EndTime, agg.WakeTime)
+}
+
+func testAggregatesRange(t *testing.T, b Backend) {
+	repos := open(t, b)
+	aggRepo := requireAggregates(t, repos)
+	ctx := context.Background()
+	alice, bob := newUserID(), newUserID()
+	day := baseTime().Truncate(24 * time.Hour)
+
+	for i := 0; i < 5; i++ {
+		require.NoError(t, repos.Sleep.SaveSleepLog(ctx, newLog(alice, day.AddDate(0, 0, i).Add(time.Hour), 5)))
+	}
+	require.NoError(t, repos.Sleep.SaveSleepLog(ctx, newLog(bob, day.Add(time.Hour), 5)))
+
+	aggs, err := aggRepo.ListDailyAggregates(ctx, alice, day.AddDate(0, 0, 1), day.AddDate(0, 0, 3))
+	require.NoError(t, err)
+	require.Len(t, aggs, 3, "range bounds are inclusive days")
+	assert.Equal(t, storage.DayOf(day.AddDate(0, 0, 3)), aggs[0].Date)
+	assert.Equal(t, storage.DayOf(day.AddDate(0, 0, 1)), aggs[2].Date)
+
+	aggs, err = aggRepo.ListDailyAggregates(ctx, bob, day.AddDate(0, 0, -1), day.AddDate(0, 0, 10))
+	require.NoError(t, err)
+	require.Len(t, aggs, 1)
+	assert.Equal(t, bob, aggs[0].UserID)
+
+	aggs, err = aggRepo.ListDailyAggregates(ctx, newUserID(), day, day.AddDate(0, 0, 10))
+	require.NoError(t, err)
+	assert.NotNil(t, aggs)
+	assert.Empty(t, aggs)
+}
+
+func testAggregatesRebuild(t *testing.T, b Backend) {
+	repos := open(t, b)
+	aggRepo := requireAggregates(t, repos)
+	ctx := context.Background()
+	userID := newUserID()
+	day := baseTime().Truncate(24 * time.Hour)
+
+	for i := 0; i < 4; i++ {
+		l := newLog(userID, day.AddDate(0, 0, i/2).Add(time.Duration(i)*time.Hour), i+3)
+		l.Interruptions = []string{"noise"}
+		require.NoError(t, repos.Sleep.SaveSleepLog(ctx, l))
+	}
+	from, to := day.AddDate(0, 0, -1), day.AddDate(0, 0, 3)
+	before, err := aggRepo.ListDailyAggregates(ctx, userID, from, to)
+	require.NoError(t, err)
+
+	logs, err := repos.Sleep.ListSleepLogs(ctx, userID)
+	require.NoError(t, err)
+	expected := storage.BuildDailyAggregates(logs)
+
+	require.NoError(t, aggRepo.RebuildDailyAggregates(ctx))
+	after, err := aggRepo.ListDailyAggregates(ctx, userID, from, to)
+	require.NoError(t, err)
+
+	require.Len(t, after, len(expected))
+	require.Len(t, before, len(expected))
+	for i := range expected {
+		for _, got := range []internal.DailyAggregate{before[i], after[i]} {
+			assert.Equal(t, expected[i].Date, got.Date)
+			assert.Equal(t, expected[i].LogCount, got.LogCount)
+			assert.Equal(t, expected[i].TotalSleepSeconds, got.TotalSleepSeconds)
+			assert.Equal(t, expected[i].QualitySum, got.QualitySum)
+			assert.Equal(t, expected[i].InterruptionCount, got.InterruptionCount)
+			assert.True(t, expected[i].Bedtime.Equal(got.Bedtime))
+			assert.Equal(t, expected[i].BedtimeOffset, got.BedtimeOffset)
+			assert.True(t, expected[i].WakeTime.Equal(got.WakeTime))
+		}
+	}
+}
+
+// testAggregatesBedtimeOffset checks that a day's bedtime keeps the offset
+// its log started in, through writes, rebuilds and reads of the log itself.
+func testAggregatesBedtimeOffset(t *testing.T, b Backend) {
+	repos := open(t, b)
+	aggRepo := requireAggregates(t, repos)
+	ctx := context.Background()
+	userID := newUserID()
+	day := baseTime().Truncate(24 * time.Hour)
+	berlin := time.FixedZone("", 2*60*60)
+	newYork := time.FixedZone("", -4*60*60)
+
+	early := newLog(userID, day.Add(2*time.Hour).In(berlin), 7)
+	late := newLog(userID, day.Add(6*time.Hour).In(newYork), 7)
+	require.NoError(t, repos.Sleep.SaveSleepLog(ctx, late))
+	require.NoError(t, repos.Sleep.SaveSleepLog(ctx, early))
+
+	got, err := repos.Sleep.GetSleepLog(ctx, early.ID)
+	require.NoError(t, err)
+	_, offset := got.StartTime.Zone()
+	assert.Equal(t, 2*60*60, offset, "start_time keeps the offset it was saved with")
+
+	check := func(when string) {
+		t.Helper()
+		aggs, err := aggRepo.ListDailyAggregates(ctx, userID, day, day)
+		require.NoError(t, err)
+		require.Len(t, aggs, 1)
+		assert.Equal(t, 2*60*60, aggs[0].BedtimeOffset, when)
+		assert.Equal(t, 4, aggs[0].LocalBedtime().Hour(), when)
+	}
+	check("after write")
+	require.NoError(t, aggRepo.RebuildDailyAggregates(ctx))
+	check("after rebuild")
+}
+
+func requireUsers(t *testing.T, repos Repositories) storage.UserRepository {
+	t.Helper()
+	if repos.Users == nil {
+		t.Skip("backend does not provide a UserRepository")
+	}
+	return repos.Users
+}
+
+func newUser() *internal.User {
+	id := newUserID()
+	return &internal.User{
+		ID:           id,
+		Name:         "Test User",
+		Email:        id + "@example.com",
+		PasswordHash: "$2a$10$" + uuid.NewString(),
+		CreatedAt:    time.Now().UTC().Truncate(time.Second),
+	}
+}
+
+func testUsersCreateAndLookup(t *testing.T, b Backend) {
+	users := requireUsers(t, open(t, b))
+	ctx := context.Background()
+	want := newUser()
+	require.NoError(t, users.CreateUser(ctx, want))
+
+	for _, lookup := range []func() (*internal.User, error){
+		func() (*internal.User, error) { return users.GetUserByID(ctx, want.ID) },
+		func() (*internal.User, error) { return users.GetUserByEmail(ctx, want.Email) },
+	} {
+		got, err := lookup()
+		require.NoError(t, err)
+		assert.Equal(t, want.ID, got.ID)
+		assert.Equal(t, want.Name, got.Name)
+		assert.Equal(t, want.Email, got.Email)
+		assert.Equal(t, want.PasswordHash, got.PasswordHash)
+		assert.True(t, want.CreatedAt.Equal(got.CreatedAt))
+	}
+
+	dup := newUser()
+	dup.Email = want.Email
+	assert.ErrorIs(t, users.CreateUser(ctx, dup), storage.ErrEmailTaken)
+
+	_, err := users.GetUserByID(ctx, newUserID())
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+	_, err = users.GetUserByEmail(ctx, newUserID()+"@example.com")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+}
+
+func testUsersUpdateAndList(t *testing.T, b Backend) {
+	users := requireUsers(t, open(t, b))
+	ctx := context.Background()
+	older, newer := newUser(), newUser()
+	newer.CreatedAt = older.CreatedAt.Add(time.Second)
+	require.NoError(t, users.CreateUser(ctx, newer))
+	require.NoError(t, users.CreateUser(ctx, older))
+
+	disabledAt := time.Now().UTC().Truncate(time.Second)
+	update := *newer
+	update.Name, update.Role, update.DisabledAt = "Renamed", internal.RoleViewer, &disabledAt
+	update.Email, update.PasswordHash = "changed@example.com", "changed"
+	require.NoError(t, users.UpdateUser(ctx, &update))
+
+	got, err := users.GetUserByID(ctx, newer.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Renamed", got.Name)
+	assert.Equal(t, internal.RoleViewer, got.Role)
+	require.NotNil(t, got.DisabledAt)
+	assert.True(t, disabledAt.Equal(*got.DisabledAt))
+	assert.Equal(t, newer.Email, got.Email, "email is not updatable")
+	assert.Equal(t, newer.PasswordHash, got.PasswordHash, "password is not updatable")
+
+	got.DisabledAt = nil
+	require.NoError(t, users.UpdateUser(ctx, got))
+	got, err = users.GetUserByID(ctx, newer.ID)
+	require.NoError(t, err)
+	assert.Nil(t, got.DisabledAt)
+
+	missing := newUser()
+	assert.ErrorIs(t, users.UpdateUser(ctx, missing), storage.ErrNotFound)
+
+	// Other tests may share the backend, so only check relative order.
+	all, err := users.ListUsers(ctx)
+	require.NoError(t, err)
+	pos := map[string]int{}
+	for i, u := range all {
+		pos[u.ID] = i
+	}
+	require.Contains(t, pos, older.ID)
+	require.Contains(t, pos, newer.ID)
+	assert.Less(t, pos[older.ID], pos[newer.ID])
+}
+
+func testAccessTokens(t *testing.T, b Backend) {
+	users := requireUsers(t, open(t, b))
+	ctx := context.Background()
+	user := newUser()
+	require.NoError(t, users.CreateUser(ctx, user))
+
+	now := time.Now().UTC().Truncate(time.Second)
+	want := &internal.AccessToken{Hash: uuid.NewString(), UserID: user.ID, CreatedAt: now, ExpiresAt: now.Add(time.Hour)}
+	require.NoError(t, users.SaveAccessToken(ctx, want))
+
+	got, err := users.GetAccessToken(ctx, want.Hash)
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, got.UserID)
+	assert.True(t, want.ExpiresAt.Equal(got.ExpiresAt))
+
+	require.NoError(t, users.DeleteAccessToken(ctx, want.Hash))
+	_, err = users.GetAccessToken(ctx, want.Hash)
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+	assert.NoError(t, users.DeleteAccessToken(ctx, want.Hash), "deleting twice is not an error")
+}
+
+func testUsersPersistence(t *testing.T, b Backend) {
+	if !b.Persistent {
+		t.Skip("backend does not persist data across reopen")
+	}
+	ctx := context.Background()
+	first := open(t, b)
+	users := requireUsers(t, first)
+	want := newUser()
+	require.NoError(t, users.CreateUser(ctx, want))
+	now := time.Now().UTC().Truncate(time.Second)
+	token := &internal.AccessToken{Hash: uuid.NewString(), UserID: want.ID, CreatedAt: now, ExpiresAt: now.Add(time.Hour)}
+	require.NoError(t, users.SaveAccessToken(ctx, token))
+	if first.Close != nil {
+		require.NoError(t, first.Close())
+	}
+
+	second := open(t, b)
+	got, err := second.Users.GetUserByEmail(ctx, want.Email)
+	require.NoError(t, err)
+	assert.Equal(t, want.PasswordHash, got.PasswordHash, "password hashes must survive a reopen")
+	_, err = second.Users.GetAccessToken(ctx, token.Hash)
+	assert.NoError(t, err)
+}
+
+func requireSessions(t *testing.T, repos Repositories) (storage.UserRepository, storage.SessionRepository) {
+	t.Helper()
+	if repos.Users == nil || repos.Sessions == nil {
+		t.Skip("backend does not provide a SessionRepository")
+	}
+	return repos.Users, repos.Sessions
+}
+
+func newSession(userID string, at time.Time) *internal.Session {
+	return &internal.Session{
+		ID: uuid.NewString(), UserID: userID, UserAgent: "test", IP: "127.0.0.1",
+		CreatedAt: at, LastUsedAt: at, ExpiresAt: at.Add(24 * time.Hour),
+	}
+}
+
+func testSessionsLifecycle(t *testing.T, b Backend) {
+	users, sessions := requireSessions(t, open(t, b))
+	ctx := context.Background()
+	user := newUser()
+	require.NoError(t, users.CreateUser(ctx, user))
+	now := time.Now().UTC().Truncate(time.Second)
+
+	older, newer := newSession(user.ID, now.Add(-time.Hour)), newSession(user.ID, now)
+	require.NoError(t, sessions.CreateSession(ctx, older))
+	require.NoError(t, sessions.CreateSession(ctx, newer))
+
+	list, err := sessions.ListSessions(ctx, user.ID)
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	assert.Equal(t, newer.ID, list[0].ID, "sessions are ordered most recently used first")
+
+	require.NoError(t, sessions.TouchSession(ctx, older.ID, now.Add(time.Minute), now.Add(48*time.Hour)))
+	list, err = sessions.ListSessions(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, older.ID, list[0].ID)
+	assert.True(t, list[0].ExpiresAt.Equal(now.Add(48*time.Hour)))
+
+	token := &internal.AccessToken{Hash: uuid.NewString(), UserID: user.ID, SessionID: older.ID, CreatedAt: now, ExpiresAt: now.Add(time.Hour)}
+	require.NoError(t, users.SaveAccessToken(ctx, token))
+	require.NoError(t, sessions.RevokeSession(ctx, older.ID, now))
+	got, err := sessions.GetSession(ctx, older.ID)
+	require.NoError(t, err)
+	require.NotNil(t, got.RevokedAt)
+	assert.False(t, got.Active(now))
+	_, err = users.GetAccessToken(ctx, token.Hash)
+	assert.ErrorIs(t, err, storage.ErrNotFound, "revoking a session deletes its access tokens")
+
+	assert.ErrorIs(t, sessions.RevokeSession(ctx, uuid.NewString(), now), storage.ErrNotFound)
+	_, err = sessions.GetSession(ctx, uuid.NewString())
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+}
+
+func testRefreshTokensSingleUse(t *testing.T, b Backend) {
+	users, sessions := requireSessions(t, open(t, b))
+	ctx := context.Background()
+	user := newUser()
+	require.NoError(t, users.CreateUser(ctx, user))
+	now := time.Now().UTC().Truncate(time.Second)
+	session := newSession(user.ID, now)
+	require.NoError(t, sessions.CreateSession(ctx, session))
+
+	token := &internal.RefreshToken{Hash: uuid.NewString(), SessionID: session.ID, UserID: user.ID, CreatedAt: now, ExpiresAt: now.Add(time.Hour)}
+	require.NoError(t, sessions.SaveRefreshToken(ctx, token))
+
+	// Of several concurrent uses exactly one wins; the rest see a reuse.
+	const attempts = 8
+	var wg sync.WaitGroup
+	results := make(chan error, attempts)
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			got, err := sessions.ConsumeRefreshToken(ctx, token.Hash, now)
+			if err == nil || errors.Is(err, storage.ErrTokenReused) {
+				assert.Equal(t, session.ID, got.SessionID)
+			}
+			results <- err
+		}()
+	}
+	wg.Wait()
+	close(results)
+	wins := 0
+	for err := range results {
+		if err == nil {
+			wins++
+		} else {
+			assert.ErrorIs(t, err, storage.ErrTokenReused)
+		}
+	}
+	assert.Equal(t, 1, wins)
+
+	_, err := sessions.ConsumeRefreshToken(ctx, uuid.NewString(), now)
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+}
+
+func testAPIKeys(t *testing.T, b Backend) {
+	repos := open(t, b)
+	if repos.Users == nil || repos.APIKeys == nil {
+		t.Skip("backend does not provide an APIKeyRepository")
+	}
+	ctx := context.Background()
+	owner, other := newUser(), newUser()
+	require.NoError(t, repos.Users.CreateUser(ctx, owner))
+	require.NoError(t, repos.Users.CreateUser(ctx, other))
+	now := time.Now().UTC().Truncate(time.Second)
+
+	older := &internal.APIKey{ID: uuid.NewString(), UserID: owner.ID, Name: "old", Prefix: "slk_old", Hash: uuid.NewString(),
+		Scopes: []string{"sleep:read"}, CreatedAt: now.Add(-time.Hour)}
+	newer := &internal.APIKey{ID: uuid.NewString(), UserID: owner.ID, Name: "new", Prefix: "slk_new", Hash: uuid.NewString(),
+		Scopes: []string{"sleep:read", "sleep:write"}, CreatedAt: now}
+	require.NoError(t, repos.APIKeys.CreateAPIKey(ctx, older))
+	require.NoError(t, repos.APIKeys.CreateAPIKey(ctx, newer))
+
+	got, err := repos.APIKeys.GetAPIKeyByHash(ctx, newer.Hash)
+	require.NoError(t, err)
+	assert.Equal(t, newer.ID, got.ID)
+	assert.Equal(t, newer.Scopes, got.Scopes)
+	assert.Nil(t, got.LastUsedAt)
+
+	require.NoError(t, repos.APIKeys.TouchAPIKey(ctx, newer.ID, now))
+	got, err = repos.APIKeys.GetAPIKeyByHash(ctx, newer.Hash)
+	require.NoError(t, err)
+	require.NotNil(t, got.LastUsedAt)
+	assert.True(t, got.LastUsedAt.Equal(now))
+
+	keys, err := repos.APIKeys.ListAPIKeys(ctx, owner.ID)
+	require.NoError(t, err)
+	require.Len(t, keys, 2)
+	assert.Equal(t, newer.ID, keys[0].ID, "keys are listed newest first")
+	keys, err = repos.APIKeys.ListAPIKeys(ctx, other.ID)
+	require.NoError(t, err)
+	assert.NotNil(t, keys)
+	assert.Empty(t, keys)
+
+	assert.ErrorIs(t, repos.APIKeys.DeleteAPIKey(ctx, other.ID, older.ID), storage.ErrNotFound, "keys can only be deleted by their owner")
+	require.NoError(t, repos.APIKeys.DeleteAPIKey(ctx, owner.ID, older.ID))
+	_, err = repos.APIKeys.GetAPIKeyByHash(ctx, older.Hash)
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+}
+
+func testGrants(t *testing.T, b Backend) {
+	repos := open(t, b)
+	if repos.Users == nil || repos.Sharing == nil {
+		t.Skip("backend does not provide a SharingRepository")
+	}
+	ctx := context.Background()
+	owner, grantee := newUser(), newUser()
+	require.NoError(t, repos.Users.CreateUser(ctx, owner))
+	require.NoError(t, repos.Users.CreateUser(ctx, grantee))
+	now := time.Now().UTC().Truncate(time.Second)
+	expires := now.Add(24 * time.Hour)
+
+	older := &internal.Grant{ID: uuid.NewString(), OwnerID: owner.ID, GranteeID: grantee.ID, GranteeEmail: grantee.Email, CreatedAt: now.Add(-time.Hour)}
+	newer := &internal.Grant{ID: uuid.NewString(), OwnerID: owner.ID, GranteeID: grantee.ID, GranteeEmail: grantee.Email, CreatedAt: now, ExpiresAt: &expires}
+	require.NoError(t, repos.Sharing.CreateGrant(ctx, older))
+	require.NoError(t, repos.Sharing.CreateGrant(ctx, newer))
+
+	got, err := repos.Sharing.GetGrant(ctx, newer.ID)
+	require.NoError(t, err)
+	assert.Equal(t, grantee.Email, got.GranteeEmail)
+	require.NotNil(t, got.ExpiresAt)
+	assert.True(t, expires.Equal(*got.ExpiresAt))
+	assert.Nil(t, got.AcceptedAt)
+	_, err = repos.Sharing.GetGrant(ctx, uuid.NewString())
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+
+	got.AcceptedAt, got.RevokedAt = &now, &now
+	require.NoError(t, repos.Sharing.UpdateGrant(ctx, got))
+	got, err = repos.Sharing.GetGrant(ctx, newer.ID)
+	require.NoError(t, err)
+	require.NotNil(t, got.AcceptedAt)
+	require.NotNil(t, got.RevokedAt)
+	assert.True(t, now.Equal(*got.RevokedAt))
+	assert.ErrorIs(t, repos.Sharing.UpdateGrant(ctx, &internal.Grant{ID: uuid.NewString()}), storage.ErrNotFound)
+
+	for _, list := range []func() ([]internal.Grant, error){
+		func() ([]internal.Grant, error) { return repos.Sharing.ListGrantsByOwner(ctx, owner.ID) },
+		func() ([]internal.Grant, error) { return repos.Sharing.ListGrantsByGrantee(ctx, grantee.ID) },
+		func() ([]internal.Grant, error) { return repos.Sharing.ListGrantsByEmail(ctx, grantee.Email) },
+	} {
+		grants, err := list()
+		require.NoError(t, err)
+		require.Len(t, grants, 2)
+		assert.Equal(t, newer.ID, grants[0].ID, "grants are listed newest first")
+	}
+	grants, err := repos.Sharing.ListGrantsByOwner(ctx, grantee.ID)
+	require.NoError(t, err)
+	assert.NotNil(t, grants)
+	assert.Empty(t, grants)
+
+	// A grant to an email with no account yet has no grantee until accepted.
+	invited := &internal.Grant{ID: uuid.NewString(), OwnerID: owner.ID, GranteeEmail: "invited-" + uuid.NewString() + "@example.com", CreatedAt: now}
+	require.NoError(t, repos.Sharing.CreateGrant(ctx, invited))
+	grants, err = repos.Sharing.ListGrantsByEmail(ctx, invited.GranteeEmail)
+	require.NoError(t, err)
+	require.Len(t, grants, 1)
+	assert.Empty(t, grants[0].GranteeID)
+	invitee := newUser()
+	invitee.Email = invited.GranteeEmail
+	require.NoError(t, repos.Users.CreateUser(ctx, invitee))
+	grants[0].GranteeID, grants[0].AcceptedAt = invitee.ID, &now
+	require.NoError(t, repos.Sharing.UpdateGrant(ctx, &grants[0]))
+	got, err = repos.Sharing.GetGrant(ctx, invited.ID)
+	require.NoError(t, err)
+	assert.Equal(t, invitee.ID, got.GranteeID)
+	grants, err = repos.Sharing.ListGrantsByGrantee(ctx, invitee.ID)
+	require.NoError(t, err)
+	require.Len(t, grants, 1)
+	assert.Equal(t, invited.ID, grants[0].ID)
+
+	for i, path := range []string{"/users/x/sleep", "/users/x/sleep/stats"} {
+		require.NoError(t, repos.Sharing.RecordGrantAccess(ctx, &internal.GrantAccess{
+			ID: uuid.NewString(), GrantID: older.ID, OwnerID: owner.ID, GranteeID: grantee.ID,
+			Method: "GET", Path: path, At: now.Add(time.Duration(i) * time.Second),
+		}))
+	}
+	accesses, err := repos.Sharing.ListGrantAccesses(ctx, owner.ID)
+	require.NoError(t, err)
+	require.Len(t, accesses, 2)
+	assert.Equal(t, "/users/x/sleep/stats", accesses[0].Path, "accesses are listed newest first")
+	assert.Equal(t, grantee.ID, accesses[0].GranteeID)
+	accesses, err = repos.Sharing.ListGrantAccesses(ctx, grantee.ID)
+	require.NoError(t, err)
+	assert.Empty(t, accesses)
+}
+
+func testAuditLog(t *testing.T, b Backend) {
+	repos := open(t, b)
+	if repos.Audit == nil {
+		t.Skip("backend does not provide an AuditRepository")
+	}
+	ctx := context.Background()
+	alice, bob := newUserID(), newUserID()
+	now := time.Now().UTC().Truncate(time.Second)
+	events := []*internal.AuditEvent{
+		{Action: internal.AuditLogin, ActorID: alice, TargetUserID: alice, RequestID: "req-1", IP: "10.0.0.1"},
+		{Action: internal.AuditSleepCreate, ActorID: alice, TargetUserID: alice, ResourceID: uuid.NewString()},
+		{Action: internal.AuditGrantCreate, ActorID: alice, TargetUserID: bob, Details: map[string]string{"note": "x"}},
+		{Action: internal.AuditLoginFailed, TargetUserID: bob, IP: "10.0.0.2"},
+	}
+	for i, e := range events {
+		e.ID = uuid.NewString()
+		e.At = now.Add(time.Duration(i-len(events)) * time.Minute)
+		require.NoError(t, repos.Audit.AppendAuditEvent(ctx, e))
+	}
+
+	check := func(repos Repositories) {
+		got, err := repos.Audit.ListAuditEvents(ctx, storage.AuditFilter{UserID: alice, Limit: 10})
+		require.NoError(t, err)
+		require.Len(t, got, 3)
+		assert.Equal(t, events[2].ID, got[0].ID, "events are listed newest first")
+		assert.Equal(t, map[string]string{"note": "x"}, got[0].Details)
+		assert.Equal(t, "req-1", got[2].RequestID)
+		assert.Equal(t, "10.0.0.1", got[2].IP)
+		assert.True(t, events[0].At.Equal(got[2].At))
+
+		got, err = repos.Audit.ListAuditEvents(ctx, storage.AuditFilter{UserID: bob, Limit: 10})
+		require.NoError(t, err)
+		require.Len(t, got, 2, "events count for their actor and their target")
+		assert.Empty(t, got[0].ActorID)
+
+		got, err = repos.Audit.ListAuditEvents(ctx, storage.AuditFilter{UserID: alice, Action: internal.AuditSleepCreate, Limit: 10})
+		require.NoError(t, err)
+		require.Len(t, got, 1)
+		assert.Equal(t, events[1].ResourceID, got[0].ResourceID)
+
+		got, err = repos.Audit.ListAuditEvents(ctx, storage.AuditFilter{UserID: alice, Since: events[1].At, Until: events[2].At, Limit: 10})
+		require.NoError(t, err)
+		require.Len(t, got, 1, "since is inclusive and until exclusive")
+		assert.Equal(t, events[1].ID, got[0].ID)
+
+		got, err = repos.Audit.ListAuditEvents(ctx, storage.AuditFilter{UserID: alice, Limit: 2})
+		require.NoError(t, err)
+		assert.Len(t, got, 2)
+
+		got, err = repos.Audit.ListAuditEvents(ctx, storage.AuditFilter{UserID: newUserID(), Limit: 10})
+		require.NoError(t, err)
+		assert.NotNil(t, got)
+		assert.Empty(t, got)
+	}
+	check(repos)
+
+	if !b.Persistent {
+		return
+	}
+	if repos.Close != nil {
+		require.NoError(t, repos.Close())
+	}
+	check(open(t, b))
+}
@@ -0,0 +1,28 @@
+CREATE TABLE IF NOT EXISTS users (
+    id    TEXT PRIMARY KEY,
+    token TEXT UNIQUE,
+    name  TEXT NOT NULL DEFAULT ''
+);
+
+CREATE TABLE IF NOT EXISTS sleep_logs (
+    id            TEXT PRIMARY KEY,
+    user_id       TEXT NOT NULL,
+    start_time    TIMESTAMPTZ NOT NULL,
+    end_time      TIMESTAMPTZ NOT NULL,
+    quality       INTEGER NOT NULL,
+    reason        TEXT NOT NULL DEFAULT '',
+    interruptions TEXT[],
+    created_at    TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS sleep_logs_user_start_idx ON sleep_logs (user_id, start_time DESC);
+
+CREATE TABLE IF NOT EXISTS goals (
+    id         TEXT PRIMARY KEY,
+    user_id    TEXT NOT NULL,
+    type       TEXT NOT NULL,
+    value      TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS goals_user_created_idx ON goals (user_id, created_at DESC);
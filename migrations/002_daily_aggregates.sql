@@ -0,0 +1,13 @@
+-- Per-user, per-day rollups maintained by the application on every sleep log
+-- write. Days are UTC calendar days of the log's start_time.
+CREATE TABLE IF NOT EXISTS daily_sleep_aggregates (
+    user_id             TEXT NOT NULL,
+    day                 DATE NOT NULL,
+    log_count           INTEGER NOT NULL,
+    total_sleep_seconds BIGINT NOT NULL,
+    quality_sum         INTEGER NOT NULL,
+    interruption_count  INTEGER NOT NULL,
+    bedtime             TIMESTAMPTZ NOT NULL,
+    wake_time           TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (user_id, day)
+);
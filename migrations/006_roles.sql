@@ -0,0 +1,3 @@
+-- Account roles (user, viewer, admin) and admin-disabled accounts.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS role TEXT NOT NULL DEFAULT 'user';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS disabled_at TIMESTAMPTZ;
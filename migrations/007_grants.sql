@@ -0,0 +1,27 @@
+-- Read-only access to a user's data granted to another user, and the log of
+-- accesses made under each grant.
+CREATE TABLE IF NOT EXISTS grants (
+    id            TEXT PRIMARY KEY,
+    owner_id      TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    grantee_id    TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    grantee_email TEXT NOT NULL,
+    created_at    TIMESTAMPTZ NOT NULL,
+    expires_at    TIMESTAMPTZ,
+    accepted_at   TIMESTAMPTZ,
+    revoked_at    TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS grants_owner_idx ON grants (owner_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS grants_grantee_idx ON grants (grantee_id, created_at DESC);
+
+CREATE TABLE IF NOT EXISTS grant_accesses (
+    id         TEXT PRIMARY KEY,
+    grant_id   TEXT NOT NULL REFERENCES grants (id) ON DELETE CASCADE,
+    owner_id   TEXT NOT NULL,
+    grantee_id TEXT NOT NULL,
+    method     TEXT NOT NULL,
+    path       TEXT NOT NULL,
+    at         TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS grant_accesses_owner_idx ON grant_accesses (owner_id, at DESC);
@@ -0,0 +1,28 @@
+-- Append-only audit trail of security-relevant and data-changing actions.
+-- Rows are never updated or deleted; a trigger rejects both.
+CREATE TABLE IF NOT EXISTS audit_events (
+    id             TEXT PRIMARY KEY,
+    at             TIMESTAMPTZ NOT NULL,
+    action         TEXT NOT NULL,
+    actor_id       TEXT NOT NULL DEFAULT '',
+    target_user_id TEXT NOT NULL DEFAULT '',
+    resource_id    TEXT NOT NULL DEFAULT '',
+    request_id     TEXT NOT NULL DEFAULT '',
+    ip             TEXT NOT NULL DEFAULT '',
+    details        JSONB
+);
+
+CREATE INDEX IF NOT EXISTS audit_events_at_idx ON audit_events (at DESC);
+CREATE INDEX IF NOT EXISTS audit_events_actor_idx ON audit_events (actor_id, at DESC);
+CREATE INDEX IF NOT EXISTS audit_events_target_idx ON audit_events (target_user_id, at DESC);
+
+CREATE OR REPLACE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS audit_events_append_only ON audit_events;
+CREATE TRIGGER audit_events_append_only
+    BEFORE UPDATE OR DELETE ON audit_events
+    FOR EACH ROW EXECUTE FUNCTION audit_events_append_only();
@@ -0,0 +1,8 @@
+-- Where imported sleep logs came from: the importer, the log's ID there and
+-- the fields it had that sleep logs do not. A user has at most one log per
+-- source and ID, so re-importing updates it.
+ALTER TABLE sleep_logs ADD COLUMN IF NOT EXISTS source JSONB;
+
+CREATE UNIQUE INDEX IF NOT EXISTS sleep_logs_source_idx
+    ON sleep_logs (user_id, (source->>'name'), (source->>'id'))
+    WHERE source IS NOT NULL;
@@ -0,0 +1,3 @@
+-- The UTC offset of the log that set a day's bedtime, so that goals compare
+-- bedtimes on the user's clock rather than in UTC.
+ALTER TABLE daily_sleep_aggregates ADD COLUMN IF NOT EXISTS bedtime_offset INTEGER NOT NULL DEFAULT 0;
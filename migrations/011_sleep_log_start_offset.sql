@@ -0,0 +1,3 @@
+-- The UTC offset each log's start time was given in. timestamptz keeps only
+-- the instant, and daily aggregates need the offset to fill bedtime_offset.
+ALTER TABLE sleep_logs ADD COLUMN IF NOT EXISTS start_offset INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,6 @@
+-- Grants are made out to an email and only bound to an account when that
+-- account accepts them, so that sharing does not reveal which emails have
+-- accounts.
+ALTER TABLE grants ALTER COLUMN grantee_id DROP NOT NULL;
+
+CREATE INDEX IF NOT EXISTS grants_grantee_email_idx ON grants (grantee_email, created_at DESC);
@@ -0,0 +1,227 @@
+// Package client is a typed Go client for the SleepTracker API's /api/v1
+// endpoints. It is written by hand; its types mirror the schemas in the
+// API's OpenAPI document, and the repository's tests fail if they drift.
+//
+//	c := client.New("http://localhost:8088", client.WithToken(token))
+//	log, err := c.CreateSleepLog(ctx, client.SleepLogInput{...})
+//	for log, err := range c.SleepLogs(ctx, client.ListSleepLogsOptions{}) { ... }
+//
+// Requests that fail with a network error, 429 or a 5xx status are retried
+// with exponential backoff, as are creates that conflict with an earlier
+// attempt still in progress. Creates carry an Idempotency-Key, the same on
+// every attempt, so a retry never creates a second resource.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// basePath is where version 1 of the API is mounted.
+const basePath = "/api/v1"
+
+// TokenSource supplies the bearer token for each request, so that callers
+// can refresh it. An access token, JWT or personal API key all work.
+type TokenSource interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// TokenFunc adapts a function to a TokenSource.
+type TokenFunc func(ctx context.Context) (string, error)
+
+func (f TokenFunc) Token(ctx context.Context) (string, error) { return f(ctx) }
+
+type staticToken string
+
+func (t staticToken) Token(context.Context) (string, error) { return string(t), nil }
+
+// Client calls the API. It is safe for concurrent use.
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	tokens     TokenSource
+	maxRetries int
+	backoff    time.Duration
+}
+
+type Option func(*Client)
+
+// WithToken sends token as the bearer token on every request.
+func WithToken(token string) Option {
+	return func(c *Client) { c.tokens = staticToken(token) }
+}
+
+// WithTokenSource asks ts for the bearer token before every request.
+func WithTokenSource(ts TokenSource) Option {
+	return func(c *Client) { c.tokens = ts }
+}
+
+// WithHTTPClient sends requests with hc instead of http.DefaultClient.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) { c.httpClient = hc }
+}
+
+// WithRetries retries a failed request up to max times, waiting backoff
+// before the first retry and twice as long before each one after that, or
+// as long as a Retry-After header asks. Zero max disables retries.
+func WithRetries(max int, backoff time.Duration) Option {
+	return func(c *Client) { c.maxRetries, c.backoff = max, backoff }
+}
+
+// New returns a client for the API at baseURL, such as
+// "http://localhost:8088". By default it retries twice, starting at 200ms.
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		httpClient: http.DefaultClient,
+		maxRetries: 2,
+		backoff:    200 * time.Millisecond,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+type idempotencyKey struct{}
+
+// WithIdempotencyKey makes creates called with ctx send key instead of a
+// generated one, so that a create retried after the process restarts, not
+// just by the client, still happens once.
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKey{}, key)
+}
+
+// envelope is the body of every successful response.
+type envelope struct {
+	Data json.RawMessage `json:"data"`
+	Meta json.RawMessage `json:"meta"`
+}
+
+// do sends a request to path, under /api/v1, and decodes the response's
+// data into data and its meta into meta, when they are non-nil.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body, data, meta any) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return fmt.Errorf("sleeptracker: encode request: %w", err)
+		}
+	}
+	target := c.baseURL + basePath + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	key, _ := ctx.Value(idempotencyKey{}).(string)
+	if method == http.MethodPost && key == "" {
+		key = uuid.NewString()
+	}
+
+	for attempt := 0; ; attempt++ {
+		resp, err := c.send(ctx, method, target, payload, key)
+		if err == nil && !retryable(resp) {
+			defer resp.Body.Close()
+			return decode(resp, data, meta)
+		}
+		if attempt >= c.maxRetries || ctx.Err() != nil {
+			if err != nil {
+				return fmt.Errorf("sleeptracker: %s %s: %w", method, path, err)
+			}
+			defer resp.Body.Close()
+			return decode(resp, data, meta)
+		}
+
+		wait := c.backoff << attempt
+		if resp != nil {
+			if s, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+				wait = time.Duration(s) * time.Second
+			}
+			_, _ = io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("sleeptracker: %s %s: %w", method, path, ctx.Err())
+		case <-time.After(wait):
+		}
+	}
+}
+
+func (c *Client) send(ctx context.Context, method, target string, payload []byte, key string) (*http.Response, error) {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if key != "" {
+		req.Header.Set("Idempotency-Key", key)
+	}
+	if c.tokens != nil {
+		token, err := c.tokens.Token(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("get token: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return c.httpClient.Do(req)
+}
+
+// retryable reports whether a request that got resp may succeed if sent
+// again. A 409 with Retry-After means an earlier attempt with the same
+// Idempotency-Key, such as one that timed out, is still running: once it
+// finishes, the retry gets its response.
+func retryable(resp *http.Response) bool {
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	case http.StatusConflict:
+		return resp.Header.Get("Retry-After") != ""
+	}
+	return false
+}
+
+func decode(resp *http.Response, data, meta any) error {
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("sleeptracker: read response: %w", err)
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		return newError(resp, raw)
+	}
+	if len(raw) == 0 {
+		return nil
+	}
+	var env envelope
+	if err := json.Unmarshal(raw, &env); err != nil {
+		return fmt.Errorf("sleeptracker: decode response: %w", err)
+	}
+	if data != nil && len(env.Data) > 0 {
+		if err := json.Unmarshal(env.Data, data); err != nil {
+			return fmt.Errorf("sleeptracker: decode response data: %w", err)
+		}
+	}
+	if meta != nil && len(env.Meta) > 0 {
+		if err := json.Unmarshal(env.Meta, meta); err != nil {
+			return fmt.Errorf("sleeptracker: decode response meta: %w", err)
+		}
+	}
+	return nil
+}
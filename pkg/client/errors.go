@@ -0,0 +1,72 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+)
+
+// Kinds of Error, for use with errors.Is.
+var (
+	ErrValidation   = errors.New("validation failed")
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrForbidden    = errors.New("forbidden")
+	ErrNotFound     = errors.New("not found")
+	ErrConflict     = errors.New("conflict")
+	ErrRateLimited  = errors.New("rate limited")
+)
+
+var errorKinds = map[int]error{
+	http.StatusBadRequest:      ErrValidation,
+	http.StatusUnauthorized:    ErrUnauthorized,
+	http.StatusForbidden:       ErrForbidden,
+	http.StatusNotFound:        ErrNotFound,
+	http.StatusConflict:        ErrConflict,
+	http.StatusTooManyRequests: ErrRateLimited,
+}
+
+// FieldError says why one request field was rejected.
+type FieldError struct {
+	Field   string            `json:"field"`
+	Code    string            `json:"code"`
+	Message string            `json:"message"`
+	Params  map[string]string `json:"params,omitempty"`
+}
+
+// Error is an error response from the API, decoded from its error envelope.
+// errors.Is matches it against the Err kinds by status.
+type Error struct {
+	StatusCode int
+	Message    string
+	// Fields lists every rejected field of a request that failed
+	// validation.
+	Fields []FieldError
+	// RequestID identifies the request in the server's logs.
+	RequestID string
+}
+
+func (e *Error) Error() string {
+	return "sleeptracker: " + strconv.Itoa(e.StatusCode) + ": " + e.Message
+}
+
+func (e *Error) Is(target error) bool {
+	return errorKinds[e.StatusCode] == target
+}
+
+func newError(resp *http.Response, body []byte) *Error {
+	e := &Error{StatusCode: resp.StatusCode, RequestID: resp.Header.Get("X-Request-ID")}
+	var env struct {
+		Error *struct {
+			Code    int          `json:"code"`
+			Message string       `json:"message"`
+			Fields  []FieldError `json:"fields"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(body, &env) == nil && env.Error != nil {
+		e.Message, e.Fields = env.Error.Message, env.Error.Fields
+	} else {
+		e.Message = http.StatusText(resp.StatusCode)
+	}
+	return e
+}
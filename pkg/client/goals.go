@@ -0,0 +1,35 @@
+package client
+
+import (
+	"context"
+	"net/http"
+)
+
+// SetGoal sets the user's sleep goal, replacing any current one.
+func (c *Client) SetGoal(ctx context.Context, in GoalInput) (*Goal, error) {
+	var goal Goal
+	if err := c.do(ctx, http.MethodPost, "/goals", nil, in, &goal, nil); err != nil {
+		return nil, err
+	}
+	return &goal, nil
+}
+
+// GetGoal returns the user's current goal, or an error matching ErrNotFound
+// if none is set.
+func (c *Client) GetGoal(ctx context.Context) (*Goal, error) {
+	var goal Goal
+	if err := c.do(ctx, http.MethodGet, "/goals", nil, nil, &goal, nil); err != nil {
+		return nil, err
+	}
+	return &goal, nil
+}
+
+// GetGoalProgress returns progress towards the current goal over the last
+// week.
+func (c *Client) GetGoalProgress(ctx context.Context) (*GoalProgress, error) {
+	var progress GoalProgress
+	if err := c.do(ctx, http.MethodGet, "/goals/progress", nil, nil, &progress, nil); err != nil {
+		return nil, err
+	}
+	return &progress, nil
+}
@@ -0,0 +1,79 @@
+package client
+
+import "time"
+
+type SleepLog struct {
+	ID            string          `json:"id"`
+	UserID        string          `json:"user_id"`
+	StartTime     time.Time       `json:"start_time"`
+	EndTime       time.Time       `json:"end_time"`
+	Quality       int             `json:"quality"` // 1–10
+	Reason        string          `json:"reason,omitempty"`
+	Interruptions []string        `json:"interruptions,omitempty"`
+	Source        *SleepLogSource `json:"source,omitempty"`
+	CreatedAt     time.Time       `json:"created_at"`
+}
+
+// SleepLogSource is where a log imported from another app came from.
+type SleepLogSource struct {
+	Name     string         `json:"name"`
+	ID       string         `json:"id"`
+	Metadata map[string]any `json:"metadata,omitempty"`
+}
+
+// SleepLogInput records a night's sleep. EndTime must be after StartTime,
+// at most 24h later, and not in the future.
+type SleepLogInput struct {
+	StartTime     time.Time `json:"start_time"`
+	EndTime       time.Time `json:"end_time"`
+	Quality       int       `json:"quality"` // 1–10
+	Reason        string    `json:"reason,omitempty"`
+	Interruptions []string  `json:"interruptions,omitempty"`
+}
+
+type SleepStats struct {
+	AverageQuality float64 `json:"average_quality"`
+	// Trend is the average quality for each day of the last week with
+	// sleep logged, newest first.
+	Trend []int `json:"trend"`
+}
+
+type Recommendation struct {
+	Recommendation string `json:"recommendation"`
+	Reason         string `json:"reason"`
+	Action         string `json:"action"`
+	Source         string `json:"source"`
+}
+
+// Goal types, and the format of their values.
+const (
+	GoalDuration    = "duration"    // hours slept per night: "7h"
+	GoalConsistency = "consistency" // hour to be asleep before: "before 23"
+	GoalQuality     = "quality"     // average quality to exceed: "> 7"
+)
+
+type Goal struct {
+	ID        string    `json:"id"`
+	UserID    string    `json:"user_id"`
+	Type      string    `json:"type"`
+	Value     string    `json:"value"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type GoalInput struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+type GoalProgress struct {
+	Goal      Goal      `json:"goal"`
+	Progress  []GoalDay `json:"progress"`
+	MetDays   int       `json:"met_days"`
+	TotalDays int       `json:"total_days"`
+}
+
+// GoalDay says whether the goal was met on one day, YYYY-MM-DD.
+type GoalDay struct {
+	Date string `json:"date"`
+	Met  bool   `json:"met"`
+}
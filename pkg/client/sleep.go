@@ -0,0 +1,104 @@
+package client
+
+import (
+	"context"
+	"iter"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+func (c *Client) CreateSleepLog(ctx context.Context, in SleepLogInput) (*SleepLog, error) {
+	var log SleepLog
+	if err := c.do(ctx, http.MethodPost, "/sleep-logs", nil, in, &log, nil); err != nil {
+		return nil, err
+	}
+	return &log, nil
+}
+
+func (c *Client) GetSleepLog(ctx context.Context, id string) (*SleepLog, error) {
+	var log SleepLog
+	if err := c.do(ctx, http.MethodGet, "/sleep-logs/"+url.PathEscape(id), nil, nil, &log, nil); err != nil {
+		return nil, err
+	}
+	return &log, nil
+}
+
+type ListSleepLogsOptions struct {
+	// Limit is the page size; zero returns every log in one page, and
+	// SleepLogs uses 100.
+	Limit int
+	// Cursor is the NextCursor of the previous page.
+	Cursor string
+}
+
+type SleepLogPage struct {
+	Logs []SleepLog
+	// NextCursor fetches the next page; it is empty on the last one.
+	NextCursor string
+}
+
+// ListSleepLogs returns one page of the user's sleep logs, newest first.
+func (c *Client) ListSleepLogs(ctx context.Context, opts ListSleepLogsOptions) (*SleepLogPage, error) {
+	query := url.Values{}
+	if opts.Limit > 0 {
+		query.Set("limit", strconv.Itoa(opts.Limit))
+	}
+	if opts.Cursor != "" {
+		query.Set("cursor", opts.Cursor)
+	}
+	var page SleepLogPage
+	var meta struct {
+		NextCursor string `json:"next_cursor"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/sleep-logs", query, nil, &page.Logs, &meta); err != nil {
+		return nil, err
+	}
+	page.NextCursor = meta.NextCursor
+	return &page, nil
+}
+
+// SleepLogs iterates over the user's sleep logs, newest first, fetching
+// pages as it goes. Iteration stops after the first error, which is
+// yielded with a zero SleepLog.
+func (c *Client) SleepLogs(ctx context.Context, opts ListSleepLogsOptions) iter.Seq2[SleepLog, error] {
+	if opts.Limit == 0 {
+		opts.Limit = 100
+	}
+	return func(yield func(SleepLog, error) bool) {
+		for {
+			page, err := c.ListSleepLogs(ctx, opts)
+			if err != nil {
+				yield(SleepLog{}, err)
+				return
+			}
+			for _, log := range page.Logs {
+				if !yield(log, nil) {
+					return
+				}
+			}
+			if page.NextCursor == "" {
+				return
+			}
+			opts.Cursor = page.NextCursor
+		}
+	}
+}
+
+// GetSleepStats returns the average quality and daily trend for the last
+// week.
+func (c *Client) GetSleepStats(ctx context.Context) (*SleepStats, error) {
+	var stats SleepStats
+	if err := c.do(ctx, http.MethodGet, "/sleep-stats", nil, nil, nil, &stats); err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
+
+func (c *Client) GetRecommendation(ctx context.Context) (*Recommendation, error) {
+	var rec Recommendation
+	if err := c.do(ctx, http.MethodGet, "/sleep-recommendations", nil, nil, nil, &rec); err != nil {
+		return nil, err
+	}
+	return &rec, nil
+}
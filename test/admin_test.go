@@ -0,0 +1,113 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yourname/sleeptracker/internal"
+	"github.com/yourname/sleeptracker/internal/service"
+)
+
+// registerAdmin registers an account and promotes it the way ADMIN_EMAILS
+// does at startup.
+func registerAdmin(t *testing.T, r *gin.Engine, app *TestApp, email string) tokenPair {
+	t.Helper()
+	registerAndLogin(t, r, email)
+	require.NoError(t, service.BootstrapAdmins(context.Background(), app.UserRepo(), []string{email}, app.Logger()))
+	return login(t, r, email)
+}
+
+func TestAdminRoutesRequireRole(t *testing.T) {
+	t.Parallel()
+	r, app := setupRouterAndStorage(t)
+	ctx := context.Background()
+
+	// Registering with an ADMIN_EMAILS address grants nothing: only startup
+	// promotes accounts that already exist.
+	admin := registerAndLogin(t, r, "admin@example.com")
+	assert.Equal(t, 403, doJSON(r, "GET", "/api/v1/admin/users", admin.AccessToken, "").Code)
+	require.NoError(t, service.BootstrapAdmins(ctx, app.UserRepo(), []string{"admin@example.com"}, app.Logger()))
+	admin = login(t, r, "admin@example.com")
+	user := registerAndLogin(t, r, "user@example.com")
+	registerAndLogin(t, r, "viewer@example.com")
+
+	// Regular users get a 403 in the usual response shape.
+	w := doJSON(r, "GET", "/api/v1/admin/users", user.AccessToken, "")
+	require.Equal(t, 403, w.Code)
+	var denied struct {
+		Error struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &denied))
+	assert.Equal(t, 403, denied.Error.Code)
+	assert.Contains(t, denied.Error.Message, "admin")
+
+	// Promoted accounts are admins.
+	w = doJSON(r, "GET", "/api/v1/admin/users", admin.AccessToken, "")
+	require.Equal(t, 200, w.Code, w.Body.String())
+	var listed struct {
+		Data []internal.User `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &listed))
+	require.Len(t, listed.Data, 3)
+	assert.Equal(t, internal.RoleAdmin, listed.Data[0].Role)
+	assert.NotContains(t, w.Body.String(), "password")
+
+	viewerAccount, err := app.UserRepo().GetUserByEmail(ctx, "viewer@example.com")
+	require.NoError(t, err)
+	w = doJSON(r, "PUT", "/api/v1/admin/users/"+viewerAccount.ID+"/role", admin.AccessToken, `{"role":"viewer"}`)
+	require.Equal(t, 200, w.Code, w.Body.String())
+	w = doJSON(r, "PUT", "/api/v1/admin/users/"+viewerAccount.ID+"/role", admin.AccessToken, `{"role":"root"}`)
+	assert.Equal(t, 400, w.Code)
+
+	// Viewers can read system-wide data and their own, but change nothing.
+	viewer := login(t, r, "viewer@example.com")
+	assert.Equal(t, 200, doJSON(r, "GET", "/api/v1/admin/users", viewer.AccessToken, "").Code)
+	assert.Equal(t, 200, doJSON(r, "GET", "/api/v1/admin/health", viewer.AccessToken, "").Code)
+	assert.Equal(t, 200, doJSON(r, "GET", "/api/v1/sleep-logs", viewer.AccessToken, "").Code)
+	w = doJSON(r, "POST", "/api/v1/sleep-logs", viewer.AccessToken, `{"start_time":"2025-07-16T22:00:00Z","end_time":"2025-07-17T06:00:00Z","quality":8}`)
+	assert.Equal(t, 403, w.Code)
+	w = doJSON(r, "POST", "/api/v1/goals", viewer.AccessToken, `{"type":"duration","value":"7h"}`)
+	assert.Equal(t, 403, w.Code)
+	w = doJSON(r, "POST", "/api/v1/admin/users/"+viewerAccount.ID+"/disable", viewer.AccessToken, "")
+	assert.Equal(t, 403, w.Code)
+
+	// Admins cannot lock themselves out.
+	adminAccount, err := app.UserRepo().GetUserByEmail(ctx, "admin@example.com")
+	require.NoError(t, err)
+	w = doJSON(r, "POST", "/api/v1/admin/users/"+adminAccount.ID+"/disable", admin.AccessToken, "")
+	assert.Equal(t, 409, w.Code)
+	w = doJSON(r, "POST", "/api/v1/admin/users/missing/disable", admin.AccessToken, "")
+	assert.Equal(t, 404, w.Code)
+}
+
+func TestDisabledUserIsLockedOut(t *testing.T) {
+	t.Parallel()
+	r, app := setupRouterAndStorage(t)
+	admin := registerAdmin(t, r, app, "admin@example.com")
+	user := registerAndLogin(t, r, "user@example.com")
+	account, err := app.UserRepo().GetUserByEmail(context.Background(), "user@example.com")
+	require.NoError(t, err)
+
+	w := doJSON(r, "POST", "/api/v1/admin/users/"+account.ID+"/disable", admin.AccessToken, "")
+	require.Equal(t, 200, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), "disabled_at")
+
+	// Existing tokens stop working and new logins are refused.
+	assert.Equal(t, 401, doJSON(r, "GET", "/api/v1/sleep-logs", user.AccessToken, "").Code)
+	w = doJSON(r, "POST", "/api/v1/auth/refresh", "", `{"refresh_token":"`+user.RefreshToken+`"}`)
+	assert.Equal(t, 401, w.Code)
+	w = doJSON(r, "POST", "/api/v1/auth/login", "", `{"email":"user@example.com","password":"correct horse"}`)
+	assert.Equal(t, 403, w.Code)
+
+	w = doJSON(r, "POST", "/api/v1/admin/users/"+account.ID+"/enable", admin.AccessToken, "")
+	require.Equal(t, 200, w.Code, w.Body.String())
+	user = login(t, r, "user@example.com")
+	assert.Equal(t, 200, doJSON(r, "GET", "/api/v1/sleep-logs", user.AccessToken, "").Code)
+}
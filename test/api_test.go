@@ -10,10 +10,14 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/yourname/sleeptracker/internal"
 	api "github.com/yourname/sleeptracker/internal/api"
 	"github.com/yourname/sleeptracker/internal/auth"
-	"github.com/yourname/sleeptracker/internal/config"
+	"github.com/yourname/sleeptracker/internal/backup"
+	"github.com/yourname/sleeptracker/internal/idempotency"
+	"github.com/yourname/sleeptracker/internal/openapi"
+	"github.com/yourname/sleeptracker/internal/service"
 	"github.com/yourname/sleeptracker/internal/storage"
 	"go.uber.org/zap"
 )
@@ -22,48 +26,74 @@ type TestApp struct {
 	logger    internal.Logger
 	sleepRepo storage.SleepLogRepository
 	goalRepo  storage.GoalRepository
+	aggRepo   storage.AggregateRepository
+	userRepo  storage.UserRepository
+	sessions  storage.SessionRepository
+	apiKeys   storage.APIKeyRepository
+	sharing   storage.SharingRepository
+	audit     storage.AuditRepository
+	health    storage.HealthChecker
+	routes    api.RouteConfig
 }
 
-func (a *TestApp) Logger() internal.Logger               { return a.logger }
-func (a *TestApp) SleepRepo() storage.SleepLogRepository { return a.sleepRepo }
-func (a *TestApp) GoalRepo() storage.GoalRepository      { return a.goalRepo }
+func (a *TestApp) Logger() internal.Logger                    { return a.logger }
+func (a *TestApp) SleepRepo() storage.SleepLogRepository      { return a.sleepRepo }
+func (a *TestApp) GoalRepo() storage.GoalRepository           { return a.goalRepo }
+func (a *TestApp) AggregateRepo() storage.AggregateRepository { return a.aggRepo }
+func (a *TestApp) UserRepo() storage.UserRepository           { return a.userRepo }
+func (a *TestApp) SessionRepo() storage.SessionRepository     { return a.sessions }
+func (a *TestApp) APIKeyRepo() storage.APIKeyRepository       { return a.apiKeys }
+func (a *TestApp) SharingRepo() storage.SharingRepository     { return a.sharing }
+func (a *TestApp) AuditRepo() storage.AuditRepository         { return a.audit }
+func (a *TestApp) Snapshotter() *backup.Snapshotter           { return nil }
+func (a *TestApp) StorageHealth() storage.HealthChecker       { return a.health }
+func (a *TestApp) RouteConfig() api.RouteConfig               { return a.routes }
 
-func setupRouterAndStorage(t *testing.T) (*gin.Engine, *TestApp) {
+func TestMain(m *testing.M) {
 	gin.SetMode(gin.TestMode)
-	testDir := "testdata"
-	if _, err := os.Stat(testDir); os.IsNotExist(err) {
-		_ = os.MkdirAll(testDir, 0755)
-	}
-	sleepFile := testDir + "/test_sleep_logs.json"
-	goalsFile := testDir + "/test_goals.json"
-	os.Remove(sleepFile)
-	os.Remove(goalsFile)
-	sleepRepo, goalRepo, err := storage.NewFileRepositories(sleepFile, goalsFile, internal.NewZapLogger(zap.NewNop().Sugar()))
-	assert.NoError(t, err)
+	os.Exit(m.Run())
+}
+
+func setupRouterAndStorage(t *testing.T) (*gin.Engine, *TestApp) {
 	logger := internal.NewZapLogger(zap.NewNop().Sugar())
+	mem := storage.NewMemoryStorage(logger)
 	app := &TestApp{
 		logger:    logger,
-		sleepRepo: sleepRepo,
-		goalRepo:  goalRepo,
+		sleepRepo: mem,
+		goalRepo:  mem,
+		aggRepo:   mem,
+		userRepo:  mem,
+		sessions:  mem,
+		apiKeys:   mem,
+		sharing:   mem,
+		audit:     mem,
+		health:    mem,
+	}
+	spec, err := openapi.NewValidator(logger)
+	require.NoError(t, err)
+	app.routes = api.RouteConfig{
+		TokenPolicy: service.TokenPolicy{AccessTTL: time.Hour, RefreshTTL: 24 * time.Hour},
+		Auth: auth.NewChainProvider(
+			auth.NewAPIKeyAuthProvider(mem, mem, logger),
+			auth.NewTokenAuthProvider(mem, mem, logger),
+			auth.NewLocalAuthProvider("MOCK-TOKEN", logger),
+		),
+		OpenAPI:     spec,
+		Idempotency: idempotency.NewMemoryStore(time.Hour),
 	}
-	cfg := &config.Config{Env: "development"}
-	r := gin.Default()
-	r.Use(auth.AuthMiddleware(auth.NewLocalAuthProvider("MOCK-TOKEN", logger), cfg))
-	r.POST("/sleep", api.PostSleep(app))
-	r.GET("/sleep", api.GetSleep(app))
-	r.GET("/sleep/stats", api.GetSleepStats(app))
-	r.GET("/sleep/recommendations", api.GetSleepRecommendations(app))
-	r.POST("/api/goals", api.PostGoal(app))
-	r.GET("/api/goals/progress", api.GetGoalProgress(app))
+	r := gin.New()
+	r.Use(api.RequestIDMiddleware())
+	api.RegisterRoutes(r, app)
 	return r, app
 }
 
 func TestPostGoal_ValidAndInvalid(t *testing.T) {
+	t.Parallel()
 	r, _ := setupRouterAndStorage(t)
 	ts := httptest.NewRecorder()
 	// Valid
 	body := `{"type":"duration","value":"7h"}`
-	req, _ := http.NewRequest("POST", "/api/goals", strings.NewReader(body))
+	req, _ := http.NewRequest("POST", "/api/v1/goals", strings.NewReader(body))
 	req.Header.Set("Authorization", "Bearer MOCK-TOKEN")
 	req.Header.Set("Content-Type", "application/json")
 	r.ServeHTTP(ts, req)
@@ -71,7 +101,7 @@ func TestPostGoal_ValidAndInvalid(t *testing.T) {
 	// Invalid: missing value
 	ts = httptest.NewRecorder()
 	body = `{"type":"duration"}`
-	req, _ = http.NewRequest("POST", "/api/goals", strings.NewReader(body))
+	req, _ = http.NewRequest("POST", "/api/v1/goals", strings.NewReader(body))
 	req.Header.Set("Authorization", "Bearer MOCK-TOKEN")
 	req.Header.Set("Content-Type", "application/json")
 	r.ServeHTTP(ts, req)
@@ -79,7 +109,7 @@ func TestPostGoal_ValidAndInvalid(t *testing.T) {
 	// Invalid: unsupported type
 	ts = httptest.NewRecorder()
 	body = `{"type":"banana","value":"7h"}`
-	req, _ = http.NewRequest("POST", "/api/goals", strings.NewReader(body))
+	req, _ = http.NewRequest("POST", "/api/v1/goals", strings.NewReader(body))
 	req.Header.Set("Authorization", "Bearer MOCK-TOKEN")
 	req.Header.Set("Content-Type", "application/json")
 	r.ServeHTTP(ts, req)
@@ -87,6 +117,7 @@ func TestPostGoal_ValidAndInvalid(t *testing.T) {
 }
 
 func TestPostSleep_ValidAndInvalid(t *testing.T) {
+	t.Parallel()
 	r, app := setupRouterAndStorage(t)
 	ts := httptest.NewRecorder()
 	// Valid
@@ -94,7 +125,7 @@ func TestPostSleep_ValidAndInvalid(t *testing.T) {
 	end := time.Now().Format(time.RFC3339)
 	body := `{"start_time":"` + start + `","end_time":"` + end + `","quality":7}`
 	app.logger.Infof("TestPostSleep_ValidAndInvalid valid request body: %s", body)
-	req, _ := http.NewRequest("POST", "/sleep", strings.NewReader(body))
+	req, _ := http.NewRequest("POST", "/api/v1/sleep-logs", strings.NewReader(body))
 	req.Header.Set("Authorization", "Bearer MOCK-TOKEN")
 	req.Header.Set("Content-Type", "application/json")
 	r.ServeHTTP(ts, req)
@@ -103,7 +134,7 @@ func TestPostSleep_ValidAndInvalid(t *testing.T) {
 	// Invalid: quality out of range
 	ts = httptest.NewRecorder()
 	body = `{"start_time":"` + start + `","end_time":"` + end + `","quality":99}`
-	req, _ = http.NewRequest("POST", "/sleep", strings.NewReader(body))
+	req, _ = http.NewRequest("POST", "/api/v1/sleep-logs", strings.NewReader(body))
 	req.Header.Set("Authorization", "Bearer MOCK-TOKEN")
 	req.Header.Set("Content-Type", "application/json")
 	r.ServeHTTP(ts, req)
@@ -111,7 +142,7 @@ func TestPostSleep_ValidAndInvalid(t *testing.T) {
 	// Invalid: missing start_time
 	ts = httptest.NewRecorder()
 	body = `{"end_time":"` + end + `","quality":7}`
-	req, _ = http.NewRequest("POST", "/sleep", strings.NewReader(body))
+	req, _ = http.NewRequest("POST", "/api/v1/sleep-logs", strings.NewReader(body))
 	req.Header.Set("Authorization", "Bearer MOCK-TOKEN")
 	req.Header.Set("Content-Type", "application/json")
 	r.ServeHTTP(ts, req)
@@ -119,20 +150,22 @@ func TestPostSleep_ValidAndInvalid(t *testing.T) {
 }
 
 func TestGetGoalProgress_NoGoal(t *testing.T) {
+	t.Parallel()
 	r, _ := setupRouterAndStorage(t)
 	ts := httptest.NewRecorder()
-	req, _ := http.NewRequest("GET", "/api/goals/progress", nil)
+	req, _ := http.NewRequest("GET", "/api/v1/goals/progress", nil)
 	req.Header.Set("Authorization", "Bearer MOCK-TOKEN")
 	r.ServeHTTP(ts, req)
 	assert.Equal(t, 404, ts.Code)
 }
 
 func TestSleepAPI(t *testing.T) {
+	t.Parallel()
 	r, app := setupRouterAndStorage(t)
 	w := httptest.NewRecorder()
 	jsonBody := `{"start_time":"2025-07-16T22:00:00Z","end_time":"2025-07-17T06:00:00Z","quality":8,"reason":"Felt rested","interruptions":["bathroom"]}`
 	app.logger.Infof("TestSleepAPI request body: %s", jsonBody)
-	req, _ := http.NewRequest("POST", "/sleep", strings.NewReader(jsonBody))
+	req, _ := http.NewRequest("POST", "/api/v1/sleep-logs", strings.NewReader(jsonBody))
 	req.Header.Set("Authorization", "Bearer MOCK-TOKEN")
 	req.Header.Set("Content-Type", "application/json")
 	r.ServeHTTP(w, req)
@@ -141,12 +174,75 @@ func TestSleepAPI(t *testing.T) {
 }
 
 func TestSleepAuthFail(t *testing.T) {
+	t.Parallel()
 	r, _ := setupRouterAndStorage(t)
 	w := httptest.NewRecorder()
-	req, _ := http.NewRequest("POST", "/sleep",
+	req, _ := http.NewRequest("POST", "/api/v1/sleep-logs",
 		strings.NewReader(`{"start_time":"2025-07-16T22:00:00Z","end_time":"2025-07-17T06:00:00Z","quality":8}`))
 	req.Header.Set("Authorization", "Bearer WRONG-TOKEN")
 	req.Header.Set("Content-Type", "application/json")
 	r.ServeHTTP(w, req)
 	assert.Equal(t, 401, w.Code)
 }
+
+func TestCreatedLocationAndConditionalGet(t *testing.T) {
+	t.Parallel()
+	r, _ := setupRouterAndStorage(t)
+	owner := registerAndLogin(t, r, "turing@example.com")
+	other := registerAndLogin(t, r, "church@example.com")
+
+	w := doJSON(r, "POST", "/api/v1/sleep-logs", owner.AccessToken, `{"start_time":"2025-07-16T22:00:00Z","end_time":"2025-07-17T06:00:00Z","quality":8}`)
+	require.Equal(t, 201, w.Code, w.Body.String())
+	location := w.Header().Get("Location")
+	require.True(t, strings.HasPrefix(location, "/api/v1/sleep-logs/"), location)
+
+	w = doJSON(r, "GET", location, owner.AccessToken, "")
+	require.Equal(t, 200, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), "2025-07-16T22:00:00Z")
+	assert.Equal(t, 404, doJSON(r, "GET", location, other.AccessToken, "").Code, "other users' logs are not found")
+	assert.Equal(t, 404, doJSON(r, "GET", "/api/v1/sleep-logs/missing", owner.AccessToken, "").Code)
+
+	// Reads carry an ETag; sending it back gets a 304 until the data changes.
+	w = doJSON(r, "GET", "/api/v1/sleep-logs", owner.AccessToken, "")
+	require.Equal(t, 200, w.Code)
+	etag := w.Header().Get("ETag")
+	require.NotEmpty(t, etag)
+	conditional := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/api/v1/sleep-logs", nil)
+		req.Header.Set("Authorization", "Bearer "+owner.AccessToken)
+		req.Header.Set("If-None-Match", `W/"stale", `+etag)
+		r.ServeHTTP(w, req)
+		return w
+	}
+	w = conditional()
+	assert.Equal(t, 304, w.Code)
+	assert.Empty(t, w.Body.String())
+	doJSON(r, "POST", "/api/v1/sleep-logs", owner.AccessToken, `{"start_time":"2025-07-17T22:00:00Z","end_time":"2025-07-18T06:00:00Z","quality":6}`)
+	assert.Equal(t, 200, conditional().Code)
+
+	w = doJSON(r, "POST", "/api/v1/goals", owner.AccessToken, `{"type":"duration","value":"7h"}`)
+	require.Equal(t, 201, w.Code, w.Body.String())
+	assert.Equal(t, "/api/v1/goals", w.Header().Get("Location"))
+	w = doJSON(r, "GET", "/api/v1/goals", owner.AccessToken, "")
+	require.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), `"value":"7h"`)
+	assert.Equal(t, 404, doJSON(r, "GET", "/api/v1/goals", other.AccessToken, "").Code)
+
+	w = doJSON(r, "POST", "/api/v1/shares", owner.AccessToken, `{"email":"church@example.com"}`)
+	require.Equal(t, 201, w.Code, w.Body.String())
+	location = w.Header().Get("Location")
+	require.True(t, strings.HasPrefix(location, "/api/v1/shares/"), location)
+	assert.Equal(t, 200, doJSON(r, "GET", location, owner.AccessToken, "").Code)
+	assert.Equal(t, 200, doJSON(r, "GET", location, other.AccessToken, "").Code, "the grantee can see the grant")
+	assert.Equal(t, 204, doJSON(r, "DELETE", location, owner.AccessToken, "").Code)
+
+	w = doJSON(r, "POST", "/api/v1/api-keys", owner.AccessToken, `{"name":"watch","scopes":["sleep:read"]}`)
+	require.Equal(t, 201, w.Code, w.Body.String())
+	location = w.Header().Get("Location")
+	require.True(t, strings.HasPrefix(location, "/api/v1/api-keys/"), location)
+	w = doJSON(r, "GET", location, owner.AccessToken, "")
+	require.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), `"name":"watch"`)
+	assert.Equal(t, 404, doJSON(r, "GET", location, other.AccessToken, "").Code)
+}
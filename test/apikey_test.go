@@ -0,0 +1,80 @@
+package test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIKeyLifecycleAndScopes(t *testing.T) {
+	t.Parallel()
+	r, _ := setupRouterAndStorage(t)
+	owner := registerAndLogin(t, r, "hopper@example.com")
+
+	w := doJSON(r, "POST", "/api/v1/api-keys", owner.AccessToken, `{"name":"home assistant","scopes":["sleep:read","sleep:write"]}`)
+	require.Equal(t, 201, w.Code, w.Body.String())
+	var created struct {
+		Data struct {
+			ID     string   `json:"id"`
+			Key    string   `json:"key"`
+			Prefix string   `json:"prefix"`
+			Scopes []string `json:"scopes"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	key := created.Data.Key
+	require.True(t, strings.HasPrefix(key, created.Data.Prefix))
+	assert.Equal(t, []string{"sleep:read", "sleep:write"}, created.Data.Scopes)
+
+	// The key works as a bearer token and in the X-API-Key header.
+	w = doJSON(r, "POST", "/api/v1/sleep-logs", key, `{"start_time":"2025-07-16T22:00:00Z","end_time":"2025-07-17T06:00:00Z","quality":8}`)
+	assert.Less(t, w.Code, 300, w.Body.String())
+	w = httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/v1/sleep-logs", nil)
+	req.Header.Set("X-API-Key", key)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), "2025-07-16T22:00:00Z")
+
+	// Routes outside the key's scopes are forbidden, as is key management.
+	assert.Equal(t, 403, doJSON(r, "GET", "/api/v1/sleep-stats", key, "").Code)
+	assert.Equal(t, 403, doJSON(r, "POST", "/api/v1/goals", key, `{"type":"duration","value":"7h"}`).Code)
+	assert.Equal(t, 403, doJSON(r, "POST", "/api/v1/api-keys", key, `{"name":"escalate","scopes":["sleep:read"]}`).Code)
+
+	w = doJSON(r, "GET", "/api/v1/api-keys", owner.AccessToken, "")
+	require.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), created.Data.Prefix)
+	assert.NotContains(t, w.Body.String(), key, "the full key is only shown once")
+	assert.NotContains(t, w.Body.String(), "hash")
+
+	other := registerAndLogin(t, r, "lovelace@example.com")
+	assert.Equal(t, 404, doJSON(r, "DELETE", "/api/v1/api-keys/"+created.Data.ID, other.AccessToken, "").Code)
+	assert.Equal(t, 204, doJSON(r, "DELETE", "/api/v1/api-keys/"+created.Data.ID, owner.AccessToken, "").Code)
+	assert.Equal(t, 401, doJSON(r, "GET", "/api/v1/sleep-logs", key, "").Code, "revoked keys stop working")
+}
+
+func TestAPIKeyValidation(t *testing.T) {
+	t.Parallel()
+	r, _ := setupRouterAndStorage(t)
+	owner := registerAndLogin(t, r, "turing@example.com")
+	for _, body := range []string{
+		`{"name":"no scopes","scopes":[]}`,
+		`{"name":"unknown scope","scopes":["sleep:delete"]}`,
+		`{"name":"account scope","scopes":["account"]}`,
+		`{"scopes":["sleep:read"]}`,
+	} {
+		assert.Equal(t, 400, doJSON(r, "POST", "/api/v1/api-keys", owner.AccessToken, body).Code, body)
+	}
+
+	// Only API keys are accepted in the X-API-Key header.
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/v1/sleep-logs", nil)
+	req.Header.Set("X-API-Key", owner.AccessToken)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, 401, w.Code)
+}
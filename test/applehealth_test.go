@@ -0,0 +1,78 @@
+package test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const appleHealthExport = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE HealthData [
+<!ELEMENT HealthData (ExportDate,Me,(Record|Workout)*)>
+<!ATTLIST HealthData locale CDATA #REQUIRED>
+]>
+<HealthData locale="en_US">
+ <ExportDate value="2025-07-20 09:00:00 +0200"/>
+ <Me HKCharacteristicTypeIdentifierDateOfBirth=""/>
+ <Record type="HKQuantityTypeIdentifierStepCount" sourceName="iPhone" unit="count" startDate="2025-07-01 18:00:00 +0200" endDate="2025-07-01 18:10:00 +0200" value="420"/>
+ <Record type="HKCategoryTypeIdentifierSleepAnalysis" sourceName="iPhone" startDate="2025-07-01 22:30:00 +0200" endDate="2025-07-02 07:00:00 +0200" value="HKCategoryValueSleepAnalysisInBed"/>
+ <Record type="HKCategoryTypeIdentifierSleepAnalysis" sourceName="Watch" startDate="2025-07-01 23:00:00 +0200" endDate="2025-07-02 02:00:00 +0200" value="HKCategoryValueSleepAnalysisAsleepCore">
+  <MetadataEntry key="HKTimeZone" value="Europe/Paris"/>
+ </Record>
+ <Record type="HKCategoryTypeIdentifierSleepAnalysis" sourceName="Watch" startDate="2025-07-02 02:00:00 +0200" endDate="2025-07-02 02:20:00 +0200" value="HKCategoryValueSleepAnalysisAwake"/>
+ <Record type="HKCategoryTypeIdentifierSleepAnalysis" sourceName="Watch" startDate="2025-07-02 02:20:00 +0200" endDate="2025-07-02 04:00:00 +0200" value="HKCategoryValueSleepAnalysisAsleepDeep"/>
+ <Record type="HKCategoryTypeIdentifierSleepAnalysis" sourceName="Watch" startDate="2025-07-02 04:01:00 +0200" endDate="2025-07-02 06:30:00 +0200" value="HKCategoryValueSleepAnalysisAsleepREM"/>
+ <Record type="HKCategoryTypeIdentifierSleepAnalysis" sourceName="iPhone" startDate="2025-07-02 23:00:00 +0200" endDate="2025-07-03 06:00:00 +0200" value="HKCategoryValueSleepAnalysisInBed"/>
+ <Record type="HKCategoryTypeIdentifierSleepAnalysis" sourceName="Watch" startDate="2025-07-03 14:00:00 +0200" endDate="2025-07-03 14:05:00 +0200" value="HKCategoryValueSleepAnalysisAwake"/>
+</HealthData>
+`
+
+func TestAppleHealthImport(t *testing.T) {
+	t.Parallel()
+	r, _ := setupRouterAndStorage(t)
+	token := registerAndLogin(t, r, "hopper@example.com").AccessToken
+	path := "/api/v1/sleep-logs/import/apple-health"
+
+	dry := decodeImport(t, postImport(r, path+"?dry_run=true", token, "application/xml", appleHealthExport))
+	assert.True(t, dry.DryRun)
+	assert.Equal(t, 2, dry.Rows, "awake-only nights are dropped")
+	assert.Equal(t, 2, dry.Valid)
+	assert.Zero(t, dry.Imported)
+	w := doJSON(r, "GET", "/api/v1/sleep-logs", token, "")
+	assert.NotContains(t, w.Body.String(), `"id"`)
+
+	result := decodeImport(t, postImport(r, path, token, "text/xml", appleHealthExport))
+	assert.Equal(t, 2, result.Imported)
+	assert.Empty(t, result.Errors)
+
+	w = doJSON(r, "GET", "/api/v1/sleep-logs", token, "")
+	require.Equal(t, 200, w.Code)
+	body := w.Body.String()
+	assert.Contains(t, body, `"start_time":"2025-07-01T23:00:00+02:00","end_time":"2025-07-02T06:30:00+02:00"`, "the night spans the asleep samples")
+	assert.Contains(t, body, `"interruptions":["awake 02:00-02:20 (20m)"]`, "gaps under two minutes are not interruptions")
+	assert.Contains(t, body, `"start_time":"2025-07-02T23:00:00+02:00","end_time":"2025-07-03T06:00:00+02:00"`, "in bed only spans the night")
+	assert.Contains(t, body, `"quality":8`, "asleep 84% of the time in bed")
+	assert.Contains(t, body, `"reason":"Imported from Apple Health"`)
+
+	assert.Contains(t, body, `"source":{"name":"apple-health","id":"2025-07-01T20:30:00Z"}`, "a night is identified by its first record")
+
+	// Uploading the same export again skips every night.
+	again := decodeImport(t, postImport(r, path, token, "application/xml", appleHealthExport))
+	assert.Zero(t, again.Imported)
+	assert.Zero(t, again.Updated)
+	assert.Equal(t, 2, again.Skipped)
+	assert.Empty(t, again.Errors)
+
+	w = doJSON(r, "GET", "/api/v1/audit-events?action=sleep.import", token, "")
+	require.Equal(t, 200, w.Code, w.Body.String())
+	assert.Equal(t, 1, strings.Count(w.Body.String(), `"source":"apple-health"`))
+
+	w = postImport(r, path, token, "application/json", appleHealthExport)
+	assert.Equal(t, 415, w.Code, w.Body.String())
+	w = postImport(r, path, token, "application/xml", `<Workouts/>`)
+	assert.Equal(t, 400, w.Code, w.Body.String())
+	w = postImport(r, path, token, "application/xml", `<HealthData><Record`)
+	assert.Equal(t, 400, w.Code, w.Body.String())
+}
@@ -0,0 +1,132 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yourname/sleeptracker/internal"
+)
+
+func auditEvents(t *testing.T, r *gin.Engine, path, token string) []internal.AuditEvent {
+	t.Helper()
+	w := doJSON(r, "GET", path, token, "")
+	require.Equal(t, 200, w.Code, w.Body.String())
+	var resp struct {
+		Data []internal.AuditEvent `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	return resp.Data
+}
+
+func actions(events []internal.AuditEvent) []string {
+	names := make([]string, len(events))
+	for i, e := range events {
+		names[i] = e.Action
+	}
+	return names
+}
+
+func TestAuditTrail(t *testing.T) {
+	t.Parallel()
+	r, app := setupRouterAndStorage(t)
+	ctx := context.Background()
+	admin := registerAdmin(t, r, app, "admin@example.com")
+	alice := registerAndLogin(t, r, "alice@example.com")
+	bob := registerAndLogin(t, r, "bob@example.com")
+	aliceAccount, err := app.UserRepo().GetUserByEmail(ctx, "alice@example.com")
+	require.NoError(t, err)
+	bobAccount, err := app.UserRepo().GetUserByEmail(ctx, "bob@example.com")
+	require.NoError(t, err)
+
+	w := doJSON(r, "POST", "/api/v1/auth/login", "", `{"email":"alice@example.com","password":"wrong password"}`)
+	require.Equal(t, 401, w.Code)
+	w = doJSON(r, "POST", "/api/v1/sleep-logs", alice.AccessToken, `{"start_time":"2025-07-16T22:00:00Z","end_time":"2025-07-17T06:00:00Z","quality":8}`)
+	require.Less(t, w.Code, 300, w.Body.String())
+	fromIP := func(method, path, token, body, ip string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.RemoteAddr = ip + ":1234"
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	w = fromIP("POST", "/api/v1/shares", alice.AccessToken, `{"email":"Bob@Example.com"}`, "198.51.100.4")
+	require.Equal(t, 201, w.Code, w.Body.String())
+	var grant struct {
+		Data struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &grant))
+	w = fromIP("POST", "/api/v1/shares/"+grant.Data.ID+"/accept", bob.AccessToken, "", "198.51.100.9")
+	require.Equal(t, 200, w.Code, w.Body.String())
+
+	req, _ := http.NewRequest("GET", "/api/v1/sleep-logs", nil)
+	req.Header.Set("Authorization", "Bearer stolen-token")
+	req.Header.Set("X-Request-ID", "req-failed-auth")
+	req.RemoteAddr = "203.0.113.7:4321"
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, 401, w.Code)
+
+	// Owners see what they did and what was done to their account, newest
+	// first, with the request it came from.
+	events := auditEvents(t, r, "/api/v1/audit-events", alice.AccessToken)
+	assert.Equal(t, []string{internal.AuditGrantAccept, internal.AuditGrantCreate, internal.AuditSleepCreate, internal.AuditLoginFailed, internal.AuditLogin, internal.AuditRegister}, actions(events))
+	assert.Equal(t, bobAccount.ID, events[0].ActorID)
+	assert.Empty(t, events[0].IP, "Alice does not learn Bob's IP")
+	assert.Equal(t, "198.51.100.4", events[1].IP, "owners see the IP of what they did")
+	assert.Empty(t, events[1].TargetUserID, "invitations name no account")
+	assert.Equal(t, map[string]string{"grantee_email": "bob@example.com"}, events[1].Details, "but record the normalized email")
+	assert.Equal(t, aliceAccount.ID, events[2].ActorID)
+	assert.NotEmpty(t, events[2].ResourceID)
+	assert.NotEmpty(t, events[2].RequestID)
+	assert.Empty(t, events[3].ActorID, "failed logins have no actor")
+	assert.Equal(t, aliceAccount.ID, events[3].TargetUserID)
+
+	events = auditEvents(t, r, "/api/v1/audit-events?action=sleep.create", alice.AccessToken)
+	assert.Equal(t, []string{internal.AuditSleepCreate}, actions(events))
+	assert.Len(t, auditEvents(t, r, "/api/v1/audit-events?limit=2", alice.AccessToken), 2)
+	assert.Equal(t, 400, doJSON(r, "GET", "/api/v1/audit-events?limit=5000", alice.AccessToken, "").Code)
+	assert.Equal(t, 400, doJSON(r, "GET", "/api/v1/audit-events?since=yesterday", alice.AccessToken, "").Code)
+
+	// Bob sees his acceptance of Alice's grant but none of her records, even
+	// when asking for them.
+	events = auditEvents(t, r, "/api/v1/audit-events?user_id="+aliceAccount.ID, bob.AccessToken)
+	assert.Equal(t, []string{internal.AuditGrantAccept, internal.AuditLogin, internal.AuditRegister}, actions(events))
+	assert.Equal(t, "198.51.100.9", events[0].IP)
+
+	// Admin actions on Bob's account show neither the admin nor their IP.
+	w = doJSON(r, "POST", "/api/v1/admin/users/"+bobAccount.ID+"/disable", admin.AccessToken, "")
+	require.Less(t, w.Code, 300, w.Body.String())
+	w = doJSON(r, "POST", "/api/v1/admin/users/"+bobAccount.ID+"/enable", admin.AccessToken, "")
+	require.Less(t, w.Code, 300, w.Body.String())
+	bob = login(t, r, "bob@example.com")
+	events = auditEvents(t, r, "/api/v1/audit-events?action=user.disable", bob.AccessToken)
+	require.Len(t, events, 1)
+	assert.Equal(t, bobAccount.ID, events[0].TargetUserID)
+	assert.Empty(t, events[0].ActorID)
+	assert.Empty(t, events[0].IP)
+
+	// Admins query the whole log, including failed authentication.
+	assert.Equal(t, 403, doJSON(r, "GET", "/api/v1/admin/audit-events", alice.AccessToken, "").Code)
+	events = auditEvents(t, r, "/api/v1/admin/audit-events?action=auth.failed", admin.AccessToken)
+	require.Len(t, events, 1)
+	assert.Equal(t, "req-failed-auth", events[0].RequestID)
+	assert.Equal(t, "203.0.113.7", events[0].IP)
+	assert.Equal(t, "/api/v1/sleep-logs", events[0].Details["path"])
+	assert.NotContains(t, doJSON(r, "GET", "/api/v1/admin/audit-events", admin.AccessToken, "").Body.String(), "stolen-token")
+	events = auditEvents(t, r, "/api/v1/admin/audit-events?user_id="+bobAccount.ID, admin.AccessToken)
+	assert.Equal(t, []string{internal.AuditLogin, internal.AuditUserEnable, internal.AuditUserDisable, internal.AuditGrantAccept, internal.AuditLogin, internal.AuditRegister}, actions(events))
+	assert.NotEmpty(t, events[2].ActorID, "admins see who acted")
+	events = auditEvents(t, r, "/api/v1/admin/audit-events?user_id="+aliceAccount.ID, admin.AccessToken)
+	assert.Equal(t, "198.51.100.9", events[0].IP)
+}
@@ -0,0 +1,189 @@
+package test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func doJSON(r *gin.Engine, method, path, token, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestRegisterLoginLogout(t *testing.T) {
+	t.Parallel()
+	r, _ := setupRouterAndStorage(t)
+
+	w := doJSON(r, "POST", "/api/v1/auth/register", "", `{"email":"Ada@Example.com","password":"correct horse","name":"Ada"}`)
+	require.Equal(t, 201, w.Code, w.Body.String())
+	assert.NotContains(t, w.Body.String(), "password")
+	var registered struct {
+		Data struct {
+			ID    string `json:"id"`
+			Email string `json:"email"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &registered))
+	assert.Equal(t, "ada@example.com", registered.Data.Email)
+
+	// Emails are unique regardless of case.
+	w = doJSON(r, "POST", "/api/v1/auth/register", "", `{"email":"ada@example.com","password":"another one"}`)
+	assert.Equal(t, 409, w.Code)
+
+	w = doJSON(r, "POST", "/api/v1/auth/login", "", `{"email":"ada@example.com","password":"wrong password"}`)
+	assert.Equal(t, 401, w.Code)
+	w = doJSON(r, "POST", "/api/v1/auth/login", "", `{"email":"nobody@example.com","password":"correct horse"}`)
+	assert.Equal(t, 401, w.Code)
+
+	w = doJSON(r, "POST", "/api/v1/auth/login", "", `{"email":"ada@example.com","password":"correct horse"}`)
+	require.Equal(t, 200, w.Code, w.Body.String())
+	var login struct {
+		Data struct {
+			AccessToken string `json:"access_token"`
+			TokenType   string `json:"token_type"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &login))
+	token := login.Data.AccessToken
+	require.NotEmpty(t, token)
+	assert.Equal(t, "Bearer", login.Data.TokenType)
+
+	// The issued token authenticates as the registered user.
+	w = doJSON(r, "POST", "/api/v1/sleep-logs", token, `{"start_time":"2025-07-16T22:00:00Z","end_time":"2025-07-17T06:00:00Z","quality":8}`)
+	require.Less(t, w.Code, 300, w.Body.String())
+	assert.Contains(t, w.Body.String(), registered.Data.ID)
+
+	w = doJSON(r, "POST", "/api/v1/auth/logout", token, "")
+	assert.Equal(t, 204, w.Code)
+	w = doJSON(r, "GET", "/api/v1/sleep-logs", token, "")
+	assert.Equal(t, 401, w.Code, "a logged out token is rejected")
+
+	// The fallback provider still accepts its own tokens.
+	w = doJSON(r, "GET", "/api/v1/sleep-logs", "MOCK-TOKEN", "")
+	assert.Equal(t, 200, w.Code)
+}
+
+func TestRegisterValidation(t *testing.T) {
+	t.Parallel()
+	r, _ := setupRouterAndStorage(t)
+	for _, body := range []string{
+		`{"email":"not-an-email","password":"long enough"}`,
+		`{"email":"a@example.com","password":"short"}`,
+		`{"email":"a@example.com"}`,
+		`not json`,
+	} {
+		w := doJSON(r, "POST", "/api/v1/auth/register", "", body)
+		assert.Equal(t, 400, w.Code, body)
+	}
+
+	// 30 euro signs are 30 characters but 90 bytes, more than bcrypt takes.
+	w := doJSON(r, "POST", "/api/v1/auth/register", "", `{"email":"a@example.com","password":"`+strings.Repeat("€", 30)+`"}`)
+	require.Equal(t, 400, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"field":"password"`)
+	assert.Contains(t, w.Body.String(), `"code":"max"`)
+}
+
+type tokenPair struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	SessionID    string `json:"session_id"`
+}
+
+func registerAndLogin(t *testing.T, r *gin.Engine, email string) tokenPair {
+	t.Helper()
+	w := doJSON(r, "POST", "/api/v1/auth/register", "", `{"email":"`+email+`","password":"correct horse"}`)
+	require.Equal(t, 201, w.Code, w.Body.String())
+	return login(t, r, email)
+}
+
+func login(t *testing.T, r *gin.Engine, email string) tokenPair {
+	t.Helper()
+	w := doJSON(r, "POST", "/api/v1/auth/login", "", `{"email":"`+email+`","password":"correct horse"}`)
+	require.Equal(t, 200, w.Code, w.Body.String())
+	return decodeTokens(t, w)
+}
+
+func decodeTokens(t *testing.T, w *httptest.ResponseRecorder) tokenPair {
+	t.Helper()
+	var resp struct {
+		Data tokenPair `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	return resp.Data
+}
+
+func TestRefreshRotationAndReuseDetection(t *testing.T) {
+	t.Parallel()
+	r, _ := setupRouterAndStorage(t)
+	first := registerAndLogin(t, r, "grace@example.com")
+	require.NotEmpty(t, first.RefreshToken)
+
+	w := doJSON(r, "POST", "/api/v1/auth/refresh", "", `{"refresh_token":"`+first.RefreshToken+`"}`)
+	require.Equal(t, 200, w.Code, w.Body.String())
+	second := decodeTokens(t, w)
+	assert.NotEqual(t, first.RefreshToken, second.RefreshToken, "refresh tokens rotate on every use")
+	assert.Equal(t, first.SessionID, second.SessionID)
+	assert.Equal(t, 200, doJSON(r, "GET", "/api/v1/sleep-logs", second.AccessToken, "").Code)
+
+	// Replaying the rotated token revokes the whole family...
+	w = doJSON(r, "POST", "/api/v1/auth/refresh", "", `{"refresh_token":"`+first.RefreshToken+`"}`)
+	assert.Equal(t, 401, w.Code)
+	// ...including the tokens issued by the legitimate refresh.
+	assert.Equal(t, 401, doJSON(r, "GET", "/api/v1/sleep-logs", second.AccessToken, "").Code)
+	w = doJSON(r, "POST", "/api/v1/auth/refresh", "", `{"refresh_token":"`+second.RefreshToken+`"}`)
+	assert.Equal(t, 401, w.Code)
+
+	w = doJSON(r, "POST", "/api/v1/auth/refresh", "", `{"refresh_token":"unknown"}`)
+	assert.Equal(t, 401, w.Code)
+}
+
+func TestListAndRevokeSessions(t *testing.T) {
+	t.Parallel()
+	r, _ := setupRouterAndStorage(t)
+	phone := registerAndLogin(t, r, "linus@example.com")
+	laptop := login(t, r, "linus@example.com")
+	other := registerAndLogin(t, r, "ken@example.com")
+
+	w := doJSON(r, "GET", "/api/v1/auth/sessions", laptop.AccessToken, "")
+	require.Equal(t, 200, w.Code)
+	var list struct {
+		Data []struct {
+			ID      string `json:"id"`
+			Current bool   `json:"current"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	require.Len(t, list.Data, 2)
+	for _, s := range list.Data {
+		assert.Equal(t, s.ID == laptop.SessionID, s.Current)
+	}
+
+	// Another user's session cannot be revoked.
+	w = doJSON(r, "DELETE", "/api/v1/auth/sessions/"+other.SessionID, laptop.AccessToken, "")
+	assert.Equal(t, 404, w.Code)
+	assert.Equal(t, 200, doJSON(r, "GET", "/api/v1/sleep-logs", other.AccessToken, "").Code)
+
+	w = doJSON(r, "DELETE", "/api/v1/auth/sessions/"+phone.SessionID, laptop.AccessToken, "")
+	assert.Equal(t, 204, w.Code)
+	assert.Equal(t, 401, doJSON(r, "GET", "/api/v1/sleep-logs", phone.AccessToken, "").Code, "revoked sessions lose access immediately")
+	w = doJSON(r, "POST", "/api/v1/auth/refresh", "", `{"refresh_token":"`+phone.RefreshToken+`"}`)
+	assert.Equal(t, 401, w.Code)
+
+	w = doJSON(r, "GET", "/api/v1/auth/sessions", laptop.AccessToken, "")
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	require.Len(t, list.Data, 1)
+	assert.Equal(t, laptop.SessionID, list.Data[0].ID)
+}
@@ -0,0 +1,149 @@
+package test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yourname/sleeptracker/internal"
+	"github.com/yourname/sleeptracker/internal/backup"
+	"github.com/yourname/sleeptracker/internal/storage"
+	"go.uber.org/zap"
+)
+
+func TestSnapshotAndRestore(t *testing.T) {
+	logger := internal.NewZapLogger(zap.NewNop().Sugar())
+	dir := t.TempDir()
+	sleepFile, goalsFile := filepath.Join(dir, "sleep_logs.json"), filepath.Join(dir, "goals.json")
+	ctx := context.Background()
+	start := time.Date(2025, 7, 16, 22, 0, 0, 0, time.UTC)
+
+	s, err := storage.NewFileStorage(sleepFile, goalsFile, logger)
+	require.NoError(t, err)
+	require.NoError(t, s.SaveSleepLog(ctx, &internal.SleepLog{ID: "l1", UserID: "u1", StartTime: start, EndTime: start.Add(8 * time.Hour), Quality: 8}))
+
+	snapshotter := backup.NewSnapshotter(s, backup.Config{Dir: filepath.Join(dir, "backups"), KeepHourly: 2, KeepDaily: 2}, logger)
+	snap, err := snapshotter.Snapshot()
+	require.NoError(t, err)
+	assert.FileExists(t, snap.Path)
+	snapshots, err := snapshotter.List()
+	require.NoError(t, err)
+	require.Len(t, snapshots, 1)
+	assert.Equal(t, snap.Name, snapshots[0].Name)
+
+	// A bad bulk change after the snapshot...
+	require.NoError(t, s.SaveSleepLog(ctx, &internal.SleepLog{ID: "l2", UserID: "u1", StartTime: start.AddDate(0, 0, 1), EndTime: start.AddDate(0, 0, 1).Add(time.Hour), Quality: 1}))
+	require.NoError(t, s.AppendAuditEvent(ctx, &internal.AuditEvent{ID: "e1", At: time.Now(), Action: internal.AuditSleepCreate, ActorID: "u1", TargetUserID: "u1", ResourceID: "l2"}))
+	require.NoError(t, s.Close())
+
+	// ...is undone by restoring it.
+	validated := false
+	err = backup.Restore(snap.Path, storage.FileDataFiles(sleepFile, goalsFile), func(staging string) error {
+		validated = true
+		_, err := os.Stat(filepath.Join(staging, "sleep_logs.json"))
+		return err
+	})
+	require.NoError(t, err)
+	assert.True(t, validated)
+	assert.FileExists(t, sleepFile+".pre-restore")
+
+	restored, err := storage.NewFileStorage(sleepFile, goalsFile, logger)
+	require.NoError(t, err)
+	defer restored.Close()
+	logs, err := restored.ListSleepLogs(ctx, "u1")
+	require.NoError(t, err)
+	require.Len(t, logs, 1)
+	assert.Equal(t, "l1", logs[0].ID)
+
+	// The audit trail is never rolled back.
+	events, err := restored.ListAuditEvents(ctx, storage.AuditFilter{UserID: "u1", Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, "l2", events[0].ResourceID)
+}
+
+func TestRestoreRollsBackOnFailedSwap(t *testing.T) {
+	logger := internal.NewZapLogger(zap.NewNop().Sugar())
+	dir := t.TempDir()
+	sleepFile, goalsFile := filepath.Join(dir, "sleep_logs.json"), filepath.Join(dir, "goals.json")
+	ctx := context.Background()
+	start := time.Date(2025, 7, 16, 22, 0, 0, 0, time.UTC)
+
+	s, err := storage.NewFileStorage(sleepFile, goalsFile, logger)
+	require.NoError(t, err)
+	require.NoError(t, s.SaveSleepLog(ctx, &internal.SleepLog{ID: "l1", UserID: "u1", StartTime: start, EndTime: start.Add(8 * time.Hour), Quality: 8}))
+	snap, err := backup.NewSnapshotter(s, backup.Config{Dir: filepath.Join(dir, "backups")}, logger).Snapshot()
+	require.NoError(t, err)
+	require.NoError(t, s.SaveSleepLog(ctx, &internal.SleepLog{ID: "l2", UserID: "u1", StartTime: start.AddDate(0, 0, 1), EndTime: start.AddDate(0, 0, 1).Add(time.Hour), Quality: 1}))
+	require.NoError(t, s.Close())
+
+	// A directory where the third target would be set aside makes the swap
+	// fail after the first two files were replaced.
+	targets := storage.FileDataFiles(sleepFile, goalsFile)
+	require.FileExists(t, targets[2])
+	blocker := targets[2] + ".pre-restore"
+	require.NoError(t, os.MkdirAll(filepath.Join(blocker, "busy"), 0o755))
+	before, err := os.ReadFile(sleepFile)
+	require.NoError(t, err)
+
+	assert.Error(t, backup.Restore(snap.Path, targets, nil))
+	after, err := os.ReadFile(sleepFile)
+	require.NoError(t, err)
+	assert.Equal(t, string(before), string(after), "the replaced files are put back")
+	assert.NoFileExists(t, sleepFile+".pre-restore")
+	assert.FileExists(t, targets[2])
+
+	reopened, err := storage.NewFileStorage(sleepFile, goalsFile, logger)
+	require.NoError(t, err)
+	defer reopened.Close()
+	logs, err := reopened.ListSleepLogs(ctx, "u1")
+	require.NoError(t, err)
+	assert.Len(t, logs, 2)
+}
+
+func TestRestoreRejectsInvalidSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "sleep_logs.json")
+	require.NoError(t, os.WriteFile(target, []byte("[]"), 0o644))
+
+	bogus := filepath.Join(dir, "snapshot-20250101T000000Z.tar.gz")
+	require.NoError(t, os.WriteFile(bogus, []byte("not a gzip stream"), 0o644))
+	assert.Error(t, backup.Restore(bogus, []string{target}, nil))
+
+	// The live file is untouched when validation fails.
+	data, err := os.ReadFile(target)
+	require.NoError(t, err)
+	assert.Equal(t, "[]", string(data))
+	assert.NoFileExists(t, target+".pre-restore")
+}
+
+func TestSnapshotRetention(t *testing.T) {
+	base := time.Date(2025, 7, 20, 12, 30, 0, 0, time.UTC)
+	var snapshots []backup.Snapshot
+	// Newest first: two per hour for the last 3 hours, then one per day for 3 days.
+	for h := 0; h < 3; h++ {
+		for _, m := range []int{0, 20} {
+			at := base.Add(-time.Duration(h)*time.Hour - time.Duration(m)*time.Minute)
+			snapshots = append(snapshots, backup.Snapshot{Name: at.Format(time.RFC3339), CreatedAt: at})
+		}
+	}
+	for d := 1; d <= 3; d++ {
+		at := base.AddDate(0, 0, -d)
+		snapshots = append(snapshots, backup.Snapshot{Name: at.Format(time.RFC3339), CreatedAt: at})
+	}
+
+	expired := backup.Expired(snapshots, 2, 2)
+	names := make([]string, len(expired))
+	for i, e := range expired {
+		names[i] = e.Name
+	}
+	// Kept: newest of hours 12 and 11 (hourly), plus newest of 07-19 (daily).
+	assert.ElementsMatch(t, []string{
+		snapshots[1].Name, snapshots[3].Name, snapshots[4].Name, snapshots[5].Name,
+		snapshots[7].Name, snapshots[8].Name,
+	}, names)
+}
@@ -0,0 +1,114 @@
+package test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yourname/sleeptracker/internal"
+	"github.com/yourname/sleeptracker/internal/cache"
+	"github.com/yourname/sleeptracker/internal/service"
+	"github.com/yourname/sleeptracker/internal/storage"
+	"github.com/yourname/sleeptracker/internal/storage/storagetest"
+	"go.uber.org/zap"
+)
+
+func TestLRUEvictsLeastRecentlyUsed(t *testing.T) {
+	c := cache.New[string, int](2, time.Minute)
+	c.Set("a", 1)
+	c.Set("b", 2)
+	_, _ = c.Get("a") // "b" is now the least recently used
+	c.Set("c", 3)
+
+	_, ok := c.Get("b")
+	assert.False(t, ok)
+	v, ok := c.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, 1, v)
+
+	stats := c.Stats()
+	assert.Equal(t, uint64(1), stats.Evictions)
+	assert.Equal(t, 2, stats.Size)
+	assert.Equal(t, uint64(2), stats.Hits)
+	assert.Equal(t, uint64(1), stats.Misses)
+}
+
+func TestLRUExpiresEntries(t *testing.T) {
+	c := cache.New[string, int](10, 10*time.Millisecond)
+	c.Set("a", 1)
+	time.Sleep(20 * time.Millisecond)
+	_, ok := c.Get("a")
+	assert.False(t, ok)
+	assert.Equal(t, 0, c.Len())
+}
+
+func TestCachedSleepLogRepository(t *testing.T) {
+	ctx := context.Background()
+	mem := storage.NewMemoryStorage(internal.NewZapLogger(zap.NewNop().Sugar()))
+	repo := storage.NewCachedSleepLogRepository(mem, 10, time.Minute)
+	start := time.Now().Add(-8 * time.Hour)
+
+	assert.NoError(t, repo.SaveSleepLog(ctx, &internal.SleepLog{ID: "l1", UserID: "u1", StartTime: start, EndTime: start.Add(7 * time.Hour), Quality: 6}))
+
+	logs, err := repo.ListSleepLogs(ctx, "u1")
+	assert.NoError(t, err)
+	assert.Len(t, logs, 1)
+	// Mutating the result must not leak into the cache.
+	logs[0].Quality = 1
+	logs, err = repo.ListSleepLogs(ctx, "u1")
+	assert.NoError(t, err)
+	assert.Equal(t, 6, logs[0].Quality)
+	assert.Equal(t, uint64(1), repo.CacheStats().SleepLogs.Hits)
+	assert.Equal(t, uint64(1), repo.CacheStats().SleepLogs.Misses)
+
+	stats, err := service.GetSleepStats(ctx, repo, mem, "u1")
+	assert.NoError(t, err)
+	assert.Equal(t, 6.0, stats.AverageQuality)
+	_, err = service.GetSleepStats(ctx, repo, mem, "u1")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), repo.CacheStats().SleepStats.Hits)
+
+	// Saving through the decorator invalidates both logs and stats.
+	assert.NoError(t, repo.SaveSleepLog(ctx, &internal.SleepLog{ID: "l2", UserID: "u1", StartTime: start.Add(time.Hour), EndTime: start.Add(8 * time.Hour), Quality: 8}))
+	logs, err = repo.ListSleepLogs(ctx, "u1")
+	assert.NoError(t, err)
+	assert.Len(t, logs, 2)
+	stats, err = service.GetSleepStats(ctx, repo, mem, "u1")
+	assert.NoError(t, err)
+	assert.Equal(t, 7.0, stats.AverageQuality)
+}
+
+func TestCachedStatsRacingASaveAreNotKept(t *testing.T) {
+	ctx := context.Background()
+	mem := storage.NewMemoryStorage(internal.NewZapLogger(zap.NewNop().Sugar()))
+	repo := storage.NewCachedSleepLogRepository(mem, 10, time.Minute)
+	start := time.Now().Add(-8 * time.Hour)
+
+	// A save lands while the stats are computed, so they are already stale.
+	_, err := repo.SleepStats("u1", func() (*internal.SleepStats, error) {
+		err := repo.SaveSleepLog(ctx, &internal.SleepLog{ID: "l1", UserID: "u1", StartTime: start, EndTime: start.Add(7 * time.Hour), Quality: 6})
+		return &internal.SleepStats{}, err
+	})
+	assert.NoError(t, err)
+	assert.Zero(t, repo.CacheStats().SleepStats.Size)
+
+	_, err = repo.SleepStats("u1", func() (*internal.SleepStats, error) { return &internal.SleepStats{AverageQuality: 6}, nil })
+	assert.NoError(t, err)
+	stats, err := repo.SleepStats("u1", func() (*internal.SleepStats, error) { return nil, assert.AnError })
+	assert.NoError(t, err)
+	assert.Equal(t, 6.0, stats.AverageQuality, "reads with no save in between are kept")
+}
+
+func TestCachedSleepLogRepositoryConformance(t *testing.T) {
+	logger := internal.NewZapLogger(zap.NewNop().Sugar())
+	storagetest.Run(t, func(t *testing.T) storagetest.Backend {
+		mem := storage.NewMemoryStorage(logger)
+		cached := storage.NewCachedSleepLogRepository(mem, 100, time.Minute)
+		return storagetest.Backend{
+			Open: func(t *testing.T) storagetest.Repositories {
+				return storagetest.Repositories{Sleep: cached, Goals: mem}
+			},
+		}
+	})
+}
@@ -0,0 +1,141 @@
+package test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/stretchr/testify/require"
+	"github.com/yourname/sleeptracker/internal/openapi"
+	"github.com/yourname/sleeptracker/pkg/client"
+)
+
+// clientOperations maps each client method to the operation it calls: the
+// JSON body it sends, if any, and the member of the response it decodes.
+var clientOperations = map[string]struct {
+	method, path string
+	request      any
+	member       string // "data" or "meta"
+	response     any
+}{
+	"CreateSleepLog":    {"POST", "/sleep-logs", client.SleepLogInput{}, "data", client.SleepLog{}},
+	"GetSleepLog":       {"GET", "/sleep-logs/{id}", nil, "data", client.SleepLog{}},
+	"ListSleepLogs":     {"GET", "/sleep-logs", nil, "data", []client.SleepLog{}},
+	"GetSleepStats":     {"GET", "/sleep-stats", nil, "meta", client.SleepStats{}},
+	"GetRecommendation": {"GET", "/sleep-recommendations", nil, "meta", client.Recommendation{}},
+	"SetGoal":           {"POST", "/goals", client.GoalInput{}, "data", client.Goal{}},
+	"GetGoal":           {"GET", "/goals", nil, "data", client.Goal{}},
+	"GetGoalProgress":   {"GET", "/goals/progress", nil, "data", client.GoalProgress{}},
+}
+
+// The client is written by hand, so this holds its types to the OpenAPI
+// document: a field added to, renamed in or dropped from a schema, or a
+// change of type, fails here until the client follows.
+func TestClientMatchesSpec(t *testing.T) {
+	t.Parallel()
+	loader := openapi3.NewLoader()
+	doc, err := loader.LoadFromData(openapi.Spec)
+	require.NoError(t, err)
+
+	methods := reflect.TypeOf(&client.Client{})
+	for i := range methods.NumMethod() {
+		name := methods.Method(i).Name
+		if name == "SleepLogs" { // pages through ListSleepLogs
+			continue
+		}
+		if _, ok := clientOperations[name]; !ok {
+			t.Errorf("client method %s is not listed in clientOperations", name)
+		}
+	}
+
+	for name, op := range clientOperations {
+		item := doc.Paths.Value(op.path)
+		require.NotNil(t, item, "%s: no path %s", name, op.path)
+		operation := item.GetOperation(op.method)
+		require.NotNil(t, operation, "%s: no operation %s %s", name, op.method, op.path)
+
+		if op.request != nil {
+			require.NotNil(t, operation.RequestBody, "%s: no request body", name)
+			media := operation.RequestBody.Value.Content.Get("application/json")
+			require.NotNil(t, media, "%s: no JSON request body", name)
+			compareSchema(t, name+" request", reflect.TypeOf(op.request), media.Schema.Value, true)
+		}
+
+		var body *openapi3.Schema
+		for _, status := range []int{200, 201} {
+			if resp := operation.Responses.Status(status); resp != nil {
+				if media := resp.Value.Content.Get("application/json"); media != nil {
+					body = media.Schema.Value
+					break
+				}
+			}
+		}
+		require.NotNil(t, body, "%s: no JSON success response", name)
+		member := body.Properties[op.member]
+		require.NotNil(t, member, "%s: response has no %s", name, op.member)
+		compareSchema(t, name+" response", reflect.TypeOf(op.response), member.Value, false)
+	}
+}
+
+// compareSchema reports where typ and schema disagree. Request fields the
+// spec requires must always be sent, and optional ones left out when empty.
+func compareSchema(t *testing.T, at string, typ reflect.Type, schema *openapi3.Schema, request bool) {
+	t.Helper()
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	want := map[reflect.Kind]string{
+		reflect.String: "string", reflect.Bool: "boolean", reflect.Int: "integer", reflect.Int64: "integer",
+		reflect.Float64: "number", reflect.Slice: "array", reflect.Struct: "object", reflect.Map: "object",
+	}[typ.Kind()]
+	if typ == reflect.TypeOf(time.Time{}) {
+		want = "string"
+		if schema.Format != "date-time" {
+			t.Errorf("%s: time.Time for a string of format %q", at, schema.Format)
+		}
+	}
+	if want == "" || !schema.Type.Is(want) {
+		t.Errorf("%s: %s for a schema of type %v", at, typ, schema.Type.Slice())
+		return
+	}
+
+	switch {
+	case typ.Kind() == reflect.Slice:
+		compareSchema(t, at+"[]", typ.Elem(), schema.Items.Value, request)
+	case typ.Kind() == reflect.Map:
+		if schema.AdditionalProperties.Has == nil || !*schema.AdditionalProperties.Has {
+			t.Errorf("%s: map for an object without additionalProperties", at)
+		}
+	case typ.Kind() == reflect.Struct && want == "object":
+		required := make(map[string]bool)
+		for _, name := range schema.Required {
+			required[name] = true
+		}
+		seen := make(map[string]bool)
+		for i := range typ.NumField() {
+			name, opts, _ := strings.Cut(typ.Field(i).Tag.Get("json"), ",")
+			if name == "" || name == "-" {
+				continue
+			}
+			seen[name] = true
+			property := schema.Properties[name]
+			if property == nil {
+				t.Errorf("%s: field %s is not in the spec", at, name)
+				continue
+			}
+			if omitempty := strings.Contains(opts, "omitempty"); request && required[name] && omitempty {
+				t.Errorf("%s: required field %s is omitempty", at, name)
+			} else if request && !required[name] && !omitempty {
+				t.Errorf("%s: optional field %s is sent when empty", at, name)
+			}
+			compareSchema(t, at+"."+name, typ.Field(i).Type, property.Value, request)
+		}
+		for name := range schema.Properties {
+			if !seen[name] {
+				t.Errorf("%s: spec property %s has no field", at, name)
+			}
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yourname/sleeptracker/pkg/client"
+)
+
+func newClientServer(t *testing.T, h http.Handler) string {
+	t.Helper()
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+func TestClientSleepLogsAndGoals(t *testing.T) {
+	t.Parallel()
+	r, _ := setupRouterAndStorage(t)
+	url := newClientServer(t, r)
+	tokens := registerAndLogin(t, r, "hopper@example.com")
+	c := client.New(url, client.WithToken(tokens.AccessToken))
+	ctx := context.Background()
+
+	night := time.Now().UTC().Truncate(time.Hour).Add(-48 * time.Hour)
+	var created []string
+	for i := range 5 {
+		start := night.Add(time.Duration(i) * time.Hour)
+		log, err := c.CreateSleepLog(ctx, client.SleepLogInput{
+			StartTime: start, EndTime: start.Add(8 * time.Hour), Quality: 6 + i%3,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 6+i%3, log.Quality)
+		created = append(created, log.ID)
+	}
+
+	got, err := c.GetSleepLog(ctx, created[0])
+	require.NoError(t, err)
+	assert.True(t, night.Equal(got.StartTime))
+
+	page, err := c.ListSleepLogs(ctx, client.ListSleepLogsOptions{Limit: 2})
+	require.NoError(t, err)
+	require.Len(t, page.Logs, 2)
+	assert.Equal(t, created[4], page.Logs[0].ID, "newest first")
+	assert.NotEmpty(t, page.NextCursor)
+
+	var seen []string
+	for log, err := range c.SleepLogs(ctx, client.ListSleepLogsOptions{Limit: 2}) {
+		require.NoError(t, err)
+		seen = append(seen, log.ID)
+	}
+	assert.ElementsMatch(t, created, seen, "the iterator walks every page")
+
+	stats, err := c.GetSleepStats(ctx)
+	require.NoError(t, err)
+	assert.InDelta(t, 6.8, stats.AverageQuality, 0.01)
+
+	rec, err := c.GetRecommendation(ctx)
+	require.NoError(t, err)
+	assert.NotEmpty(t, rec.Recommendation)
+
+	_, err = c.GetGoal(ctx)
+	assert.ErrorIs(t, err, client.ErrNotFound)
+	goal, err := c.SetGoal(ctx, client.GoalInput{Type: client.GoalDuration, Value: "7h"})
+	require.NoError(t, err)
+	assert.Equal(t, "7h", goal.Value)
+	progress, err := c.GetGoalProgress(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, goal.ID, progress.Goal.ID)
+	assert.NotEmpty(t, progress.Progress)
+}
+
+func TestClientErrors(t *testing.T) {
+	t.Parallel()
+	r, _ := setupRouterAndStorage(t)
+	url := newClientServer(t, r)
+	tokens := registerAndLogin(t, r, "lovelace@example.com")
+	c := client.New(url, client.WithToken(tokens.AccessToken))
+	ctx := context.Background()
+
+	start := time.Now().UTC().Add(-10 * time.Hour)
+	_, err := c.CreateSleepLog(ctx, client.SleepLogInput{StartTime: start, EndTime: start.Add(8 * time.Hour), Quality: 11})
+	require.ErrorIs(t, err, client.ErrValidation)
+	var apiErr *client.Error
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusBadRequest, apiErr.StatusCode)
+	require.Len(t, apiErr.Fields, 1)
+	assert.Equal(t, "quality", apiErr.Fields[0].Field)
+	assert.Equal(t, "max", apiErr.Fields[0].Code)
+	assert.NotEmpty(t, apiErr.RequestID)
+
+	_, err = c.GetSleepLog(ctx, "missing")
+	assert.ErrorIs(t, err, client.ErrNotFound)
+
+	_, err = client.New(url).ListSleepLogs(ctx, client.ListSleepLogsOptions{})
+	assert.ErrorIs(t, err, client.ErrUnauthorized)
+}
+
+// flakyProxy passes the first create through to the router but tells the
+// client it failed, as if the connection dropped after the server acted.
+type flakyProxy struct {
+	http.Handler
+	mu   sync.Mutex
+	keys []string
+}
+
+func (p *flakyProxy) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		p.Handler.ServeHTTP(w, req)
+		return
+	}
+	p.mu.Lock()
+	p.keys = append(p.keys, req.Header.Get("Idempotency-Key"))
+	first := len(p.keys) == 1
+	p.mu.Unlock()
+	if first {
+		p.Handler.ServeHTTP(httptest.NewRecorder(), req)
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	p.Handler.ServeHTTP(w, req)
+}
+
+func TestClientRetriesCreatesOnce(t *testing.T) {
+	t.Parallel()
+	r, _ := setupRouterAndStorage(t)
+	proxy := &flakyProxy{Handler: r}
+	url := newClientServer(t, proxy)
+	tokens := registerAndLogin(t, r, "hamilton@example.com")
+	c := client.New(url, client.WithToken(tokens.AccessToken), client.WithRetries(2, time.Millisecond))
+	ctx := context.Background()
+
+	start := time.Now().UTC().Add(-10 * time.Hour)
+	log, err := c.CreateSleepLog(ctx, client.SleepLogInput{StartTime: start, EndTime: start.Add(8 * time.Hour), Quality: 7})
+	require.NoError(t, err)
+	require.Len(t, proxy.keys, 2)
+	assert.NotEmpty(t, proxy.keys[0])
+	assert.Equal(t, proxy.keys[0], proxy.keys[1], "retries reuse the idempotency key")
+
+	page, err := c.ListSleepLogs(ctx, client.ListSleepLogsOptions{})
+	require.NoError(t, err)
+	require.Len(t, page.Logs, 1, "the retry replayed the first create")
+	assert.Equal(t, log.ID, page.Logs[0].ID)
+
+	// Reusing a key for a different request is a conflict.
+	ctx = client.WithIdempotencyKey(ctx, proxy.keys[0])
+	_, err = c.CreateSleepLog(ctx, client.SleepLogInput{StartTime: start, EndTime: start.Add(8 * time.Hour), Quality: 3})
+	assert.ErrorIs(t, err, client.ErrConflict)
+}
+
+// slowProxy carries out the first create but answers it as if a retry had
+// found it still in progress.
+type slowProxy struct {
+	http.Handler
+	mu    sync.Mutex
+	posts int
+}
+
+func (p *slowProxy) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	p.mu.Lock()
+	p.posts++
+	first := req.Method == http.MethodPost && p.posts == 1
+	p.mu.Unlock()
+	if first {
+		p.Handler.ServeHTTP(httptest.NewRecorder(), req)
+		w.Header().Set("Retry-After", "0")
+		w.WriteHeader(http.StatusConflict)
+		return
+	}
+	p.Handler.ServeHTTP(w, req)
+}
+
+func TestClientRetriesInProgressConflicts(t *testing.T) {
+	t.Parallel()
+	r, _ := setupRouterAndStorage(t)
+	url := newClientServer(t, &slowProxy{Handler: r})
+	tokens := registerAndLogin(t, r, "lovelace@example.com")
+	c := client.New(url, client.WithToken(tokens.AccessToken), client.WithRetries(2, time.Millisecond))
+	ctx := context.Background()
+
+	start := time.Now().UTC().Add(-10 * time.Hour)
+	log, err := c.CreateSleepLog(ctx, client.SleepLogInput{StartTime: start, EndTime: start.Add(8 * time.Hour), Quality: 7})
+	require.NoError(t, err, "the retry gets the response of the create it waited for")
+	page, err := c.ListSleepLogs(ctx, client.ListSleepLogsOptions{})
+	require.NoError(t, err)
+	require.Len(t, page.Logs, 1)
+	assert.Equal(t, log.ID, page.Logs[0].ID)
+}
@@ -0,0 +1,161 @@
+package test
+
+import (
+	"encoding/csv"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func getCSV(r http.Handler, path, token, accept string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", path, nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestSleepLogsCSV(t *testing.T) {
+	t.Parallel()
+	r, _ := setupRouterAndStorage(t)
+	token := registerAndLogin(t, r, "knuth@example.com").AccessToken
+	w := doJSON(r, "POST", "/api/v1/sleep-logs", token, `{"start_time":"2025-07-16T22:00:00Z","end_time":"2025-07-17T05:30:00Z","quality":8,"reason":"Late, \"loud\" party","interruptions":["bathroom","noise, outside"]}`)
+	require.Equal(t, 201, w.Code, w.Body.String())
+	w = doJSON(r, "POST", "/api/v1/sleep-logs", token, `{"start_time":"2025-07-15T22:00:00Z","end_time":"2025-07-16T06:00:00Z","quality":6}`)
+	require.Equal(t, 201, w.Code, w.Body.String())
+
+	w = getCSV(r, "/api/v1/sleep-logs?tz=America/New_York", token, "text/csv")
+	require.Equal(t, 200, w.Code, w.Body.String())
+	assert.Equal(t, "text/csv; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Header().Get("Content-Disposition"), `filename="sleep-logs.csv"`)
+	assert.Equal(t, "Accept", w.Header().Get("Vary"))
+	assert.Contains(t, w.Body.String(), "\r\n", "rows end in CRLF")
+
+	rows, err := csv.NewReader(strings.NewReader(w.Body.String())).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, rows, 3)
+	assert.Equal(t, []string{"id", "start_time", "end_time", "duration_hours", "quality", "reason", "interruptions"}, rows[0])
+	assert.Equal(t, []string{"2025-07-16T18:00:00-04:00", "2025-07-17T01:30:00-04:00", "7.50", "8", `Late, "loud" party`, "bathroom; noise, outside"}, rows[1][1:])
+	assert.Equal(t, "2025-07-15T18:00:00-04:00", rows[2][1], "newest first")
+	assert.Empty(t, rows[2][6])
+
+	// ?format= overrides Accept either way.
+	w = getCSV(r, "/api/v1/sleep-logs?format=csv", token, "")
+	require.Equal(t, 200, w.Code)
+	assert.True(t, strings.HasPrefix(w.Body.String(), "id,start_time"))
+	assert.Contains(t, w.Body.String(), "2025-07-16T22:00:00Z", "UTC by default")
+	w = getCSV(r, "/api/v1/sleep-logs?format=json", token, "text/csv")
+	require.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "application/json")
+
+	// Pages link to the next one.
+	w = getCSV(r, "/api/v1/sleep-logs?limit=1", token, "text/csv")
+	require.Equal(t, 200, w.Code)
+	link := w.Header().Get("Link")
+	require.True(t, strings.HasPrefix(link, "</api/v1/sleep-logs?"), link)
+	next := strings.TrimPrefix(strings.Split(link, ">")[0], "<")
+	w = getCSV(r, next, token, "text/csv")
+	require.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), "2025-07-15T22:00:00Z")
+	assert.Empty(t, w.Header().Get("Link"))
+
+	// Without a limit, the rest of the history follows the cursor.
+	w = getCSV(r, strings.Replace(next, "limit=1", "format=csv", 1), token, "")
+	require.Equal(t, 200, w.Code, w.Body.String())
+	rows, err = csv.NewReader(strings.NewReader(w.Body.String())).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+	assert.Equal(t, "2025-07-15T22:00:00Z", rows[1][1])
+	w = doProblem(r, "GET", "/api/v1/sleep-logs?format=csv&cursor=bogus", token, "")
+	assert.Equal(t, 400, w.Code, "the cursor is checked before the export starts")
+
+	w = doProblem(r, "GET", "/api/v1/sleep-logs?format=csv&tz=Mars/Olympus", token, "")
+	require.Equal(t, 400, w.Code, w.Body.String())
+	params := decodeProblem(t, w).InvalidParams
+	require.Len(t, params, 1)
+	assert.Equal(t, "tz", params[0].Field)
+	assert.Equal(t, "invalid", params[0].Code)
+	w = doProblem(r, "GET", "/api/v1/sleep-logs?format=xml", token, "")
+	require.Equal(t, 400, w.Code, w.Body.String())
+	params = decodeProblem(t, w).InvalidParams
+	require.Len(t, params, 1)
+	assert.Equal(t, "format", params[0].Field)
+	assert.Equal(t, "one_of", params[0].Code)
+
+	// Text that a spreadsheet would run as a formula is escaped.
+	w = doJSON(r, "POST", "/api/v1/sleep-logs", token, `{"start_time":"2025-07-14T22:00:00Z","end_time":"2025-07-15T06:00:00Z","quality":5,"reason":"=HYPERLINK(\"http://evil.example\",\"x\")","interruptions":["@SUM(1)","-2+3"]}`)
+	require.Equal(t, 201, w.Code, w.Body.String())
+	w = getCSV(r, "/api/v1/sleep-logs?format=csv", token, "")
+	require.Equal(t, 200, w.Code)
+	rows, err = csv.NewReader(strings.NewReader(w.Body.String())).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, rows, 4)
+	assert.Equal(t, `'=HYPERLINK("http://evil.example","x")`, rows[3][5])
+	assert.Equal(t, "'@SUM(1); -2+3", rows[3][6])
+	assert.Equal(t, `Late, "loud" party`, rows[1][5], "other text is left alone")
+
+	// Without tz, times keep the offset they were recorded in; with it,
+	// they are converted.
+	traveller := registerAndLogin(t, r, "traveller@example.com").AccessToken
+	w = doJSON(r, "POST", "/api/v1/sleep-logs", traveller, `{"start_time":"2025-07-16T23:00:00+02:00","end_time":"2025-07-17T07:00:00+02:00","quality":7}`)
+	require.Equal(t, 201, w.Code, w.Body.String())
+	w = getCSV(r, "/api/v1/sleep-logs?format=csv", traveller, "")
+	require.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), "2025-07-16T23:00:00+02:00,2025-07-17T07:00:00+02:00,")
+	w = getCSV(r, "/api/v1/sleep-logs?format=csv&tz=UTC", traveller, "")
+	require.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), "2025-07-16T21:00:00Z,2025-07-17T05:00:00Z,")
+}
+
+func TestSleepStatsCSV(t *testing.T) {
+	t.Parallel()
+	r, _ := setupRouterAndStorage(t)
+	token := registerAndLogin(t, r, "dijkstra@example.com").AccessToken
+	start := time.Now().UTC().Truncate(24 * time.Hour).Add(-23 * time.Hour) // 01:00 yesterday
+	for i, quality := range []string{"6", "9"} {
+		s := start.Add(time.Duration(i) * 10 * time.Minute)
+		body := `{"start_time":"` + s.Format(time.RFC3339) + `","end_time":"` + s.Add(8*time.Hour).Format(time.RFC3339) + `","quality":` + quality + `,"interruptions":["noise"]}`
+		w := doJSON(r, "POST", "/api/v1/sleep-logs", token, body)
+		require.Equal(t, 201, w.Code, w.Body.String())
+	}
+
+	w := getCSV(r, "/api/v1/sleep-stats?tz=Asia/Tokyo", token, "text/csv")
+	require.Equal(t, 200, w.Code, w.Body.String())
+	assert.Contains(t, w.Header().Get("Content-Disposition"), `filename="sleep-stats.csv"`)
+	rows, err := csv.NewReader(strings.NewReader(w.Body.String())).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+	assert.Equal(t, []string{"date", "logs", "average_quality", "total_sleep_hours", "interruptions", "bedtime", "wake_time"}, rows[0])
+	assert.Equal(t, []string{start.Format("2006-01-02"), "2", "7.50", "16.00", "2"}, rows[1][:5])
+	assert.Equal(t, start.In(time.FixedZone("JST", 9*3600)).Format(time.RFC3339), rows[1][5])
+
+	// Without tz, bedtime and wake time keep the offset of the bedtime's log.
+	traveller := registerAndLogin(t, r, "lovelace@example.com").AccessToken
+	berlin := time.FixedZone("", 2*3600)
+	body := `{"start_time":"` + start.In(berlin).Format(time.RFC3339) + `","end_time":"` + start.Add(8*time.Hour).Format(time.RFC3339) + `","quality":7}`
+	w = doJSON(r, "POST", "/api/v1/sleep-logs", traveller, body)
+	require.Equal(t, 201, w.Code, w.Body.String())
+	w = getCSV(r, "/api/v1/sleep-stats?format=csv", traveller, "")
+	require.Equal(t, 200, w.Code, w.Body.String())
+	rows, err = csv.NewReader(strings.NewReader(w.Body.String())).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+	assert.Equal(t, []string{start.In(berlin).Format(time.RFC3339), start.Add(8 * time.Hour).In(berlin).Format(time.RFC3339)}, rows[1][5:])
+
+	w = doJSON(r, "GET", "/api/v1/sleep-stats", token, "")
+	require.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), `"average_quality":7.5`)
+
+	// CSV exports are audited; JSON reads are not.
+	events := auditEvents(t, r, "/api/v1/audit-events?action=sleep.export", token)
+	require.Len(t, events, 1)
+	assert.Equal(t, "sleep-stats.csv", events[0].Details["file"])
+}
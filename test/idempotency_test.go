@@ -0,0 +1,77 @@
+package test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yourname/sleeptracker/internal"
+	"github.com/yourname/sleeptracker/internal/idempotency"
+	"go.uber.org/zap"
+)
+
+func newIdempotentRouter(handler gin.HandlerFunc) *gin.Engine {
+	logger := internal.NewZapLogger(zap.NewNop().Sugar())
+	r := gin.New()
+	r.Use(gin.CustomRecoveryWithWriter(io.Discard, func(c *gin.Context, _ any) { c.AbortWithStatus(http.StatusInternalServerError) }))
+	r.POST("/things", idempotency.Middleware(idempotency.NewMemoryStore(time.Hour), 1<<20, logger), handler)
+	return r
+}
+
+func postKeyed(r http.Handler, key, body string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("POST", "/things", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(idempotency.Header, key)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestIdempotencyInProgressConflict(t *testing.T) {
+	t.Parallel()
+	started, release := make(chan struct{}), make(chan struct{})
+	r := newIdempotentRouter(func(c *gin.Context) {
+		close(started)
+		<-release
+		c.JSON(http.StatusCreated, gin.H{"data": "made"})
+	})
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- postKeyed(r, "k1", `{}`) }()
+	<-started
+
+	// A retry while the first request runs may be tried again later.
+	w := postKeyed(r, "k1", `{}`)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.NotEmpty(t, w.Header().Get("Retry-After"))
+	close(release)
+	require.Equal(t, http.StatusCreated, (<-done).Code)
+
+	// A key reused for a different request may not.
+	w = postKeyed(r, "k1", `{"other":true}`)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Empty(t, w.Header().Get("Retry-After"))
+}
+
+func TestIdempotencyReleasesKeyAfterPanic(t *testing.T) {
+	t.Parallel()
+	var calls atomic.Int32
+	r := newIdempotentRouter(func(c *gin.Context) {
+		if calls.Add(1) == 1 {
+			panic("handler failed")
+		}
+		c.JSON(http.StatusCreated, gin.H{"data": "made"})
+	})
+
+	assert.Equal(t, http.StatusInternalServerError, postKeyed(r, "k1", `{}`).Code)
+	w := postKeyed(r, "k1", `{}`)
+	assert.Equal(t, http.StatusCreated, w.Code, "the key is not left in progress")
+	assert.Equal(t, int32(2), calls.Load())
+}
@@ -0,0 +1,160 @@
+package test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yourname/sleeptracker/internal/service"
+)
+
+func postImport(r http.Handler, path, token, contentType, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", contentType)
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func decodeImport(t *testing.T, w *httptest.ResponseRecorder) service.ImportResult {
+	t.Helper()
+	require.Equal(t, 200, w.Code, w.Body.String())
+	var resp struct {
+		Data service.ImportResult `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	return resp.Data
+}
+
+// rowCodes maps each rejected row to the codes of its rejected fields.
+func rowCodes(result service.ImportResult) map[int][]string {
+	codes := make(map[int][]string)
+	for _, e := range result.Errors {
+		for _, f := range e.Fields {
+			codes[e.Row] = append(codes[e.Row], f.Field+":"+f.Code)
+		}
+	}
+	return codes
+}
+
+func TestImportJSON(t *testing.T) {
+	t.Parallel()
+	r, _ := setupRouterAndStorage(t)
+	token := registerAndLogin(t, r, "noether@example.com").AccessToken
+	w := doJSON(r, "POST", "/api/v1/sleep-logs", token, `{"start_time":"2025-07-10T22:00:00Z","end_time":"2025-07-11T06:00:00Z","quality":7}`)
+	require.Equal(t, 201, w.Code, w.Body.String())
+
+	body := `[
+		{"start_time":"2025-07-01T22:00:00Z","end_time":"2025-07-02T06:00:00Z","quality":8,"interruptions":["noise"]},
+		{"start_time":"2025-07-02T22:00:00Z","end_time":"2025-07-03T06:00:00Z","quality":11},
+		{"start_time":"2025-07-11T05:00:00Z","end_time":"2025-07-11T09:00:00Z","quality":5},
+		{"start_time":"2025-07-01T22:00:00Z","end_time":"2025-07-02T06:00:00Z","quality":6},
+		{"start_time":"yesterday","end_time":"2025-07-04T06:00:00Z","quality":"good"},
+		{"start_time":"2025-07-03T22:00:00","end_time":"2025-07-04T06:00:00","quality":9}
+	]`
+	dry := decodeImport(t, postImport(r, "/api/v1/sleep-logs/import?dry_run=true", token, "application/json", body))
+	assert.True(t, dry.DryRun)
+	assert.Equal(t, 6, dry.Rows)
+	assert.Equal(t, 2, dry.Valid)
+	assert.Zero(t, dry.Imported)
+	assert.Equal(t, map[int][]string{
+		2: {"quality:max"},
+		3: {"start_time:overlap"},
+		4: {"start_time:duplicate"},
+		5: {"quality:type"},
+	}, rowCodes(dry))
+	assert.Equal(t, "1", dry.Errors[2].Fields[0].Params["row"], "duplicates name the earlier row")
+
+	w = doJSON(r, "GET", "/api/v1/sleep-logs", token, "")
+	assert.Equal(t, 1, strings.Count(w.Body.String(), `"id"`), "a dry run saves nothing")
+
+	result := decodeImport(t, postImport(r, "/api/v1/sleep-logs/import", token, "application/json", body))
+	assert.False(t, result.DryRun)
+	assert.Equal(t, 2, result.Imported)
+	assert.Equal(t, rowCodes(dry), rowCodes(result))
+
+	w = doJSON(r, "GET", "/api/v1/sleep-logs", token, "")
+	assert.Equal(t, 3, strings.Count(w.Body.String(), `"id"`))
+	assert.Contains(t, w.Body.String(), `"start_time":"2025-07-03T22:00:00Z"`, "timestamps without an offset are UTC by default")
+
+	// Importing again rejects every row as a duplicate or overlap.
+	again := decodeImport(t, postImport(r, "/api/v1/sleep-logs/import", token, "application/json", body))
+	assert.Zero(t, again.Imported)
+	assert.Equal(t, []string{"start_time:duplicate"}, rowCodes(again)[1])
+
+	w = doJSON(r, "GET", "/api/v1/audit-events?action=sleep.import", token, "")
+	require.Equal(t, 200, w.Code, w.Body.String())
+	assert.Equal(t, 1, strings.Count(w.Body.String(), `"imported":"2"`))
+
+	w = postImport(r, "/api/v1/sleep-logs/import", token, "application/json", `{"start_time":"2025-07-01T22:00:00Z"}`)
+	assert.Equal(t, 400, w.Code)
+}
+
+func TestImportCSV(t *testing.T) {
+	t.Parallel()
+	r, _ := setupRouterAndStorage(t)
+	token := registerAndLogin(t, r, "germain@example.com").AccessToken
+
+	body := "\ufeffDate In,Date Out,Score,Notes,Wakeups\r\n" +
+		"2025-07-01 23:00,2025-07-02 07:00,8,\"Late, but fine\",bathroom; noise\r\n" +
+		"2025-07-02 23:00,2025-07-03 07:00,,,\r\n" +
+		"2025-07-03 23:00,2025-07-04 07:30,6,,\r\n"
+	path := "/api/v1/sleep-logs/import?tz=Europe/Paris" +
+		"&columns[start_time]=Date+In&columns[end_time]=Date+Out&columns[quality]=Score&columns[reason]=Notes&columns[interruptions]=Wakeups"
+	result := decodeImport(t, postImport(r, path, token, "text/csv", body))
+	assert.Equal(t, 3, result.Rows)
+	assert.Equal(t, 2, result.Imported)
+	assert.Equal(t, map[int][]string{3: {"quality:required"}}, rowCodes(result), "rows are numbered by CSV line")
+
+	// The export keeps the offset the times were read in, and reads back
+	// in as it was written.
+	export := getCSV(r, "/api/v1/sleep-logs", token, "text/csv")
+	require.Equal(t, 200, export.Code)
+	assert.Contains(t, export.Body.String(), `2025-07-01T23:00:00+02:00,2025-07-02T07:00:00+02:00,8.00,8,"Late, but fine",bathroom; noise`)
+	other := registerAndLogin(t, r, "kovalevskaya@example.com").AccessToken
+	copied := decodeImport(t, postImport(r, "/api/v1/sleep-logs/import", other, "text/csv", export.Body.String()))
+	assert.Equal(t, 2, copied.Imported)
+	assert.Empty(t, copied.Errors)
+	reexport := getCSV(r, "/api/v1/sleep-logs", other, "text/csv")
+	assert.Equal(t, dropIDs(export.Body.String()), dropIDs(reexport.Body.String()))
+
+	w := doProblem(r, "POST", "/api/v1/sleep-logs/import?columns[start_time]=Bedtime&columns[wake]=x", token, "[]")
+	require.Equal(t, 400, w.Code, w.Body.String())
+	assert.Equal(t, "columns[wake]", decodeProblem(t, w).InvalidParams[0].Field)
+
+	w = postImport(r, "/api/v1/sleep-logs/import?columns[start_time]=Bedtime", token, "text/csv", body)
+	require.Equal(t, 400, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `columns[start_time]`)
+}
+
+func TestIdempotentImportBodyIsBounded(t *testing.T) {
+	t.Parallel()
+	r, _ := setupRouterAndStorage(t)
+	token := registerAndLogin(t, r, "liskov@example.com").AccessToken
+
+	// The Idempotency-Key middleware reads the body before the handler's
+	// own limit applies, so it must enforce one too.
+	body := "[" + strings.Repeat(" ", 10<<20) + "]"
+	req, _ := http.NewRequest("POST", "/api/v1/sleep-logs/import", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Idempotency-Key", "big-import")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, 413, w.Code, w.Body.String())
+}
+
+// dropIDs removes the first column of each CSV line.
+func dropIDs(csv string) string {
+	lines := strings.Split(csv, "\r\n")
+	for i, line := range lines {
+		_, rest, _ := strings.Cut(line, ",")
+		lines[i] = rest
+	}
+	return strings.Join(lines, "\r\n")
+}
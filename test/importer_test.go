@@ -0,0 +1,180 @@
+package test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yourname/sleeptracker/internal"
+	"github.com/yourname/sleeptracker/internal/importer"
+)
+
+const fitbitExport = `[
+  {"logId":26589710670,"dateOfSleep":"2025-07-02","startTime":"2025-07-01T23:10:00.000","endTime":"2025-07-02T07:00:00.000",
+   "duration":28200000,"minutesAsleep":435,"efficiency":92,"type":"stages","infoCode":0,"mainSleep":true,
+   "levels":{
+     "summary":{"deep":{"count":1,"minutes":265},"light":{"count":1,"minutes":180},"wake":{"count":2,"minutes":25}},
+     "data":[
+       {"dateTime":"2025-07-01T23:10:00.000","level":"wake","seconds":600},
+       {"dateTime":"2025-07-01T23:20:00.000","level":"light","seconds":10800},
+       {"dateTime":"2025-07-02T02:20:00.000","level":"wake","seconds":900},
+       {"dateTime":"2025-07-02T02:35:00.000","level":"deep","seconds":15900}
+     ],
+     "shortData":[{"dateTime":"2025-07-02T04:00:00.000","level":"wake","seconds":60}]
+   }},
+  {"logId":26589710671,"dateOfSleep":"2025-07-02","startTime":"2025-07-02T14:00:00.000","endTime":"2025-07-02T14:45:00.000",
+   "efficiency":75,"type":"classic","mainSleep":false,"levels":{"data":[]}}
+]`
+
+// listLogs returns the user's sleep logs, newest first.
+func listLogs(t *testing.T, r *gin.Engine, token string) []internal.SleepLog {
+	t.Helper()
+	w := doJSON(r, "GET", "/api/v1/sleep-logs", token, "")
+	require.Equal(t, 200, w.Code, w.Body.String())
+	var resp struct {
+		Data []internal.SleepLog `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	return resp.Data
+}
+
+func TestFitbitImport(t *testing.T) {
+	t.Parallel()
+	r, _ := setupRouterAndStorage(t)
+	token := registerAndLogin(t, r, "lovelace@example.com").AccessToken
+	path := "/api/v1/sleep-logs/import/fitbit?tz=America/New_York"
+
+	result := decodeImport(t, postImport(r, path, token, "application/json", fitbitExport))
+	assert.Equal(t, 2, result.Imported)
+	assert.Empty(t, result.Errors)
+
+	logs := listLogs(t, r, token)
+	require.Len(t, logs, 2)
+	nap, night := logs[0], logs[1]
+	ny, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+	assert.True(t, time.Date(2025, 7, 1, 23, 20, 0, 0, ny).Equal(night.StartTime), "the night starts when the user fell asleep")
+	assert.True(t, time.Date(2025, 7, 2, 7, 0, 0, 0, ny).Equal(night.EndTime))
+	assert.Equal(t, []string{"awake 02:20-02:35 (15m)"}, night.Interruptions)
+	assert.Equal(t, 9, night.Quality, "Fitbit's efficiency is the quality")
+	assert.Equal(t, "Imported from Fitbit", night.Reason)
+
+	require.NotNil(t, night.Source)
+	assert.Equal(t, "fitbit", night.Source.Name)
+	assert.Equal(t, "26589710670", night.Source.ID)
+	meta := night.Source.Metadata
+	assert.Equal(t, "2025-07-02", meta["dateOfSleep"], "unknown fields are kept")
+	assert.Equal(t, float64(435), meta["minutesAsleep"])
+	assert.NotContains(t, meta, "startTime")
+	levels, ok := meta["levels"].(map[string]any)
+	require.True(t, ok)
+	assert.Contains(t, levels, "summary")
+	assert.Contains(t, levels, "shortData")
+	assert.NotContains(t, levels, "data", "the stage timeline is mapped, not kept")
+	assert.Equal(t, 8, nap.Quality)
+	assert.Empty(t, nap.Interruptions)
+	assert.NotContains(t, nap.Source.Metadata, "levels")
+
+	// Fitbit recalculated the nap: importing again updates it in place.
+	changed := strings.Replace(fitbitExport, `"endTime":"2025-07-02T14:45:00.000",
+   "efficiency":75`, `"endTime":"2025-07-02T14:50:00.000",
+   "efficiency":55`, 1)
+	require.NotEqual(t, fitbitExport, changed)
+	again := decodeImport(t, postImport(r, path, token, "application/json", changed))
+	assert.Zero(t, again.Imported)
+	assert.Equal(t, 1, again.Updated)
+	assert.Equal(t, 1, again.Skipped)
+	assert.Empty(t, again.Errors)
+
+	logs = listLogs(t, r, token)
+	require.Len(t, logs, 2)
+	assert.Equal(t, nap.ID, logs[0].ID)
+	assert.Equal(t, 6, logs[0].Quality)
+	assert.True(t, time.Date(2025, 7, 2, 14, 50, 0, 0, ny).Equal(logs[0].EndTime))
+
+	w := doJSON(r, "GET", "/api/v1/audit-events?action=sleep.import", token, "")
+	require.Equal(t, 200, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"details":{"imported":"0","source":"fitbit","updated":"1"}`)
+
+	w = postImport(r, path, token, "application/xml", fitbitExport)
+	assert.Equal(t, 415, w.Code, w.Body.String())
+	w = postImport(r, path, token, "application/json", `[{"logId":1,"startTime":"last night"}]`)
+	assert.Equal(t, 400, w.Code, w.Body.String())
+	w = postImport(r, "/api/v1/sleep-logs/import/garmin", token, "application/json", fitbitExport)
+	assert.Equal(t, 400, w.Code, w.Body.String())
+}
+
+func TestGoogleFitImport(t *testing.T) {
+	t.Parallel()
+	r, _ := setupRouterAndStorage(t)
+	token := registerAndLogin(t, r, "hamilton@example.com").AccessToken
+
+	takeout := `{
+	  "fitnessActivity": "sleep",
+	  "startTime": "2025-07-03T21:00:00.000Z",
+	  "endTime": "2025-07-04T05:00:00.000Z",
+	  "duration": "28800s",
+	  "segment": [
+	    {"fitnessActivity": "sleep.light", "startTime": "2025-07-03T21:10:00.000Z", "endTime": "2025-07-04T01:00:00.000Z"},
+	    {"fitnessActivity": "sleep.awake", "startTime": "2025-07-04T01:00:00.000Z", "endTime": "2025-07-04T01:30:00.000Z"},
+	    {"fitnessActivity": "sleep.deep", "startTime": "2025-07-04T01:30:00.000Z", "endTime": "2025-07-04T05:00:00.000Z"}
+	  ],
+	  "aggregate": [{"metricName": "com.google.active_minutes", "intValue": 0}]
+	}`
+	result := decodeImport(t, postImport(r, "/api/v1/sleep-logs/import/google-fit?tz=Europe/Berlin", token, "application/json", takeout))
+	assert.Equal(t, 1, result.Imported)
+
+	rest := `{"session": [
+	  {"id": "1751716800000-nap", "name": "Nap", "activityType": 72, "startTimeMillis": "1751716800000", "endTimeMillis": "1751719200000",
+	   "application": {"packageName": "com.urbandroid.sleep"}},
+	  {"id": "walk", "activityType": 7, "startTimeMillis": "1751720000000", "endTimeMillis": "1751721000000"}
+	]}`
+	result = decodeImport(t, postImport(r, "/api/v1/sleep-logs/import/google-fit", token, "application/json", rest))
+	assert.Equal(t, 1, result.Rows, "sessions other than sleep are skipped")
+	assert.Equal(t, 1, result.Imported)
+
+	logs := listLogs(t, r, token)
+	require.Len(t, logs, 2)
+	nap, night := logs[0], logs[1]
+	assert.Equal(t, "2025-07-03T23:10:00+02:00", night.StartTime.Format(time.RFC3339), "times are in tz")
+	assert.Equal(t, "2025-07-04T07:00:00+02:00", night.EndTime.Format(time.RFC3339))
+	assert.Equal(t, []string{"awake 03:00-03:30 (30m)"}, night.Interruptions)
+	assert.Equal(t, 9, night.Quality, "asleep 92% of the time in bed")
+	assert.Equal(t, &internal.SleepLogSource{Name: "google-fit", ID: "2025-07-03T21:00:00Z", Metadata: map[string]any{
+		"duration":  "28800s",
+		"aggregate": []any{map[string]any{"metricName": "com.google.active_minutes", "intValue": float64(0)}},
+	}}, night.Source)
+
+	assert.True(t, time.Date(2025, 7, 5, 12, 0, 0, 0, time.UTC).Equal(nap.StartTime))
+	assert.Equal(t, 5, nap.Quality, "without stages nothing is known about quality")
+	assert.Equal(t, "1751716800000-nap", nap.Source.ID)
+	assert.Equal(t, "Nap", nap.Source.Metadata["name"])
+
+	again := decodeImport(t, postImport(r, "/api/v1/sleep-logs/import/google-fit?tz=Europe/Berlin", token, "application/json", takeout))
+	assert.Equal(t, 1, again.Skipped)
+}
+
+func TestImporterNight(t *testing.T) {
+	t.Parallel()
+	midnight := time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC)
+	var samples []importer.Sample
+	for i := range 25 {
+		start := midnight.Add(time.Duration(i) * 15 * time.Minute)
+		samples = append(samples, importer.Sample{Start: start, End: start.Add(10 * time.Minute), Stage: importer.Asleep})
+	}
+	samples = append(samples, importer.Sample{Start: midnight.Add(12 * time.Hour), End: midnight.Add(13 * time.Hour), Stage: importer.InBed})
+
+	nights := importer.Nights(samples)
+	require.Len(t, nights, 2, "a gap of over 3 hours starts a new night")
+	log := nights[0].SleepLog("Test")
+	assert.Len(t, log.Interruptions, 20)
+	assert.Equal(t, "5 more awake periods", log.Interruptions[19])
+	assert.Equal(t, 7, log.Quality, "asleep two thirds of the night")
+	assert.Equal(t, 5, nights[1].SleepLog("Test").Quality)
+
+	assert.Equal(t, []string{"apple-health", "fitbit", "google-fit"}, importer.Names())
+}
@@ -0,0 +1,256 @@
+package test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yourname/sleeptracker/internal"
+	api "github.com/yourname/sleeptracker/internal/api"
+	"github.com/yourname/sleeptracker/internal/auth"
+	"go.uber.org/zap"
+)
+
+func signHS256(t *testing.T, secret string, claims jwt.MapClaims) string {
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+	require.NoError(t, err)
+	return token
+}
+
+func signRS256(t *testing.T, key *rsa.PrivateKey, kid string, claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	require.NoError(t, err)
+	return signed
+}
+
+func jwksDocument(t *testing.T, keys map[string]*rsa.PrivateKey) []byte {
+	var doc struct {
+		Keys []map[string]string `json:"keys"`
+	}
+	for kid, key := range keys {
+		doc.Keys = append(doc.Keys, map[string]string{
+			"kty": "RSA", "kid": kid, "use": "sig", "alg": "RS256",
+			"n": base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e": base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		})
+	}
+	data, err := json.Marshal(doc)
+	require.NoError(t, err)
+	return data
+}
+
+func TestJWTAuthProvider_HS256Claims(t *testing.T) {
+	logger := internal.NewZapLogger(zap.NewNop().Sugar())
+	p, err := auth.NewJWTAuthProvider(auth.JWTConfig{Secret: "s3cret", Issuer: "https://issuer.test", Audience: "sleeptracker"}, logger)
+	require.NoError(t, err)
+
+	now := time.Now()
+	valid := jwt.MapClaims{
+		"sub": "user-42", "name": "Ada", "iss": "https://issuer.test", "aud": "sleeptracker",
+		"exp": now.Add(time.Hour).Unix(), "nbf": now.Add(-time.Minute).Unix(),
+	}
+	user, err := p.Authenticate(context.Background(), auth.Credentials{Token: signHS256(t, "s3cret", valid)})
+	require.NoError(t, err)
+	assert.Equal(t, "user-42", user.ID)
+	assert.Equal(t, "Ada", user.Name)
+
+	with := func(key string, value interface{}) jwt.MapClaims {
+		claims := jwt.MapClaims{}
+		for k, v := range valid {
+			claims[k] = v
+		}
+		if value == nil {
+			delete(claims, key)
+		} else {
+			claims[key] = value
+		}
+		return claims
+	}
+	rejected := map[string]string{
+		"expired":       signHS256(t, "s3cret", with("exp", now.Add(-time.Hour).Unix())),
+		"not yet valid": signHS256(t, "s3cret", with("nbf", now.Add(time.Hour).Unix())),
+		"no exp":        signHS256(t, "s3cret", with("exp", nil)),
+		"wrong issuer":  signHS256(t, "s3cret", with("iss", "https://other.test")),
+		"wrong aud":     signHS256(t, "s3cret", with("aud", "other")),
+		"no sub":        signHS256(t, "s3cret", with("sub", nil)),
+		"wrong secret":  signHS256(t, "other", valid),
+		"garbage":       "not.a.jwt",
+	}
+	for name, token := range rejected {
+		_, err := p.Authenticate(context.Background(), auth.Credentials{Token: token})
+		assert.Error(t, err, name)
+	}
+}
+
+// A token's role claim is only trusted when the provider is told to, and
+// then only for known roles.
+func TestJWTRoleClaim(t *testing.T) {
+	t.Parallel()
+	logger := internal.NewZapLogger(zap.NewNop().Sugar())
+	claims := func(role string) string {
+		return signHS256(t, "s3cret", jwt.MapClaims{"sub": "jwt-" + role, "role": role, "exp": time.Now().Add(time.Hour).Unix()})
+	}
+	router := func(roleClaim bool) *gin.Engine {
+		_, app := setupRouterAndStorage(t)
+		p, err := auth.NewJWTAuthProvider(auth.JWTConfig{Secret: "s3cret", RoleClaim: roleClaim}, logger)
+		require.NoError(t, err)
+		app.routes.Auth = p
+		r := gin.New()
+		r.Use(api.RequestIDMiddleware())
+		api.RegisterRoutes(r, app)
+		return r
+	}
+
+	r := router(false)
+	assert.Equal(t, 403, doJSON(r, "GET", "/api/v1/admin/users", claims("admin"), "").Code, "role claims are ignored by default")
+	assert.Equal(t, 200, doJSON(r, "GET", "/api/v1/sleep-logs", claims("admin"), "").Code, "as a regular user")
+
+	r = router(true)
+	assert.Equal(t, 200, doJSON(r, "GET", "/api/v1/admin/users", claims("admin"), "").Code)
+	assert.Equal(t, 403, doJSON(r, "GET", "/api/v1/admin/users", claims("root"), "").Code, "unknown roles are regular users")
+	assert.Equal(t, 403, doJSON(r, "GET", "/api/v1/admin/users", claims("user"), "").Code)
+}
+
+func TestJWTAuthProvider_JWKSFile(t *testing.T) {
+	logger := internal.NewZapLogger(zap.NewNop().Sugar())
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(path, jwksDocument(t, map[string]*rsa.PrivateKey{"k1": key}), 0o644))
+
+	p, err := auth.NewJWTAuthProvider(auth.JWTConfig{JWKSFile: path}, logger)
+	require.NoError(t, err)
+	claims := jwt.MapClaims{"sub": "u7", "exp": time.Now().Add(time.Hour).Unix()}
+	user, err := p.Authenticate(context.Background(), auth.Credentials{Token: signRS256(t, key, "k1", claims)})
+	require.NoError(t, err)
+	assert.Equal(t, "u7", user.ID)
+
+	// An HS256 token is rejected when only RS256 keys are configured.
+	_, err = p.Authenticate(context.Background(), auth.Credentials{Token: signHS256(t, "anything", claims)})
+	assert.Error(t, err)
+	// So is a token signed with a key the JWKS does not list.
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	_, err = p.Authenticate(context.Background(), auth.Credentials{Token: signRS256(t, other, "k2", claims)})
+	assert.Error(t, err)
+}
+
+func TestJWTAuthProvider_JWKSURLRotation(t *testing.T) {
+	logger := internal.NewZapLogger(zap.NewNop().Sugar())
+	oldKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	newKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	var mu sync.Mutex
+	served := map[string]*rsa.PrivateKey{"old": oldKey}
+	fetches := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		fetches++
+		w.Write(jwksDocument(t, served))
+	}))
+	defer srv.Close()
+
+	claims := jwt.MapClaims{"sub": "u1", "exp": time.Now().Add(time.Hour).Unix()}
+	oldToken, newToken := signRS256(t, oldKey, "old", claims), signRS256(t, newKey, "new", claims)
+	p, err := auth.NewJWTAuthProvider(auth.JWTConfig{JWKSURL: srv.URL, JWKSRefresh: 200 * time.Millisecond}, logger)
+	require.NoError(t, err)
+	ctx := context.Background()
+	fetchCount := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return fetches
+	}
+
+	for i := 0; i < 3; i++ {
+		_, err = p.Authenticate(ctx, auth.Credentials{Token: oldToken})
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 1, fetchCount(), "the JWKS document is cached")
+
+	mu.Lock()
+	served = map[string]*rsa.PrivateKey{"new": newKey}
+	mu.Unlock()
+	// Refetching for an unknown key is rate limited...
+	_, err = p.Authenticate(ctx, auth.Credentials{Token: newToken})
+	assert.Error(t, err)
+	assert.Equal(t, 1, fetchCount())
+
+	// ...and once the limit passes the rotated key is picked up.
+	time.Sleep(250 * time.Millisecond)
+	user, err := p.Authenticate(ctx, auth.Credentials{Token: newToken})
+	require.NoError(t, err)
+	assert.Equal(t, "u1", user.ID)
+	_, err = p.Authenticate(ctx, auth.Credentials{Token: oldToken})
+	assert.Error(t, err, "retired keys stop validating")
+}
+
+func TestJWTAuthProvider_JWKSFetchIsShared(t *testing.T) {
+	logger := internal.NewZapLogger(zap.NewNop().Sugar())
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	release := make(chan struct{})
+	var mu sync.Mutex
+	fetches := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		fetches++
+		mu.Unlock()
+		<-release
+		w.Write(jwksDocument(t, map[string]*rsa.PrivateKey{"k1": key}))
+	}))
+	defer srv.Close()
+
+	token := signRS256(t, key, "k1", jwt.MapClaims{"sub": "u1", "exp": time.Now().Add(time.Hour).Unix()})
+	p, err := auth.NewJWTAuthProvider(auth.JWTConfig{JWKSURL: srv.URL}, logger)
+	require.NoError(t, err)
+
+	// A caller that gives up does not wait for the slow fetch...
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = p.Authenticate(ctx, auth.Credentials{Token: token})
+	assert.Error(t, err)
+	assert.Less(t, time.Since(start), time.Second)
+
+	// ...and callers arriving meanwhile share it rather than queueing.
+	var wg sync.WaitGroup
+	errs := make(chan error, 5)
+	for range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := p.Authenticate(context.Background(), auth.Credentials{Token: token})
+			errs <- err
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		assert.NoError(t, err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, 1, fetches)
+}
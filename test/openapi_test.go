@@ -0,0 +1,94 @@
+package test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yourname/sleeptracker/internal"
+	api "github.com/yourname/sleeptracker/internal/api"
+	"github.com/yourname/sleeptracker/internal/openapi"
+	"go.uber.org/zap"
+)
+
+func TestRoutesMatchSpec(t *testing.T) {
+	t.Parallel()
+	r, app := setupRouterAndStorage(t)
+
+	var registered []string
+	for _, route := range r.Routes() {
+		if path, ok := strings.CutPrefix(route.Path, api.V1Prefix); ok {
+			registered = append(registered, route.Method+" "+path)
+		}
+	}
+	assert.ElementsMatch(t, app.routes.OpenAPI.Operations(), registered)
+}
+
+func TestRequestsAreValidatedAgainstSpec(t *testing.T) {
+	t.Parallel()
+	r, app := setupRouterAndStorage(t)
+	user := registerAndLogin(t, r, "lamport@example.com")
+	admin := registerAdmin(t, r, app, "admin@example.com")
+
+	fields := func(w *httptest.ResponseRecorder) []internal.FieldError {
+		t.Helper()
+		require.Equal(t, 400, w.Code, w.Body.String())
+		return decodeProblem(t, w).InvalidParams
+	}
+
+	assert.Equal(t, []internal.FieldError{
+		{Field: "limit", Code: "type", Message: "must be a number", Params: map[string]string{"expected": "number"}},
+	}, fields(doProblem(r, "GET", "/api/v1/audit-events?limit=lots", user.AccessToken, "")))
+	assert.Equal(t, []internal.FieldError{
+		{Field: "since", Code: "format", Message: "must be a valid date-time", Params: map[string]string{"format": "date-time"}},
+	}, fields(doProblem(r, "GET", "/api/v1/audit-events?since=yesterday", user.AccessToken, "")))
+	assert.ElementsMatch(t, []internal.FieldError{
+		{Field: "scopes", Code: "required", Message: "is required"},
+		{Field: "name", Code: "max", Message: "must have at most 100 characters", Params: map[string]string{"max": "100"}},
+	}, fields(doProblem(r, "POST", "/api/v1/api-keys", user.AccessToken, `{"name":"`+strings.Repeat("k", 101)+`"}`)))
+	assert.Equal(t, []internal.FieldError{
+		{Field: "interruptions[1]", Code: "max", Message: "must have at most 100 characters", Params: map[string]string{"max": "100"}},
+	}, fields(doProblem(r, "POST", "/api/v1/sleep-logs", user.AccessToken,
+		`{"start_time":"2025-07-16T22:00:00Z","end_time":"2025-07-17T06:00:00Z","quality":7,"interruptions":["dog","`+strings.Repeat("z", 101)+`"]}`)))
+	assert.Equal(t, []internal.FieldError{
+		{Field: "role", Code: "one_of", Message: "must be one of: user, viewer, admin", Params: map[string]string{"values": "user,viewer,admin"}},
+	}, fields(doProblem(r, "PUT", "/api/v1/admin/users/someone/role", admin.AccessToken, `{"role":"root"}`)))
+
+	// Legacy aliases are checked against the same operation.
+	assert.Equal(t, 400, doJSON(r, "POST", "/api/goals", user.AccessToken, `{"type":"nap","value":"20m"}`).Code)
+
+	// Credentials are checked before the request is.
+	assert.Equal(t, 401, doJSON(r, "POST", "/api/v1/goals", "", `{"type":"nap"}`).Code)
+	assert.Equal(t, 403, doJSON(r, "PUT", "/api/v1/admin/users/someone/role", user.AccessToken, `{"role":"root"}`).Code)
+}
+
+func TestNonConformingResponsesFail(t *testing.T) {
+	t.Parallel()
+	spec, err := openapi.NewValidator(internal.NewZapLogger(zap.NewNop().Sugar()))
+	require.NoError(t, err)
+	require.True(t, spec.CheckResponses, "responses are checked in gin's test mode")
+
+	r := gin.New()
+	r.GET("/goals", spec.Middleware(http.MethodGet, "/goals"), func(c *gin.Context) {
+		c.Header("ETag", `"abc"`)
+		c.JSON(http.StatusOK, gin.H{"data": gin.H{"id": "g1", "type": "nap"}})
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/goals", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, 500, w.Code)
+	assert.Empty(t, w.Header().Get("ETag"))
+	var body struct {
+		Error internal.AppError `json:"error"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Contains(t, body.Error.Message, "Response does not match the OpenAPI spec")
+
+	assert.Nil(t, spec.Middleware(http.MethodGet, "/undocumented"))
+}
@@ -0,0 +1,106 @@
+package test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yourname/sleeptracker/internal"
+	"github.com/yourname/sleeptracker/internal/response"
+)
+
+func doProblem(r *gin.Engine, method, path, token, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", response.ProblemContentType)
+	req.Header.Set("X-Request-ID", "req-problem")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func decodeProblem(t *testing.T, w *httptest.ResponseRecorder) response.Problem {
+	t.Helper()
+	assert.Equal(t, response.ProblemContentType, w.Header().Get("Content-Type"))
+	var p response.Problem
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &p))
+	return p
+}
+
+func TestProblemDetails(t *testing.T) {
+	t.Parallel()
+	r, _ := setupRouterAndStorage(t)
+	user := registerAndLogin(t, r, "hamming@example.com")
+
+	w := doProblem(r, "POST", "/api/v1/sleep-logs", user.AccessToken, `{"start_time":"2025-07-17T06:00:00Z","end_time":"2025-07-16T22:00:00Z","quality":99}`)
+	require.Equal(t, 400, w.Code, w.Body.String())
+	p := decodeProblem(t, w)
+	assert.Equal(t, "urn:sleeptracker:problem:validation", p.Type)
+	assert.Equal(t, "Bad Request", p.Title)
+	assert.Equal(t, 400, p.Status)
+	assert.Equal(t, "/api/v1/sleep-logs", p.Instance)
+	assert.Equal(t, "req-problem", p.RequestID)
+	// quality is out of the spec's range, so the handler never checks the times.
+	assert.Equal(t, []internal.FieldError{
+		{Field: "quality", Code: "max", Message: "must be at most 10", Params: map[string]string{"max": "10"}},
+	}, p.InvalidParams)
+
+	w = doProblem(r, "POST", "/api/v1/sleep-logs", user.AccessToken, `{"start_time":"2025-07-17T06:00:00Z","end_time":"2025-07-16T22:00:00Z","quality":7}`)
+	require.Equal(t, 400, w.Code, w.Body.String())
+	assert.Equal(t, []internal.FieldError{
+		{Field: "end_time", Code: "after", Message: "must be after start_time", Params: map[string]string{"field": "start_time"}},
+	}, decodeProblem(t, w).InvalidParams)
+
+	w = doProblem(r, "POST", "/api/v1/sleep-logs", user.AccessToken, `{"start_time":"2025-07-16T22:00:00Z","end_time":"2025-07-17T06:00:00Z","quality":"good"}`)
+	require.Equal(t, 400, w.Code)
+	p = decodeProblem(t, w)
+	assert.Equal(t, []internal.FieldError{
+		{Field: "quality", Code: "type", Message: "must be a JSON number", Params: map[string]string{"expected": "number"}},
+	}, p.InvalidParams)
+
+	w = doProblem(r, "GET", "/api/v1/goals", user.AccessToken, "")
+	require.Equal(t, 404, w.Code)
+	p = decodeProblem(t, w)
+	assert.Equal(t, "urn:sleeptracker:problem:not-found", p.Type)
+	assert.Contains(t, p.Detail, "no goal set")
+	assert.Empty(t, p.InvalidParams)
+
+	w = doProblem(r, "GET", "/api/v1/sleep-logs", "", "")
+	require.Equal(t, 401, w.Code)
+	assert.Equal(t, "urn:sleeptracker:problem:unauthorized", decodeProblem(t, w).Type)
+
+	// Without the Accept header, errors keep the usual envelope.
+	w = doJSON(r, "POST", "/api/v1/auth/register", "", `{"email":"hamming@example.com","password":"correct horse"}`)
+	require.Equal(t, 409, w.Code)
+	var envelope struct {
+		Error internal.AppError `json:"error"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &envelope))
+	assert.Equal(t, 409, envelope.Error.Code)
+	assert.Equal(t, "Registration failed: email already registered", envelope.Error.Message)
+}
+
+func TestUnexpectedErrorsAreNotExposed(t *testing.T) {
+	t.Parallel()
+	for _, accept := range []string{"", response.ProblemContentType} {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request, _ = http.NewRequest("GET", "/api/v1/sleep-logs", nil)
+		c.Request.Header.Set("Accept", accept)
+
+		response.Error(c, errors.New(`ERROR: relation "sleep_logs" does not exist (SQLSTATE 42P01)`), "Failed to fetch logs")
+		assert.Equal(t, 500, w.Code)
+		assert.Contains(t, w.Body.String(), "Failed to fetch logs")
+		assert.NotContains(t, w.Body.String(), "sleep_logs")
+		assert.True(t, c.IsAborted())
+	}
+}
@@ -0,0 +1,132 @@
+package test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yourname/sleeptracker/internal"
+	"github.com/yourname/sleeptracker/internal/ratelimit"
+	"go.uber.org/zap"
+)
+
+func TestParseLimit(t *testing.T) {
+	limit, err := ratelimit.ParseLimit("60/1m")
+	require.NoError(t, err)
+	assert.Equal(t, ratelimit.Limit{Requests: 60, Period: time.Minute}, limit)
+	for _, off := range []string{"", "0", "off"} {
+		limit, err = ratelimit.ParseLimit(off)
+		require.NoError(t, err)
+		assert.False(t, limit.Enabled())
+	}
+	for _, bad := range []string{"60", "x/1m", "60/soon", "60/0s", "-1/1m"} {
+		_, err = ratelimit.ParseLimit(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestMemoryLimiterTokenBucket(t *testing.T) {
+	l := ratelimit.NewMemoryLimiter()
+	ctx := context.Background()
+	limit := ratelimit.Limit{Requests: 2, Period: 200 * time.Millisecond}
+
+	for i := 0; i < 2; i++ {
+		res, err := l.Take(ctx, "k", limit, 1)
+		require.NoError(t, err)
+		assert.True(t, res.Allowed)
+		assert.Equal(t, 1-i, res.Remaining)
+	}
+	res, err := l.Take(ctx, "k", limit, 1)
+	require.NoError(t, err)
+	assert.False(t, res.Allowed)
+	assert.Positive(t, res.RetryAfter)
+	assert.LessOrEqual(t, res.RetryAfter, 100*time.Millisecond)
+
+	other, err := l.Take(ctx, "other", limit, 1)
+	require.NoError(t, err)
+	assert.True(t, other.Allowed, "keys have separate buckets")
+
+	// One token refills every 100ms.
+	time.Sleep(110 * time.Millisecond)
+	res, err = l.Take(ctx, "k", limit, 0)
+	require.NoError(t, err)
+	assert.True(t, res.Allowed, "a peek reports the refilled token")
+	res, err = l.Take(ctx, "k", limit, 1)
+	require.NoError(t, err)
+	assert.True(t, res.Allowed)
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	logger := internal.NewZapLogger(zap.NewNop().Sugar())
+	l := ratelimit.NewMemoryLimiter()
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		if id := c.GetHeader("X-Test-User"); id != "" {
+			c.Set("user", &internal.User{ID: id})
+		}
+	})
+	r.GET("/limited", ratelimit.Middleware(l, "test", ratelimit.Limit{Requests: 2, Period: time.Minute}, logger), func(c *gin.Context) {
+		c.Status(200)
+	})
+	get := func(user, ip string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/limited", nil)
+		req.Header.Set("X-Test-User", user)
+		req.RemoteAddr = ip + ":1234"
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := get("alice", "10.0.0.1")
+	require.Equal(t, 200, w.Code)
+	assert.Equal(t, "2", w.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "1", w.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "2;w=60", w.Header().Get("RateLimit-Policy"))
+	assert.Equal(t, 200, get("alice", "10.0.0.2").Code, "users are limited across IPs")
+	w = get("alice", "10.0.0.3")
+	require.Equal(t, 429, w.Code)
+	assert.Equal(t, "30", w.Header().Get("Retry-After"))
+	assert.Contains(t, w.Body.String(), `"code":429`)
+
+	assert.Equal(t, 200, get("bob", "10.0.0.1").Code)
+	// Anonymous requests are limited per IP.
+	assert.Equal(t, 200, get("", "10.0.0.9").Code)
+	assert.Equal(t, 200, get("", "10.0.0.9").Code)
+	assert.Equal(t, 429, get("", "10.0.0.9").Code)
+	assert.Equal(t, 200, get("", "10.0.0.10").Code)
+}
+
+func TestAuthFailureRateLimit(t *testing.T) {
+	logger := internal.NewZapLogger(zap.NewNop().Sugar())
+	l := ratelimit.NewMemoryLimiter()
+	r := gin.New()
+	r.Use(ratelimit.FailureMiddleware(l, "auth-failures", ratelimit.Limit{Requests: 2, Period: time.Minute}, logger))
+	r.GET("/", func(c *gin.Context) {
+		if c.GetHeader("Authorization") != "Bearer good" {
+			c.AbortWithStatus(401)
+			return
+		}
+		c.Status(200)
+	})
+	get := func(token string) int {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.RemoteAddr = "10.0.0.1:1234"
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	for i := 0; i < 5; i++ {
+		assert.Equal(t, 200, get("good"), "successful requests are not counted")
+	}
+	assert.Equal(t, 401, get("guess1"))
+	assert.Equal(t, 401, get("guess2"))
+	assert.Equal(t, 429, get("guess3"))
+	assert.Equal(t, 429, get("good"), "the IP is blocked until the bucket refills")
+}
@@ -0,0 +1,122 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yourname/sleeptracker/internal"
+	"github.com/yourname/sleeptracker/internal/auth"
+	"go.uber.org/zap"
+)
+
+func TestRemoteAuthProvider_CachesAndDeduplicates(t *testing.T) {
+	var calls atomic.Int32
+	release := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		var req struct{ Token string }
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		<-release
+		if req.Token == `tok"en` {
+			json.NewEncoder(w).Encode(&internal.User{ID: "u2", Name: "Remote User"})
+			return
+		}
+		w.WriteHeader(401)
+	}))
+	defer ts.Close()
+	logger := internal.NewZapLogger(zap.NewNop().Sugar())
+	p := auth.NewRemoteAuthProvider(auth.RemoteConfig{URL: ts.URL}, logger)
+	ctx := context.Background()
+
+	// Concurrent lookups of one token share a single call, and a token with
+	// a quote in it is encoded properly.
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			user, err := p.Authenticate(ctx, auth.Credentials{Token: `tok"en`})
+			if assert.NoError(t, err) {
+				assert.Equal(t, "u2", user.ID)
+				assert.Equal(t, `tok"en`, user.Token)
+			}
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	assert.Equal(t, int32(1), calls.Load())
+
+	// Valid and rejected tokens are both cached.
+	_, err := p.Authenticate(ctx, auth.Credentials{Token: `tok"en`})
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		_, err = p.Authenticate(ctx, auth.Credentials{Token: "wrong"})
+		assert.Error(t, err)
+	}
+	assert.Equal(t, int32(2), calls.Load())
+	stats := p.Stats()
+	assert.Equal(t, uint64(1), stats.Rejections)
+	assert.Equal(t, uint64(2), stats.RejectedCache.Hits)
+	assert.Equal(t, "closed", stats.BreakerState)
+}
+
+func TestRemoteAuthProvider_RetriesAndCircuitBreaker(t *testing.T) {
+	var calls atomic.Int32
+	var healthy atomic.Bool
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := calls.Add(1)
+		// The first call of the test fails once, then recovers.
+		if !healthy.Load() || n == 1 {
+			w.WriteHeader(503)
+			return
+		}
+		json.NewEncoder(w).Encode(&internal.User{ID: "u2"})
+	}))
+	defer ts.Close()
+	logger := internal.NewZapLogger(zap.NewNop().Sugar())
+	p := auth.NewRemoteAuthProvider(auth.RemoteConfig{
+		URL: ts.URL, Retries: 1, RetryBackoff: time.Millisecond,
+		BreakerThreshold: 2, BreakerCooldown: 100 * time.Millisecond,
+	}, logger)
+	ctx := context.Background()
+
+	healthy.Store(true)
+	_, err := p.Authenticate(ctx, auth.Credentials{Token: "a"})
+	require.NoError(t, err, "a failed call is retried")
+	assert.Equal(t, int32(2), calls.Load())
+
+	// Two lookups that fail even after retrying open the breaker...
+	healthy.Store(false)
+	for _, token := range []string{"b", "c"} {
+		_, err = p.Authenticate(ctx, auth.Credentials{Token: token})
+		assert.ErrorIs(t, err, auth.ErrAuthUnavailable)
+	}
+	assert.Equal(t, int32(6), calls.Load())
+	assert.Equal(t, "open", p.Stats().BreakerState)
+
+	// ...after which lookups fail fast without calling the service.
+	_, err = p.Authenticate(ctx, auth.Credentials{Token: "d"})
+	assert.ErrorIs(t, err, auth.ErrAuthUnavailable)
+	assert.Equal(t, int32(6), calls.Load())
+
+	// Once the cooldown passes, a successful probe closes it again.
+	healthy.Store(true)
+	time.Sleep(150 * time.Millisecond)
+	_, err = p.Authenticate(ctx, auth.Credentials{Token: "d"})
+	require.NoError(t, err)
+	stats := p.Stats()
+	assert.Equal(t, "closed", stats.BreakerState)
+	assert.Equal(t, uint64(1), stats.BreakerTrips)
+	assert.Equal(t, uint64(1), stats.BreakerRejections)
+	assert.Equal(t, uint64(7), stats.Calls)
+	assert.Positive(t, stats.LatencyAvgMillis)
+}
@@ -0,0 +1,58 @@
+package test
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	api "github.com/yourname/sleeptracker/internal/api"
+)
+
+func TestLegacyRoutesAreDeprecatedAliases(t *testing.T) {
+	t.Parallel()
+	r, _ := setupRouterAndStorage(t)
+	deprecation := "@" + strconv.FormatInt(api.LegacyDeprecatedAt.Unix(), 10)
+	sunset := api.LegacySunset.Format(http.TimeFormat)
+
+	w := doJSON(r, "GET", "/api/v1/sleep-logs", "MOCK-TOKEN", "")
+	require.Equal(t, 200, w.Code)
+	assert.Empty(t, w.Header().Get("Deprecation"))
+	assert.Empty(t, w.Header().Get("Sunset"))
+
+	legacy := []struct {
+		method, path, body string
+		status             int
+		successor          string
+	}{
+		{"GET", "/sleep", "", 200, "/api/v1/sleep-logs"},
+		{"GET", "/sleep/stats", "", 200, "/api/v1/sleep-stats"},
+		{"GET", "/sleep/missing", "", 404, "/api/v1/sleep-logs/missing"},
+		{"POST", "/api/goals", `{"type":"duration","value":"7h"}`, 201, "/api/v1/goals"},
+		{"GET", "/api/goals/progress", "", 200, "/api/v1/goals/progress"},
+		{"POST", "/auth/login", `{"email":"nobody@example.com","password":"wrong"}`, 401, "/api/v1/auth/login"},
+	}
+	for _, tt := range legacy {
+		w := doJSON(r, tt.method, tt.path, "MOCK-TOKEN", tt.body)
+		assert.Equal(t, tt.status, w.Code, tt.path)
+		assert.Equal(t, deprecation, w.Header().Get("Deprecation"), tt.path)
+		assert.Equal(t, sunset, w.Header().Get("Sunset"), tt.path)
+		assert.Equal(t, "<"+tt.successor+`>; rel="successor-version"`, w.Header().Get("Link"), tt.path)
+	}
+
+	// Endpoints added alongside /api/v1 have no unversioned alias.
+	for _, path := range []string{"/audit", "/api/keys", "/shares", "/users/u1/sleep/stats", "/admin/users", "/debug/vars"} {
+		assert.Equal(t, 404, doJSON(r, "GET", path, "MOCK-TOKEN", "").Code, path)
+	}
+
+	// Responses that never reach the handler are marked too.
+	w = doJSON(r, "GET", "/sleep", "", "")
+	assert.Equal(t, 401, w.Code)
+	assert.Equal(t, deprecation, w.Header().Get("Deprecation"))
+
+	// Created resources point at their /api/v1 location.
+	w = doJSON(r, "POST", "/sleep", "MOCK-TOKEN", `{"start_time":"2025-07-16T22:00:00Z","end_time":"2025-07-17T06:00:00Z","quality":8}`)
+	require.Equal(t, 201, w.Code, w.Body.String())
+	assert.Equal(t, 200, doJSON(r, "GET", w.Header().Get("Location"), "MOCK-TOKEN", "").Code)
+}
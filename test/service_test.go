@@ -6,25 +6,24 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/yourname/sleeptracker/internal"
 	"github.com/yourname/sleeptracker/internal/auth"
+	"github.com/yourname/sleeptracker/internal/service"
 	"github.com/yourname/sleeptracker/internal/storage"
+	"github.com/yourname/sleeptracker/internal/storage/storagetest"
 	"go.uber.org/zap"
 )
 
 func setupFileStorage(t *testing.T) storage.SleepLogRepository {
-	testDir := "testdata"
-	if _, err := os.Stat(testDir); os.IsNotExist(err) {
-		_ = os.MkdirAll(testDir, 0755)
-	}
-	sleepFile := testDir + "/test_sleep_logs.json"
-	goalsFile := testDir + "/test_goals.json"
-	os.Remove(sleepFile)
-	os.Remove(goalsFile)
+	testDir := t.TempDir()
+	sleepFile := filepath.Join(testDir, "test_sleep_logs.json")
+	goalsFile := filepath.Join(testDir, "test_goals.json")
 	repo, _, err := storage.NewFileRepositories(sleepFile, goalsFile, internal.NewZapLogger(zap.NewNop().Sugar()))
 	assert.NoError(t, err)
 	return repo
@@ -62,13 +61,92 @@ func TestPostgresStorageReady(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestFileStorageConformance(t *testing.T) {
+	logger := internal.NewZapLogger(zap.NewNop().Sugar())
+	storagetest.Run(t, func(t *testing.T) storagetest.Backend {
+		dir := t.TempDir()
+		return storagetest.Backend{
+			Persistent: true,
+			Open: func(t *testing.T) storagetest.Repositories {
+				s, err := storage.NewFileStorage(filepath.Join(dir, "sleep_logs.json"), filepath.Join(dir, "goals.json"), logger)
+				if err != nil {
+					t.Fatalf("open file storage: %v", err)
+				}
+				return storagetest.Repositories{Sleep: s, Goals: s, Aggregates: s, Users: s, Sessions: s, APIKeys: s, Sharing: s, Audit: s, Close: s.Close}
+			},
+		}
+	})
+}
+
+func TestMemoryStorageConformance(t *testing.T) {
+	logger := internal.NewZapLogger(zap.NewNop().Sugar())
+	storagetest.Run(t, func(t *testing.T) storagetest.Backend {
+		s := storage.NewMemoryStorage(logger)
+		return storagetest.Backend{
+			Open: func(t *testing.T) storagetest.Repositories {
+				return storagetest.Repositories{Sleep: s, Goals: s, Aggregates: s, Users: s, Sessions: s, APIKeys: s, Sharing: s, Audit: s}
+			},
+		}
+	})
+}
+
+func TestMemoryStorageFixture(t *testing.T) {
+	fixture := filepath.Join(t.TempDir(), "fixture.json")
+	data := `{
+		"sleep_logs": [
+			{"id": "l1", "user_id": "u1", "start_time": "2025-07-15T22:00:00Z", "end_time": "2025-07-16T06:00:00Z", "quality": 6},
+			{"id": "l2", "user_id": "u1", "start_time": "2025-07-16T22:00:00Z", "end_time": "2025-07-17T06:00:00Z", "quality": 8}
+		],
+		"goals": [
+			{"id": "g1", "user_id": "u1", "type": "duration", "value": "7h", "created_at": "2025-07-16T10:00:00Z"}
+		]
+	}`
+	assert.NoError(t, os.WriteFile(fixture, []byte(data), 0644))
+
+	logger := internal.NewZapLogger(zap.NewNop().Sugar())
+	s, err := storage.NewMemoryStorageFromFixture(fixture, logger)
+	assert.NoError(t, err)
+	ctx := context.Background()
+	logs, err := s.ListSleepLogs(ctx, "u1")
+	assert.NoError(t, err)
+	assert.Len(t, logs, 2)
+	assert.Equal(t, "l2", logs[0].ID)
+	goal, err := s.GetGoal(ctx, "u1")
+	assert.NoError(t, err)
+	assert.Equal(t, "7h", goal.Value)
+
+	_, err = storage.NewMemoryStorageFromFixture(filepath.Join(t.TempDir(), "missing.json"), logger)
+	assert.Error(t, err)
+}
+
+func TestPostgresStorageConformance(t *testing.T) {
+	dsn := os.Getenv("POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("POSTGRES_DSN not set, skipping Postgres conformance suite")
+	}
+	logger := internal.NewZapLogger(zap.NewNop().Sugar())
+	storagetest.Run(t, func(t *testing.T) storagetest.Backend {
+		return storagetest.Backend{
+			Persistent: true,
+			Open: func(t *testing.T) storagetest.Repositories {
+				s, err := storage.NewPostgresStorage(dsn, logger)
+				if err != nil {
+					t.Fatalf("open postgres storage: %v", err)
+				}
+				return storagetest.Repositories{Sleep: s, Goals: s, Aggregates: s, Users: s, Sessions: s, APIKeys: s, Sharing: s, Audit: s, Close: s.Close}
+			},
+		}
+	})
+}
+
 func TestLocalAuthProvider(t *testing.T) {
 	logger := internal.NewZapLogger(zap.NewNop().Sugar())
 	provider := auth.NewLocalAuthProvider("MOCK-TOKEN", logger)
-	user, err := provider.ValidateTokenLocal("MOCK-TOKEN")
+	user, err := provider.Authenticate(context.Background(), auth.Credentials{Token: "MOCK-TOKEN"})
 	assert.NoError(t, err)
 	assert.Equal(t, "u1", user.ID)
-	_, err = provider.ValidateTokenLocal("WRONG-TOKEN")
+	assert.Equal(t, internal.RoleUser, user.Role, "the demo user is not an admin")
+	_, err = provider.Authenticate(context.Background(), auth.Credentials{Token: "WRONG-TOKEN"})
 	assert.Error(t, err)
 }
 
@@ -85,11 +163,116 @@ func TestRemoteAuthProviderReady(t *testing.T) {
 	}))
 	defer ts.Close()
 	logger := internal.NewZapLogger(zap.NewNop().Sugar())
-	provider := auth.NewRemoteAuthProvider(ts.URL, logger)
+	provider := auth.NewRemoteAuthProvider(auth.RemoteConfig{URL: ts.URL}, logger)
 	ctx := context.Background()
-	user, err := provider.ValidateTokenRemote(ctx, "MOCK-TOKEN")
+	user, err := provider.Authenticate(ctx, auth.Credentials{Token: "MOCK-TOKEN"})
 	assert.NoError(t, err)
 	assert.Equal(t, "u2", user.ID)
-	_, err = provider.ValidateTokenRemote(ctx, "WRONG-TOKEN")
+	_, err = provider.Authenticate(ctx, auth.Credentials{Token: "WRONG-TOKEN"})
 	assert.Error(t, err)
 }
+
+func TestChainProvider(t *testing.T) {
+	logger := internal.NewZapLogger(zap.NewNop().Sugar())
+	mem := storage.NewMemoryStorage(logger)
+	ctx := context.Background()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(503)
+	}))
+	defer ts.Close()
+	down := auth.NewRemoteAuthProvider(auth.RemoteConfig{URL: ts.URL, Retries: -1}, logger)
+
+	chain := auth.NewChainProvider(
+		auth.NewAPIKeyAuthProvider(mem, mem, logger),
+		auth.NewLocalAuthProvider("MOCK-TOKEN", logger),
+	)
+	user, err := chain.Authenticate(ctx, auth.Credentials{Token: "MOCK-TOKEN"})
+	require.NoError(t, err, "later providers are tried when earlier ones do not recognize the token")
+	assert.Equal(t, "u1", user.ID)
+	_, err = chain.Authenticate(ctx, auth.Credentials{Token: "slk_unknown"})
+	assert.ErrorIs(t, err, auth.ErrUnrecognized)
+
+	// A provider that fails for another reason is reported over the ones
+	// that merely did not recognize the token.
+	chain = auth.NewChainProvider(auth.NewLocalAuthProvider("MOCK-TOKEN", logger), down)
+	_, err = chain.Authenticate(ctx, auth.Credentials{Token: "other"})
+	assert.ErrorIs(t, err, auth.ErrAuthUnavailable)
+	user, err = chain.Authenticate(ctx, auth.Credentials{Token: "MOCK-TOKEN"})
+	require.NoError(t, err)
+	assert.Equal(t, "u1", user.ID)
+}
+
+// Goal progress and the stats trend are read from daily aggregates, so they
+// have one entry per day rather than one per log.
+func TestGoalProgressAndTrendAreDaily(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	s := storage.NewMemoryStorage(internal.NewZapLogger(zap.NewNop().Sugar()))
+	berlin := time.FixedZone("CEST", 2*3600)
+	yesterday := time.Now().In(berlin).AddDate(0, 0, -1)
+	at := func(hour, minute int) time.Time {
+		return time.Date(yesterday.Year(), yesterday.Month(), yesterday.Day(), hour, minute, 0, 0, berlin)
+	}
+	// 21:30 and 22:15 in Berlin are 19:30 and 20:15 UTC, the same UTC day.
+	for _, l := range []internal.SleepLog{
+		{ID: "early", UserID: "u1", StartTime: at(21, 30), EndTime: at(21, 30).Add(4 * time.Hour), Quality: 6},
+		{ID: "late", UserID: "u1", StartTime: at(22, 15), EndTime: at(22, 15).Add(4 * time.Hour), Quality: 9},
+	} {
+		require.NoError(t, s.SaveSleepLog(ctx, &l))
+	}
+	days, err := service.GetSleepDays(ctx, s, "u1")
+	require.NoError(t, err)
+	require.Len(t, days, 1)
+	assert.Equal(t, 2*3600, days[0].BedtimeOffset)
+
+	avg, trend := service.CalculateSleepStats(days)
+	assert.Equal(t, 7.5, avg)
+	assert.Equal(t, []int{8}, trend, "one entry per day, its average rounded")
+
+	progress := func(goalType, value string) service.GoalProgress {
+		return service.CalculateGoalProgress(&internal.Goal{Type: goalType, Value: value}, days)
+	}
+	// The bedtime is the day's earliest, on the clock of its log: 21:30 is
+	// not before 21 even though it is 19:30 UTC.
+	assert.Equal(t, 0, progress("consistency", "before 21").MetDays)
+	assert.Equal(t, 1, progress("consistency", "before 22").MetDays)
+	assert.Equal(t, 1, progress("duration", "8h").MetDays, "both logs count toward the day's sleep")
+	assert.Equal(t, 1, progress("quality", "> 7").TotalDays)
+	assert.Equal(t, 1, progress("quality", "> 7").MetDays)
+}
+
+// staleSleepRepo lists no logs, as if another import saved its logs after
+// this one read them.
+type staleSleepRepo struct {
+	storage.SleepLogRepository
+}
+
+func (staleSleepRepo) ListSleepLogs(context.Context, string) ([]internal.SleepLog, error) {
+	return nil, nil
+}
+
+// An import racing another of the same export fails with a conflict that
+// does not carry the storage error.
+func TestImportSourceRace(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	s := storage.NewMemoryStorage(internal.NewZapLogger(zap.NewNop().Sugar()))
+	user := &internal.User{ID: "u1"}
+	start := time.Now().UTC().Truncate(time.Second).AddDate(0, 0, -2)
+	rows := []service.ImportRow{{
+		Row:     1,
+		Request: service.SleepLogRequest{StartTime: start, EndTime: start.Add(8 * time.Hour), Quality: 7},
+		Source:  &internal.SleepLogSource{Name: "fitbit", ID: "1"},
+	}}
+
+	result, err := service.ImportSleepLogs(ctx, s, user, rows, &service.ImportOptions{})
+	require.NoError(t, err)
+	require.Equal(t, 1, result.Imported)
+
+	_, err = service.ImportSleepLogs(ctx, staleSleepRepo{s}, user, rows, &service.ImportOptions{})
+	require.ErrorIs(t, err, service.ErrImportConflict)
+	assert.NotContains(t, err.Error(), "storage")
+	logs, err := s.ListSleepLogs(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Len(t, logs, 1)
+}
@@ -0,0 +1,159 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yourname/sleeptracker/internal"
+)
+
+func TestSharingGrantLifecycle(t *testing.T) {
+	t.Parallel()
+	r, app := setupRouterAndStorage(t)
+	ctx := context.Background()
+	patient := registerAndLogin(t, r, "patient@example.com")
+	clinician := registerAndLogin(t, r, "clinician@example.com")
+	stranger := registerAndLogin(t, r, "stranger@example.com")
+	owner, err := app.UserRepo().GetUserByEmail(ctx, "patient@example.com")
+	require.NoError(t, err)
+	w := doJSON(r, "POST", "/api/v1/sleep-logs", patient.AccessToken, `{"start_time":"2025-07-16T22:00:00Z","end_time":"2025-07-17T06:00:00Z","quality":8}`)
+	require.Less(t, w.Code, 300, w.Body.String())
+
+	sharedSleep := "/api/v1/users/" + owner.ID + "/sleep-logs"
+	assert.Equal(t, 403, doJSON(r, "GET", sharedSleep, clinician.AccessToken, "").Code, "no grant yet")
+
+	w = doJSON(r, "POST", "/api/v1/shares", patient.AccessToken, `{"email":"Clinician@example.com","expires_at":"`+time.Now().Add(time.Hour).Format(time.RFC3339)+`"}`)
+	require.Equal(t, 201, w.Code, w.Body.String())
+	var created struct {
+		Data struct {
+			ID     string `json:"id"`
+			Status string `json:"status"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	assert.Equal(t, internal.GrantPending, created.Data.Status)
+	assert.Equal(t, 409, doJSON(r, "POST", "/api/v1/shares", patient.AccessToken, `{"email":"clinician@example.com"}`).Code)
+	assert.Equal(t, 400, doJSON(r, "POST", "/api/v1/shares", patient.AccessToken, `{"email":"Patient@example.com"}`).Code)
+	assert.Equal(t, 400, doJSON(r, "POST", "/api/v1/shares", patient.AccessToken, `{"email":"patient@example.com"}`).Code)
+	assert.Equal(t, 400, doJSON(r, "POST", "/api/v1/shares", patient.AccessToken, `{"email":"clinician@example.com","expires_at":"2020-01-01T00:00:00Z"}`).Code)
+
+	// A pending grant gives no access until the grantee accepts it, and only
+	// the grantee can accept it.
+	assert.Equal(t, 403, doJSON(r, "GET", sharedSleep, clinician.AccessToken, "").Code)
+	assert.Equal(t, 404, doJSON(r, "POST", "/api/v1/shares/"+created.Data.ID+"/accept", stranger.AccessToken, "").Code)
+	w = doJSON(r, "GET", "/api/v1/shares/incoming", clinician.AccessToken, "")
+	require.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), created.Data.ID)
+	w = doJSON(r, "POST", "/api/v1/shares/"+created.Data.ID+"/accept", clinician.AccessToken, "")
+	require.Equal(t, 200, w.Code, w.Body.String())
+
+	w = doJSON(r, "GET", sharedSleep, clinician.AccessToken, "")
+	require.Equal(t, 200, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), owner.ID)
+	assert.Equal(t, 200, doJSON(r, "GET", "/api/v1/users/"+owner.ID+"/sleep-stats", clinician.AccessToken, "").Code)
+	assert.Equal(t, 403, doJSON(r, "GET", sharedSleep, stranger.AccessToken, "").Code)
+	// Grants are read-only: the grantee's own routes still act on their own data.
+	w = doJSON(r, "GET", "/api/v1/sleep-logs", clinician.AccessToken, "")
+	assert.NotContains(t, w.Body.String(), owner.ID)
+
+	// The owner sees every access made under the grant.
+	w = doJSON(r, "GET", "/api/v1/shares/access-log", patient.AccessToken, "")
+	require.Equal(t, 200, w.Code)
+	var log struct {
+		Data []internal.GrantAccess `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &log))
+	require.Len(t, log.Data, 2)
+	assert.Equal(t, "/api/v1/users/"+owner.ID+"/sleep-stats", log.Data[0].Path)
+	assert.Equal(t, created.Data.ID, log.Data[0].GrantID)
+
+	// Exports made by a grantee show in the owner's audit log.
+	assert.Equal(t, 200, getCSV(r, sharedSleep, clinician.AccessToken, "text/csv").Code)
+	clinicianAccount, err := app.UserRepo().GetUserByEmail(ctx, "clinician@example.com")
+	require.NoError(t, err)
+	events := auditEvents(t, r, "/api/v1/audit-events?action=sleep.export", patient.AccessToken)
+	require.Len(t, events, 1)
+	assert.Equal(t, clinicianAccount.ID, events[0].ActorID)
+	assert.Equal(t, owner.ID, events[0].TargetUserID)
+
+	w = doJSON(r, "DELETE", "/api/v1/shares/"+created.Data.ID, patient.AccessToken, "")
+	require.Equal(t, 204, w.Code)
+	assert.Equal(t, 403, doJSON(r, "GET", sharedSleep, clinician.AccessToken, "").Code, "revoked grants give no access")
+	w = doJSON(r, "GET", "/api/v1/shares", patient.AccessToken, "")
+	assert.Contains(t, w.Body.String(), `"status":"revoked"`)
+}
+
+func TestSharingDoesNotRevealAccounts(t *testing.T) {
+	t.Parallel()
+	r, _ := setupRouterAndStorage(t)
+	owner := registerAndLogin(t, r, "owner@example.com")
+	registerAndLogin(t, r, "member@example.com")
+
+	share := func(email string) map[string]any {
+		t.Helper()
+		w := doJSON(r, "POST", "/api/v1/shares", owner.AccessToken, `{"email":"`+email+`"}`)
+		require.Equal(t, 201, w.Code, w.Body.String())
+		var created struct {
+			Data map[string]any `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+		delete(created.Data, "id")
+		delete(created.Data, "created_at")
+		delete(created.Data, "grantee_email")
+		return created.Data
+	}
+	assert.Equal(t, share("member@example.com"), share("nobody@example.com"), "an account and a stranger get the same answer")
+	assert.NotContains(t, share("third@example.com"), "grantee_id")
+
+	// The account that had the email when invited can accept.
+	member := login(t, r, "member@example.com")
+	incoming := func(token string) []string {
+		t.Helper()
+		w := doJSON(r, "GET", "/api/v1/shares/incoming", token, "")
+		require.Equal(t, 200, w.Code)
+		var list struct {
+			Data []struct {
+				ID string `json:"id"`
+			} `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+		var ids []string
+		for _, g := range list.Data {
+			ids = append(ids, g.ID)
+		}
+		return ids
+	}
+	ids := incoming(member.AccessToken)
+	require.Len(t, ids, 1)
+	w := doJSON(r, "POST", "/api/v1/shares/"+ids[0]+"/accept", member.AccessToken, "")
+	require.Equal(t, 200, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"status":"active"`)
+	assert.Contains(t, w.Body.String(), `"grantee_id"`)
+
+	// Emails are not verified, so whoever registers an invited address
+	// afterwards can neither see nor accept the invitation.
+	w = doJSON(r, "GET", "/api/v1/shares", owner.AccessToken, "")
+	require.Equal(t, 200, w.Code)
+	var given struct {
+		Data []struct {
+			ID           string `json:"id"`
+			GranteeEmail string `json:"grantee_email"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &given))
+	var invitation string
+	for _, g := range given.Data {
+		if g.GranteeEmail == "nobody@example.com" {
+			invitation = g.ID
+		}
+	}
+	require.NotEmpty(t, invitation)
+	squatter := registerAndLogin(t, r, "Nobody@Example.com")
+	assert.Empty(t, incoming(squatter.AccessToken))
+	assert.Equal(t, 404, doJSON(r, "POST", "/api/v1/shares/"+invitation+"/accept", squatter.AccessToken, "").Code)
+	assert.Equal(t, 404, doJSON(r, "GET", "/api/v1/shares/"+invitation, squatter.AccessToken, "").Code)
+}
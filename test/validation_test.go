@@ -0,0 +1,82 @@
+package test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yourname/sleeptracker/internal"
+	"github.com/yourname/sleeptracker/internal/service"
+)
+
+func fieldCodes(t *testing.T, err error) map[string][]string {
+	t.Helper()
+	var verr *internal.ValidationError
+	require.True(t, errors.As(err, &verr), "want a ValidationError, got %v", err)
+	assert.ErrorIs(t, err, internal.ErrValidation)
+	codes := map[string][]string{}
+	for _, f := range verr.Fields {
+		assert.NotEmpty(t, f.Message)
+		codes[f.Field] = append(codes[f.Field], f.Code)
+	}
+	return codes
+}
+
+func TestValidateSleepLogRequest(t *testing.T) {
+	t.Parallel()
+	start := time.Now().Add(-8 * time.Hour)
+	valid := func() *service.SleepLogRequest {
+		return &service.SleepLogRequest{StartTime: start, EndTime: start.Add(7 * time.Hour), Quality: 7, Interruptions: []string{"noise"}}
+	}
+	require.NoError(t, service.ValidateSleepLogRequest(valid()))
+
+	tests := []struct {
+		name   string
+		modify func(*service.SleepLogRequest)
+		want   map[string][]string
+	}{
+		{"missing fields", func(r *service.SleepLogRequest) { *r = service.SleepLogRequest{} },
+			map[string][]string{"start_time": {"required"}, "end_time": {"required"}, "quality": {"required"}}},
+		{"end before start", func(r *service.SleepLogRequest) { r.EndTime = start.Add(-time.Hour) },
+			map[string][]string{"end_time": {"after"}}},
+		{"too long", func(r *service.SleepLogRequest) { r.StartTime = start.Add(-20 * time.Hour) },
+			map[string][]string{"end_time": {"max_duration"}}},
+		{"ends in the future", func(r *service.SleepLogRequest) { r.EndTime = time.Now().Add(2 * time.Hour) },
+			map[string][]string{"end_time": {"not_future"}}},
+		{"quality out of range", func(r *service.SleepLogRequest) { r.Quality = 11 },
+			map[string][]string{"quality": {"max"}}},
+		{"too many interruptions", func(r *service.SleepLogRequest) { r.Interruptions = make([]string, 21) },
+			map[string][]string{"interruptions": {"max"}}},
+		{"blank interruption", func(r *service.SleepLogRequest) { r.Interruptions = []string{"noise", ""} },
+			map[string][]string{"interruptions[1]": {"required"}}},
+		{"long interruption", func(r *service.SleepLogRequest) { r.Interruptions = []string{strings.Repeat("x", 101)} },
+			map[string][]string{"interruptions[0]": {"max"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := valid()
+			tt.modify(req)
+			assert.Equal(t, tt.want, fieldCodes(t, service.ValidateSleepLogRequest(req)))
+		})
+	}
+}
+
+func TestValidationErrorsNameJSONFields(t *testing.T) {
+	t.Parallel()
+	err := service.ValidateRegisterRequest(&service.RegisterRequest{Email: "not an email", Password: "short"})
+	assert.Equal(t, map[string][]string{"email": {"email"}, "password": {"min"}}, fieldCodes(t, err))
+
+	err = service.ValidateGoalRequest(&service.GoalRequest{Type: "banana", Value: "7h"})
+	var verr *internal.ValidationError
+	require.True(t, errors.As(err, &verr))
+	assert.Equal(t, []internal.FieldError{{
+		Field:   "type",
+		Code:    "one_of",
+		Message: "must be one of: duration, consistency, quality",
+		Params:  map[string]string{"values": "duration,consistency,quality"},
+	}}, verr.Fields)
+	assert.Equal(t, "type: must be one of: duration, consistency, quality", err.Error())
+}